@@ -0,0 +1,41 @@
+package main
+
+import (
+	"expvar"
+	"log/slog"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/cache"
+	"github.com/LuoZihYuan/Go-Cart/internal/config"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+)
+
+// withProductCache puts the configured cache in front of the product repository
+// and publishes its counters as the expvar "product_cache"
+func withProductCache(cfg config.CacheConfig, repo repository.ProductRepository) repository.ProductRepository {
+	var c cache.Cache
+	switch cfg.Backend {
+	case "memory":
+		c = cache.NewLRU(cfg.Size)
+		slog.Info("caching products in memory", "size", cfg.Size, "ttl", cfg.TTL)
+	case "redis":
+		redis := cache.NewRedis(cache.RedisOptions{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+			Timeout:  cfg.Redis.Timeout,
+			PoolSize: cfg.Redis.PoolSize,
+		})
+		// The cache is optional at runtime, so an unreachable server is only reported
+		if err := redis.Ping(); err != nil {
+			slog.Warn("redis is not reachable; products will be read from the database until it is", "addr", cfg.Redis.Addr, "error", err)
+		}
+		c = redis
+		slog.Info("caching products in redis", "addr", cfg.Redis.Addr, "ttl", cfg.TTL)
+	default: // none
+		return repo
+	}
+
+	cached := repository.NewCachedProductRepository(repo, c, cfg.TTL)
+	expvar.Publish("product_cache", expvar.Func(func() any { return cached.Stats() }))
+	return cached
+}
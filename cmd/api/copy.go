@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/config"
+	"github.com/LuoZihYuan/Go-Cart/internal/datacopy"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+)
+
+const copyUsage = `usage: api copy --to <db_type> [flags]
+
+Copies every product, cart and promotion, with customers' uses of promotions,
+from the backend selected by DB_TYPE to the one named by --to, keeping their IDs. Both backends are configured by the usual
+settings, so they must be of different types. Stop writes to the source first,
+or copy again and verify once they have stopped.
+
+flags:
+`
+
+// copyFlags are the options of the "copy" subcommand
+type copyFlags struct {
+	to          string
+	batchSize   int
+	concurrency int
+	checkpoint  string
+	dryRun      bool
+	verify      bool
+	verifyOnly  bool
+}
+
+// runCopy implements the "copy" subcommand
+func runCopy(args []string) {
+	var flags copyFlags
+	fs := flag.NewFlagSet("copy", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), copyUsage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&flags.to, "to", "", "destination backend (memory, mysql, postgres, sqlite, dynamo)")
+	fs.IntVar(&flags.batchSize, "batch-size", 100, "records listed, written and checkpointed at a time")
+	fs.IntVar(&flags.concurrency, "concurrency", 8, "writes in flight at once")
+	fs.StringVar(&flags.checkpoint, "checkpoint", "", "file to save progress to and resume from")
+	fs.BoolVar(&flags.dryRun, "dry-run", false, "read the source and report what would be copied, without writing")
+	fs.BoolVar(&flags.verify, "verify", false, "compare counts and checksums of both backends after copying")
+	fs.BoolVar(&flags.verifyOnly, "verify-only", false, "compare both backends without copying")
+
+	cfg, _, err := config.LoadFlagSet(fs, args, os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	initLogger(cfg.Log)
+
+	dstCfg, err := copyDestination(cfg, flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "copy: %v\n", err)
+		os.Exit(2)
+	}
+
+	// An interrupted copy stops after its current batch and can be resumed from the checkpoint
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	src := openCopyBackend(cfg)
+	defer src.Close()
+	dst := openCopyBackend(dstCfg)
+	defer dst.Close()
+
+	source := datacopy.Backend{Name: cfg.DBType, Products: src.products, Carts: src.carts, Promotions: src.promotions}
+	destination := datacopy.Backend{Name: dstCfg.DBType, Products: dst.products, Carts: dst.carts, Promotions: dst.promotions}
+
+	if !flags.verifyOnly {
+		report, err := datacopy.Copy(ctx, source, destination, datacopy.Options{
+			BatchSize:   flags.batchSize,
+			Concurrency: flags.concurrency,
+			Checkpoint:  flags.checkpoint,
+			DryRun:      flags.dryRun,
+		})
+		if report != nil {
+			printCopyReport(report, flags.dryRun)
+		}
+		if err != nil {
+			fatal("copy failed", err)
+		}
+	}
+
+	if flags.verify || flags.verifyOnly {
+		verification, err := datacopy.Verify(ctx, source, destination, flags.batchSize)
+		if err != nil {
+			fatal("verification failed", err)
+		}
+		printVerification(verification)
+		if !verification.Match() {
+			os.Exit(1)
+		}
+	}
+}
+
+// copyDestination returns the destination's configuration: the source's with DB_TYPE replaced
+func copyDestination(cfg *config.Config, flags copyFlags) (*config.Config, error) {
+	switch {
+	case flags.to == "":
+		return nil, errors.New("--to is required")
+	case flags.to == cfg.DBType:
+		return nil, fmt.Errorf("source and destination are both %s; choose a different --to or DB_TYPE", cfg.DBType)
+	case flags.batchSize < 1 || flags.concurrency < 1:
+		return nil, errors.New("--batch-size and --concurrency must be at least 1")
+	case flags.dryRun && flags.verifyOnly:
+		return nil, errors.New("--dry-run and --verify-only cannot be combined")
+	}
+
+	dst := *cfg
+	dst.DBType = flags.to
+	if err := dst.Validate(); err != nil {
+		return nil, err
+	}
+	for _, c := range []*config.Config{cfg, &dst} {
+		if c.DBType == "memory" && c.Memory.DataDir == "" {
+			return nil, errors.New("the memory backend needs MEMORY_DATA_DIR to be copied to or from")
+		}
+	}
+	return &dst, nil
+}
+
+// openCopyBackend opens a backend with retries around the database repositories
+func openCopyBackend(cfg *config.Config) *backend {
+	b := openBackend(cfg)
+	if cfg.DBType != "memory" {
+		r := newResilience(cfg.Resilience, cfg.DBType)
+		b.products = repository.NewResilientProductRepository(b.products, r)
+		b.carts = repository.NewResilientCartRepository(b.carts, r)
+		b.promotions = repository.NewResilientPromotionRepository(b.promotions, r)
+	}
+	return b
+}
+
+func printCopyReport(report *datacopy.Report, dryRun bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if dryRun {
+		fmt.Fprintln(w, "RECORDS\tTO COPY\tALREADY IN DESTINATION\tCHECKSUM")
+	} else {
+		fmt.Fprintln(w, "RECORDS\tCOPIED\tCHECKSUM")
+	}
+	for _, row := range []struct {
+		name   string
+		result datacopy.Result
+	}{
+		{"products", report.Products},
+		{"carts", report.Carts},
+		{"promotions", report.Promotions},
+		{"redemptions", report.Redemptions},
+	} {
+		r := row.result
+		if dryRun {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", row.name, r.Copied.Count, r.Existing, r.Copied.Checksum)
+		} else {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", row.name, r.Copied.Count, r.Copied.Checksum)
+		}
+	}
+	w.Flush()
+}
+
+func printVerification(v *datacopy.Verification) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RECORDS\tSOURCE\tDESTINATION\tSOURCE CHECKSUM\tDESTINATION CHECKSUM\tMATCH")
+	for _, row := range []struct {
+		name       string
+		comparison datacopy.Comparison
+	}{
+		{"products", v.Products},
+		{"carts", v.Carts},
+		{"promotions", v.Promotions},
+		{"redemptions", v.Redemptions},
+	} {
+		c := row.comparison
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%v\n", row.name, c.Source.Count, c.Destination.Count,
+			c.Source.Checksum, c.Destination.Checksum, c.Match())
+	}
+	w.Flush()
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/config"
+	"github.com/LuoZihYuan/Go-Cart/internal/migrations"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// backend holds the repositories of the storage backend selected by DB_TYPE
+type backend struct {
+	products   repository.ProductRepository
+	carts      repository.CartRepository
+	promotions repository.PromotionRepository
+	closers    []func() error
+}
+
+// Close releases the backend's connections and flushes persistent memory repositories
+func (b *backend) Close() {
+	for _, closeFn := range slices.Backward(b.closers) {
+		if err := closeFn(); err != nil {
+			slog.Error("failed to close backend", "error", err)
+		}
+	}
+}
+
+// openBackend connects to the backend selected by DB_TYPE, migrating SQL schemas
+// and checking DynamoDB tables as configured, and exits if it cannot
+func openBackend(cfg *config.Config) *backend {
+	b := &backend{}
+
+	switch cfg.DBType {
+	case "mysql":
+		db, migrator := openSQLDatabase(cfg)
+		b.closers = append(b.closers, db.Close)
+		if cfg.AutoMigrate {
+			migrateUp(migrator)
+		}
+		b.products = repository.NewProductMySQLRepository(db)
+		b.carts = repository.NewCartMySQLRepository(db)
+		b.promotions = repository.NewPromotionMySQLRepository(db)
+		slog.Info("using MySQL repositories")
+
+	case "postgres":
+		db, migrator := openSQLDatabase(cfg)
+		b.closers = append(b.closers, db.Close)
+		if cfg.AutoMigrate {
+			migrateUp(migrator)
+		}
+		b.products = repository.NewProductPostgresRepository(db)
+		b.carts = repository.NewCartPostgresRepository(db)
+		b.promotions = repository.NewPromotionPostgresRepository(db)
+		slog.Info("using PostgreSQL repositories")
+
+	case "sqlite":
+		db, migrator := openSQLDatabase(cfg)
+		b.closers = append(b.closers, db.Close)
+		if cfg.AutoMigrate {
+			migrateUp(migrator)
+		}
+		b.products = repository.NewProductSQLiteRepository(db)
+		b.carts = repository.NewCartSQLiteRepository(db)
+		b.promotions = repository.NewPromotionSQLiteRepository(db)
+		slog.Info("using SQLite repositories")
+
+	case "dynamo":
+		client := initDynamoDB(cfg.DynamoDB)
+		productsTable := cfg.DynamoDB.TableName(cfg.DynamoDB.ProductsTable)
+		cartsTable := cfg.DynamoDB.TableName(cfg.DynamoDB.CartsTable)
+		promotionsTable := cfg.DynamoDB.TableName(cfg.DynamoDB.PromotionsTable)
+		if cfg.DynamoDB.CreateTables || cfg.DynamoDB.VerifyTables {
+			err := repository.EnsureDynamoDBTables(context.Background(), client, cfg.DynamoDB.CreateTables,
+				repository.ProductsTable(productsTable),
+				repository.CartsTable(cartsTable),
+				repository.PromotionsTable(promotionsTable),
+			)
+			if err != nil {
+				fatal("DynamoDB tables are not usable", err)
+			}
+		}
+		b.products = repository.NewProductDynamoDBRepository(client, productsTable)
+		b.carts = repository.NewCartDynamoDBRepository(client, cartsTable)
+		b.promotions = repository.NewPromotionDynamoDBRepository(client, promotionsTable)
+		slog.Info("using DynamoDB repositories")
+
+	default: // memory
+		if cfg.Memory.DataDir == "" {
+			b.products = repository.NewProductMemoryRepository()
+			b.carts = repository.NewCartMemoryRepository()
+			b.promotions = repository.NewPromotionMemoryRepository()
+			slog.Info("using in-memory repositories")
+			break
+		}
+
+		products, carts, promotions := openPersistentMemory(cfg.Memory)
+		b.closers = append(b.closers, products.Close, carts.Close, promotions.Close)
+		b.products, b.carts, b.promotions = products, carts, promotions
+		slog.Info("using persistent in-memory repositories", "data_dir", cfg.Memory.DataDir)
+	}
+
+	return b
+}
+
+// openPersistentMemory restores the memory repositories from the data directory
+// and compacts their logs periodically in the background
+func openPersistentMemory(cfg config.MemoryConfig) (*repository.ProductMemoryRepository, *repository.CartMemoryRepository, *repository.PromotionMemoryRepository) {
+	products, err := repository.NewPersistentProductMemoryRepository(cfg.DataDir, cfg.Sync)
+	if err != nil {
+		fatal("failed to restore products", err)
+	}
+	carts, err := repository.NewPersistentCartMemoryRepository(cfg.DataDir, cfg.Sync)
+	if err != nil {
+		fatal("failed to restore carts", err)
+	}
+	promotions, err := repository.NewPersistentPromotionMemoryRepository(cfg.DataDir, cfg.Sync)
+	if err != nil {
+		fatal("failed to restore promotions", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(cfg.CompactInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := products.Compact(); err != nil {
+				slog.Error("failed to compact products", "error", err)
+			}
+			if err := carts.Compact(); err != nil {
+				slog.Error("failed to compact carts", "error", err)
+			}
+			if err := promotions.Compact(); err != nil {
+				slog.Error("failed to compact promotions", "error", err)
+			}
+		}
+	}()
+
+	return products, carts, promotions
+}
+
+// openSQLDatabase connects to the SQL backend selected by DB_TYPE and
+// returns it with the migrator for its schema
+func openSQLDatabase(cfg *config.Config) (*sql.DB, *migrations.Migrator) {
+	var db *sql.DB
+	var migrator *migrations.Migrator
+	var err error
+
+	switch cfg.DBType {
+	case "mysql":
+		db = initMySQL(cfg.MySQL)
+		migrator, err = migrations.NewMySQL(db)
+	case "postgres":
+		db = initPostgres(cfg.Postgres)
+		migrator, err = migrations.NewPostgres(db)
+	case "sqlite":
+		db = initSQLite(cfg.SQLite)
+		migrator, err = migrations.NewSQLite(db)
+	default:
+		err = fmt.Errorf("DB_TYPE=%s is not a SQL backend", cfg.DBType)
+	}
+	if err != nil {
+		fatal("failed to load migrations", err)
+	}
+
+	return db, migrator
+}
+
+func initMySQL(cfg config.MySQLConfig) *sql.DB {
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true&charset=utf8mb4",
+		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Database)
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		fatal("failed to connect to MySQL", err)
+	}
+
+	// Configure connection pool
+	db.SetMaxOpenConns(cfg.MaxConnections)
+	db.SetMaxIdleConns(cfg.MaxIdleConnections)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	// Verify connection
+	if err := db.Ping(); err != nil {
+		fatal("failed to ping MySQL", err)
+	}
+
+	slog.Info("connected to MySQL", "host", cfg.Host, "port", cfg.Port)
+	return db
+}
+
+func initPostgres(cfg config.PostgresConfig) *sql.DB {
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Path:     cfg.Database,
+		RawQuery: url.Values{"sslmode": {cfg.SSLMode}}.Encode(),
+	}
+
+	db, err := sql.Open("pgx", dsn.String())
+	if err != nil {
+		fatal("failed to connect to PostgreSQL", err)
+	}
+
+	// Configure connection pool
+	db.SetMaxOpenConns(cfg.MaxConnections)
+	db.SetMaxIdleConns(cfg.MaxIdleConnections)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	// Verify connection
+	if err := db.Ping(); err != nil {
+		fatal("failed to ping PostgreSQL", err)
+	}
+
+	slog.Info("connected to PostgreSQL", "host", cfg.Host, "port", cfg.Port)
+	return db
+}
+
+func initSQLite(cfg config.SQLiteConfig) *sql.DB {
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o755); err != nil {
+		fatal("failed to create SQLite directory", err)
+	}
+
+	// Pragmas are applied to every connection the pool opens
+	pragmas := url.Values{}
+	pragmas.Add("_pragma", "journal_mode(WAL)")
+	pragmas.Add("_pragma", "synchronous(NORMAL)")
+	pragmas.Add("_pragma", "foreign_keys(ON)")
+	pragmas.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", cfg.BusyTimeout.Milliseconds()))
+	// Start write transactions with the write lock so they wait on busy_timeout instead of failing
+	pragmas.Add("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+cfg.Path+"?"+pragmas.Encode())
+	if err != nil {
+		fatal("failed to open SQLite database", err)
+	}
+
+	// Verify the file can be opened and the pragmas apply
+	if err := db.Ping(); err != nil {
+		fatal("failed to open SQLite database", err)
+	}
+
+	slog.Info("opened SQLite database", "path", cfg.Path)
+	return db
+}
+
+func initDynamoDB(cfg config.DynamoDBConfig) *dynamodb.Client {
+	ctx := context.TODO()
+
+	var awsCfg aws.Config
+	var err error
+
+	if cfg.Endpoint != "" {
+		// Local DynamoDB
+		awsCfg, err = awsconfig.LoadDefaultConfig(ctx,
+			awsconfig.WithRegion(cfg.Region),
+			awsconfig.WithEndpointResolverWithOptions(aws.EndpointResolverWithOptionsFunc(
+				func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+					return aws.Endpoint{URL: cfg.Endpoint}, nil
+				})),
+			awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+				cfg.AccessKeyID,
+				cfg.SecretAccessKey,
+				"",
+			)),
+		)
+	} else {
+		// AWS DynamoDB (uses IAM role from ECS task)
+		awsCfg, err = awsconfig.LoadDefaultConfig(ctx,
+			awsconfig.WithRegion(cfg.Region),
+		)
+	}
+
+	if err != nil {
+		fatal("failed to load DynamoDB config", err)
+	}
+
+	client := dynamodb.NewFromConfig(awsCfg)
+	slog.Info("connected to DynamoDB", "region", cfg.Region, "endpoint", cfg.Endpoint)
+
+	return client
+}
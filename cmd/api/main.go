@@ -2,26 +2,28 @@ package main
 
 import (
 	"context"
-	"database/sql"
+	"errors"
+	"expvar"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/LuoZihYuan/Go-Cart/internal/config"
 	"github.com/LuoZihYuan/Go-Cart/internal/handlers"
-	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+	"github.com/LuoZihYuan/Go-Cart/internal/logging"
+	"github.com/LuoZihYuan/Go-Cart/internal/middleware"
 	"github.com/LuoZihYuan/Go-Cart/internal/router"
 	"github.com/LuoZihYuan/Go-Cart/internal/services"
-
-	_ "github.com/go-sql-driver/mysql"
-
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 )
 
 // @title E-commerce API
@@ -40,152 +42,169 @@ import (
 // @tag.description Product management operations
 // @tag.name Shopping Cart
 // @tag.description Shopping cart operations
+// @tag.name Promotions
+// @tag.description Promotion and coupon code management
 // @tag.name Warehouse
 // @tag.description Warehouse and inventory operations
 // @tag.name Payments
 // @tag.description Payment processing operations
 func main() {
-	dbType := getEnv("DB_TYPE", "memory")
-	log.Printf("Starting with DB_TYPE=%s", dbType)
-
-	var productRepo repository.ProductRepository
-	var cartRepo repository.CartRepository
-
-	switch dbType {
-	case "mysql":
-		db := initMySQL()
-		defer db.Close()
-		productRepo = repository.NewProductMySQLRepository(db)
-		cartRepo = repository.NewCartMySQLRepository(db)
-		log.Println("Using MySQL repositories")
-
-	case "dynamo":
-		client := initDynamoDB()
-		productRepo = repository.NewProductDynamoDBRepository(client)
-		cartRepo = repository.NewCartDynamoDBRepository(client)
-		log.Println("Using DynamoDB repositories")
-
-	default: // memory
-		productRepo = repository.NewProductMemoryRepository()
-		cartRepo = repository.NewCartMemoryRepository()
-		log.Println("Using in-memory repositories")
+	args := os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			runMigrate(args[1:])
+			return
+		case "copy":
+			runCopy(args[1:])
+			return
+		}
+	}
+
+	cfg, opts := loadConfig("api", args)
+	if opts.PrintConfig {
+		if err := cfg.Redacted().Write(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
 
+	logger := initLogger(cfg.Log)
+	slog.Info("starting", "db_type", cfg.DBType, "config_file", opts.File)
+
+	// Pricing is loaded before the backend is opened, so a bad rates file exits
+	// without leaving the backend unclosed
+	cartPricing := services.Pricing{
+		Currency: cfg.Pricing.BaseCurrency,
+		Exchange: loadExchangeRates(cfg.Pricing),
+		Taxes:    loadTaxCalculator(cfg.Pricing),
+		Shipping: loadShippingCalculator(cfg.Pricing),
+	}
+
+	// SIGINT or SIGTERM stops the server and the background jobs
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	backend := openBackend(cfg)
+	productRepo, cartRepo, promotionRepo := backend.products, backend.carts, backend.promotions
+	var jobs sync.WaitGroup
+	startCartSweeper(ctx, &jobs, cfg.Carts, backend.carts)
+
+	// The memory backend has no transient failures to retry
+	if cfg.DBType != "memory" {
+		productRepo, cartRepo, promotionRepo = withResilience(cfg.Resilience, cfg.DBType, productRepo, cartRepo, promotionRepo)
+	}
+	productRepo = withProductCache(cfg.Cache, productRepo)
+	startAbandonedCartReminder(ctx, &jobs, cfg, backend.carts, productRepo)
+
 	// Initialize services
 	productService := services.NewProductService(productRepo)
-	cartService := services.NewCartService(cartRepo, productRepo)
+	limits := services.QuantityLimits{MaxLineQuantity: cfg.Carts.MaxLineQuantity, MaxLines: cfg.Carts.MaxLines}
+	promotionService := services.NewPromotionService(promotionRepo)
+	cartService := services.NewCartService(cartRepo, productRepo, promotionRepo, cartPricing, cfg.Carts.TTL, cfg.Carts.SingleActive, limits)
 
 	// Initialize handlers
 	productHandler := handlers.NewProductHandler(productService)
 	cartHandler := handlers.NewCartHandler(cartService)
+	promotionHandler := handlers.NewPromotionHandler(promotionService)
 
 	// Combine all handlers
 	allHandlers := &router.AllHandlers{
-		ProductHandler: productHandler,
-		CartHandler:    cartHandler,
+		ProductHandler:   productHandler,
+		CartHandler:      cartHandler,
+		PromotionHandler: promotionHandler,
 	}
 
-	// Setup Gin router
-	r := gin.Default()
+	// Setup Gin router with structured logging and request IDs
+	r := gin.New()
+	r.Use(middleware.RequestID())
+	r.Use(middleware.Logger(logger))
+	r.Use(middleware.Recovery(logger))
+	r.Use(middleware.Errors(logger))
+	if cfg.Server.RequestTimeout > 0 {
+		r.Use(middleware.Timeout(cfg.Server.RequestTimeout))
+	}
 
 	// Setup routes
 	router.SetupRoutes(r, allHandlers)
 
+	if cfg.Server.DebugVars {
+		r.GET("/debug/vars", gin.WrapH(expvar.Handler()))
+	}
+
 	// Setup Swagger (conditionally compiled based on build tags)
 	setupSwagger(r)
 
 	// Start server
-	log.Println("Starting server on :8080")
-	if err := r.Run(":8080"); err != nil {
-		log.Fatal("Failed to start server:", err)
+	server := &http.Server{Addr: ":" + strconv.Itoa(cfg.Server.Port), Handler: r}
+	slog.Info("starting server", "addr", server.Addr)
+	err := serve(ctx, server, cfg.Server.ShutdownTimeout)
+
+	// Stop the background jobs before closing the backend they use
+	stop()
+	jobs.Wait()
+	backend.Close()
+	if err != nil {
+		fatal("server failed", err)
 	}
+	slog.Info("server stopped")
 }
 
-func initMySQL() *sql.DB {
-	host := getEnv("MYSQL_HOST", "localhost")
-	port := getEnv("MYSQL_PORT", "3306")
-	database := getEnv("MYSQL_DATABASE", "gocart")
-	user := getEnv("MYSQL_USER", "gocart")
-	password := getEnv("MYSQL_PASSWORD", "secret")
-	maxConns := getEnvAsInt("MYSQL_MAX_CONNECTIONS", 20)
-	maxIdleConns := getEnvAsInt("MYSQL_MAX_IDLE_CONNECTIONS", 5)
+// serve runs server until it fails or ctx is done, then gives in-flight requests up
+// to timeout to finish
+func serve(ctx context.Context, server *http.Server, timeout time.Duration) error {
+	errc := make(chan error, 1)
+	go func() { errc <- server.ListenAndServe() }()
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&charset=utf8mb4",
-		user, password, host, port, database)
-
-	db, err := sql.Open("mysql", dsn)
-	if err != nil {
-		log.Fatalf("Failed to connect to MySQL: %v", err)
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
 	}
 
-	// Configure connection pool
-	db.SetMaxOpenConns(maxConns)
-	db.SetMaxIdleConns(maxIdleConns)
-	db.SetConnMaxLifetime(time.Hour)
-
-	// Verify connection
-	if err := db.Ping(); err != nil {
-		log.Fatalf("Failed to ping MySQL: %v", err)
+	slog.Info("shutting down server", "timeout", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
 	}
-
-	log.Printf("Connected to MySQL at %s:%s", host, port)
-	return db
+	return nil
 }
 
-func initDynamoDB() *dynamodb.Client {
-	ctx := context.TODO()
-	region := getEnv("DYNAMODB_REGION", "us-east-1")
-	endpoint := getEnv("DYNAMODB_ENDPOINT", "")
-
-	var cfg aws.Config
-	var err error
-
-	if endpoint != "" {
-		// Local DynamoDB
-		cfg, err = config.LoadDefaultConfig(ctx,
-			config.WithRegion(region),
-			config.WithEndpointResolverWithOptions(aws.EndpointResolverWithOptionsFunc(
-				func(service, region string, options ...interface{}) (aws.Endpoint, error) {
-					return aws.Endpoint{URL: endpoint}, nil
-				})),
-			config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
-				getEnv("AWS_ACCESS_KEY_ID", "fakekey"),
-				getEnv("AWS_SECRET_ACCESS_KEY", "fakesecret"),
-				"",
-			)),
-		)
-	} else {
-		// AWS DynamoDB (uses IAM role from ECS task)
-		cfg, err = config.LoadDefaultConfig(ctx,
-			config.WithRegion(region),
-		)
+// loadConfig loads and validates the configuration, exiting on invalid values
+func loadConfig(name string, args []string) (*config.Config, config.Options) {
+	cfg, opts, err := config.Load(name, args, os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
 	}
-
 	if err != nil {
-		log.Fatalf("Failed to load DynamoDB config: %v", err)
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
 	}
-
-	client := dynamodb.NewFromConfig(cfg)
-	log.Printf("Connected to DynamoDB in region %s", region)
-	if endpoint != "" {
-		log.Printf("Using local endpoint: %s", endpoint)
-	}
-
-	return client
+	return cfg, opts
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// initLogger installs a JSON logger at the configured level
+func initLogger(cfg config.LogConfig) *slog.Logger {
+	// The level was checked by config.Validate
+	level, _ := logging.ParseLevel(cfg.Level)
+	logger := logging.New(os.Stdout, level)
+	slog.SetDefault(logger)
+
+	// Route Gin's own debug output through the structured logger
+	gin.DebugPrintFunc = func(format string, values ...any) {
+		logger.Debug(strings.TrimSpace(fmt.Sprintf(format, values...)), "component", "gin")
+	}
+	gin.DebugPrintRouteFunc = func(httpMethod, absolutePath, handlerName string, _ int) {
+		logger.Debug("route registered", "method", httpMethod, "path", absolutePath, "handler", handlerName)
 	}
-	return defaultValue
+
+	return logger
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
-		}
-	}
-	return defaultValue
+// fatal logs an error and exits the process
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
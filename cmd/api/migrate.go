@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/migrations"
+)
+
+const migrateUsage = `usage: api migrate <command> [flags]
+
+commands:
+  up          apply all pending migrations
+  down [n]    roll back the last n applied migrations (default 1)
+  status      list migrations and when they were applied
+
+Flags are the same as for the server; run "api --help" to list them.`
+
+// runMigrate implements the "migrate" subcommand
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+
+	command, args := args[0], args[1:]
+	steps := 1
+	if command == "down" && len(args) > 0 {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			if n < 1 {
+				fmt.Fprintln(os.Stderr, "migrate down: n must be at least 1")
+				os.Exit(2)
+			}
+			steps, args = n, args[1:]
+		}
+	}
+
+	cfg, _ := loadConfig("migrate "+command, args)
+	initLogger(cfg.Log)
+
+	if cfg.DBType == "memory" || cfg.DBType == "dynamo" {
+		fmt.Fprintf(os.Stderr, "migrate: DB_TYPE=%s has no SQL schema to migrate\n", cfg.DBType)
+		os.Exit(2)
+	}
+
+	db, migrator := openSQLDatabase(cfg)
+	defer db.Close()
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		logMigrations("applied migration", applied)
+		if err != nil {
+			fatal("migration failed", err)
+		}
+		slog.Info("database schema is up to date", "applied", len(applied))
+
+	case "down":
+		rolledBack, err := migrator.Down(ctx, steps)
+		logMigrations("rolled back migration", rolledBack)
+		if err != nil {
+			fatal("rollback failed", err)
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			fatal("failed to read migration status", err)
+		}
+		printMigrationStatus(statuses)
+
+	default:
+		fmt.Fprintf(os.Stderr, "migrate: unknown command %q\n\n%s\n", command, migrateUsage)
+		os.Exit(2)
+	}
+}
+
+// migrateUp applies pending migrations at server startup
+func migrateUp(migrator *migrations.Migrator) {
+	applied, err := migrator.Up(context.Background())
+	logMigrations("applied migration", applied)
+	if err != nil {
+		fatal("migration failed", err)
+	}
+}
+
+func logMigrations(msg string, applied []migrations.Migration) {
+	for _, m := range applied {
+		slog.Info(msg, "version", m.Version, "name", m.Name)
+	}
+}
+
+func printMigrationStatus(statuses []migrations.Status) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+	}
+	w.Flush()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"sync"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/config"
+	"github.com/LuoZihYuan/Go-Cart/internal/jobs"
+	"github.com/LuoZihYuan/Go-Cart/internal/notify"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+)
+
+// newNotifier creates the configured notifier
+func newNotifier(cfg config.NotifyConfig) notify.Notifier {
+	switch cfg.Backend {
+	case "webhook":
+		return notify.NewWebhook(cfg.Webhook.URL, cfg.Webhook.Timeout)
+	case "smtp":
+		return notify.NewSMTP(notify.SMTPOptions{
+			Addr:    cfg.SMTP.Addr,
+			From:    cfg.SMTP.From,
+			To:      cfg.SMTP.To,
+			Timeout: cfg.SMTP.Timeout,
+		})
+	default: // log
+		return notify.NewLog(slog.Default())
+	}
+}
+
+// startAbandonedCartReminder sends reminders about abandoned carts in the background,
+// publishing its counters as the expvar "abandoned_carts". It stops when ctx is done;
+// wait for wg before closing carts.
+func startAbandonedCartReminder(ctx context.Context, wg *sync.WaitGroup, cfg *config.Config, carts repository.CartRepository, products repository.ProductRepository) {
+	store, ok := carts.(repository.AbandonedCartStore)
+	if cfg.Abandoned.After <= 0 || !ok {
+		return
+	}
+
+	reminder := jobs.NewAbandonedCartReminder(store, products, cfg.Pricing.BaseCurrency, newNotifier(cfg.Notify),
+		cfg.Abandoned.After, cfg.Abandoned.CheckInterval, cfg.Abandoned.BatchSize)
+	expvar.Publish("abandoned_carts", expvar.Func(func() any { return reminder.Stats() }))
+	wg.Go(func() { reminder.Run(ctx) })
+
+	slog.Info("reminding of abandoned carts", "after", cfg.Abandoned.After, "notify_backend", cfg.Notify.Backend)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/config"
+	"github.com/LuoZihYuan/Go-Cart/internal/pricing"
+)
+
+// loadTaxCalculator loads the configured tax rates, or returns nil to leave carts
+// untaxed, and exits if the rates cannot be loaded
+func loadTaxCalculator(cfg config.PricingConfig) pricing.TaxCalculator {
+	if cfg.TaxRatesFile == "" {
+		return nil
+	}
+
+	table, err := pricing.LoadTaxTable(cfg.TaxRatesFile)
+	if err != nil {
+		fatal("failed to load tax rates", err)
+	}
+	slog.Info("taxing carts", "rates_file", cfg.TaxRatesFile)
+	return table
+}
+
+// loadShippingCalculator loads the configured shipping rates, or returns nil to ship
+// carts for nothing, and exits if the rates cannot be loaded
+func loadShippingCalculator(cfg config.PricingConfig) pricing.ShippingCalculator {
+	if cfg.ShippingRatesFile == "" {
+		return nil
+	}
+
+	table, err := pricing.LoadShippingTable(cfg.ShippingRatesFile)
+	if err != nil {
+		fatal("failed to load shipping rates", err)
+	}
+	slog.Info("shipping carts", "rates_file", cfg.ShippingRatesFile)
+	return table
+}
+
+// loadExchangeRates loads the configured exchange rates, or returns nil to price carts
+// in the base currency only, and exits if the rates cannot be loaded or are against
+// another currency
+func loadExchangeRates(cfg config.PricingConfig) pricing.Converter {
+	if cfg.ExchangeRatesFile == "" {
+		return nil
+	}
+
+	rates, err := pricing.LoadExchangeRates(cfg.ExchangeRatesFile)
+	if err != nil {
+		fatal("failed to load exchange rates", err)
+	}
+	if rates.Base() != cfg.BaseCurrency {
+		fatal("failed to load exchange rates", fmt.Errorf("rates are against %s, not the base currency %s", rates.Base(), cfg.BaseCurrency))
+	}
+	slog.Info("converting currencies", "rates_file", cfg.ExchangeRatesFile, "base", rates.Base())
+	return rates
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"expvar"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/config"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+	"github.com/LuoZihYuan/Go-Cart/internal/resilience"
+)
+
+// withResilience wraps the repositories with retries and one circuit breaker for
+// their shared backend, publishing the breaker's state as the expvar "circuit_breaker"
+func withResilience(cfg config.ResilienceConfig, backend string, products repository.ProductRepository, carts repository.CartRepository, promotions repository.PromotionRepository) (repository.ProductRepository, repository.CartRepository, repository.PromotionRepository) {
+	r := newResilience(cfg, backend)
+	expvar.Publish("circuit_breaker", expvar.Func(func() any { return r.Breaker.Stats() }))
+
+	return repository.NewResilientProductRepository(products, r), repository.NewResilientCartRepository(carts, r),
+		repository.NewResilientPromotionRepository(promotions, r)
+}
+
+// newResilience builds the retry policy and a circuit breaker for one backend
+func newResilience(cfg config.ResilienceConfig, backend string) repository.Resilience {
+	return repository.Resilience{
+		Retry: resilience.RetryPolicy{
+			MaxAttempts: cfg.RetryMaxAttempts,
+			BaseDelay:   cfg.RetryBaseDelay,
+			MaxDelay:    cfg.RetryMaxDelay,
+		},
+		Breaker: resilience.NewBreaker(backend, cfg.BreakerThreshold, cfg.BreakerOpenTimeout),
+	}
+}
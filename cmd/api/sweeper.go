@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"sync"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/config"
+	"github.com/LuoZihYuan/Go-Cart/internal/jobs"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+)
+
+// startCartSweeper deletes expired carts in the background for backends that do not
+// expire them themselves, publishing its counters as the expvar "cart_sweeper".
+// It stops when ctx is done; wait for wg before closing carts.
+func startCartSweeper(ctx context.Context, wg *sync.WaitGroup, cfg config.CartsConfig, carts repository.CartRepository) {
+	deleter, ok := carts.(repository.ExpiredCartDeleter)
+	if cfg.TTL <= 0 || !ok {
+		return
+	}
+
+	sweeper := jobs.NewCartSweeper(deleter, cfg.SweepInterval, cfg.ExpiredRetention, cfg.SweepBatchSize)
+	expvar.Publish("cart_sweeper", expvar.Func(func() any { return sweeper.Stats() }))
+	wg.Go(func() { sweeper.Run(ctx) })
+
+	slog.Info("sweeping expired carts", "interval", cfg.SweepInterval, "retention", cfg.ExpiredRetention)
+}
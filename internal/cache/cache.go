@@ -0,0 +1,17 @@
+// Package cache provides the byte-oriented caches used by read-through repositories:
+// an in-process LRU with expiry and a client for Redis-protocol servers.
+package cache
+
+import "time"
+
+// Cache stores opaque values under string keys, each with its own time to live
+type Cache interface {
+	// Get returns the value stored under key; ok is false if it is missing or expired
+	Get(key string) (value []byte, ok bool, err error)
+
+	// Set stores value under key until ttl elapses
+	Set(key string, value []byte, ttl time.Duration) error
+
+	// Delete removes key; deleting a missing key is not an error
+	Delete(key string) error
+}
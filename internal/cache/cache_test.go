@@ -0,0 +1,233 @@
+package cache_test
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/cache"
+	"github.com/LuoZihYuan/Go-Cart/internal/cache/redistest"
+)
+
+// testCache checks the behaviour every Cache implementation shares
+func testCache(t *testing.T, newCache func(t *testing.T) cache.Cache) {
+	t.Run("GetMissing", func(t *testing.T) {
+		c := newCache(t)
+		if _, ok, err := c.Get("missing"); err != nil || ok {
+			t.Fatalf("Get(missing) = ok %v, err %v; want a miss", ok, err)
+		}
+	})
+
+	t.Run("SetThenGet", func(t *testing.T) {
+		c := newCache(t)
+		mustSet(t, c, "key", "value", time.Minute)
+		assertValue(t, c, "key", "value")
+
+		mustSet(t, c, "key", "replaced", time.Minute)
+		assertValue(t, c, "key", "replaced")
+	})
+
+	t.Run("BinaryValues", func(t *testing.T) {
+		c := newCache(t)
+		value := "line\r\nbreak \x00 and $5\r\n"
+		mustSet(t, c, "binary", value, time.Minute)
+		assertValue(t, c, "binary", value)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		c := newCache(t)
+		mustSet(t, c, "key", "value", time.Minute)
+		if err := c.Delete("key"); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok, _ := c.Get("key"); ok {
+			t.Error("key still present after Delete")
+		}
+		if err := c.Delete("key"); err != nil {
+			t.Errorf("deleting a missing key: %v", err)
+		}
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		c := newCache(t)
+		mustSet(t, c, "key", "value", 50*time.Millisecond)
+		assertValue(t, c, "key", "value")
+
+		time.Sleep(100 * time.Millisecond)
+		if _, ok, _ := c.Get("key"); ok {
+			t.Error("key still present after its TTL")
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		c := newCache(t)
+		var wg sync.WaitGroup
+		for i := range 16 {
+			wg.Go(func() {
+				key := fmt.Sprintf("key-%d", i)
+				for range 20 {
+					if err := c.Set(key, []byte(key), time.Minute); err != nil {
+						t.Error(err)
+						return
+					}
+					if value, ok, err := c.Get(key); err != nil || !ok || string(value) != key {
+						t.Errorf("Get(%s) = %q, %v, %v", key, value, ok, err)
+						return
+					}
+				}
+			})
+		}
+		wg.Wait()
+	})
+}
+
+func mustSet(t *testing.T, c cache.Cache, key, value string, ttl time.Duration) {
+	t.Helper()
+	if err := c.Set(key, []byte(value), ttl); err != nil {
+		t.Fatalf("Set(%s): %v", key, err)
+	}
+}
+
+func assertValue(t *testing.T, c cache.Cache, key, want string) {
+	t.Helper()
+	value, ok, err := c.Get(key)
+	if err != nil {
+		t.Fatalf("Get(%s): %v", key, err)
+	}
+	if !ok || string(value) != want {
+		t.Fatalf("Get(%s) = %q, %v; want %q", key, value, ok, want)
+	}
+}
+
+func TestLRU(t *testing.T) {
+	testCache(t, func(t *testing.T) cache.Cache { return cache.NewLRU(100) })
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := cache.NewLRU(2)
+	mustSet(t, c, "a", "1", time.Minute)
+	mustSet(t, c, "b", "2", time.Minute)
+	assertValue(t, c, "a", "1") // a is now more recently used than b
+	mustSet(t, c, "c", "3", time.Minute)
+
+	if _, ok, _ := c.Get("b"); ok {
+		t.Error("b was not evicted")
+	}
+	assertValue(t, c, "a", "1")
+	assertValue(t, c, "c", "3")
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", c.Len())
+	}
+}
+
+func TestLRUReturnsCopies(t *testing.T) {
+	c := cache.NewLRU(1)
+	value := []byte("value")
+	if err := c.Set("key", value, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	value[0] = 'X'
+
+	got, _, _ := c.Get("key")
+	got[1] = 'X'
+	assertValue(t, c, "key", "value")
+}
+
+func newRedis(addr, password string) *cache.Redis {
+	return cache.NewRedis(cache.RedisOptions{
+		Addr:     addr,
+		Password: password,
+		Timeout:  time.Second,
+		PoolSize: 4,
+	})
+}
+
+func TestRedisStandIn(t *testing.T) {
+	testCache(t, func(t *testing.T) cache.Cache {
+		server := redistest.NewServer(t, "secret")
+		client := newRedis(server.Addr(), "secret")
+		t.Cleanup(func() { client.Close() })
+		return client
+	})
+}
+
+// TestRedis runs against a real server at GOCART_TEST_REDIS_ADDR, e.g.
+// "localhost:6379" with the docker compose redis profile. Keys are not
+// namespaced, so use a server with nothing else on it.
+func TestRedis(t *testing.T) {
+	addr := os.Getenv("GOCART_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("GOCART_TEST_REDIS_ADDR not set")
+	}
+
+	testCache(t, func(t *testing.T) cache.Cache {
+		client := newRedis(addr, "")
+		t.Cleanup(func() { client.Close() })
+		return client
+	})
+}
+
+func TestRedisAuth(t *testing.T) {
+	server := redistest.NewServer(t, "secret")
+
+	if err := newRedis(server.Addr(), "wrong").Ping(); err == nil {
+		t.Error("Ping with a wrong password succeeded")
+	}
+	if err := newRedis(server.Addr(), "").Ping(); err == nil {
+		t.Error("Ping without a password succeeded")
+	}
+	if err := newRedis(server.Addr(), "secret").Ping(); err != nil {
+		t.Errorf("Ping with the right password: %v", err)
+	}
+}
+
+func TestRedisReusesConnections(t *testing.T) {
+	server := redistest.NewServer(t, "")
+	client := newRedis(server.Addr(), "")
+	defer client.Close()
+
+	for range 50 {
+		if err := client.Ping(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := server.Connections(); n != 1 {
+		t.Errorf("opened %d connections for sequential commands, want 1", n)
+	}
+
+	var wg sync.WaitGroup
+	for range 32 {
+		wg.Go(func() {
+			if err := client.Ping(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+	if n := server.Connections(); n > 4 {
+		t.Errorf("opened %d connections, want at most the pool size of 4", n)
+	}
+}
+
+func TestRedisTimeout(t *testing.T) {
+	server := redistest.NewServer(t, "")
+	client := cache.NewRedis(cache.RedisOptions{Addr: server.Addr(), Timeout: 50 * time.Millisecond, PoolSize: 1})
+	defer client.Close()
+
+	server.Pause()
+	start := time.Now()
+	if _, _, err := client.Get("key"); err == nil {
+		t.Fatal("Get succeeded while the server was not answering")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Get took %v, want about the 50ms timeout", elapsed)
+	}
+
+	// The timed-out connection is discarded and a new one works once the server answers
+	server.Resume()
+	if err := client.Ping(); err != nil {
+		t.Errorf("Ping after the server resumed: %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// RedisOptions configures a Redis client
+type RedisOptions struct {
+	Addr     string
+	Password string // sent with AUTH when not empty
+	DB       int    // selected with SELECT when not zero
+	// Timeout bounds dialing and each command's round trip
+	Timeout time.Duration
+	// PoolSize is the maximum number of open connections
+	PoolSize int
+}
+
+// RedisError is an error reply from the server
+type RedisError struct {
+	Message string
+}
+
+func (e *RedisError) Error() string {
+	return "redis: " + e.Message
+}
+
+// errRedisProtocol is returned when a reply cannot be parsed
+var errRedisProtocol = errors.New("redis: protocol error")
+
+// Redis is a minimal client for servers speaking the Redis protocol (RESP2).
+// It supports the commands a cache needs and keeps a small connection pool.
+type Redis struct {
+	opts  RedisOptions
+	slots chan struct{}   // one token per connection that may be open
+	idle  chan *redisConn // connections ready for reuse
+}
+
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+// NewRedis creates a client; connections are opened on first use
+func NewRedis(opts RedisOptions) *Redis {
+	return &Redis{
+		opts:  opts,
+		slots: make(chan struct{}, opts.PoolSize),
+		idle:  make(chan *redisConn, opts.PoolSize),
+	}
+}
+
+// Ping checks that the server is reachable and the credentials are accepted
+func (r *Redis) Ping() error {
+	_, err := r.do("PING")
+	return err
+}
+
+// Get returns the value stored under key
+func (r *Redis) Get(key string) ([]byte, bool, error) {
+	reply, err := r.do("GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("%w: unexpected GET reply %T", errRedisProtocol, reply)
+	}
+	return value, true, nil
+}
+
+// Set stores value under key with a millisecond expiry
+func (r *Redis) Set(key string, value []byte, ttl time.Duration) error {
+	_, err := r.do("SET", key, string(value), "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	return err
+}
+
+// Delete removes key
+func (r *Redis) Delete(key string) error {
+	_, err := r.do("DEL", key)
+	return err
+}
+
+// Close closes the idle connections
+func (r *Redis) Close() error {
+	for {
+		select {
+		case c := <-r.idle:
+			c.conn.Close()
+			<-r.slots
+		default:
+			return nil
+		}
+	}
+}
+
+// do sends one command and reads its reply. Replies are decoded as
+// string (simple string), int64, []byte (bulk string), []any or nil.
+func (r *Redis) do(args ...string) (any, error) {
+	c, err := r.acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := c.roundTrip(r.opts.Timeout, args...)
+	var redisErr *RedisError
+	if err != nil && !errors.As(err, &redisErr) {
+		// The connection's state is unknown after an I/O or protocol error
+		c.conn.Close()
+		<-r.slots
+		return nil, err
+	}
+
+	r.idle <- c
+	return reply, err
+}
+
+// acquire returns an idle connection or dials a new one, waiting while the pool is full
+func (r *Redis) acquire() (*redisConn, error) {
+	select {
+	case c := <-r.idle:
+		return c, nil
+	default:
+	}
+
+	timer := time.NewTimer(r.opts.Timeout)
+	defer timer.Stop()
+	select {
+	case c := <-r.idle:
+		return c, nil
+	case r.slots <- struct{}{}:
+	case <-timer.C:
+		return nil, errors.New("redis: timed out waiting for a connection")
+	}
+
+	c, err := r.dial()
+	if err != nil {
+		<-r.slots
+		return nil, err
+	}
+	return c, nil
+}
+
+func (r *Redis) dial() (*redisConn, error) {
+	conn, err := net.DialTimeout("tcp", r.opts.Addr, r.opts.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		writer: bufio.NewWriter(conn),
+	}
+
+	if r.opts.Password != "" {
+		if _, err := c.roundTrip(r.opts.Timeout, "AUTH", r.opts.Password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if r.opts.DB != 0 {
+		if _, err := c.roundTrip(r.opts.Timeout, "SELECT", strconv.Itoa(r.opts.DB)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (c *redisConn) roundTrip(timeout time.Duration, args ...string) (any, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(c.writer, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.writer, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := c.writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	return readReply(c.reader)
+}
+
+// readReply decodes one RESP2 reply
+func readReply(reader *bufio.Reader) (any, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errRedisProtocol
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, &RedisError{Message: line[1:]}
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, errRedisProtocol
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < -1 {
+			return nil, errRedisProtocol
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		value := make([]byte, n+2)
+		if _, err := io.ReadFull(reader, value); err != nil {
+			return nil, err
+		}
+		if value[n] != '\r' || value[n+1] != '\n' {
+			return nil, errRedisProtocol
+		}
+		return value[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < -1 {
+			return nil, errRedisProtocol
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		values := make([]any, n)
+		for i := range values {
+			if values[i], err = readReply(reader); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	default:
+		return nil, errRedisProtocol
+	}
+}
+
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", errRedisProtocol
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,269 @@
+// Package redistest runs an in-process stand-in for a Redis server, so code
+// using cache.Redis can be tested without one. It understands the commands the
+// client sends (PING, AUTH, SELECT, GET, SET with PX/EX, DEL) and nothing else.
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Server is a stand-in Redis server listening on a local port
+type Server struct {
+	listener net.Listener
+	password string
+
+	mu      sync.Mutex
+	values  map[string]entry
+	conns   map[net.Conn]struct{}
+	paused  bool
+	dialled int
+}
+
+type entry struct {
+	value     string
+	expiresAt time.Time // zero for no expiry
+}
+
+// NewServer starts a server that is shut down when the test ends.
+// If password is not empty, clients must AUTH before other commands.
+func NewServer(t testing.TB, password string) *Server {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		listener: listener,
+		password: password,
+		values:   make(map[string]entry),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	go s.serve()
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Addr returns the host:port the server listens on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Connections returns how many connections clients have opened
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dialled
+}
+
+// Keys returns the number of unexpired keys
+func (s *Server) Keys() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for key := range s.values {
+		if _, ok := s.lookup(key); ok {
+			n++
+		}
+	}
+	return n
+}
+
+// Pause stops the server from answering until Resume, to simulate an outage
+func (s *Server) Pause() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = true
+}
+
+// Resume answers commands again
+func (s *Server) Resume() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = false
+}
+
+// Close stops the server and drops every connection
+func (s *Server) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.dialled++
+		s.mu.Unlock()
+
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	reader := bufio.NewReader(conn)
+	authenticated := s.password == ""
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+
+		// A paused server reads commands but never answers them
+		for s.isPaused() {
+			time.Sleep(time.Millisecond)
+		}
+
+		var reply string
+		if !authenticated && !strings.EqualFold(args[0], "AUTH") {
+			reply = "-NOAUTH Authentication required.\r\n"
+		} else {
+			reply = s.execute(args, &authenticated)
+		}
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) isPaused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+func (s *Server) execute(args []string, authenticated *bool) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "AUTH":
+		if len(args) != 2 || args[1] != s.password {
+			return "-WRONGPASS invalid username-password pair\r\n"
+		}
+		*authenticated = true
+		return "+OK\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		if len(args) != 2 {
+			return wrongArgs(args[0])
+		}
+		e, ok := s.lookup(args[1])
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(e.value), e.value)
+	case "SET":
+		if len(args) != 3 && len(args) != 5 {
+			return wrongArgs(args[0])
+		}
+		e := entry{value: args[2]}
+		if len(args) == 5 {
+			n, err := strconv.ParseInt(args[4], 10, 64)
+			if err != nil || n <= 0 {
+				return "-ERR invalid expire time in 'set' command\r\n"
+			}
+			switch strings.ToUpper(args[3]) {
+			case "PX":
+				e.expiresAt = time.Now().Add(time.Duration(n) * time.Millisecond)
+			case "EX":
+				e.expiresAt = time.Now().Add(time.Duration(n) * time.Second)
+			default:
+				return "-ERR syntax error\r\n"
+			}
+		}
+		s.values[args[1]] = e
+		return "+OK\r\n"
+	case "DEL":
+		if len(args) < 2 {
+			return wrongArgs(args[0])
+		}
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, ok := s.lookup(key); ok {
+				deleted++
+			}
+			delete(s.values, key)
+		}
+		return fmt.Sprintf(":%d\r\n", deleted)
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+// lookup returns the entry under key, dropping it if expired
+func (s *Server) lookup(key string) (entry, bool) {
+	e, ok := s.values[key]
+	if ok && !e.expiresAt.IsZero() && !time.Now().Before(e.expiresAt) {
+		delete(s.values, key)
+		return entry{}, false
+	}
+	return e, ok
+}
+
+func wrongArgs(command string) string {
+	return fmt.Sprintf("-ERR wrong number of arguments for '%s' command\r\n", strings.ToLower(command))
+}
+
+// readCommand reads one command sent as a RESP array of bulk strings
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("expected array, got %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid array length %q", line)
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("expected bulk string, got %q", line)
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid bulk length %q", line)
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
@@ -0,0 +1,554 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// redacted replaces secret values when the configuration is printed
+const redacted = "REDACTED"
+
+// Config is the complete application configuration
+type Config struct {
+	Server      ServerConfig     `yaml:"server"`
+	Log         LogConfig        `yaml:"log"`
+	DBType      string           `yaml:"db_type"`
+	AutoMigrate bool             `yaml:"auto_migrate"` // apply pending SQL migrations at startup
+	Memory      MemoryConfig     `yaml:"memory"`
+	MySQL       MySQLConfig      `yaml:"mysql"`
+	Postgres    PostgresConfig   `yaml:"postgres"`
+	SQLite      SQLiteConfig     `yaml:"sqlite"`
+	DynamoDB    DynamoDBConfig   `yaml:"dynamodb"`
+	Cache       CacheConfig      `yaml:"cache"`
+	Resilience  ResilienceConfig `yaml:"resilience"`
+	Carts       CartsConfig      `yaml:"carts"`
+	Abandoned   AbandonedConfig  `yaml:"abandoned_carts"`
+	Notify      NotifyConfig     `yaml:"notify"`
+	Pricing     PricingConfig    `yaml:"pricing"`
+}
+
+// ServerConfig configures the HTTP server
+type ServerConfig struct {
+	Port int `yaml:"port"`
+	// DebugVars serves expvar metrics, including product cache counters, at /debug/vars
+	DebugVars bool `yaml:"debug_vars"`
+	// RequestTimeout is the deadline for handling a request, retries included; 0 disables it
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+	// ShutdownTimeout is how long in-flight requests may run once the server is told to stop
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// LogConfig configures structured logging
+type LogConfig struct {
+	Level string `yaml:"level"`
+}
+
+// MemoryConfig configures the in-memory backend (DB_TYPE=memory)
+type MemoryConfig struct {
+	// DataDir enables persistence: a snapshot and change log are kept there
+	// and replayed at startup. Leave empty to keep data in memory only.
+	DataDir string `yaml:"data_dir"`
+	// Sync flushes every change to disk before responding
+	Sync bool `yaml:"sync"`
+	// CompactInterval is how often the log is folded into a new snapshot
+	CompactInterval time.Duration `yaml:"compact_interval"`
+}
+
+// MySQLConfig configures the MySQL backend (DB_TYPE=mysql)
+type MySQLConfig struct {
+	Host               string        `yaml:"host"`
+	Port               int           `yaml:"port"`
+	Database           string        `yaml:"database"`
+	User               string        `yaml:"user"`
+	Password           string        `yaml:"password"`
+	MaxConnections     int           `yaml:"max_connections"`
+	MaxIdleConnections int           `yaml:"max_idle_connections"`
+	ConnMaxLifetime    time.Duration `yaml:"conn_max_lifetime"`
+}
+
+// PostgresConfig configures the PostgreSQL backend (DB_TYPE=postgres)
+type PostgresConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Database string `yaml:"database"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	// SSLMode is passed to the server as sslmode (disable, require, verify-full, ...)
+	SSLMode            string        `yaml:"sslmode"`
+	MaxConnections     int           `yaml:"max_connections"`
+	MaxIdleConnections int           `yaml:"max_idle_connections"`
+	ConnMaxLifetime    time.Duration `yaml:"conn_max_lifetime"`
+}
+
+// SQLiteConfig configures the embedded SQLite backend (DB_TYPE=sqlite)
+type SQLiteConfig struct {
+	// Path is the database file, created along with its directory if missing
+	Path string `yaml:"path"`
+	// BusyTimeout is how long a write waits for another connection's lock
+	BusyTimeout time.Duration `yaml:"busy_timeout"`
+}
+
+// DynamoDBConfig configures the DynamoDB backend (DB_TYPE=dynamo)
+type DynamoDBConfig struct {
+	Region string `yaml:"region"`
+	// Endpoint points at DynamoDB Local; leave empty to use AWS
+	Endpoint        string `yaml:"endpoint"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	// TablePrefix is prepended to every table name, e.g. "stage-" for stage-Products
+	TablePrefix     string `yaml:"table_prefix"`
+	ProductsTable   string `yaml:"products_table"`
+	CartsTable      string `yaml:"carts_table"`
+	PromotionsTable string `yaml:"promotions_table"`
+	// CreateTables creates missing tables and indexes at startup (for DynamoDB Local)
+	CreateTables bool `yaml:"create_tables"`
+	// VerifyTables checks at startup that the tables have the expected key schema
+	VerifyTables bool `yaml:"verify_tables"`
+}
+
+// CacheConfig configures the read-through product cache
+type CacheConfig struct {
+	// Backend is none, memory (an LRU in each process) or redis (shared by all instances)
+	Backend string `yaml:"backend"`
+	// TTL bounds how long a product can be served stale after another instance changes it
+	TTL time.Duration `yaml:"ttl"`
+	// Size is the maximum number of products held by the memory backend
+	Size  int         `yaml:"size"`
+	Redis RedisConfig `yaml:"redis"`
+}
+
+// RedisConfig configures the connection to a Redis-protocol server (cache.backend=redis)
+type RedisConfig struct {
+	Addr     string `yaml:"addr"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+	// Timeout bounds connecting and each command; on timeout the repository is read instead
+	Timeout  time.Duration `yaml:"timeout"`
+	PoolSize int           `yaml:"pool_size"`
+}
+
+// ResilienceConfig configures retries and the circuit breaker around the database
+// repositories (not used by the memory backend)
+type ResilienceConfig struct {
+	// RetryMaxAttempts is the number of tries per repository call, including the first; 1 disables retries
+	RetryMaxAttempts int `yaml:"retry_max_attempts"`
+	// RetryBaseDelay is the backoff before the first retry; it doubles with each retry, with jitter
+	RetryBaseDelay time.Duration `yaml:"retry_base_delay"`
+	RetryMaxDelay  time.Duration `yaml:"retry_max_delay"`
+	// BreakerThreshold is the number of consecutive backend failures that opens the breaker; 0 disables it
+	BreakerThreshold int `yaml:"breaker_threshold"`
+	// BreakerOpenTimeout is how long the breaker fails fast before letting a trial request through
+	BreakerOpenTimeout time.Duration `yaml:"breaker_open_timeout"`
+}
+
+// CartsConfig configures cart expiry and the sweeper that deletes expired carts
+type CartsConfig struct {
+	// TTL is how long a cart lives after it was last changed; 0 keeps carts forever
+	TTL time.Duration `yaml:"ttl"`
+	// ExpiredRetention is how long an expired cart is kept, answering 410 Gone, before it is deleted
+	ExpiredRetention time.Duration `yaml:"expired_retention"`
+	// SweepInterval is how often expired carts are deleted (not used by DynamoDB, whose TTL does it)
+	SweepInterval time.Duration `yaml:"sweep_interval"`
+	// SweepBatchSize is the most carts deleted per statement, keeping each delete short
+	SweepBatchSize int `yaml:"sweep_batch_size"`
+	// SingleActive gives each customer one open cart: creating a cart returns the open one if there is one
+	SingleActive bool `yaml:"single_active"`
+	// MaxLineQuantity is the most of one product a cart may hold; 0 for no limit
+	MaxLineQuantity int `yaml:"max_line_quantity"`
+	// MaxLines is the most different products a cart may hold; 0 for no limit
+	MaxLines int `yaml:"max_lines"`
+}
+
+// AbandonedConfig configures reminders about carts left idle with items in them
+type AbandonedConfig struct {
+	// After is how long a cart must be unchanged to count as abandoned; 0 disables reminders
+	After time.Duration `yaml:"after"`
+	// CheckInterval is how often abandoned carts are looked for
+	CheckInterval time.Duration `yaml:"check_interval"`
+	// BatchSize is the most carts read at a time
+	BatchSize int `yaml:"batch_size"`
+}
+
+// NotifyConfig configures where events such as abandoned carts are delivered
+type NotifyConfig struct {
+	// Backend is log, webhook or smtp
+	Backend string        `yaml:"backend"`
+	Webhook WebhookConfig `yaml:"webhook"`
+	SMTP    SMTPConfig    `yaml:"smtp"`
+}
+
+// WebhookConfig configures the webhook notifier (notify.backend=webhook)
+type WebhookConfig struct {
+	URL     string        `yaml:"url"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// SMTPConfig configures the SMTP notifier (notify.backend=smtp), meant for a local
+// stand-in that captures mail; it uses neither authentication nor TLS
+type SMTPConfig struct {
+	Addr string `yaml:"addr"`
+	From string `yaml:"from"`
+	// To is the recipient, with {customer_id} replaced by the customer's ID
+	To      string        `yaml:"to"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// PricingConfig configures how carts are priced beyond their products and coupons
+type PricingConfig struct {
+	// TaxRatesFile is a YAML table of tax rates by destination and category; empty
+	// leaves carts untaxed
+	TaxRatesFile string `yaml:"tax_rates_file"`
+	// ShippingRatesFile is a YAML table of shipping options by destination zone and
+	// weight; empty offers no shipping options and charges nothing for shipping
+	ShippingRatesFile string `yaml:"shipping_rates_file"`
+	// BaseCurrency is the ISO 4217 code of the currency products' prices, fixed
+	// promotion amounts and shipping rates are given in
+	BaseCurrency string `yaml:"base_currency"`
+	// ExchangeRatesFile is a YAML list of dated exchange rates against the base
+	// currency; empty prices every cart in the base currency
+	ExchangeRatesFile string `yaml:"exchange_rates_file"`
+}
+
+// TableName returns the full name of a table after applying the prefix
+func (c DynamoDBConfig) TableName(name string) string {
+	return c.TablePrefix + name
+}
+
+// Options are command-line switches that control loading rather than the application
+type Options struct {
+	// File is the YAML config file, from --config or CONFIG_FILE
+	File string
+	// PrintConfig dumps the effective configuration and exits
+	PrintConfig bool
+}
+
+// Default returns the configuration used when nothing else is set
+func Default() *Config {
+	return &Config{
+		Server:      ServerConfig{Port: 8080, RequestTimeout: 10 * time.Second, ShutdownTimeout: 10 * time.Second},
+		Log:         LogConfig{Level: "info"},
+		DBType:      "memory",
+		AutoMigrate: true,
+		Memory: MemoryConfig{
+			CompactInterval: 5 * time.Minute,
+		},
+		MySQL: MySQLConfig{
+			Host:               "localhost",
+			Port:               3306,
+			Database:           "gocart",
+			User:               "gocart",
+			Password:           "secret",
+			MaxConnections:     20,
+			MaxIdleConnections: 5,
+			ConnMaxLifetime:    time.Hour,
+		},
+		Postgres: PostgresConfig{
+			Host:               "localhost",
+			Port:               5432,
+			Database:           "gocart",
+			User:               "gocart",
+			Password:           "secret",
+			SSLMode:            "disable",
+			MaxConnections:     20,
+			MaxIdleConnections: 5,
+			ConnMaxLifetime:    time.Hour,
+		},
+		SQLite: SQLiteConfig{
+			Path:        "data/gocart.db",
+			BusyTimeout: 5 * time.Second,
+		},
+		DynamoDB: DynamoDBConfig{
+			Region:          "us-east-1",
+			AccessKeyID:     "fakekey",
+			SecretAccessKey: "fakesecret",
+			ProductsTable:   "Products",
+			CartsTable:      "Carts",
+			PromotionsTable: "Promotions",
+			VerifyTables:    true,
+		},
+		Cache: CacheConfig{
+			Backend: "none",
+			TTL:     5 * time.Minute,
+			Size:    10000,
+			Redis: RedisConfig{
+				Addr:     "localhost:6379",
+				Timeout:  100 * time.Millisecond,
+				PoolSize: 10,
+			},
+		},
+		Resilience: ResilienceConfig{
+			RetryMaxAttempts:   3,
+			RetryBaseDelay:     50 * time.Millisecond,
+			RetryMaxDelay:      time.Second,
+			BreakerThreshold:   5,
+			BreakerOpenTimeout: 30 * time.Second,
+		},
+		Carts: CartsConfig{
+			TTL:              7 * 24 * time.Hour,
+			ExpiredRetention: 24 * time.Hour,
+			SweepInterval:    10 * time.Minute,
+			SweepBatchSize:   500,
+			MaxLineQuantity:  999,
+			MaxLines:         100,
+		},
+		Abandoned: AbandonedConfig{
+			CheckInterval: 5 * time.Minute,
+			BatchSize:     100,
+		},
+		Notify: NotifyConfig{
+			Backend: "log",
+			Webhook: WebhookConfig{Timeout: 5 * time.Second},
+			SMTP: SMTPConfig{
+				Addr:    "localhost:1025",
+				From:    "carts@example.com",
+				To:      "customer-{customer_id}@example.com",
+				Timeout: 10 * time.Second,
+			},
+		},
+		Pricing: PricingConfig{
+			BaseCurrency: "USD",
+		},
+	}
+}
+
+// setting binds one configuration field to its environment variable and flag
+type setting struct {
+	env    string
+	flag   string
+	usage  string
+	value  any // pointer to the Config field
+	secret bool
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{env: "PORT", flag: "port", usage: "HTTP listen port", value: &c.Server.Port},
+		{env: "REQUEST_TIMEOUT", flag: "request-timeout", usage: "deadline for handling a request (0 disables)", value: &c.Server.RequestTimeout},
+		{env: "SHUTDOWN_TIMEOUT", flag: "shutdown-timeout", usage: "how long in-flight requests may finish when stopping", value: &c.Server.ShutdownTimeout},
+		{env: "DEBUG_VARS", flag: "debug-vars", usage: "serve expvar metrics at /debug/vars", value: &c.Server.DebugVars},
+		{env: "LOG_LEVEL", flag: "log-level", usage: "log level (debug, info, warn, error)", value: &c.Log.Level},
+		{env: "DB_TYPE", flag: "db-type", usage: "storage backend (memory, mysql, postgres, sqlite, dynamo)", value: &c.DBType},
+		{env: "DB_AUTO_MIGRATE", flag: "db-auto-migrate", usage: "apply pending SQL migrations at startup", value: &c.AutoMigrate},
+
+		{env: "MEMORY_DATA_DIR", flag: "memory-data-dir", usage: "directory to persist the memory backend to (empty disables persistence)", value: &c.Memory.DataDir},
+		{env: "MEMORY_SYNC", flag: "memory-sync", usage: "flush every memory backend change to disk before responding", value: &c.Memory.Sync},
+		{env: "MEMORY_COMPACT_INTERVAL", flag: "memory-compact-interval", usage: "how often the memory backend log is compacted into a snapshot", value: &c.Memory.CompactInterval},
+
+		{env: "MYSQL_HOST", flag: "mysql-host", usage: "MySQL host", value: &c.MySQL.Host},
+		{env: "MYSQL_PORT", flag: "mysql-port", usage: "MySQL port", value: &c.MySQL.Port},
+		{env: "MYSQL_DATABASE", flag: "mysql-database", usage: "MySQL database name", value: &c.MySQL.Database},
+		{env: "MYSQL_USER", flag: "mysql-user", usage: "MySQL user", value: &c.MySQL.User},
+		{env: "MYSQL_PASSWORD", flag: "mysql-password", usage: "MySQL password", value: &c.MySQL.Password, secret: true},
+		{env: "MYSQL_MAX_CONNECTIONS", flag: "mysql-max-connections", usage: "maximum open MySQL connections", value: &c.MySQL.MaxConnections},
+		{env: "MYSQL_MAX_IDLE_CONNECTIONS", flag: "mysql-max-idle-connections", usage: "maximum idle MySQL connections", value: &c.MySQL.MaxIdleConnections},
+		{env: "MYSQL_CONN_MAX_LIFETIME", flag: "mysql-conn-max-lifetime", usage: "maximum lifetime of a MySQL connection", value: &c.MySQL.ConnMaxLifetime},
+
+		{env: "POSTGRES_HOST", flag: "postgres-host", usage: "PostgreSQL host", value: &c.Postgres.Host},
+		{env: "POSTGRES_PORT", flag: "postgres-port", usage: "PostgreSQL port", value: &c.Postgres.Port},
+		{env: "POSTGRES_DATABASE", flag: "postgres-database", usage: "PostgreSQL database name", value: &c.Postgres.Database},
+		{env: "POSTGRES_USER", flag: "postgres-user", usage: "PostgreSQL user", value: &c.Postgres.User},
+		{env: "POSTGRES_PASSWORD", flag: "postgres-password", usage: "PostgreSQL password", value: &c.Postgres.Password, secret: true},
+		{env: "POSTGRES_SSLMODE", flag: "postgres-sslmode", usage: "PostgreSQL sslmode (disable, require, verify-ca, verify-full)", value: &c.Postgres.SSLMode},
+		{env: "POSTGRES_MAX_CONNECTIONS", flag: "postgres-max-connections", usage: "maximum open PostgreSQL connections", value: &c.Postgres.MaxConnections},
+		{env: "POSTGRES_MAX_IDLE_CONNECTIONS", flag: "postgres-max-idle-connections", usage: "maximum idle PostgreSQL connections", value: &c.Postgres.MaxIdleConnections},
+		{env: "POSTGRES_CONN_MAX_LIFETIME", flag: "postgres-conn-max-lifetime", usage: "maximum lifetime of a PostgreSQL connection", value: &c.Postgres.ConnMaxLifetime},
+
+		{env: "SQLITE_PATH", flag: "sqlite-path", usage: "SQLite database file", value: &c.SQLite.Path},
+		{env: "SQLITE_BUSY_TIMEOUT", flag: "sqlite-busy-timeout", usage: "how long SQLite writes wait for a lock", value: &c.SQLite.BusyTimeout},
+
+		{env: "DYNAMODB_REGION", flag: "dynamodb-region", usage: "DynamoDB region", value: &c.DynamoDB.Region},
+		{env: "DYNAMODB_ENDPOINT", flag: "dynamodb-endpoint", usage: "DynamoDB Local endpoint (empty for AWS)", value: &c.DynamoDB.Endpoint},
+		{env: "AWS_ACCESS_KEY_ID", flag: "dynamodb-access-key-id", usage: "access key for DynamoDB Local", value: &c.DynamoDB.AccessKeyID, secret: true},
+		{env: "AWS_SECRET_ACCESS_KEY", flag: "dynamodb-secret-access-key", usage: "secret key for DynamoDB Local", value: &c.DynamoDB.SecretAccessKey, secret: true},
+		{env: "DYNAMODB_TABLE_PREFIX", flag: "dynamodb-table-prefix", usage: "prefix prepended to every DynamoDB table name", value: &c.DynamoDB.TablePrefix},
+		{env: "DYNAMODB_PRODUCTS_TABLE", flag: "dynamodb-products-table", usage: "DynamoDB products table name", value: &c.DynamoDB.ProductsTable},
+		{env: "DYNAMODB_CARTS_TABLE", flag: "dynamodb-carts-table", usage: "DynamoDB carts table name", value: &c.DynamoDB.CartsTable},
+		{env: "DYNAMODB_PROMOTIONS_TABLE", flag: "dynamodb-promotions-table", usage: "DynamoDB promotions table name", value: &c.DynamoDB.PromotionsTable},
+		{env: "DYNAMODB_CREATE_TABLES", flag: "dynamodb-create-tables", usage: "create missing DynamoDB tables and indexes at startup", value: &c.DynamoDB.CreateTables},
+		{env: "DYNAMODB_VERIFY_TABLES", flag: "dynamodb-verify-tables", usage: "verify DynamoDB key schemas at startup", value: &c.DynamoDB.VerifyTables},
+
+		{env: "CACHE_BACKEND", flag: "cache-backend", usage: "product cache (none, memory, redis)", value: &c.Cache.Backend},
+		{env: "CACHE_TTL", flag: "cache-ttl", usage: "how long products stay cached", value: &c.Cache.TTL},
+		{env: "CACHE_SIZE", flag: "cache-size", usage: "maximum products held by the memory cache", value: &c.Cache.Size},
+		{env: "REDIS_ADDR", flag: "redis-addr", usage: "Redis host:port for the redis cache", value: &c.Cache.Redis.Addr},
+		{env: "REDIS_PASSWORD", flag: "redis-password", usage: "Redis password", value: &c.Cache.Redis.Password, secret: true},
+		{env: "REDIS_DB", flag: "redis-db", usage: "Redis database number", value: &c.Cache.Redis.DB},
+		{env: "REDIS_TIMEOUT", flag: "redis-timeout", usage: "timeout for connecting to Redis and each command", value: &c.Cache.Redis.Timeout},
+		{env: "REDIS_POOL_SIZE", flag: "redis-pool-size", usage: "maximum open Redis connections", value: &c.Cache.Redis.PoolSize},
+
+		{env: "RETRY_MAX_ATTEMPTS", flag: "retry-max-attempts", usage: "tries per database call, including the first (1 disables retries)", value: &c.Resilience.RetryMaxAttempts},
+		{env: "RETRY_BASE_DELAY", flag: "retry-base-delay", usage: "backoff before the first retry, doubled for each further retry", value: &c.Resilience.RetryBaseDelay},
+		{env: "RETRY_MAX_DELAY", flag: "retry-max-delay", usage: "maximum backoff between retries", value: &c.Resilience.RetryMaxDelay},
+		{env: "BREAKER_THRESHOLD", flag: "breaker-threshold", usage: "consecutive database failures that open the circuit breaker (0 disables it)", value: &c.Resilience.BreakerThreshold},
+		{env: "BREAKER_OPEN_TIMEOUT", flag: "breaker-open-timeout", usage: "how long an open circuit breaker fails fast", value: &c.Resilience.BreakerOpenTimeout},
+
+		{env: "CART_TTL", flag: "cart-ttl", usage: "how long a cart lives after its last change (0 keeps carts forever)", value: &c.Carts.TTL},
+		{env: "CART_EXPIRED_RETENTION", flag: "cart-expired-retention", usage: "how long expired carts are kept, answering 410 Gone, before deletion", value: &c.Carts.ExpiredRetention},
+		{env: "CART_SWEEP_INTERVAL", flag: "cart-sweep-interval", usage: "how often expired carts are deleted", value: &c.Carts.SweepInterval},
+		{env: "CART_SWEEP_BATCH_SIZE", flag: "cart-sweep-batch-size", usage: "maximum expired carts deleted per statement", value: &c.Carts.SweepBatchSize},
+		{env: "CART_SINGLE_ACTIVE", flag: "cart-single-active", usage: "give each customer one open cart, returned instead of creating another", value: &c.Carts.SingleActive},
+		{env: "CART_MAX_LINE_QUANTITY", flag: "cart-max-line-quantity", usage: "most of one product a cart may hold (0 for no limit)", value: &c.Carts.MaxLineQuantity},
+		{env: "CART_MAX_LINES", flag: "cart-max-lines", usage: "most different products a cart may hold (0 for no limit)", value: &c.Carts.MaxLines},
+
+		{env: "ABANDONED_CART_AFTER", flag: "abandoned-cart-after", usage: "how long a cart with items must be unchanged to send a reminder (0 disables reminders)", value: &c.Abandoned.After},
+		{env: "ABANDONED_CART_CHECK_INTERVAL", flag: "abandoned-cart-check-interval", usage: "how often abandoned carts are looked for", value: &c.Abandoned.CheckInterval},
+		{env: "ABANDONED_CART_BATCH_SIZE", flag: "abandoned-cart-batch-size", usage: "maximum abandoned carts read at a time", value: &c.Abandoned.BatchSize},
+
+		{env: "NOTIFY_BACKEND", flag: "notify-backend", usage: "where events are delivered (log, webhook, smtp)", value: &c.Notify.Backend},
+		{env: "NOTIFY_WEBHOOK_URL", flag: "notify-webhook-url", usage: "URL events are posted to", value: &c.Notify.Webhook.URL, secret: true},
+		{env: "NOTIFY_WEBHOOK_TIMEOUT", flag: "notify-webhook-timeout", usage: "timeout for posting one event", value: &c.Notify.Webhook.Timeout},
+		{env: "NOTIFY_SMTP_ADDR", flag: "notify-smtp-addr", usage: "SMTP server host:port", value: &c.Notify.SMTP.Addr},
+		{env: "NOTIFY_SMTP_FROM", flag: "notify-smtp-from", usage: "sender address of emails", value: &c.Notify.SMTP.From},
+		{env: "NOTIFY_SMTP_TO", flag: "notify-smtp-to", usage: "recipient address, with {customer_id} replaced by the customer's ID", value: &c.Notify.SMTP.To},
+		{env: "NOTIFY_SMTP_TIMEOUT", flag: "notify-smtp-timeout", usage: "timeout for sending one email", value: &c.Notify.SMTP.Timeout},
+
+		{env: "TAX_RATES_FILE", flag: "tax-rates-file", usage: "YAML table of tax rates (empty leaves carts untaxed)", value: &c.Pricing.TaxRatesFile},
+		{env: "SHIPPING_RATES_FILE", flag: "shipping-rates-file", usage: "YAML table of shipping rates (empty charges nothing for shipping)", value: &c.Pricing.ShippingRatesFile},
+		{env: "BASE_CURRENCY", flag: "base-currency", usage: "ISO 4217 code of the currency prices are given in", value: &c.Pricing.BaseCurrency},
+		{env: "EXCHANGE_RATES_FILE", flag: "exchange-rates-file", usage: "YAML list of exchange rates against the base currency (empty prices carts in the base currency only)", value: &c.Pricing.ExchangeRatesFile},
+	}
+}
+
+// Load builds the configuration from defaults, an optional YAML file,
+// environment variables and command-line flags, in increasing order of precedence.
+// The result is validated; any invalid value is reported as an error.
+func Load(name string, args []string, getenv func(string) string) (*Config, Options, error) {
+	return LoadFlagSet(flag.NewFlagSet(name, flag.ContinueOnError), args, getenv)
+}
+
+// LoadFlagSet is Load with a flag set the caller has already defined flags of its
+// own on, such as a subcommand's. The flag set must use flag.ContinueOnError.
+func LoadFlagSet(fs *flag.FlagSet, args []string, getenv func(string) string) (*Config, Options, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	// Flags are recorded during parsing and applied last so they override everything else
+	type flagValue struct {
+		setting setting
+		raw     string
+	}
+	var flagValues []flagValue
+	var opts Options
+
+	fs.StringVar(&opts.File, "config", getenv("CONFIG_FILE"), "path to a YAML config file (env CONFIG_FILE)")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective configuration with secrets redacted and exit")
+	for _, s := range settings {
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		record := func(raw string) error {
+			flagValues = append(flagValues, flagValue{setting: s, raw: raw})
+			return nil
+		}
+		// Boolean flags may be given bare, as --debug-vars, meaning true
+		if _, ok := s.value.(*bool); ok {
+			fs.BoolFunc(s.flag, usage, record)
+		} else {
+			fs.Func(s.flag, usage, record)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, opts, err
+	}
+	if fs.NArg() > 0 {
+		return nil, opts, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	if opts.File != "" {
+		if err := cfg.loadFile(opts.File); err != nil {
+			return nil, opts, err
+		}
+	}
+
+	var errs []error
+	for _, s := range settings {
+		if raw := getenv(s.env); raw != "" {
+			if err := setValue(s.value, raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+			}
+		}
+	}
+	for _, fv := range flagValues {
+		if err := setValue(fv.setting.value, fv.raw); err != nil {
+			errs = append(errs, fmt.Errorf("--%s: %w", fv.setting.flag, err))
+		}
+	}
+	errs = append(errs, cfg.Validate())
+	if err := errors.Join(errs...); err != nil {
+		return nil, opts, err
+	}
+
+	return cfg, opts, nil
+}
+
+// loadFile overlays the values present in a YAML file onto the configuration
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// setValue parses raw into the field pointed to by value
+func setValue(value any, raw string) error {
+	switch v := value.(type) {
+	case *string:
+		*v = raw
+	case *int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		*v = n
+	case *bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		*v = b
+	case *time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		*v = d
+	default:
+		return fmt.Errorf("unsupported setting type %T", value)
+	}
+	return nil
+}
+
+// Redacted returns a copy of the configuration with secrets masked
+func (c *Config) Redacted() *Config {
+	cp := *c
+	for _, s := range cp.settings() {
+		if v, ok := s.value.(*string); ok && s.secret && *v != "" {
+			*v = redacted
+		}
+	}
+	return &cp
+}
+
+// Write prints the configuration as YAML
+func (c *Config) Write(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+	return enc.Close()
+}
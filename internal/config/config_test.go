@@ -0,0 +1,183 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/config"
+)
+
+// writeConfigFile writes data to a YAML file in a temporary directory and returns its path
+func writeConfigFile(t *testing.T, data string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// envFunc looks variables up in env
+func envFunc(env map[string]string) func(string) string {
+	return func(key string) string { return env[key] }
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeConfigFile(t, `
+server:
+  port: 9000
+  request_timeout: 5s
+log:
+  level: warn
+`)
+	otherFile := writeConfigFile(t, "server:\n  port: 9500\n")
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		args    []string
+		port    int
+		timeout time.Duration
+		level   string
+	}{
+		{name: "defaults", port: 8080, timeout: 10 * time.Second, level: "info"},
+		{name: "file", args: []string{"--config", file}, port: 9000, timeout: 5 * time.Second, level: "warn"},
+		{name: "file from env", env: map[string]string{"CONFIG_FILE": file}, port: 9000, timeout: 5 * time.Second, level: "warn"},
+		{
+			name: "flag names the file over env",
+			env:  map[string]string{"CONFIG_FILE": file},
+			args: []string{"--config", otherFile},
+			port: 9500, timeout: 10 * time.Second, level: "info",
+		},
+		{
+			name: "env over file",
+			env:  map[string]string{"PORT": "9100", "LOG_LEVEL": "debug"},
+			args: []string{"--config", file},
+			port: 9100, timeout: 5 * time.Second, level: "debug",
+		},
+		{name: "empty env is unset", env: map[string]string{"PORT": ""}, args: []string{"--config", file}, port: 9000, timeout: 5 * time.Second, level: "warn"},
+		{
+			name: "flag over env and file",
+			env:  map[string]string{"PORT": "9100", "REQUEST_TIMEOUT": "1s"},
+			args: []string{"--config", file, "--port", "9200"},
+			port: 9200, timeout: time.Second, level: "warn",
+		},
+		{name: "last flag wins", args: []string{"--port", "9300", "--port=9400"}, port: 9400, timeout: 10 * time.Second, level: "info"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _, err := config.Load("test", tt.args, envFunc(tt.env))
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.Server.Port != tt.port || cfg.Server.RequestTimeout != tt.timeout || cfg.Log.Level != tt.level {
+				t.Fatalf("port, request timeout, log level = %d, %v, %s; want %d, %v, %s",
+					cfg.Server.Port, cfg.Server.RequestTimeout, cfg.Log.Level, tt.port, tt.timeout, tt.level)
+			}
+		})
+	}
+}
+
+func TestLoadBooleanFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		// debugVars, sync, singleActive and autoMigrate are the settings expected
+		debugVars, sync, singleActive, autoMigrate bool
+	}{
+		{name: "defaults", autoMigrate: true},
+		{
+			name:      "bare",
+			args:      []string{"--debug-vars", "--memory-sync", "--cart-single-active"},
+			debugVars: true, sync: true, singleActive: true, autoMigrate: true,
+		},
+		{name: "bare before another flag", args: []string{"--debug-vars", "--port", "9000"}, debugVars: true, autoMigrate: true},
+		{name: "single dash", args: []string{"-memory-sync"}, sync: true, autoMigrate: true},
+		{name: "explicit true", args: []string{"--debug-vars=true", "--memory-sync=1"}, debugVars: true, sync: true, autoMigrate: true},
+		{name: "explicit false", args: []string{"--db-auto-migrate=false"}},
+		{
+			name:         "false over env",
+			env:          map[string]string{"DEBUG_VARS": "true", "CART_SINGLE_ACTIVE": "true"},
+			args:         []string{"--debug-vars=false"},
+			singleActive: true, autoMigrate: true,
+		},
+		{name: "bare over env", env: map[string]string{"MEMORY_SYNC": "false"}, args: []string{"--memory-sync"}, sync: true, autoMigrate: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _, err := config.Load("test", tt.args, envFunc(tt.env))
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.Server.DebugVars != tt.debugVars || cfg.Memory.Sync != tt.sync || cfg.Carts.SingleActive != tt.singleActive || cfg.AutoMigrate != tt.autoMigrate {
+				t.Fatalf("debug vars, memory sync, single active, auto migrate = %t, %t, %t, %t; want %t, %t, %t, %t",
+					cfg.Server.DebugVars, cfg.Memory.Sync, cfg.Carts.SingleActive, cfg.AutoMigrate,
+					tt.debugVars, tt.sync, tt.singleActive, tt.autoMigrate)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		// want holds a substring of each error reported
+		want []string
+	}{
+		{name: "integer in env", env: map[string]string{"PORT": "http"}, want: []string{`PORT: invalid integer "http"`}},
+		{name: "boolean in env", env: map[string]string{"DEBUG_VARS": "maybe"}, want: []string{`DEBUG_VARS: invalid boolean "maybe"`}},
+		{name: "boolean in flag", args: []string{"--memory-sync=maybe"}, want: []string{`--memory-sync: invalid boolean "maybe"`}},
+		{name: "duration in flag", args: []string{"--request-timeout", "10"}, want: []string{`--request-timeout: invalid duration "10"`}},
+		{name: "port out of range", args: []string{"--port", "70000"}, want: []string{"server.port must be between 1 and 65535, got 70000"}},
+		{name: "negative request timeout", env: map[string]string{"REQUEST_TIMEOUT": "-1s"}, want: []string{"server.request_timeout cannot be negative"}},
+		{name: "no shutdown timeout", args: []string{"--shutdown-timeout", "0s"}, want: []string{"server.shutdown_timeout must be positive"}},
+		{name: "log level", env: map[string]string{"LOG_LEVEL": "loud"}, want: []string{"log.level"}},
+		{name: "db type", args: []string{"--db-type", "oracle"}, want: []string{`db_type must be one of memory, mysql, postgres, sqlite, dynamo, got "oracle"`}},
+		{name: "unknown file field", file: "server:\n  hostname: example.com\n", want: []string{"field hostname not found"}},
+		{name: "file value of the wrong type", file: "server:\n  port: eighty\n", want: []string{"parse config file"}},
+		{name: "missing file", args: []string{"--config", "missing.yaml"}, want: []string{"open config file"}},
+		{name: "unknown flag", args: []string{"--no-such-flag"}, want: []string{"flag provided but not defined"}},
+		{name: "extra argument", args: []string{"serve"}, want: []string{"unexpected arguments: [serve]"}},
+		{
+			name: "every error at once",
+			env:  map[string]string{"PORT": "0", "LOG_LEVEL": "loud"},
+			args: []string{"--request-timeout", "soon"},
+			want: []string{"server.port must be between 1 and 65535, got 0", "log.level", `--request-timeout: invalid duration "soon"`},
+		},
+		{
+			name: "backend settings checked for the chosen backend",
+			env:  map[string]string{"DB_TYPE": "sqlite", "SQLITE_PATH": ""},
+			args: []string{"--sqlite-path", ""},
+			want: []string{"sqlite.path is required"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"--config", writeConfigFile(t, tt.file)}, args...)
+			}
+
+			_, _, err := config.Load("test", args, envFunc(tt.env))
+			if err == nil {
+				t.Fatal("Load succeeded, want an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Fatalf("Load error = %v, want one containing %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/logging"
+	"github.com/LuoZihYuan/Go-Cart/internal/pricing"
+)
+
+// Validate reports every invalid value in the configuration
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(validPort(c.Server.Port), "server.port must be between 1 and 65535, got %d", c.Server.Port)
+	check(c.Server.RequestTimeout >= 0, "server.request_timeout cannot be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: %w", err))
+	}
+
+	switch c.DBType {
+	case "memory":
+		check(c.Memory.DataDir == "" || c.Memory.CompactInterval > 0,
+			"memory.compact_interval must be positive when memory.data_dir is set")
+	case "mysql":
+		check(c.MySQL.Host != "", "mysql.host is required")
+		check(validPort(c.MySQL.Port), "mysql.port must be between 1 and 65535, got %d", c.MySQL.Port)
+		check(c.MySQL.Database != "", "mysql.database is required")
+		check(c.MySQL.User != "", "mysql.user is required")
+		check(c.MySQL.MaxConnections >= 1, "mysql.max_connections must be at least 1, got %d", c.MySQL.MaxConnections)
+		check(c.MySQL.MaxIdleConnections >= 0 && c.MySQL.MaxIdleConnections <= c.MySQL.MaxConnections,
+			"mysql.max_idle_connections must be between 0 and max_connections (%d), got %d",
+			c.MySQL.MaxConnections, c.MySQL.MaxIdleConnections)
+		check(c.MySQL.ConnMaxLifetime >= 0, "mysql.conn_max_lifetime cannot be negative")
+	case "postgres":
+		check(c.Postgres.Host != "", "postgres.host is required")
+		check(validPort(c.Postgres.Port), "postgres.port must be between 1 and 65535, got %d", c.Postgres.Port)
+		check(c.Postgres.Database != "", "postgres.database is required")
+		check(c.Postgres.User != "", "postgres.user is required")
+		check(validSSLMode(c.Postgres.SSLMode),
+			"postgres.sslmode must be one of disable, allow, prefer, require, verify-ca, verify-full, got %q", c.Postgres.SSLMode)
+		check(c.Postgres.MaxConnections >= 1, "postgres.max_connections must be at least 1, got %d", c.Postgres.MaxConnections)
+		check(c.Postgres.MaxIdleConnections >= 0 && c.Postgres.MaxIdleConnections <= c.Postgres.MaxConnections,
+			"postgres.max_idle_connections must be between 0 and max_connections (%d), got %d",
+			c.Postgres.MaxConnections, c.Postgres.MaxIdleConnections)
+		check(c.Postgres.ConnMaxLifetime >= 0, "postgres.conn_max_lifetime cannot be negative")
+	case "sqlite":
+		check(c.SQLite.Path != "", "sqlite.path is required")
+		check(c.SQLite.BusyTimeout >= 0, "sqlite.busy_timeout cannot be negative")
+	case "dynamo":
+		check(c.DynamoDB.Region != "", "dynamodb.region is required")
+		check(c.DynamoDB.ProductsTable != "", "dynamodb.products_table is required")
+		check(c.DynamoDB.CartsTable != "", "dynamodb.carts_table is required")
+		check(c.DynamoDB.PromotionsTable != "", "dynamodb.promotions_table is required")
+		check(c.DynamoDB.ProductsTable != c.DynamoDB.CartsTable &&
+			c.DynamoDB.ProductsTable != c.DynamoDB.PromotionsTable &&
+			c.DynamoDB.CartsTable != c.DynamoDB.PromotionsTable,
+			"dynamodb.products_table, dynamodb.carts_table and dynamodb.promotions_table must differ")
+	default:
+		errs = append(errs, fmt.Errorf("db_type must be one of memory, mysql, postgres, sqlite, dynamo, got %q", c.DBType))
+	}
+
+	switch c.Cache.Backend {
+	case "none":
+	case "memory":
+		check(c.Cache.TTL > 0, "cache.ttl must be positive")
+		check(c.Cache.Size >= 1, "cache.size must be at least 1, got %d", c.Cache.Size)
+	case "redis":
+		check(c.Cache.TTL > 0, "cache.ttl must be positive")
+		check(c.Cache.Redis.Addr != "", "cache.redis.addr is required")
+		check(c.Cache.Redis.DB >= 0, "cache.redis.db cannot be negative")
+		check(c.Cache.Redis.Timeout > 0, "cache.redis.timeout must be positive")
+		check(c.Cache.Redis.PoolSize >= 1, "cache.redis.pool_size must be at least 1, got %d", c.Cache.Redis.PoolSize)
+	default:
+		errs = append(errs, fmt.Errorf("cache.backend must be one of none, memory, redis, got %q", c.Cache.Backend))
+	}
+
+	check(c.Resilience.RetryMaxAttempts >= 1, "resilience.retry_max_attempts must be at least 1, got %d", c.Resilience.RetryMaxAttempts)
+	if c.Resilience.RetryMaxAttempts > 1 {
+		check(c.Resilience.RetryBaseDelay > 0, "resilience.retry_base_delay must be positive")
+		check(c.Resilience.RetryMaxDelay >= c.Resilience.RetryBaseDelay,
+			"resilience.retry_max_delay must be at least retry_base_delay (%s), got %s",
+			c.Resilience.RetryBaseDelay, c.Resilience.RetryMaxDelay)
+	}
+	check(c.Resilience.BreakerThreshold >= 0, "resilience.breaker_threshold cannot be negative")
+	check(c.Resilience.BreakerThreshold == 0 || c.Resilience.BreakerOpenTimeout > 0,
+		"resilience.breaker_open_timeout must be positive when the breaker is enabled")
+
+	// Expiry times are kept to the second
+	check(c.Carts.TTL == 0 || c.Carts.TTL >= time.Second, "carts.ttl must be 0 or at least 1s, got %s", c.Carts.TTL)
+	if c.Carts.TTL > 0 {
+		check(c.Carts.ExpiredRetention >= 0, "carts.expired_retention cannot be negative")
+		check(c.Carts.SweepInterval > 0, "carts.sweep_interval must be positive")
+		check(c.Carts.SweepBatchSize >= 1, "carts.sweep_batch_size must be at least 1, got %d", c.Carts.SweepBatchSize)
+	}
+	check(c.Carts.MaxLineQuantity >= 0, "carts.max_line_quantity cannot be negative")
+	check(c.Carts.MaxLines >= 0, "carts.max_lines cannot be negative")
+
+	// Reminders are recorded to the second, like the times they are compared with
+	check(c.Abandoned.After == 0 || c.Abandoned.After >= time.Second, "abandoned_carts.after must be 0 or at least 1s, got %s", c.Abandoned.After)
+	if c.Abandoned.After > 0 {
+		check(c.Carts.TTL == 0 || c.Abandoned.After < c.Carts.TTL,
+			"abandoned_carts.after must be shorter than carts.ttl (%s), got %s", c.Carts.TTL, c.Abandoned.After)
+		check(c.Abandoned.CheckInterval > 0, "abandoned_carts.check_interval must be positive")
+		check(c.Abandoned.BatchSize >= 1, "abandoned_carts.batch_size must be at least 1, got %d", c.Abandoned.BatchSize)
+
+		switch c.Notify.Backend {
+		case "log":
+		case "webhook":
+			u, err := url.Parse(c.Notify.Webhook.URL)
+			check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+				"notify.webhook.url must be an http or https URL, got %q", c.Notify.Webhook.URL)
+			check(c.Notify.Webhook.Timeout > 0, "notify.webhook.timeout must be positive")
+		case "smtp":
+			_, _, err := net.SplitHostPort(c.Notify.SMTP.Addr)
+			check(err == nil, "notify.smtp.addr must be host:port, got %q", c.Notify.SMTP.Addr)
+			check(c.Notify.SMTP.From != "", "notify.smtp.from is required")
+			check(c.Notify.SMTP.To != "", "notify.smtp.to is required")
+			check(c.Notify.SMTP.Timeout > 0, "notify.smtp.timeout must be positive")
+		default:
+			errs = append(errs, fmt.Errorf("notify.backend must be one of log, webhook, smtp, got %q", c.Notify.Backend))
+		}
+	}
+
+	_, ok := pricing.MinorUnits(c.Pricing.BaseCurrency)
+	check(ok, "pricing.base_currency must be a supported ISO 4217 code in upper case, got %q", c.Pricing.BaseCurrency)
+
+	return errors.Join(errs...)
+}
+
+func validPort(port int) bool {
+	return port >= 1 && port <= 65535
+}
+
+func validSSLMode(mode string) bool {
+	switch mode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package datacopy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Checkpoint records how far a copy has got, so an interrupted copy can resume.
+// It is saved after every batch has been written to the destination.
+type Checkpoint struct {
+	Source      string    `json:"source"`
+	Destination string    `json:"destination"`
+	Products    Progress  `json:"products"`
+	Carts       Progress  `json:"carts"`
+	Promotions  Progress  `json:"promotions"`
+	Redemptions Progress  `json:"redemptions"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Progress is how far one kind of record has been copied
+type Progress struct {
+	// Cursor is where listing the source resumes; empty before the first batch
+	Cursor string `json:"cursor,omitempty"`
+	Done   bool   `json:"done"`
+	// Copied summarises the records written so far
+	Copied Digest `json:"copied"`
+}
+
+// loadCheckpoint reads the checkpoint at path, returning nil if there is none
+func loadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("parse checkpoint %s: %w", path, err)
+	}
+	return &checkpoint, nil
+}
+
+// save writes the checkpoint beside path and renames it into place,
+// so an interrupted save leaves the previous checkpoint intact
+func (c *Checkpoint) save(path string) error {
+	c.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,264 @@
+// Package datacopy copies products, carts and promotions between storage backends
+// through the repository interfaces, and verifies that two backends hold the same data.
+package datacopy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+)
+
+// ErrCheckpointMismatch is returned when a checkpoint was written by a copy between other backends
+var ErrCheckpointMismatch = errors.New("checkpoint belongs to a different copy")
+
+// Backend is the repositories of one storage backend
+type Backend struct {
+	// Name identifies the backend in logs and checkpoints, e.g. its DB_TYPE
+	Name       string
+	Products   repository.ProductRepository
+	Carts      repository.CartRepository
+	Promotions repository.PromotionRepository
+}
+
+// Options control a copy
+type Options struct {
+	// BatchSize is how many records are listed, written and checkpointed at a time
+	BatchSize int
+	// Concurrency is how many writes of a batch are in flight at once
+	Concurrency int
+	// Checkpoint is the file progress is saved to and resumed from; empty disables resuming
+	Checkpoint string
+	// DryRun reads the source and checks which records the destination already has, without writing
+	DryRun bool
+}
+
+// Report is the outcome of a copy
+type Report struct {
+	Products    Result
+	Carts       Result
+	Promotions  Result
+	Redemptions Result
+}
+
+// Result is the outcome of copying one kind of record
+type Result struct {
+	// Copied summarises the records read from the source and, unless it was a
+	// dry run, written to the destination, including any copied before resuming
+	Copied Digest
+	// Existing counts the records a dry run found already in the destination, which a copy would overwrite
+	Existing int64
+}
+
+// kind describes how to copy one kind of record
+type kind[T any] struct {
+	name      string
+	list      func(ctx context.Context, cursor string, limit int) ([]T, string, error)
+	write     func(ctx context.Context, record T) error
+	exists    func(ctx context.Context, record T) (bool, error)
+	id        func(record T) string
+	canonical func(record T) any
+}
+
+func productKind(src, dst Backend) kind[models.Product] {
+	return kind[models.Product]{
+		name: "products",
+		list: src.Products.List,
+		write: func(ctx context.Context, product models.Product) error {
+			return dst.Products.Upsert(ctx, &product)
+		},
+		exists: func(ctx context.Context, product models.Product) (bool, error) {
+			return dst.Products.Exists(ctx, product.ProductID)
+		},
+		id:        func(product models.Product) string { return strconv.Itoa(product.ProductID) },
+		canonical: func(product models.Product) any { return product },
+	}
+}
+
+func cartKind(src, dst Backend) kind[models.Cart] {
+	return kind[models.Cart]{
+		name: "carts",
+		list: src.Carts.List,
+		write: func(ctx context.Context, cart models.Cart) error {
+			return dst.Carts.Put(ctx, &cart)
+		},
+		exists: func(ctx context.Context, cart models.Cart) (bool, error) {
+			_, err := dst.Carts.GetByID(ctx, cart.CartID)
+			if errors.Is(err, repository.ErrCartNotFound) {
+				return false, nil
+			}
+			return err == nil, err
+		},
+		id:        func(cart models.Cart) string { return strconv.Itoa(cart.CartID) },
+		canonical: func(cart models.Cart) any { return canonicalCart(cart) },
+	}
+}
+
+func promotionKind(src, dst Backend) kind[models.Promotion] {
+	return kind[models.Promotion]{
+		name: "promotions",
+		list: src.Promotions.List,
+		write: func(ctx context.Context, promotion models.Promotion) error {
+			return dst.Promotions.Put(ctx, &promotion)
+		},
+		exists: func(ctx context.Context, promotion models.Promotion) (bool, error) {
+			_, err := dst.Promotions.GetByCode(ctx, promotion.Code)
+			if errors.Is(err, repository.ErrPromotionNotFound) {
+				return false, nil
+			}
+			return err == nil, err
+		},
+		id:        func(promotion models.Promotion) string { return promotion.Code },
+		canonical: func(promotion models.Promotion) any { return canonicalPromotion(promotion) },
+	}
+}
+
+func redemptionKind(src, dst Backend) kind[models.Redemption] {
+	return kind[models.Redemption]{
+		name: "redemptions",
+		list: src.Promotions.ListRedemptions,
+		write: func(ctx context.Context, redemption models.Redemption) error {
+			return dst.Promotions.PutRedemption(ctx, &redemption)
+		},
+		exists: func(ctx context.Context, redemption models.Redemption) (bool, error) {
+			uses, err := dst.Promotions.CustomerUses(ctx, redemption.Code, redemption.CustomerID)
+			return uses > 0, err
+		},
+		id: func(redemption models.Redemption) string {
+			return fmt.Sprintf("%s of customer %d", redemption.Code, redemption.CustomerID)
+		},
+		canonical: func(redemption models.Redemption) any { return redemption },
+	}
+}
+
+// Copy writes every product, cart and promotion and then every customer's uses of
+// promotions from src to dst, keeping their IDs and codes.
+// Records already in dst are overwritten, so a copy can be repeated or resumed
+// safely; records only in dst are left alone. With a checkpoint file, a copy that
+// was interrupted resumes after the last batch it completed.
+func Copy(ctx context.Context, src, dst Backend, opts Options) (*Report, error) {
+	if opts.BatchSize < 1 || opts.Concurrency < 1 {
+		return nil, fmt.Errorf("batch size and concurrency must be at least 1")
+	}
+
+	checkpoint := &Checkpoint{Source: src.Name, Destination: dst.Name}
+	if opts.Checkpoint != "" && !opts.DryRun {
+		saved, err := loadCheckpoint(opts.Checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		if saved != nil {
+			if saved.Source != src.Name || saved.Destination != dst.Name {
+				return nil, fmt.Errorf("%w: %s was written copying %s to %s", ErrCheckpointMismatch, opts.Checkpoint, saved.Source, saved.Destination)
+			}
+			checkpoint = saved
+			slog.Info("resuming copy from checkpoint", "checkpoint", opts.Checkpoint, "updated_at", checkpoint.UpdatedAt)
+		}
+	}
+
+	report := &Report{}
+	var err error
+	report.Products, err = copyKind(ctx, productKind(src, dst), &checkpoint.Products, checkpoint, opts)
+	if err != nil {
+		return report, err
+	}
+	report.Carts, err = copyKind(ctx, cartKind(src, dst), &checkpoint.Carts, checkpoint, opts)
+	if err != nil {
+		return report, err
+	}
+	report.Promotions, err = copyKind(ctx, promotionKind(src, dst), &checkpoint.Promotions, checkpoint, opts)
+	if err != nil {
+		return report, err
+	}
+	// Redemptions follow the promotions they count the uses of
+	report.Redemptions, err = copyKind(ctx, redemptionKind(src, dst), &checkpoint.Redemptions, checkpoint, opts)
+	return report, err
+}
+
+// copyKind copies one kind of record batch by batch, saving progress after each batch
+func copyKind[T any](ctx context.Context, k kind[T], progress *Progress, checkpoint *Checkpoint, opts Options) (Result, error) {
+	var copied digest
+	if err := copied.resume(progress.Copied); err != nil {
+		return Result{}, fmt.Errorf("%s checkpoint: %w", k.name, err)
+	}
+	if progress.Done {
+		slog.Info("already copied", "records", k.name, "count", copied.count)
+		return Result{Copied: copied.Digest()}, nil
+	}
+
+	var result Result
+	for {
+		batch, next, err := k.list(ctx, progress.Cursor, opts.BatchSize)
+		if err != nil {
+			return result, fmt.Errorf("list %s: %w", k.name, err)
+		}
+
+		existing, err := writeBatch(ctx, k, batch, opts)
+		if err != nil {
+			return result, err
+		}
+		result.Existing += existing
+		for _, record := range batch {
+			if err := copied.add(k.canonical(record)); err != nil {
+				return result, err
+			}
+		}
+		result.Copied = copied.Digest()
+
+		progress.Cursor = next
+		progress.Done = next == ""
+		progress.Copied = result.Copied
+		if opts.Checkpoint != "" && !opts.DryRun {
+			if err := checkpoint.save(opts.Checkpoint); err != nil {
+				return result, fmt.Errorf("save checkpoint: %w", err)
+			}
+		}
+		slog.Info("copied batch", "records", k.name, "batch", len(batch), "total", copied.count, "dry_run", opts.DryRun)
+
+		if progress.Done {
+			return result, nil
+		}
+	}
+}
+
+// writeBatch writes a batch to the destination, or in a dry run counts how many
+// of its records the destination already has
+func writeBatch[T any](ctx context.Context, k kind[T], batch []T, opts Options) (int64, error) {
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(opts.Concurrency)
+
+	existing := make([]bool, len(batch))
+	for i, record := range batch {
+		g.Go(func() error {
+			if opts.DryRun {
+				found, err := k.exists(ctx, record)
+				if err != nil {
+					return fmt.Errorf("check %s %s: %w", k.name, k.id(record), err)
+				}
+				existing[i] = found
+				return nil
+			}
+			if err := k.write(ctx, record); err != nil {
+				return fmt.Errorf("write %s %s: %w", k.name, k.id(record), err)
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for _, found := range existing {
+		if found {
+			count++
+		}
+	}
+	return count, nil
+}
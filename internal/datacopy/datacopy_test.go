@@ -0,0 +1,329 @@
+package datacopy_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/datacopy"
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+)
+
+var errInjected = errors.New("injected failure")
+
+func newBackend(name string) datacopy.Backend {
+	return datacopy.Backend{
+		Name:       name,
+		Products:   repository.NewProductMemoryRepository(),
+		Carts:      repository.NewCartMemoryRepository(),
+		Promotions: repository.NewPromotionMemoryRepository(),
+	}
+}
+
+// seed fills a backend with products and carts, returning the cart IDs
+func seed(t *testing.T, b datacopy.Backend, products, carts int) []int {
+	t.Helper()
+	for id := 1; id <= products; id++ {
+		p := models.Product{ProductID: id, SKU: fmt.Sprintf("SKU-%d", id), Manufacturer: "Acme", CategoryID: 1, Weight: id, Price: int64(id * 100), SomeOtherID: 1}
+		if err := b.Products.Upsert(t.Context(), &p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var ids []int
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	for i := range carts {
+		cart, err := b.Carts.Create(t.Context(), i+1, "", "USD", expiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := range i % 4 {
+			if err := b.Carts.AddItem(t.Context(), cart.CartID, models.CartItem{ProductID: j + 1, Quantity: i + 1}, expiresAt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		ids = append(ids, cart.CartID)
+	}
+	return ids
+}
+
+// seedPromotions fills a backend with promotions, each redeemed by a guest and by
+// customers 1 to its index
+func seedPromotions(t *testing.T, b datacopy.Backend, promotions int) {
+	t.Helper()
+	startsAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	for i := range promotions {
+		p := models.Promotion{Code: fmt.Sprintf("CODE%d", i), Type: models.PromotionPercentOff, PercentOff: 10, StartsAt: startsAt, MaxUsesPerCustomer: 1}
+		if err := b.Promotions.Upsert(t.Context(), &p); err != nil {
+			t.Fatal(err)
+		}
+		for customerID := range i + 1 {
+			if err := b.Promotions.Redeem(t.Context(), p.Code, customerID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func verify(t *testing.T, src, dst datacopy.Backend) *datacopy.Verification {
+	t.Helper()
+	v, err := datacopy.Verify(t.Context(), src, dst, 4)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	return v
+}
+
+func TestCopy(t *testing.T) {
+	src, dst := newBackend("source"), newBackend("destination")
+	ids := seed(t, src, 10, 9)
+
+	report, err := datacopy.Copy(t.Context(), src, dst, datacopy.Options{BatchSize: 4, Concurrency: 3})
+	if err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if report.Products.Copied.Count != 10 || report.Carts.Copied.Count != 9 {
+		t.Errorf("copied %d products and %d carts, want 10 and 9", report.Products.Copied.Count, report.Carts.Copied.Count)
+	}
+
+	v := verify(t, src, dst)
+	if !v.Match() {
+		t.Fatalf("verification failed after copy: %+v", v)
+	}
+	if v.Products.Source != report.Products.Copied || v.Carts.Source != report.Carts.Copied {
+		t.Errorf("copy digests %+v don't match the source %+v", report, v)
+	}
+
+	// IDs are kept
+	for _, id := range ids {
+		want, _ := src.Carts.GetByID(t.Context(), id)
+		got, err := dst.Carts.GetByID(t.Context(), id)
+		if err != nil || got.CustomerID != want.CustomerID || len(got.Items) != len(want.Items) {
+			t.Errorf("copied cart %d = %+v, %v; want %+v", id, got, err, want)
+		}
+	}
+}
+
+func TestCopyKeepsActiveCarts(t *testing.T) {
+	src, dst := newBackend("source"), newBackend("destination")
+	seed(t, src, 2, 3)
+	now := time.Now()
+	active, _, err := src.Carts.CreateActive(t.Context(), 7, "USD", now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := datacopy.Copy(t.Context(), src, dst, datacopy.Options{BatchSize: 2, Concurrency: 2}); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	got, created, err := dst.Carts.CreateActive(t.Context(), 7, "USD", now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("CreateActive: %v", err)
+	}
+	if created || got.CartID != active.CartID {
+		t.Fatalf("CreateActive after copy = cart %d, created %t; want the copied cart %d", got.CartID, created, active.CartID)
+	}
+	if v := verify(t, src, dst); !v.Match() {
+		t.Fatalf("verification failed after copy: %+v", v)
+	}
+}
+
+func TestCopyPromotions(t *testing.T) {
+	src, dst := newBackend("source"), newBackend("destination")
+	seedPromotions(t, src, 5)
+	checkpoint := filepath.Join(t.TempDir(), "copy.json")
+
+	report, err := datacopy.Copy(t.Context(), src, dst, datacopy.Options{BatchSize: 2, Concurrency: 2, Checkpoint: checkpoint})
+	if err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	// Guests' uses count towards the code only, so customers 1 to i redeemed CODEi
+	if report.Promotions.Copied.Count != 5 || report.Redemptions.Copied.Count != 10 {
+		t.Errorf("copied %d promotions and %d redemptions, want 5 and 10", report.Promotions.Copied.Count, report.Redemptions.Copied.Count)
+	}
+
+	v := verify(t, src, dst)
+	if !v.Match() {
+		t.Fatalf("verification failed after copy: %+v", v)
+	}
+	if v.Promotions.Source != report.Promotions.Copied || v.Redemptions.Source != report.Redemptions.Copied {
+		t.Errorf("copy digests %+v don't match the source %+v", report, v)
+	}
+
+	// Uses are kept, so the copied limits hold
+	p, err := dst.Promotions.GetByCode(t.Context(), "CODE4")
+	if err != nil || p.Uses != 5 {
+		t.Fatalf("copied CODE4 = %+v, %v; want 5 uses", p, err)
+	}
+	if err := dst.Promotions.Redeem(t.Context(), "CODE4", 4); !errors.Is(err, repository.ErrPromotionCustomerUsedUp) {
+		t.Fatalf("Redeem by a customer who used the code before the copy = %v, want ErrPromotionCustomerUsedUp", err)
+	}
+
+	// A redemption changed on one side only is detected
+	if err := dst.Promotions.Release(t.Context(), "CODE4", 4); err != nil {
+		t.Fatal(err)
+	}
+	if v := verify(t, src, dst); v.Promotions.Match() || v.Redemptions.Match() {
+		t.Errorf("promotions = %+v, redemptions = %+v; want both to differ", v.Promotions, v.Redemptions)
+	}
+}
+
+func TestVerifyDetectsDifferences(t *testing.T) {
+	src, dst := newBackend("source"), newBackend("destination")
+	seed(t, src, 3, 3)
+	if _, err := datacopy.Copy(t.Context(), src, dst, datacopy.Options{BatchSize: 2, Concurrency: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Same counts, different contents
+	changed := models.Product{ProductID: 2, SKU: "changed", Manufacturer: "Acme"}
+	if err := dst.Products.Upsert(t.Context(), &changed); err != nil {
+		t.Fatal(err)
+	}
+	// An item added on one side only
+	if err := dst.Carts.AddItem(t.Context(), 1, models.CartItem{ProductID: 9, Quantity: 1}, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	v := verify(t, src, dst)
+	if v.Products.Match() || v.Products.Source.Count != v.Products.Destination.Count {
+		t.Errorf("products = %+v, want equal counts and different checksums", v.Products)
+	}
+	if v.Carts.Match() {
+		t.Errorf("carts = %+v, want a mismatch", v.Carts)
+	}
+}
+
+func TestVerifyIgnoresItemOrder(t *testing.T) {
+	src, dst := newBackend("source"), newBackend("destination")
+	items := []models.CartItem{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 2}}
+	reversed := []models.CartItem{items[1], items[0]}
+	if err := src.Carts.Put(t.Context(), &models.Cart{CartID: 5, CustomerID: 1, Items: items}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Carts.Put(t.Context(), &models.Cart{CartID: 5, CustomerID: 1, Items: reversed}); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := verify(t, src, dst); !v.Match() {
+		t.Errorf("carts with the same items in a different order do not match: %+v", v.Carts)
+	}
+}
+
+func TestCopyDryRun(t *testing.T) {
+	src, dst := newBackend("source"), newBackend("destination")
+	seed(t, src, 5, 4)
+	seedPromotions(t, src, 3)
+	// The destination already has one product, one cart, two promotions and one redemption
+	seed(t, dst, 1, 1)
+	seedPromotions(t, dst, 2)
+
+	report, err := datacopy.Copy(t.Context(), src, dst, datacopy.Options{BatchSize: 2, Concurrency: 2, DryRun: true})
+	if err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if report.Products.Copied.Count != 5 || report.Products.Existing != 1 {
+		t.Errorf("products = %+v, want 5 read and 1 existing", report.Products)
+	}
+	if report.Carts.Copied.Count != 4 || report.Carts.Existing != 1 {
+		t.Errorf("carts = %+v, want 4 read and 1 existing", report.Carts)
+	}
+	if report.Promotions.Copied.Count != 3 || report.Promotions.Existing != 2 {
+		t.Errorf("promotions = %+v, want 3 read and 2 existing", report.Promotions)
+	}
+	if report.Redemptions.Copied.Count != 3 || report.Redemptions.Existing != 1 {
+		t.Errorf("redemptions = %+v, want 3 read and 1 existing", report.Redemptions)
+	}
+
+	v := verify(t, src, dst)
+	if v.Products.Destination.Count != 1 || v.Carts.Destination.Count != 1 {
+		t.Errorf("dry run wrote to the destination: %+v", v)
+	}
+}
+
+// failingCarts fails Put once it has been called a number of times
+type failingCarts struct {
+	repository.CartRepository
+	remaining atomic.Int64
+}
+
+func (r *failingCarts) Put(ctx context.Context, cart *models.Cart) error {
+	if r.remaining.Add(-1) < 0 {
+		return errInjected
+	}
+	return r.CartRepository.Put(ctx, cart)
+}
+
+// countingCarts counts List calls
+type countingCarts struct {
+	repository.CartRepository
+	lists atomic.Int64
+}
+
+func (r *countingCarts) List(ctx context.Context, cursor string, limit int) ([]models.Cart, string, error) {
+	r.lists.Add(1)
+	return r.CartRepository.List(ctx, cursor, limit)
+}
+
+func TestCopyResumesFromCheckpoint(t *testing.T) {
+	src, dst := newBackend("source"), newBackend("destination")
+	seed(t, src, 6, 10)
+	checkpoint := filepath.Join(t.TempDir(), "copy.json")
+	opts := datacopy.Options{BatchSize: 3, Concurrency: 1, Checkpoint: checkpoint}
+
+	// Fail partway through the third batch of carts
+	failing := &failingCarts{CartRepository: dst.Carts}
+	failing.remaining.Store(7)
+	interrupted := dst
+	interrupted.Carts = failing
+	if _, err := datacopy.Copy(t.Context(), src, interrupted, opts); !errors.Is(err, errInjected) {
+		t.Fatalf("Copy = %v, want the injected failure", err)
+	}
+
+	// Resuming lists neither the products nor the two completed cart batches again
+	counting := &countingCarts{CartRepository: src.Carts}
+	resumed := src
+	resumed.Carts = counting
+	report, err := datacopy.Copy(t.Context(), resumed, dst, opts)
+	if err != nil {
+		t.Fatalf("resumed Copy: %v", err)
+	}
+	if lists := counting.lists.Load(); lists != 2 {
+		t.Errorf("resumed copy listed %d cart batches, want 2", lists)
+	}
+
+	v := verify(t, src, dst)
+	if !v.Match() {
+		t.Fatalf("verification failed after resuming: %+v", v)
+	}
+	if report.Products.Copied != v.Products.Source || report.Carts.Copied != v.Carts.Source {
+		t.Errorf("resumed report %+v does not cover everything copied: %+v", report, v)
+	}
+
+	// A finished checkpoint makes copying again a no-op
+	counting.lists.Store(0)
+	if _, err := datacopy.Copy(t.Context(), resumed, dst, opts); err != nil {
+		t.Fatal(err)
+	}
+	if lists := counting.lists.Load(); lists != 0 {
+		t.Errorf("copy with a finished checkpoint listed %d batches", lists)
+	}
+}
+
+func TestCopyRejectsOtherCheckpoint(t *testing.T) {
+	src, dst := newBackend("mysql"), newBackend("dynamo")
+	checkpoint := filepath.Join(t.TempDir(), "copy.json")
+	opts := datacopy.Options{BatchSize: 10, Concurrency: 1, Checkpoint: checkpoint}
+	if _, err := datacopy.Copy(t.Context(), src, dst, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	other := newBackend("postgres")
+	if _, err := datacopy.Copy(t.Context(), src, other, opts); !errors.Is(err, datacopy.ErrCheckpointMismatch) {
+		t.Fatalf("Copy with another destination's checkpoint = %v, want ErrCheckpointMismatch", err)
+	}
+}
@@ -0,0 +1,83 @@
+package datacopy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+)
+
+// Digest summarises a set of records: how many there are and a checksum of their
+// contents. The checksum does not depend on the order records are added in, so
+// backends that list in different orders produce the same digest for the same data.
+type Digest struct {
+	Count    int64  `json:"count"`
+	Checksum string `json:"checksum"`
+}
+
+// digest accumulates a Digest as the XOR of each record's SHA-256.
+// Records are unique by ID, so no two of them cancel out.
+type digest struct {
+	count int64
+	sum   [sha256.Size]byte
+}
+
+func (d *digest) add(record any) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(data)
+	for i := range d.sum {
+		d.sum[i] ^= hash[i]
+	}
+	d.count++
+	return nil
+}
+
+func (d *digest) Digest() Digest {
+	return Digest{Count: d.count, Checksum: hex.EncodeToString(d.sum[:])}
+}
+
+// resume restores a digest saved in a checkpoint
+func (d *digest) resume(saved Digest) error {
+	d.count = saved.Count
+	d.sum = [sha256.Size]byte{}
+	if saved.Checksum == "" {
+		return nil
+	}
+	sum, err := hex.DecodeString(saved.Checksum)
+	if err != nil || len(sum) != sha256.Size {
+		return fmt.Errorf("invalid checksum %q", saved.Checksum)
+	}
+	copy(d.sum[:], sum)
+	return nil
+}
+
+// checksummedCart is the form of a cart that is checksummed, including whether it is
+// its customer's active cart, which is not part of its JSON
+type checksummedCart struct {
+	models.Cart
+	Active bool `json:"active,omitempty"`
+}
+
+// canonicalCart returns the cart in the form that is checksummed, with items
+// sorted by product, as backends may return them in any order
+func canonicalCart(cart models.Cart) checksummedCart {
+	items := slices.Clone(cart.Items)
+	slices.SortFunc(items, func(a, b models.CartItem) int { return a.ProductID - b.ProductID })
+	cart.Items = items
+	return checksummedCart{Cart: cart, Active: cart.Active}
+}
+
+// canonicalPromotion returns the promotion in the form that is checksummed, with its
+// validity window to the second in UTC, as some backends store no finer
+func canonicalPromotion(promotion models.Promotion) models.Promotion {
+	promotion.StartsAt = promotion.StartsAt.UTC().Truncate(time.Second)
+	promotion.EndsAt = promotion.EndsAt.UTC().Truncate(time.Second)
+	return promotion
+}
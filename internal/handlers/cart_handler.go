@@ -7,8 +7,12 @@ import (
 	"github.com/LuoZihYuan/Go-Cart/internal/models"
 	"github.com/LuoZihYuan/Go-Cart/internal/services"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
+// guestTokenHeader carries the token of a guest cart
+const guestTokenHeader = "X-Guest-Token"
+
 type CartHandler struct {
 	service *services.CartService
 }
@@ -19,63 +23,112 @@ func NewCartHandler(service *services.CartService) *CartHandler {
 
 // CreateCart handles POST /shopping-carts
 // @Summary Create a new shopping cart
-// @Description Create a new shopping cart for a customer
+// @Description Create a new shopping cart for a customer, or a guest cart if no customer is given.
+// @Description A guest cart's token is returned once and must be sent as X-Guest-Token to use the cart.
+// @Description When each customer has one active cart, the customer's open cart is returned with 200 if there is one.
+// @Description The cart is priced in the given currency, or the store's base currency if none is given; a currency
+// @Description other than the base needs an exchange rate in effect (UNSUPPORTED_CURRENCY). Asking for a currency other
+// @Description than that of the customer's open cart fails with CURRENCY_MISMATCH.
 // @ID createCart
 // @Tags Shopping Cart
 // @Accept json
 // @Produce json
-// @Param request body models.CreateCartRequest true "Customer ID"
+// @Param request body models.CreateCartRequest true "Customer ID (omit for a guest cart) and currency"
+// @Success 200 {object} models.CreateCartResponse
 // @Success 201 {object} models.CreateCartResponse
 // @Failure 400 {object} models.Error
 // @Failure 500 {object} models.Error
+// @Failure 503 {object} models.Error
 // @Router /shopping-carts [post]
 // @Security ApiKeyAuth
 // @Security BearerAuth
 func (h *CartHandler) CreateCart(c *gin.Context) {
 	var req models.CreateCartRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.Error{
-			Error:   "INVALID_INPUT",
-			Message: "Invalid input data",
-			Details: err.Error(),
-		})
+		c.Error(invalidBody(err))
 		return
 	}
 
-	cart, err := h.service.CreateCart(req.CustomerID)
-	if err == services.ErrInvalidCart {
-		c.JSON(http.StatusBadRequest, models.Error{
-			Error:   "INVALID_INPUT",
-			Message: "Invalid customer ID",
-			Details: err.Error(),
+	if req.CustomerID == 0 {
+		cart, token, err := h.service.CreateGuestCart(c.Request.Context(), req.Currency)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, models.CreateCartResponse{
+			CartID:     cart.CartID,
+			GuestToken: token,
 		})
 		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, models.Error{
-			Error:   "INTERNAL_ERROR",
-			Message: "Internal server error",
-			Details: err.Error(),
-		})
+	}
+
+	cart, created, err := h.service.CreateCart(c.Request.Context(), req.CustomerID, req.Currency)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, models.CreateCartResponse{
+	status := http.StatusCreated
+	if !created {
+		status = http.StatusOK
+	}
+	c.JSON(status, models.CreateCartResponse{
 		CartID: cart.CartID,
 	})
 }
 
+// GetActiveCart handles GET /customers/{customerId}/active-cart
+// @Summary Get a customer's active shopping cart
+// @Description Retrieve the customer's open shopping cart, when each customer has one active cart
+// @ID getActiveCart
+// @Tags Shopping Cart
+// @Accept json
+// @Produce json
+// @Param customerId path int true "Unique identifier for the customer" minimum(1)
+// @Success 200 {object} models.PricedCart
+// @Failure 400 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Failure 503 {object} models.Error
+// @Router /customers/{customerId}/active-cart [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *CartHandler) GetActiveCart(c *gin.Context) {
+	// Parse customerId from URL
+	customerIDStr := c.Param("customerId")
+	customerID, err := strconv.Atoi(customerIDStr)
+	if err != nil || customerID < 1 {
+		c.Error(errInvalidCustomerID)
+		return
+	}
+
+	cart, err := h.service.GetActiveCart(c.Request.Context(), customerID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cart)
+}
+
 // GetCart handles GET /shopping-carts/{shoppingCartId}
 // @Summary Get shopping cart by ID
-// @Description Retrieve a shopping cart's details using its unique identifier
+// @Description Retrieve a shopping cart's details using its unique identifier, priced at its products'
+// @Description current prices with the discounts of its currently valid coupons and, once it has a
+// @Description shipping address, the taxes due
 // @ID getCart
 // @Tags Shopping Cart
 // @Accept json
 // @Produce json
 // @Param shoppingCartId path int true "Unique identifier for the shopping cart" minimum(1)
-// @Success 200 {object} models.Cart
+// @Param X-Guest-Token header string false "Token of a guest cart, as returned when it was created"
+// @Success 200 {object} models.PricedCart
 // @Failure 400 {object} models.Error
 // @Failure 404 {object} models.Error
+// @Failure 410 {object} models.Error
 // @Failure 500 {object} models.Error
+// @Failure 503 {object} models.Error
 // @Router /shopping-carts/{shoppingCartId} [get]
 // @Security ApiKeyAuth
 // @Security BearerAuth
@@ -84,36 +137,14 @@ func (h *CartHandler) GetCart(c *gin.Context) {
 	cartIDStr := c.Param("shoppingCartId")
 	cartID, err := strconv.Atoi(cartIDStr)
 	if err != nil || cartID < 1 {
-		c.JSON(http.StatusBadRequest, models.Error{
-			Error:   "INVALID_INPUT",
-			Message: "Invalid cart ID",
-			Details: "Cart ID must be a positive integer",
-		})
+		c.Error(errInvalidCartID)
 		return
 	}
 
 	// Get cart from service
-	cart, err := h.service.GetCart(cartID)
-	if err == services.ErrCartNotFound {
-		c.JSON(http.StatusNotFound, models.Error{
-			Error:   "NOT_FOUND",
-			Message: "Cart not found",
-			Details: "No cart exists with the specified ID",
-		})
-		return
-	} else if err == services.ErrInvalidCart {
-		c.JSON(http.StatusBadRequest, models.Error{
-			Error:   "INVALID_INPUT",
-			Message: "Invalid cart ID",
-			Details: err.Error(),
-		})
-		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, models.Error{
-			Error:   "INTERNAL_ERROR",
-			Message: "Internal server error",
-			Details: err.Error(),
-		})
+	cart, err := h.service.GetCart(c.Request.Context(), cartID, c.GetHeader(guestTokenHeader))
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -123,17 +154,24 @@ func (h *CartHandler) GetCart(c *gin.Context) {
 
 // AddItemsToCart handles POST /shopping-carts/{shoppingCartId}/items
 // @Summary Add items to shopping cart
-// @Description Add products with specified quantities to a shopping cart
+// @Description Add products with specified quantities to a shopping cart.
+// @Description The body is either a single item or an array of up to 100 items; an array is added
+// @Description all or nothing, and every item naming a missing product is listed in the error's fields.
+// @Description Items that would take the cart past its quantity limits fail with QUANTITY_LIMIT_EXCEEDED,
+// @Description and products with no price in the cart's currency with PRICE_NOT_AVAILABLE.
 // @ID addItemsToCart
 // @Tags Shopping Cart
 // @Accept json
 // @Produce json
 // @Param shoppingCartId path int true "Unique identifier for the shopping cart" minimum(1)
-// @Param request body models.AddItemRequest true "Item details"
+// @Param X-Guest-Token header string false "Token of a guest cart, as returned when it was created"
+// @Param request body models.AddItemRequest true "Item details, or an array of them"
 // @Success 204 "Items added to cart successfully"
 // @Failure 400 {object} models.Error
 // @Failure 404 {object} models.Error
+// @Failure 410 {object} models.Error
 // @Failure 500 {object} models.Error
+// @Failure 503 {object} models.Error
 // @Router /shopping-carts/{shoppingCartId}/items [post]
 // @Security ApiKeyAuth
 // @Security BearerAuth
@@ -142,54 +180,49 @@ func (h *CartHandler) AddItemsToCart(c *gin.Context) {
 	cartIDStr := c.Param("shoppingCartId")
 	cartID, err := strconv.Atoi(cartIDStr)
 	if err != nil || cartID < 1 {
-		c.JSON(http.StatusBadRequest, models.Error{
-			Error:   "INVALID_INPUT",
-			Message: "Invalid cart ID",
-			Details: "Cart ID must be a positive integer",
-		})
+		c.Error(errInvalidCartID)
 		return
 	}
 
-	// Parse request body
-	var req models.AddItemRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.Error{
-			Error:   "INVALID_INPUT",
-			Message: "Invalid input data",
-			Details: err.Error(),
-		})
+	// Parse request body, which is a single item or an array of them
+	body, err := c.GetRawData()
+	if err != nil {
+		c.Error(invalidBody(err))
 		return
 	}
 
-	// Add item to cart
-	err = h.service.AddItemToCart(cartID, req.ProductID, req.Quantity)
-	if err == services.ErrCartNotFound {
-		c.JSON(http.StatusNotFound, models.Error{
-			Error:   "NOT_FOUND",
-			Message: "Cart not found",
-			Details: "No cart exists with the specified ID",
-		})
-		return
-	} else if err == services.ErrProductNotFound {
-		c.JSON(http.StatusNotFound, models.Error{
-			Error:   "NOT_FOUND",
-			Message: "Product not found",
-			Details: "No product exists with the specified ID",
-		})
+	guestToken := c.GetHeader(guestTokenHeader)
+	if !isJSONArray(body) {
+		var req models.AddItemRequest
+		if err := binding.JSON.BindBody(body, &req); err != nil {
+			c.Error(invalidBody(err))
+			return
+		}
+
+		// Add item to cart
+		if err := h.service.AddItemToCart(c.Request.Context(), cartID, guestToken, req.ProductID, req.Quantity); err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
 		return
-	} else if err == services.ErrInvalidCart {
-		c.JSON(http.StatusBadRequest, models.Error{
-			Error:   "INVALID_INPUT",
-			Message: "Invalid input data",
-			Details: err.Error(),
-		})
+	}
+
+	var req models.AddItemsRequest
+	if err := bindItems(body, &req); err != nil {
+		c.Error(invalidBody(err))
 		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, models.Error{
-			Error:   "INTERNAL_ERROR",
-			Message: "Internal server error",
-			Details: err.Error(),
-		})
+	}
+
+	items := make([]models.CartItem, len(req.Items))
+	for i, item := range req.Items {
+		items[i] = models.CartItem{ProductID: item.ProductID, Quantity: item.Quantity}
+	}
+
+	// Add all items to cart, or none
+	if err := h.service.AddItemsToCart(c.Request.Context(), cartID, guestToken, items); err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -198,16 +231,24 @@ func (h *CartHandler) AddItemsToCart(c *gin.Context) {
 
 // CheckoutCart handles POST /shopping-carts/{shoppingCartId}/checkout
 // @Summary Checkout shopping cart
-// @Description Process checkout for a shopping cart
+// @Description Process checkout for a shopping cart, redeeming its currently valid coupons.
+// @Description Fails with COUPON_USED_UP if one of them has been used up since it was applied.
+// @Description When taxes or shipping rates are configured, the cart needs a shipping address, or it
+// @Description fails with SHIPPING_ADDRESS_REQUIRED. When shipping rates are configured, it also needs
+// @Description a shipping option that is still offered, or it fails with SHIPPING_OPTION_REQUIRED or
+// @Description SHIPPING_OPTION_UNAVAILABLE. The response holds the order's pricing as of checkout.
 // @ID checkoutCart
 // @Tags Shopping Cart
 // @Accept json
 // @Produce json
 // @Param shoppingCartId path int true "Unique identifier for the shopping cart" minimum(1)
+// @Param X-Guest-Token header string false "Token of a guest cart, as returned when it was created"
 // @Success 200 {object} models.CheckoutResponse
 // @Failure 400 {object} models.Error
 // @Failure 404 {object} models.Error
+// @Failure 410 {object} models.Error
 // @Failure 500 {object} models.Error
+// @Failure 503 {object} models.Error
 // @Router /shopping-carts/{shoppingCartId}/checkout [post]
 // @Security ApiKeyAuth
 // @Security BearerAuth
@@ -216,47 +257,283 @@ func (h *CartHandler) CheckoutCart(c *gin.Context) {
 	cartIDStr := c.Param("shoppingCartId")
 	cartID, err := strconv.Atoi(cartIDStr)
 	if err != nil || cartID < 1 {
-		c.JSON(http.StatusBadRequest, models.Error{
-			Error:   "INVALID_INPUT",
-			Message: "Invalid cart ID",
-			Details: "Cart ID must be a positive integer",
-		})
+		c.Error(errInvalidCartID)
 		return
 	}
 
 	// Process checkout
-	orderID, err := h.service.CheckoutCart(cartID)
-	if err == services.ErrCartNotFound {
-		c.JSON(http.StatusNotFound, models.Error{
-			Error:   "NOT_FOUND",
-			Message: "Cart not found",
-			Details: "No cart exists with the specified ID",
-		})
+	orderID, priced, err := h.service.CheckoutCart(c.Request.Context(), cartID, c.GetHeader(guestTokenHeader))
+	if err != nil {
+		c.Error(err)
 		return
-	} else if err == services.ErrEmptyCart {
-		c.JSON(http.StatusBadRequest, models.Error{
-			Error:   "INVALID_STATE",
-			Message: "Cart is empty",
-			Details: "Cannot checkout an empty cart",
-		})
+	}
+
+	c.JSON(http.StatusOK, models.CheckoutResponse{
+		OrderID:   orderID,
+		Currency:  priced.Currency,
+		Subtotal:  priced.Subtotal,
+		Discounts: priced.Discounts,
+		Shipping:  priced.Shipping,
+		Taxes:     priced.Taxes,
+		Total:     priced.Total,
+	})
+}
+
+// MergeCart handles POST /shopping-carts/{shoppingCartId}/merge
+// @Summary Merge a guest cart into a customer's cart
+// @Description Fold the guest cart named by the token into the customer's shopping cart, adding
+// @Description quantities of products already in it, then delete the guest cart. Call this when
+// @Description a visitor with a guest cart signs in. Carts priced in different currencies cannot be
+// @Description merged (CURRENCY_MISMATCH).
+// @ID mergeCart
+// @Tags Shopping Cart
+// @Accept json
+// @Produce json
+// @Param shoppingCartId path int true "Unique identifier for the customer's shopping cart" minimum(1)
+// @Param request body models.MergeCartRequest true "Guest cart token"
+// @Success 200 {object} models.PricedCart
+// @Failure 400 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 410 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Failure 503 {object} models.Error
+// @Router /shopping-carts/{shoppingCartId}/merge [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *CartHandler) MergeCart(c *gin.Context) {
+	// Parse shoppingCartId from URL
+	cartIDStr := c.Param("shoppingCartId")
+	cartID, err := strconv.Atoi(cartIDStr)
+	if err != nil || cartID < 1 {
+		c.Error(errInvalidCartID)
 		return
-	} else if err == services.ErrInvalidCart {
-		c.JSON(http.StatusBadRequest, models.Error{
-			Error:   "INVALID_INPUT",
-			Message: "Invalid cart",
-			Details: err.Error(),
-		})
+	}
+
+	// Parse request body
+	var req models.MergeCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidBody(err))
 		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, models.Error{
-			Error:   "INTERNAL_ERROR",
-			Message: "Internal server error",
-			Details: err.Error(),
-		})
+	}
+
+	cart, err := h.service.MergeGuestCart(c.Request.Context(), cartID, req.GuestToken)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, models.CheckoutResponse{
-		OrderID: orderID,
-	})
+	c.JSON(http.StatusOK, cart)
+}
+
+// ApplyCoupon handles POST /shopping-carts/{shoppingCartId}/coupons
+// @Summary Apply a coupon to shopping cart
+// @Description Apply a coupon code, in any case, to a shopping cart and return the cart priced with it.
+// @Description A code that has not started or has ended fails with COUPON_NOT_ACTIVE, and one that has
+// @Description been redeemed as often as it may be, overall or by the cart's customer, with COUPON_USED_UP.
+// @Description Codes limited per customer cannot be applied to guest carts. A cart holds at most 5 codes.
+// @ID applyCoupon
+// @Tags Shopping Cart
+// @Accept json
+// @Produce json
+// @Param shoppingCartId path int true "Unique identifier for the shopping cart" minimum(1)
+// @Param X-Guest-Token header string false "Token of a guest cart, as returned when it was created"
+// @Param request body models.ApplyCouponRequest true "Coupon code"
+// @Success 200 {object} models.PricedCart
+// @Failure 400 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 410 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Failure 503 {object} models.Error
+// @Router /shopping-carts/{shoppingCartId}/coupons [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *CartHandler) ApplyCoupon(c *gin.Context) {
+	// Parse shoppingCartId from URL
+	cartIDStr := c.Param("shoppingCartId")
+	cartID, err := strconv.Atoi(cartIDStr)
+	if err != nil || cartID < 1 {
+		c.Error(errInvalidCartID)
+		return
+	}
+
+	// Parse request body
+	var req models.ApplyCouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidBody(err))
+		return
+	}
+
+	cart, err := h.service.ApplyCoupon(c.Request.Context(), cartID, c.GetHeader(guestTokenHeader), req.Code)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cart)
+}
+
+// RemoveCoupon handles DELETE /shopping-carts/{shoppingCartId}/coupons/{code}
+// @Summary Remove a coupon from shopping cart
+// @Description Remove a coupon code, in any case, from a shopping cart; removing a code it does not hold succeeds
+// @ID removeCoupon
+// @Tags Shopping Cart
+// @Accept json
+// @Produce json
+// @Param shoppingCartId path int true "Unique identifier for the shopping cart" minimum(1)
+// @Param code path string true "Coupon code"
+// @Param X-Guest-Token header string false "Token of a guest cart, as returned when it was created"
+// @Success 204 "Coupon removed from cart successfully"
+// @Failure 400 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 410 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Failure 503 {object} models.Error
+// @Router /shopping-carts/{shoppingCartId}/coupons/{code} [delete]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *CartHandler) RemoveCoupon(c *gin.Context) {
+	// Parse shoppingCartId from URL
+	cartIDStr := c.Param("shoppingCartId")
+	cartID, err := strconv.Atoi(cartIDStr)
+	if err != nil || cartID < 1 {
+		c.Error(errInvalidCartID)
+		return
+	}
+
+	if err := h.service.RemoveCoupon(c.Request.Context(), cartID, c.GetHeader(guestTokenHeader), c.Param("code")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SetShippingAddress handles PUT /shopping-carts/{shoppingCartId}/shipping-address
+// @Summary Set shopping cart's shipping address
+// @Description Set where a shopping cart is shipped, which decides its shipping options and the taxes due on it, and return the
+// @Description cart priced with them. Country is an ISO 3166-1 alpha-2 code and state the subdivision
+// @Description part of an ISO 3166-2 code, such as WA for US-WA.
+// @ID setShippingAddress
+// @Tags Shopping Cart
+// @Accept json
+// @Produce json
+// @Param shoppingCartId path int true "Unique identifier for the shopping cart" minimum(1)
+// @Param X-Guest-Token header string false "Token of a guest cart, as returned when it was created"
+// @Param request body models.Address true "Shipping address"
+// @Success 200 {object} models.PricedCart
+// @Failure 400 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 410 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Failure 503 {object} models.Error
+// @Router /shopping-carts/{shoppingCartId}/shipping-address [put]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *CartHandler) SetShippingAddress(c *gin.Context) {
+	// Parse shoppingCartId from URL
+	cartIDStr := c.Param("shoppingCartId")
+	cartID, err := strconv.Atoi(cartIDStr)
+	if err != nil || cartID < 1 {
+		c.Error(errInvalidCartID)
+		return
+	}
+
+	// Parse request body
+	var address models.Address
+	if err := c.ShouldBindJSON(&address); err != nil {
+		c.Error(invalidBody(err))
+		return
+	}
+
+	cart, err := h.service.SetShippingAddress(c.Request.Context(), cartID, c.GetHeader(guestTokenHeader), &address)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cart)
+}
+
+// GetShippingOptions handles GET /shopping-carts/{shoppingCartId}/shipping-options
+// @Summary List shopping cart's shipping options
+// @Description List the carrier services that ship a shopping cart to its shipping address, cheapest
+// @Description first, priced by the cart's total weight and the address's zone. An option is free once
+// @Description the cart's items cost enough after discounts. The list is empty when no shipping rates
+// @Description are configured. Fails with SHIPPING_ADDRESS_REQUIRED if the cart has no shipping address.
+// @ID getShippingOptions
+// @Tags Shopping Cart
+// @Produce json
+// @Param shoppingCartId path int true "Unique identifier for the shopping cart" minimum(1)
+// @Param X-Guest-Token header string false "Token of a guest cart, as returned when it was created"
+// @Success 200 {object} models.ShippingOptionsResponse
+// @Failure 400 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 410 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Failure 503 {object} models.Error
+// @Router /shopping-carts/{shoppingCartId}/shipping-options [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *CartHandler) GetShippingOptions(c *gin.Context) {
+	// Parse shoppingCartId from URL
+	cartIDStr := c.Param("shoppingCartId")
+	cartID, err := strconv.Atoi(cartIDStr)
+	if err != nil || cartID < 1 {
+		c.Error(errInvalidCartID)
+		return
+	}
+
+	options, err := h.service.ShippingOptions(c.Request.Context(), cartID, c.GetHeader(guestTokenHeader))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+// SelectShippingOption handles PUT /shopping-carts/{shoppingCartId}/shipping-option
+// @Summary Choose shopping cart's shipping option
+// @Description Choose one of a shopping cart's shipping options and return the cart priced with it.
+// @Description Its price is quoted again whenever the cart is priced. Fails with
+// @Description SHIPPING_OPTION_UNAVAILABLE if the option does not ship the cart to its address.
+// @ID selectShippingOption
+// @Tags Shopping Cart
+// @Accept json
+// @Produce json
+// @Param shoppingCartId path int true "Unique identifier for the shopping cart" minimum(1)
+// @Param X-Guest-Token header string false "Token of a guest cart, as returned when it was created"
+// @Param request body models.SelectShippingOptionRequest true "Shipping option to choose"
+// @Success 200 {object} models.PricedCart
+// @Failure 400 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 410 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Failure 503 {object} models.Error
+// @Router /shopping-carts/{shoppingCartId}/shipping-option [put]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *CartHandler) SelectShippingOption(c *gin.Context) {
+	// Parse shoppingCartId from URL
+	cartIDStr := c.Param("shoppingCartId")
+	cartID, err := strconv.Atoi(cartIDStr)
+	if err != nil || cartID < 1 {
+		c.Error(errInvalidCartID)
+		return
+	}
+
+	// Parse request body
+	var req models.SelectShippingOptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidBody(err))
+		return
+	}
+
+	cart, err := h.service.SelectShippingOption(c.Request.Context(), cartID, c.GetHeader(guestTokenHeader), req.OptionID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cart)
 }
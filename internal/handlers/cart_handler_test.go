@@ -0,0 +1,343 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/handlers"
+	"github.com/LuoZihYuan/Go-Cart/internal/middleware"
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+	"github.com/LuoZihYuan/Go-Cart/internal/router"
+	"github.com/LuoZihYuan/Go-Cart/internal/services"
+)
+
+// cartAPI serves the API's routes over memory repositories, pricing carts in USD
+type cartAPI struct {
+	router   *gin.Engine
+	carts    *repository.CartMemoryRepository
+	products *repository.ProductMemoryRepository
+}
+
+func newCartAPI(t *testing.T, singleActive bool) *cartAPI {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	a := &cartAPI{
+		carts:    repository.NewCartMemoryRepository(),
+		products: repository.NewProductMemoryRepository(),
+	}
+	promotions := repository.NewPromotionMemoryRepository()
+	cartService := services.NewCartService(a.carts, a.products, promotions, services.Pricing{Currency: "USD"}, 0, singleActive, services.QuantityLimits{})
+
+	a.router = gin.New()
+	a.router.Use(middleware.Errors(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	router.SetupRoutes(a.router, &router.AllHandlers{
+		ProductHandler:   handlers.NewProductHandler(services.NewProductService(a.products)),
+		CartHandler:      handlers.NewCartHandler(cartService),
+		PromotionHandler: handlers.NewPromotionHandler(services.NewPromotionService(promotions)),
+	})
+	return a
+}
+
+// addProducts stores a product with each of ids
+func (a *cartAPI) addProducts(t *testing.T, ids ...int) {
+	t.Helper()
+
+	for _, id := range ids {
+		product := models.Product{ProductID: id, SKU: "SKU-" + strconv.Itoa(id), Manufacturer: "Acme", CategoryID: 1, Price: 1000, SomeOtherID: 1}
+		if err := a.products.Upsert(t.Context(), &product); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// do serves a request with body, if any, as JSON
+func (a *cartAPI) do(method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+	return w
+}
+
+// createCart creates a cart for customerID and returns its ID
+func (a *cartAPI) createCart(t *testing.T, customerID int) int {
+	t.Helper()
+	return a.postCart(t, customerID, http.StatusCreated)
+}
+
+// postCart asks for a cart for customerID, expecting status, and returns its ID
+func (a *cartAPI) postCart(t *testing.T, customerID, status int) int {
+	t.Helper()
+
+	w := a.do(http.MethodPost, "/v1/shopping-carts", `{"customer_id": `+strconv.Itoa(customerID)+`}`)
+	if w.Code != status {
+		t.Fatalf("create cart status = %d, want %d: %s", w.Code, status, w.Body)
+	}
+	var created models.CreateCartResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	return created.CartID
+}
+
+// quantities returns how much of each product a cart holds
+func (a *cartAPI) quantities(t *testing.T, cartID int) map[int]int {
+	t.Helper()
+
+	cart, err := a.carts.GetByID(t.Context(), cartID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quantities := map[int]int{}
+	for _, item := range cart.Items {
+		quantities[item.ProductID] = item.Quantity
+	}
+	return quantities
+}
+
+// decodeError decodes an error response
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) models.Error {
+	t.Helper()
+
+	var body models.Error
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode error body %s: %v", w.Body, err)
+	}
+	return body
+}
+
+func TestAddItemsToCart(t *testing.T) {
+	// repeat returns a batch of n items of product 1
+	repeat := func(n int) string {
+		return "[" + strings.TrimSuffix(strings.Repeat(`{"product_id": 1, "quantity": 1},`, n), ",") + "]"
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		want   map[int]int // what the cart holds afterwards
+		// code and fields describe the error; fields lists each rejected field with its rule
+		code   string
+		fields []string
+	}{
+		{name: "single item", body: `{"product_id": 1, "quantity": 2}`, status: http.StatusNoContent, want: map[int]int{1: 2}},
+		{
+			name:   "batch",
+			body:   `[{"product_id": 1, "quantity": 2}, {"product_id": 2, "quantity": 3}]`,
+			status: http.StatusNoContent,
+			want:   map[int]int{1: 2, 2: 3},
+		},
+		{
+			name:   "batch repeating a product",
+			body:   `[{"product_id": 1, "quantity": 2}, {"product_id": 1, "quantity": 3}]`,
+			status: http.StatusNoContent,
+			want:   map[int]int{1: 5},
+		},
+		{name: "batch at the item limit", body: repeat(100), status: http.StatusNoContent, want: map[int]int{1: 100}},
+		{name: "batch past the item limit", body: repeat(101), status: http.StatusBadRequest, code: "INVALID_INPUT", fields: []string{"items max"}},
+		{name: "empty batch", body: `[]`, status: http.StatusBadRequest, code: "INVALID_INPUT", fields: []string{"items min"}},
+		{name: "single invalid item", body: `{"product_id": 1, "quantity": -1}`, status: http.StatusBadRequest, code: "INVALID_INPUT", fields: []string{"quantity min"}},
+		{
+			name:   "one invalid item",
+			body:   `[{"product_id": 1, "quantity": 2}, {"product_id": 2, "quantity": -1}]`,
+			status: http.StatusBadRequest,
+			code:   "INVALID_INPUT",
+			fields: []string{"items[1].quantity min"},
+		},
+		{
+			name:   "invalid items",
+			body:   `[{"product_id": -1, "quantity": 2}, {"product_id": 2, "quantity": 1}, {"product_id": 2, "quantity": -1}]`,
+			status: http.StatusBadRequest,
+			code:   "INVALID_INPUT",
+			fields: []string{"items[0].product_id min", "items[2].quantity min"},
+		},
+		{name: "single missing product", body: `{"product_id": 9, "quantity": 1}`, status: http.StatusNotFound, code: "NOT_FOUND"},
+		{
+			name:   "one missing product",
+			body:   `[{"product_id": 1, "quantity": 2}, {"product_id": 9, "quantity": 1}]`,
+			status: http.StatusNotFound,
+			code:   "NOT_FOUND",
+			fields: []string{"items[1].product_id exists"},
+		},
+		{
+			name:   "missing products",
+			body:   `[{"product_id": 8, "quantity": 1}, {"product_id": 1, "quantity": 2}, {"product_id": 9, "quantity": 1}]`,
+			status: http.StatusNotFound,
+			code:   "NOT_FOUND",
+			fields: []string{"items[0].product_id exists", "items[2].product_id exists"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newCartAPI(t, false)
+			a.addProducts(t, 1, 2)
+			cartID := a.createCart(t, 1)
+
+			w := a.do(http.MethodPost, "/v1/shopping-carts/"+strconv.Itoa(cartID)+"/items", tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			// Items are added all together or not at all
+			if got := a.quantities(t, cartID); !maps.Equal(got, tt.want) {
+				t.Fatalf("cart holds %v, want %v", got, tt.want)
+			}
+			if tt.code == "" {
+				return
+			}
+
+			body := decodeError(t, w)
+			if body.Error != tt.code || len(body.Fields) != len(tt.fields) {
+				t.Fatalf("error = %+v, want %s with fields %v", body, tt.code, tt.fields)
+			}
+			for i, field := range body.Fields {
+				if got := field.Field + " " + field.Rule; got != tt.fields[i] {
+					t.Fatalf("field %d = %s, want %s", i, got, tt.fields[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCreateCartSingleActive(t *testing.T) {
+	a := newCartAPI(t, true)
+	a.addProducts(t, 1)
+
+	cartID := a.postCart(t, 1, http.StatusCreated)
+	if w := a.do(http.MethodPost, "/v1/shopping-carts/"+strconv.Itoa(cartID)+"/items", `{"product_id": 1, "quantity": 2}`); w.Code != http.StatusNoContent {
+		t.Fatalf("add item status = %d, want 204: %s", w.Code, w.Body)
+	}
+
+	// Asking again returns the customer's open cart rather than a new one
+	if again := a.postCart(t, 1, http.StatusOK); again != cartID {
+		t.Fatalf("second create returned cart %d, want the active cart %d", again, cartID)
+	}
+	if got := a.quantities(t, cartID); !maps.Equal(got, map[int]int{1: 2}) {
+		t.Fatalf("active cart holds %v, want product 1 × 2", got)
+	}
+	if other := a.postCart(t, 2, http.StatusCreated); other == cartID {
+		t.Fatal("two customers share an active cart")
+	}
+}
+
+func TestCreateCartMultipleActive(t *testing.T) {
+	a := newCartAPI(t, false)
+
+	first := a.postCart(t, 1, http.StatusCreated)
+	if second := a.postCart(t, 1, http.StatusCreated); second == first {
+		t.Fatalf("second create returned cart %d again, want a new cart", first)
+	}
+}
+
+func TestCreateCartReplacesExpiredActiveCart(t *testing.T) {
+	a := newCartAPI(t, true)
+	expired := a.postCart(t, 1, http.StatusCreated)
+
+	cart, err := a.carts.GetByID(t.Context(), expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cart.ExpiresAt = time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	if err := a.carts.Put(t.Context(), cart); err != nil {
+		t.Fatal(err)
+	}
+	if w := a.do(http.MethodGet, "/v1/customers/1/active-cart", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("active cart status once expired = %d, want 404: %s", w.Code, w.Body)
+	}
+
+	replaced := a.postCart(t, 1, http.StatusCreated)
+	if replaced == expired {
+		t.Fatal("create returned the expired cart")
+	}
+	// The expired cart stays behind, answering 410 Gone until it is deleted
+	if w := a.do(http.MethodGet, "/v1/shopping-carts/"+strconv.Itoa(expired), ""); w.Code != http.StatusGone {
+		t.Fatalf("expired cart status = %d, want 410: %s", w.Code, w.Body)
+	}
+	if got := a.activeCartID(t, 1); got != replaced {
+		t.Fatalf("active cart = %d, want the replacement %d", got, replaced)
+	}
+	if again := a.postCart(t, 1, http.StatusOK); again != replaced {
+		t.Fatalf("create after replacement returned cart %d, want %d", again, replaced)
+	}
+}
+
+// activeCartID returns the ID of the customer's active cart, read through the API
+func (a *cartAPI) activeCartID(t *testing.T, customerID int) int {
+	t.Helper()
+
+	w := a.do(http.MethodGet, "/v1/customers/"+strconv.Itoa(customerID)+"/active-cart", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("active cart status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var cart models.PricedCart
+	if err := json.Unmarshal(w.Body.Bytes(), &cart); err != nil {
+		t.Fatal(err)
+	}
+	return cart.CartID
+}
+
+func TestGetActiveCart(t *testing.T) {
+	a := newCartAPI(t, true)
+	a.addProducts(t, 1)
+	cartID := a.createCart(t, 1)
+	if w := a.do(http.MethodPost, "/v1/shopping-carts/"+strconv.Itoa(cartID)+"/items", `{"product_id": 1, "quantity": 2}`); w.Code != http.StatusNoContent {
+		t.Fatalf("add item status = %d, want 204: %s", w.Code, w.Body)
+	}
+
+	w := a.do(http.MethodGet, "/v1/customers/1/active-cart", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var cart models.PricedCart
+	if err := json.Unmarshal(w.Body.Bytes(), &cart); err != nil {
+		t.Fatal(err)
+	}
+	if cart.CartID != cartID || cart.CustomerID != 1 || len(cart.Items) != 1 || cart.Subtotal != 2000 {
+		t.Fatalf("active cart = %+v, want cart %d for customer 1 priced at 2000", cart, cartID)
+	}
+
+	tests := []struct {
+		name         string
+		singleActive bool
+		path         string
+		status       int
+		code         string
+		details      string
+	}{
+		{name: "no active cart", singleActive: true, path: "/v1/customers/2/active-cart", status: http.StatusNotFound, code: "NOT_FOUND", details: "The customer has no open cart"},
+		{name: "not limited to one", path: "/v1/customers/1/active-cart", status: http.StatusNotFound, code: "NOT_FOUND", details: "Customers are not limited to one active cart"},
+		{name: "customer ID not a number", singleActive: true, path: "/v1/customers/one/active-cart", status: http.StatusBadRequest, code: "INVALID_INPUT"},
+		{name: "customer ID not positive", singleActive: true, path: "/v1/customers/0/active-cart", status: http.StatusBadRequest, code: "INVALID_INPUT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newCartAPI(t, tt.singleActive)
+			a.createCart(t, 1)
+
+			w := a.do(http.MethodGet, tt.path, "")
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if body := decodeError(t, w); body.Error != tt.code || (tt.details != "" && body.Details != tt.details) {
+				t.Fatalf("error = %+v, want %s with details %q", body, tt.code, tt.details)
+			}
+		})
+	}
+}
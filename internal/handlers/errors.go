@@ -0,0 +1,9 @@
+package handlers
+
+import "github.com/LuoZihYuan/Go-Cart/internal/services"
+
+var (
+	errInvalidCartID     = services.InvalidInput("Invalid cart ID", "Cart ID must be a positive integer")
+	errInvalidProductID  = services.InvalidInput("Invalid product ID", "Product ID must be a positive integer")
+	errInvalidCustomerID = services.InvalidInput("Invalid customer ID", "Customer ID must be a positive integer")
+)
@@ -28,6 +28,7 @@ func NewProductHandler(service *services.ProductService) *ProductHandler {
 // @Success 200 {object} models.Product
 // @Failure 404 {object} models.Error
 // @Failure 500 {object} models.Error
+// @Failure 503 {object} models.Error
 // @Router /products/{productId} [get]
 // @Security ApiKeyAuth
 // @Security BearerAuth
@@ -36,29 +37,14 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 	productIDStr := c.Param("productId")
 	productID, err := strconv.Atoi(productIDStr)
 	if err != nil || productID < 1 {
-		c.JSON(http.StatusBadRequest, models.Error{
-			Error:   "INVALID_INPUT",
-			Message: "Invalid product ID",
-			Details: "Product ID must be a positive integer",
-		})
+		c.Error(errInvalidProductID)
 		return
 	}
 
 	// Get product from service
-	product, err := h.service.GetProduct(productID)
-	if err == services.ErrProductNotFound {
-		c.JSON(http.StatusNotFound, models.Error{
-			Error:   "NOT_FOUND",
-			Message: "Product not found",
-			Details: "No product exists with the specified ID",
-		})
-		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, models.Error{
-			Error:   "INTERNAL_ERROR",
-			Message: "Internal server error",
-			Details: err.Error(),
-		})
+	product, err := h.service.GetProduct(c.Request.Context(), productID)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -79,6 +65,7 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 // @Failure 400 {object} models.Error
 // @Failure 404 {object} models.Error
 // @Failure 500 {object} models.Error
+// @Failure 503 {object} models.Error
 // @Router /products/{productId}/details [post]
 // @Security ApiKeyAuth
 // @Security BearerAuth
@@ -87,48 +74,20 @@ func (h *ProductHandler) AddProductDetails(c *gin.Context) {
 	productIDStr := c.Param("productId")
 	productID, err := strconv.Atoi(productIDStr)
 	if err != nil || productID < 1 {
-		c.JSON(http.StatusBadRequest, models.Error{
-			Error:   "INVALID_INPUT",
-			Message: "Invalid product ID",
-			Details: "Product ID must be a positive integer",
-		})
+		c.Error(errInvalidProductID)
 		return
 	}
 
 	// Parse request body
 	var product models.Product
 	if err := c.ShouldBindJSON(&product); err != nil {
-		c.JSON(http.StatusBadRequest, models.Error{
-			Error:   "INVALID_INPUT",
-			Message: "Invalid input data",
-			Details: err.Error(),
-		})
+		c.Error(invalidBody(err))
 		return
 	}
 
 	// Add product details through service
-	if err := h.service.AddProductDetails(productID, &product); err != nil {
-		if err == services.ErrProductNotFound {
-			c.JSON(http.StatusNotFound, models.Error{
-				Error:   "NOT_FOUND",
-				Message: "Product not found",
-				Details: "No product exists with the specified ID",
-			})
-			return
-		}
-		if err == services.ErrInvalidProduct || err.Error() == "product ID mismatch" {
-			c.JSON(http.StatusBadRequest, models.Error{
-				Error:   "INVALID_INPUT",
-				Message: "Invalid input data",
-				Details: err.Error(),
-			})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, models.Error{
-			Error:   "INTERNAL_ERROR",
-			Message: "Internal server error",
-			Details: err.Error(),
-		})
+	if err := h.service.AddProductDetails(c.Request.Context(), productID, &product); err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type PromotionHandler struct {
+	service *services.PromotionService
+}
+
+func NewPromotionHandler(service *services.PromotionService) *PromotionHandler {
+	return &PromotionHandler{service: service}
+}
+
+// GetPromotion handles GET /promotions/{code}
+// @Summary Get promotion by coupon code
+// @Description Retrieve a promotion, including how often its code has been redeemed, by its coupon code in any case
+// @ID getPromotion
+// @Tags Promotions
+// @Accept json
+// @Produce json
+// @Param code path string true "Coupon code"
+// @Success 200 {object} models.Promotion
+// @Failure 404 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Failure 503 {object} models.Error
+// @Router /promotions/{code} [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *PromotionHandler) GetPromotion(c *gin.Context) {
+	promotion, err := h.service.GetPromotion(c.Request.Context(), c.Param("code"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, promotion)
+}
+
+// UpsertPromotion handles PUT /promotions/{code}
+// @Summary Create or update a promotion
+// @Description Create or update the promotion redeemed with a coupon code. Codes are 1 to 32 letters, digits,
+// @Description hyphens or underscores and are case-insensitive. Updating a promotion keeps its uses.
+// @ID upsertPromotion
+// @Tags Promotions
+// @Accept json
+// @Produce json
+// @Param code path string true "Coupon code"
+// @Param promotion body models.Promotion true "Promotion details; uses is ignored"
+// @Success 204 "Promotion saved successfully"
+// @Failure 400 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Failure 503 {object} models.Error
+// @Router /promotions/{code} [put]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *PromotionHandler) UpsertPromotion(c *gin.Context) {
+	// Parse request body
+	var promotion models.Promotion
+	if err := c.ShouldBindJSON(&promotion); err != nil {
+		c.Error(invalidBody(err))
+		return
+	}
+
+	if err := h.service.UpsertPromotion(c.Request.Context(), c.Param("code"), &promotion); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
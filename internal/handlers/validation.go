@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/services"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Report validation failures using JSON field names rather than Go struct field names
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(jsonFieldName)
+	}
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	default:
+		return name
+	}
+}
+
+// isJSONArray reports whether a request body holds a JSON array rather than an object
+func isJSONArray(body []byte) bool {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// bindItems decodes a JSON array of items into req and validates it,
+// so that failures are reported by index, such as items[2].quantity
+func bindItems(body []byte, req *models.AddItemsRequest) error {
+	if err := json.Unmarshal(body, &req.Items); err != nil {
+		// Name the item the way validation failures do; the decoder reports "2.quantity"
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			index, field, _ := strings.Cut(typeErr.Field, ".")
+			typeErr.Field = "items[" + index + "]"
+			if field != "" {
+				typeErr.Field += "." + field
+			}
+		}
+		return err
+	}
+	return binding.Validator.ValidateStruct(req)
+}
+
+// invalidBody wraps a request binding error as an invalid input error,
+// listing the offending fields when the error identifies them. The decoder's own
+// message is kept for logging only, as it can echo parts of the body.
+func invalidBody(err error) error {
+	fields := bindingFieldErrors(err)
+	details := "The request body is not valid JSON of the expected shape"
+	if len(fields) > 0 {
+		details = "One or more fields failed validation"
+	}
+
+	return &services.Error{
+		Kind:    services.KindInvalidInput,
+		Message: "Invalid input data",
+		Details: details,
+		Fields:  fields,
+		Err:     err,
+	}
+}
+
+// bindingFieldErrors extracts per-field failures from validator and JSON decoding errors
+func bindingFieldErrors(err error) []models.FieldError {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]models.FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			field := fieldPath(fe)
+			fields = append(fields, models.FieldError{
+				Field:   field,
+				Rule:    fe.Tag(),
+				Param:   fe.Param(),
+				Message: ruleMessage(field, fe),
+			})
+		}
+		return fields
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return []models.FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Param:   typeErr.Type.String(),
+			Message: fmt.Sprintf("%s must be of type %s", typeErr.Field, typeErr.Type),
+		}}
+	}
+
+	return nil
+}
+
+// fieldPath drops the top-level struct name from the validator namespace
+func fieldPath(fe validator.FieldError) string {
+	_, path, found := strings.Cut(fe.Namespace(), ".")
+	if !found {
+		return fe.Field()
+	}
+	return path
+}
+
+// ruleMessage renders a human-readable message for a failed validation rule
+func ruleMessage(field string, fe validator.FieldError) string {
+	isString := fe.Kind() == reflect.String
+
+	switch fe.Tag() {
+	case "required":
+		return field + " is required"
+	case "min":
+		if isString {
+			return fmt.Sprintf("%s must be at least %s characters long", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s", field, fe.Param())
+	case "max":
+		if isString {
+			return fmt.Sprintf("%s must be at most %s characters long", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at most %s", field, fe.Param())
+	case "len":
+		if isString {
+			return fmt.Sprintf("%s must be exactly %s characters long", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must have exactly %s items", field, fe.Param())
+	case "alpha":
+		return field + " must contain only letters"
+	case "uppercase":
+		return field + " must be in upper case"
+	default:
+		if fe.Param() != "" {
+			return fmt.Sprintf("%s failed the %s=%s rule", field, fe.Tag(), fe.Param())
+		}
+		return fmt.Sprintf("%s failed the %s rule", field, fe.Tag())
+	}
+}
@@ -0,0 +1,74 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/handlers"
+	"github.com/LuoZihYuan/Go-Cart/internal/middleware"
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+	"github.com/LuoZihYuan/Go-Cart/internal/services"
+)
+
+func TestInvalidBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := services.NewCartService(repository.NewCartMemoryRepository(), repository.NewProductMemoryRepository(),
+		repository.NewPromotionMemoryRepository(), services.Pricing{Currency: "USD"}, 0, false, services.QuantityLimits{})
+	r := gin.New()
+	r.Use(middleware.Errors(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	r.POST("/shopping-carts/:shoppingCartId/items", handlers.NewCartHandler(service).AddItemsToCart)
+
+	tests := []struct {
+		name    string
+		body    string
+		details string
+		// field and rule name the rejected field; empty if none is identified
+		field, rule string
+	}{
+		{name: "truncated", body: `{"product_id": 1,`, details: "The request body is not valid JSON of the expected shape"},
+		{name: "not JSON", body: `product_id=1`, details: "The request body is not valid JSON of the expected shape"},
+		{name: "unknown shape", body: `"item"`, details: "The request body is not valid JSON of the expected shape"},
+		{name: "wrong type", body: `{"product_id": "one", "quantity": 1}`, details: "One or more fields failed validation", field: "product_id", rule: "type"},
+		{name: "wrong type in batch", body: `[{"product_id": 1, "quantity": "x"}]`, details: "One or more fields failed validation", field: "items[0].quantity", rule: "type"},
+		{name: "failed rule", body: `{"product_id": 1, "quantity": -1}`, details: "One or more fields failed validation", field: "quantity", rule: "min"},
+		{name: "empty batch", body: `[]`, details: "One or more fields failed validation", field: "items", rule: "min"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/shopping-carts/1/items", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", w.Code)
+			}
+			var body models.Error
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Error != "INVALID_INPUT" || body.Details != tt.details {
+				t.Fatalf("body = %+v, want INVALID_INPUT with details %q", body, tt.details)
+			}
+
+			if tt.field == "" {
+				if len(body.Fields) != 0 {
+					t.Fatalf("fields = %+v, want none", body.Fields)
+				}
+				return
+			}
+			if len(body.Fields) != 1 || body.Fields[0].Field != tt.field || body.Fields[0].Rule != tt.rule {
+				t.Fatalf("fields = %+v, want %s failing %s", body.Fields, tt.field, tt.rule)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/notify"
+	"github.com/LuoZihYuan/Go-Cart/internal/pricing"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+)
+
+// AbandonedCartReminder periodically finds carts left idle with items in them and
+// sends one AbandonedCart event per cart.
+// A cart is marked as reminded before its event is sent, so several instances never
+// remind of the same cart twice; if sending fails the mark is removed and the cart
+// retried on the next run. A process that dies between the two loses that reminder.
+type AbandonedCartReminder struct {
+	carts     repository.AbandonedCartStore
+	products  repository.ProductRepository
+	currency  string
+	notifier  notify.Notifier
+	after     time.Duration
+	interval  time.Duration
+	batchSize int
+
+	runs   atomic.Int64
+	sent   atomic.Int64
+	failed atomic.Int64
+}
+
+// AbandonedCartReminderStats is a snapshot of a reminder's counters
+type AbandonedCartReminderStats struct {
+	Runs   int64 `json:"runs"`
+	Sent   int64 `json:"sent"`
+	Failed int64 `json:"failed"`
+}
+
+// NewAbandonedCartReminder creates a reminder that runs every interval and notifies
+// about carts unchanged for after, reading at most batchSize carts at a time. Item
+// prices are looked up in products in each cart's currency, as carts are priced;
+// currency is the base currency, that of Product.Price and of carts naming none.
+func NewAbandonedCartReminder(carts repository.AbandonedCartStore, products repository.ProductRepository, currency string, notifier notify.Notifier, after, interval time.Duration, batchSize int) *AbandonedCartReminder {
+	return &AbandonedCartReminder{
+		carts:     carts,
+		products:  products,
+		currency:  currency,
+		notifier:  notifier,
+		after:     after,
+		interval:  interval,
+		batchSize: batchSize,
+	}
+}
+
+// Run reminds every interval until ctx is done
+func (r *AbandonedCartReminder) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		sent, err := r.Remind(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "failed to send abandoned cart reminders", "sent", sent, "error", err)
+		} else if sent > 0 {
+			slog.InfoContext(ctx, "sent abandoned cart reminders", "sent", sent)
+		}
+	}
+}
+
+// Remind notifies about every abandoned cart not yet reminded of, batch by batch, and
+// reports how many reminders it sent. It stops after a batch in which a reminder
+// failed, since that cart would only be listed again.
+func (r *AbandonedCartReminder) Remind(ctx context.Context) (int, error) {
+	r.runs.Add(1)
+	now := time.Now()
+	idleSince := now.Add(-r.after)
+
+	total := 0
+	for {
+		carts, err := r.carts.ListAbandoned(ctx, idleSince, now, r.batchSize)
+		if err != nil {
+			return total, err
+		}
+
+		var errs []error
+		for _, cart := range carts {
+			sent, err := r.remind(ctx, cart, idleSince, now)
+			if err != nil {
+				r.failed.Add(1)
+				errs = append(errs, err)
+			} else if sent {
+				total++
+				r.sent.Add(1)
+			}
+		}
+		if len(errs) > 0 {
+			return total, errors.Join(errs...)
+		}
+		// A short batch means nothing is left
+		if len(carts) < r.batchSize {
+			return total, nil
+		}
+	}
+}
+
+// remind sends the event for one cart, reporting false if another caller got to it
+// first or it changed since it was listed
+func (r *AbandonedCartReminder) remind(ctx context.Context, cart models.Cart, idleSince, now time.Time) (bool, error) {
+	marked, err := r.carts.MarkReminded(ctx, cart.CartID, idleSince, now)
+	if err != nil || !marked {
+		return false, err
+	}
+
+	event, err := r.event(ctx, cart)
+	if err == nil {
+		err = r.notifier.NotifyAbandonedCart(ctx, event)
+	}
+	if err != nil {
+		// Unmark even if ctx was canceled mid-send, so the reminder is not lost
+		if unmarkErr := r.carts.UnmarkReminded(context.WithoutCancel(ctx), cart.CartID); unmarkErr != nil {
+			err = errors.Join(err, unmarkErr)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// event builds the AbandonedCart event for cart at current prices in its currency
+func (r *AbandonedCartReminder) event(ctx context.Context, cart models.Cart) (notify.AbandonedCart, error) {
+	event := notify.AbandonedCart{
+		CartID:     cart.CartID,
+		CustomerID: cart.CustomerID,
+		Items:      make([]notify.AbandonedCartItem, len(cart.Items)),
+		Currency:   cart.Currency,
+		IdleSince:  cart.UpdatedAt,
+	}
+	// Carts made before carts had currencies are in the base currency
+	if event.Currency == "" {
+		event.Currency = r.currency
+	}
+
+	productIDs := make([]int, len(cart.Items))
+	for i, item := range cart.Items {
+		productIDs[i] = item.ProductID
+	}
+	products, err := r.products.GetByIDs(ctx, productIDs)
+	if err != nil {
+		return notify.AbandonedCart{}, err
+	}
+	byID := make(map[int]*models.Product, len(products))
+	for i := range products {
+		byID[products[i].ProductID] = &products[i]
+	}
+
+	for i, line := range pricing.Lines(cart.Items, byID, event.Currency, r.currency) {
+		event.Items[i] = notify.AbandonedCartItem{ProductID: line.ProductID, Quantity: line.Quantity, UnitPrice: line.UnitPrice}
+		event.Value += line.UnitPrice * int64(line.Quantity)
+	}
+	return event, nil
+}
+
+// Stats returns a snapshot for metrics
+func (r *AbandonedCartReminder) Stats() AbandonedCartReminderStats {
+	return AbandonedCartReminderStats{
+		Runs:   r.runs.Load(),
+		Sent:   r.sent.Load(),
+		Failed: r.failed.Load(),
+	}
+}
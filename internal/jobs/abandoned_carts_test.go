@@ -0,0 +1,117 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/jobs"
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/notify"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+)
+
+// recordingNotifier keeps the events it is sent, failing for carts in fail
+type recordingNotifier struct {
+	events []notify.AbandonedCart
+	fail   map[int]bool
+}
+
+func (n *recordingNotifier) NotifyAbandonedCart(_ context.Context, event notify.AbandonedCart) error {
+	if n.fail[event.CartID] {
+		return errors.New("notifier unavailable")
+	}
+	n.events = append(n.events, event)
+	return nil
+}
+
+func TestAbandonedCartReminderNotifiesOnce(t *testing.T) {
+	carts := repository.NewCartMemoryRepository()
+	products := repository.NewProductMemoryRepository()
+	if err := products.Upsert(t.Context(), &models.Product{ProductID: 1, Price: 250}); err != nil {
+		t.Fatal(err)
+	}
+
+	idle := time.Now().Add(-2 * time.Hour).UTC().Truncate(time.Second)
+	for _, cart := range []models.Cart{
+		{CartID: 1, CustomerID: 10, UpdatedAt: idle, Items: []models.CartItem{{ProductID: 1, Quantity: 2}, {ProductID: 99, Quantity: 1}}},
+		{CartID: 2, CustomerID: 20, UpdatedAt: idle, Items: []models.CartItem{{ProductID: 1, Quantity: 1}}},
+		{CartID: 3, CustomerID: 30, UpdatedAt: time.Now().UTC(), Items: []models.CartItem{{ProductID: 1, Quantity: 1}}},
+	} {
+		if err := carts.Put(t.Context(), &cart); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	notifier := &recordingNotifier{fail: map[int]bool{2: true}}
+	reminder := jobs.NewAbandonedCartReminder(carts, products, "USD", notifier, time.Hour, time.Minute, 1)
+
+	// A failed reminder is reported and retried on the next run
+	sent, err := reminder.Remind(t.Context())
+	if err == nil || sent != 1 {
+		t.Fatalf("first Remind = %d, %v; want 1 and an error", sent, err)
+	}
+	delete(notifier.fail, 2)
+	for _, want := range []int{1, 0} {
+		sent, err := reminder.Remind(t.Context())
+		if err != nil {
+			t.Fatalf("Remind: %v", err)
+		}
+		if sent != want {
+			t.Fatalf("Remind sent %d reminders, want %d", sent, want)
+		}
+	}
+
+	if len(notifier.events) != 2 {
+		t.Fatalf("notified %d times, want once for each of carts 1 and 2: %+v", len(notifier.events), notifier.events)
+	}
+	first := notifier.events[0]
+	// Carts naming no currency are in the base currency
+	if first.CartID != 1 || first.CustomerID != 10 || first.Value != 500 || first.Currency != "USD" || !first.IdleSince.Equal(idle) {
+		t.Errorf("event = %+v, want cart 1 of customer 10 worth 500 USD", first)
+	}
+	// A product that no longer exists is listed without a price
+	if len(first.Items) != 2 || first.Items[0].UnitPrice != 250 || first.Items[1].UnitPrice != 0 {
+		t.Errorf("items = %+v", first.Items)
+	}
+
+	if stats := reminder.Stats(); stats.Runs != 3 || stats.Sent != 2 || stats.Failed != 1 {
+		t.Errorf("Stats = %+v, want 3 runs sending 2 reminders with 1 failure", stats)
+	}
+}
+
+func TestAbandonedCartReminderPricesInCartCurrency(t *testing.T) {
+	carts := repository.NewCartMemoryRepository()
+	products := repository.NewProductMemoryRepository()
+	for _, product := range []models.Product{
+		{ProductID: 1, Price: 250, Prices: map[string]int64{"EUR": 230}},
+		{ProductID: 2, Price: 100},
+	} {
+		if err := products.Upsert(t.Context(), &product); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idle := time.Now().Add(-2 * time.Hour).UTC().Truncate(time.Second)
+	cart := models.Cart{CartID: 1, CustomerID: 10, UpdatedAt: idle, Currency: "EUR",
+		Items: []models.CartItem{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 1}}}
+	if err := carts.Put(t.Context(), &cart); err != nil {
+		t.Fatal(err)
+	}
+
+	notifier := &recordingNotifier{}
+	reminder := jobs.NewAbandonedCartReminder(carts, products, "USD", notifier, time.Hour, time.Minute, 10)
+	if sent, err := reminder.Remind(t.Context()); err != nil || sent != 1 {
+		t.Fatalf("Remind = %d, %v; want 1", sent, err)
+	}
+
+	// A product with no price in the cart's currency is listed without one, as it is priced
+	event := notifier.events[0]
+	if event.Currency != "EUR" || event.Value != 460 {
+		t.Errorf("event = %+v, want one worth 460 EUR", event)
+	}
+	if len(event.Items) != 2 || event.Items[0].UnitPrice != 230 || event.Items[1].UnitPrice != 0 {
+		t.Errorf("items = %+v", event.Items)
+	}
+}
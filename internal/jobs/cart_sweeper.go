@@ -0,0 +1,93 @@
+// Package jobs runs periodic work in the background of the API server.
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+)
+
+// CartSweeper periodically deletes expired carts, a batch at a time so no
+// single statement holds locks on many carts
+type CartSweeper struct {
+	carts     repository.ExpiredCartDeleter
+	interval  time.Duration
+	retention time.Duration
+	batchSize int
+
+	sweeps  atomic.Int64
+	deleted atomic.Int64
+	failed  atomic.Int64
+}
+
+// CartSweeperStats is a snapshot of a sweeper's counters
+type CartSweeperStats struct {
+	Sweeps  int64 `json:"sweeps"`
+	Deleted int64 `json:"deleted"`
+	Failed  int64 `json:"failed"`
+}
+
+// NewCartSweeper creates a sweeper that runs every interval and deletes carts that
+// expired more than retention ago, at most batchSize per statement
+func NewCartSweeper(carts repository.ExpiredCartDeleter, interval, retention time.Duration, batchSize int) *CartSweeper {
+	return &CartSweeper{
+		carts:     carts,
+		interval:  interval,
+		retention: retention,
+		batchSize: batchSize,
+	}
+}
+
+// Run sweeps every interval until ctx is done
+func (s *CartSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		deleted, err := s.Sweep(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "failed to delete expired carts", "deleted", deleted, "error", err)
+		} else if deleted > 0 {
+			slog.InfoContext(ctx, "deleted expired carts", "deleted", deleted)
+		}
+	}
+}
+
+// Sweep deletes every cart past its retention, batch by batch, and reports how many it deleted
+func (s *CartSweeper) Sweep(ctx context.Context) (int, error) {
+	s.sweeps.Add(1)
+	before := time.Now().Add(-s.retention)
+
+	total := 0
+	for {
+		deleted, err := s.carts.DeleteExpired(ctx, before, s.batchSize)
+		total += deleted
+		s.deleted.Add(int64(deleted))
+		if err != nil {
+			s.failed.Add(1)
+			return total, err
+		}
+		// A short batch means nothing is left
+		if deleted < s.batchSize {
+			return total, nil
+		}
+	}
+}
+
+// Stats returns a snapshot for metrics
+func (s *CartSweeper) Stats() CartSweeperStats {
+	return CartSweeperStats{
+		Sweeps:  s.sweeps.Load(),
+		Deleted: s.deleted.Load(),
+		Failed:  s.failed.Load(),
+	}
+}
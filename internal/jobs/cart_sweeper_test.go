@@ -0,0 +1,51 @@
+package jobs_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/jobs"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+)
+
+func TestCartSweeperDeletesPastRetention(t *testing.T) {
+	carts := repository.NewCartMemoryRepository()
+	now := time.Now()
+
+	var swept []int
+	for range 5 {
+		cart, err := carts.Create(t.Context(), 1, "", "USD", now.Add(-2*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		swept = append(swept, cart.CartID)
+	}
+	// Expired, but still within retention so clients are told it is gone
+	recent, _ := carts.Create(t.Context(), 2, "", "USD", now.Add(-30*time.Minute))
+	live, _ := carts.Create(t.Context(), 3, "", "USD", now.Add(time.Hour))
+
+	sweeper := jobs.NewCartSweeper(carts, time.Minute, time.Hour, 2)
+	deleted, err := sweeper.Sweep(t.Context())
+	if err != nil {
+		t.Fatalf("Sweep: %v", err)
+	}
+	if deleted != len(swept) {
+		t.Fatalf("Sweep deleted %d carts, want %d", deleted, len(swept))
+	}
+
+	for _, cartID := range swept {
+		if _, err := carts.GetByID(t.Context(), cartID); !errors.Is(err, repository.ErrCartNotFound) {
+			t.Errorf("cart %d past retention was kept: %v", cartID, err)
+		}
+	}
+	for _, cartID := range []int{recent.CartID, live.CartID} {
+		if _, err := carts.GetByID(t.Context(), cartID); err != nil {
+			t.Errorf("cart %d was deleted too early: %v", cartID, err)
+		}
+	}
+
+	if stats := sweeper.Stats(); stats.Sweeps != 1 || stats.Deleted != int64(len(swept)) || stats.Failed != 0 {
+		t.Errorf("Stats = %+v, want one sweep deleting %d carts", stats, len(swept))
+	}
+}
@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// ProblemJSON is the RFC 7807 media type clients can request via Accept
+const ProblemJSON = "application/problem+json"
+
+// problemTypeBase prefixes the per-code problem type URIs
+const problemTypeBase = "/problems/"
+
+// Errors renders the last error a handler attached with c.Error.
+// Domain errors are mapped to their status and code; anything else is logged
+// and reported to the client as a generic 500 carrying only the request ID.
+// Clients accepting application/problem+json receive an RFC 7807 document,
+// all others receive models.Error.
+func Errors(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		status, body := translateError(c, logger, c.Errors.Last().Err)
+		writeError(c, status, body)
+	}
+}
+
+// writeError aborts the request with a translated error, as an RFC 7807 problem
+// if the client prefers one and as models.Error otherwise
+func writeError(c *gin.Context, status int, body models.Error) {
+	if wantsProblem(c) {
+		c.Header("Content-Type", ProblemJSON)
+		c.AbortWithStatusJSON(status, newProblem(c, status, body))
+		return
+	}
+	c.AbortWithStatusJSON(status, body)
+}
+
+// translateError maps err to an HTTP status and a client-safe error body
+func translateError(c *gin.Context, logger *slog.Logger, err error) (int, models.Error) {
+	requestID := GetRequestID(c)
+
+	var domainErr *services.Error
+	if errors.As(err, &domainErr) && domainErr.Kind != services.KindInternal {
+		status, code := statusAndCode(domainErr.Kind)
+		if domainErr.Code != "" {
+			code = domainErr.Code
+		}
+		if domainErr.Kind == services.KindUnavailable {
+			logger.WarnContext(c.Request.Context(), "backend unavailable", slog.Any("error", err))
+			c.Header("Retry-After", retryAfter(err))
+		}
+		return status, models.Error{
+			Error:     code,
+			Message:   domainErr.Message,
+			Details:   domainErr.Details,
+			RequestID: requestID,
+			Fields:    domainErr.Fields,
+		}
+	}
+
+	logger.ErrorContext(c.Request.Context(), "internal error",
+		slog.Any("error", err),
+		slog.String("method", c.Request.Method),
+		slog.String("path", c.Request.URL.Path),
+	)
+
+	return http.StatusInternalServerError, internalError(requestID)
+}
+
+// internalError is the body reported for any failure whose cause must not reach clients
+func internalError(requestID string) models.Error {
+	return models.Error{
+		Error:     "INTERNAL_ERROR",
+		Message:   "Internal server error",
+		Details:   "Reference ID: " + requestID,
+		RequestID: requestID,
+	}
+}
+
+// statusAndCode maps a domain error kind to its HTTP status and error code
+func statusAndCode(kind services.Kind) (int, string) {
+	switch kind {
+	case services.KindInvalidInput:
+		return http.StatusBadRequest, "INVALID_INPUT"
+	case services.KindNotFound:
+		return http.StatusNotFound, "NOT_FOUND"
+	case services.KindInvalidState:
+		return http.StatusBadRequest, "INVALID_STATE"
+	case services.KindUnavailable:
+		return http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE"
+	case services.KindGone:
+		return http.StatusGone, "GONE"
+	default:
+		return http.StatusInternalServerError, "INTERNAL_ERROR"
+	}
+}
+
+// retryAfter returns the Retry-After header value, in whole seconds, for an error
+// that says when to retry (such as an open circuit breaker), or 1 otherwise
+func retryAfter(err error) string {
+	var hint interface{ RetryAfter() time.Duration }
+	seconds := 1
+	if errors.As(err, &hint) {
+		seconds = max(int(math.Ceil(hint.RetryAfter().Seconds())), 1)
+	}
+	return strconv.Itoa(seconds)
+}
+
+// wantsProblem reports whether the client prefers application/problem+json
+func wantsProblem(c *gin.Context) bool {
+	if !strings.Contains(c.GetHeader("Accept"), ProblemJSON) {
+		return false
+	}
+	return c.NegotiateFormat(binding.MIMEJSON, ProblemJSON) == ProblemJSON
+}
+
+// newProblem converts a translated error into an RFC 7807 problem details document
+func newProblem(c *gin.Context, status int, body models.Error) models.Problem {
+	detail := body.Details
+	if detail == "" {
+		detail = body.Message
+	}
+
+	return models.Problem{
+		Type:          problemTypeBase + strings.ReplaceAll(strings.ToLower(body.Error), "_", "-"),
+		Title:         problemTitle(body.Error, status),
+		Status:        status,
+		Detail:        detail,
+		Instance:      c.Request.URL.Path,
+		Code:          body.Error,
+		RequestID:     body.RequestID,
+		InvalidParams: body.Fields,
+	}
+}
+
+// problemTitle returns the fixed, human-readable title for an error code
+func problemTitle(code string, status int) string {
+	switch code {
+	case "INVALID_INPUT":
+		return "Invalid Input"
+	case "INVALID_STATE":
+		return "Invalid State"
+	case "CART_EXPIRED":
+		return "Cart Expired"
+	default:
+		return http.StatusText(status)
+	}
+}
@@ -0,0 +1,192 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/middleware"
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/services"
+)
+
+// openBreaker is a cause that says when to retry, like an open circuit breaker
+type openBreaker struct{}
+
+func (openBreaker) Error() string             { return "circuit breaker is open" }
+func (openBreaker) RetryAfter() time.Duration { return 2500 * time.Millisecond }
+
+// newErrorRouter serves GET /fail, which fails with err, or panics if err is nil
+func newErrorRouter(err error) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	r := gin.New()
+	r.Use(middleware.RequestID())
+	r.Use(middleware.Recovery(logger))
+	r.Use(middleware.Errors(logger))
+	r.GET("/fail", func(c *gin.Context) {
+		if err == nil {
+			panic("handler bug")
+		}
+		c.Error(err)
+	})
+	return r
+}
+
+func TestErrors(t *testing.T) {
+	fields := []models.FieldError{{Field: "quantity", Rule: "min", Param: "1", Message: "quantity must be at least 1"}}
+
+	tests := []struct {
+		name       string
+		err        error // nil panics
+		status     int
+		code       string
+		title      string
+		details    string
+		retryAfter string
+		fields     []models.FieldError
+	}{
+		{
+			name:    "invalid input",
+			err:     &services.Error{Kind: services.KindInvalidInput, Message: "Invalid input data", Details: "One or more fields failed validation", Fields: fields},
+			status:  http.StatusBadRequest,
+			code:    "INVALID_INPUT",
+			title:   "Invalid Input",
+			details: "One or more fields failed validation",
+			fields:  fields,
+		},
+		{
+			name:    "not found",
+			err:     services.ErrCartNotFound,
+			status:  http.StatusNotFound,
+			code:    "NOT_FOUND",
+			title:   "Not Found",
+			details: services.ErrCartNotFound.Details,
+		},
+		{
+			name:    "invalid state",
+			err:     services.ErrEmptyCart,
+			status:  http.StatusBadRequest,
+			code:    "INVALID_STATE",
+			title:   "Invalid State",
+			details: services.ErrEmptyCart.Details,
+		},
+		{
+			name:    "invalid state with its own code",
+			err:     services.ErrCouponUsedUp,
+			status:  http.StatusBadRequest,
+			code:    "COUPON_USED_UP",
+			title:   "Bad Request",
+			details: services.ErrCouponUsedUp.Details,
+		},
+		{
+			name:    "gone",
+			err:     services.ErrCartExpired,
+			status:  http.StatusGone,
+			code:    "CART_EXPIRED",
+			title:   "Cart Expired",
+			details: services.ErrCartExpired.Details,
+		},
+		{
+			name:       "unavailable",
+			err:        &services.Error{Kind: services.KindUnavailable, Message: "Service unavailable", Details: "Try again later", Err: openBreaker{}},
+			status:     http.StatusServiceUnavailable,
+			code:       "SERVICE_UNAVAILABLE",
+			title:      "Service Unavailable",
+			details:    "Try again later",
+			retryAfter: "3",
+		},
+		{
+			name:    "internal",
+			err:     services.Internal(errors.New("disk on fire")),
+			status:  http.StatusInternalServerError,
+			code:    "INTERNAL_ERROR",
+			title:   "Internal Server Error",
+			details: "Reference ID: req-1",
+		},
+		{
+			name:    "not a service error",
+			err:     errors.New("disk on fire"),
+			status:  http.StatusInternalServerError,
+			code:    "INTERNAL_ERROR",
+			title:   "Internal Server Error",
+			details: "Reference ID: req-1",
+		},
+		{
+			name:    "panic",
+			status:  http.StatusInternalServerError,
+			code:    "INTERNAL_ERROR",
+			title:   "Internal Server Error",
+			details: "Reference ID: req-1",
+		},
+	}
+
+	accepts := []struct {
+		accept  string
+		problem bool
+	}{
+		{accept: "", problem: false},
+		{accept: "*/*", problem: false},
+		{accept: "application/json", problem: false},
+		{accept: "application/problem+json", problem: true},
+		{accept: "application/problem+json, application/json", problem: true},
+		{accept: "application/json, application/problem+json", problem: false},
+	}
+
+	for _, tt := range tests {
+		for _, accept := range accepts {
+			t.Run(tt.name+"/"+accept.accept, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, "/fail", nil)
+				req.Header.Set(middleware.RequestIDHeader, "req-1")
+				if accept.accept != "" {
+					req.Header.Set("Accept", accept.accept)
+				}
+				w := httptest.NewRecorder()
+				newErrorRouter(tt.err).ServeHTTP(w, req)
+
+				if w.Code != tt.status {
+					t.Fatalf("status = %d, want %d", w.Code, tt.status)
+				}
+				if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+					t.Fatalf("Retry-After = %q, want %q", got, tt.retryAfter)
+				}
+
+				contentType := w.Header().Get("Content-Type")
+				if !accept.problem {
+					if contentType != "application/json; charset=utf-8" {
+						t.Fatalf("Content-Type = %q, want JSON", contentType)
+					}
+					var body models.Error
+					if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+						t.Fatal(err)
+					}
+					if body.Error != tt.code || body.Details != tt.details || body.RequestID != "req-1" || len(body.Fields) != len(tt.fields) {
+						t.Fatalf("body = %+v, want code %s, details %q and %d fields", body, tt.code, tt.details, len(tt.fields))
+					}
+					return
+				}
+
+				if contentType != middleware.ProblemJSON {
+					t.Fatalf("Content-Type = %q, want %s", contentType, middleware.ProblemJSON)
+				}
+				var problem models.Problem
+				if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+					t.Fatal(err)
+				}
+				if problem.Status != tt.status || problem.Code != tt.code || problem.Title != tt.title || problem.Detail != tt.details ||
+					problem.Instance != "/fail" || problem.RequestID != "req-1" || len(problem.InvalidParams) != len(tt.fields) {
+					t.Fatalf("problem = %+v, want status %d, code %s, title %q, detail %q and %d invalid params",
+						problem, tt.status, tt.code, tt.title, tt.details, len(tt.fields))
+				}
+			})
+		}
+	}
+}
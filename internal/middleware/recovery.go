@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Recovery turns panics into a logged 500 response instead of a dropped connection,
+// written as Errors writes any other internal error
+func Recovery(logger *slog.Logger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, recovered any) {
+		logger.ErrorContext(c.Request.Context(), "panic recovered",
+			slog.Any("panic", recovered),
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+		)
+
+		writeError(c, http.StatusInternalServerError, internalError(GetRequestID(c)))
+	})
+}
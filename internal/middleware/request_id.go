@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader is the header used to accept and echo request IDs
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the size of client-supplied request IDs
+const maxRequestIDLength = 128
+
+// RequestID accepts the client's X-Request-ID or generates a new one,
+// stores it in the request context and echoes it in the response
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+		c.Header(RequestIDHeader, requestID)
+
+		c.Next()
+	}
+}
+
+// GetRequestID returns the request ID assigned to the current request
+func GetRequestID(c *gin.Context) string {
+	return logging.RequestID(c.Request.Context())
+}
+
+// validRequestID only accepts short, printable IDs so they are safe to log and echo
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range requestID {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
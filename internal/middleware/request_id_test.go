@@ -0,0 +1,140 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/logging"
+	"github.com/LuoZihYuan/Go-Cart/internal/middleware"
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/services"
+)
+
+// generatedRequestID matches the IDs RequestID makes up
+var generatedRequestID = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// newRequestIDRouter serves GET /ok, which answers with the request ID it sees, and
+// GET /missing, which fails as not found, logging requests to log
+func newRequestIDRouter(log *bytes.Buffer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := logging.New(log, slog.LevelInfo)
+
+	r := gin.New()
+	r.Use(middleware.RequestID())
+	r.Use(middleware.Logger(logger))
+	r.Use(middleware.Recovery(logger))
+	r.Use(middleware.Errors(logger))
+	r.GET("/ok", func(c *gin.Context) {
+		c.String(http.StatusOK, middleware.GetRequestID(c))
+	})
+	r.GET("/missing", func(c *gin.Context) {
+		c.Error(services.ErrCartNotFound)
+	})
+	return r
+}
+
+// accessLogRequestID returns the request ID of the one access log record in log
+func accessLogRequestID(t *testing.T, log *bytes.Buffer) string {
+	t.Helper()
+
+	var requestIDs []string
+	for line := range strings.Lines(log.String()) {
+		var record struct {
+			Msg       string `json:"msg"`
+			RequestID string `json:"request_id"`
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("decode log line %s: %v", line, err)
+		}
+		if record.Msg == "request" {
+			requestIDs = append(requestIDs, record.RequestID)
+		}
+	}
+	if len(requestIDs) != 1 {
+		t.Fatalf("access log records request IDs %q, want one record", requestIDs)
+	}
+	return requestIDs[0]
+}
+
+func TestRequestID(t *testing.T) {
+	longest := strings.Repeat("a", 128)
+
+	tests := []struct {
+		name    string
+		inbound string // empty sends no header
+		kept    bool
+	}{
+		{name: "none"},
+		{name: "valid", inbound: "req-1", kept: true},
+		{name: "printable punctuation", inbound: "trace:4bf92f35/span=00f0;x", kept: true},
+		{name: "longest", inbound: longest, kept: true},
+		{name: "oversized", inbound: longest + "a"},
+		{name: "space", inbound: "req 1"},
+		{name: "control character", inbound: "req\x7f1"},
+		{name: "non-ASCII", inbound: "réq-1"},
+		{name: "log injection", inbound: "req-1\n{\"level\":\"ERROR\"}"},
+	}
+
+	for _, tt := range tests {
+		for _, path := range []string{"/ok", "/missing"} {
+			t.Run(tt.name+path, func(t *testing.T) {
+				var log bytes.Buffer
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				if tt.inbound != "" {
+					req.Header.Set(middleware.RequestIDHeader, tt.inbound)
+				}
+				w := httptest.NewRecorder()
+				newRequestIDRouter(&log).ServeHTTP(w, req)
+
+				requestID := w.Header().Get(middleware.RequestIDHeader)
+				if tt.kept && requestID != tt.inbound {
+					t.Fatalf("echoed request ID = %q, want the inbound %q", requestID, tt.inbound)
+				}
+				if !tt.kept && !generatedRequestID.MatchString(requestID) {
+					t.Fatalf("echoed request ID = %q, want a generated one", requestID)
+				}
+
+				// The same ID reaches the handler, the access log and any error body
+				if got := accessLogRequestID(t, &log); got != requestID {
+					t.Fatalf("access log request ID = %q, want %q", got, requestID)
+				}
+				if path == "/ok" {
+					if got := w.Body.String(); got != requestID {
+						t.Fatalf("handler saw request ID %q, want %q", got, requestID)
+					}
+					return
+				}
+				var body models.Error
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatal(err)
+				}
+				if body.RequestID != requestID {
+					t.Fatalf("error body request ID = %q, want %q", body.RequestID, requestID)
+				}
+			})
+		}
+	}
+}
+
+func TestRequestIDGeneratesDistinctIDs(t *testing.T) {
+	r := newRequestIDRouter(&bytes.Buffer{})
+
+	seen := make(map[string]bool)
+	for range 10 {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ok", nil))
+		requestID := w.Header().Get(middleware.RequestIDHeader)
+		if seen[requestID] {
+			t.Fatalf("request ID %q generated twice", requestID)
+		}
+		seen[requestID] = true
+	}
+}
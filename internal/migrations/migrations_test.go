@@ -0,0 +1,77 @@
+package migrations_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/migrations"
+	_ "modernc.org/sqlite"
+)
+
+// hasColumn reports whether table has a column named column
+func hasColumn(t *testing.T, db *sql.DB, table, column string) bool {
+	t.Helper()
+
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n > 0
+}
+
+func TestSQLiteFailedMigrationIsRolledBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gocart.db")
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrations.NewSQLite(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := t.Context()
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	// Roll back the last migration, which adds products.prices and then carts.currency,
+	// and make its second statement fail
+	rolledBack, err := migrator.Down(ctx, 1)
+	if err != nil || len(rolledBack) != 1 {
+		t.Fatalf("Down = %v, %v, want one migration", rolledBack, err)
+	}
+	last := rolledBack[0]
+	if _, err := db.Exec("ALTER TABLE carts ADD COLUMN currency TEXT"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrator.Up(ctx); err == nil {
+		t.Fatal("Up succeeded, want the duplicate column to fail it")
+	}
+	if hasColumn(t, db, "products", "prices") {
+		t.Fatal("products.prices was kept from the failed migration")
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied := statuses[len(statuses)-1]; applied.Version != last.Version || applied.AppliedAt != nil {
+		t.Fatalf("last migration status = %+v, want %d not applied", applied, last.Version)
+	}
+
+	// Once the conflict is gone, the migration applies in full
+	if _, err := db.Exec("ALTER TABLE carts DROP COLUMN currency"); err != nil {
+		t.Fatal(err)
+	}
+	applied, err := migrator.Up(ctx)
+	if err != nil || len(applied) != 1 || applied[0].Version != last.Version {
+		t.Fatalf("Up = %v, %v, want migration %d", applied, err, last.Version)
+	}
+	if !hasColumn(t, db, "products", "prices") || !hasColumn(t, db, "carts", "currency") {
+		t.Fatal("retried migration did not add its columns")
+	}
+}
@@ -0,0 +1,59 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+)
+
+//go:embed mysql/*.sql
+var mysqlFiles embed.FS
+
+// mysqlLockName is the GET_LOCK name shared by every instance migrating the same database
+const mysqlLockName = "gocart_schema_migrations"
+
+// mysqlLockTimeoutSeconds bounds how long an instance waits for another to finish migrating
+const mysqlLockTimeoutSeconds = 300
+
+// NewMySQL creates a Migrator for the embedded MySQL migrations
+func NewMySQL(db *sql.DB) (*Migrator, error) {
+	return New(db, mysqlDialect{}, mysqlFiles, "mysql")
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) CreateVersionTable() string {
+	return `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at DATETIME NOT NULL
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci
+	`
+}
+
+func (mysqlDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (mysqlDialect) Lock(ctx context.Context, conn *sql.Conn) error {
+	var acquired sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", mysqlLockName, mysqlLockTimeoutSeconds).Scan(&acquired)
+	if err != nil {
+		return err
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return ErrLockTimeout
+	}
+	return nil
+}
+
+func (mysqlDialect) Unlock(ctx context.Context, conn *sql.Conn, failed bool) error {
+	_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", mysqlLockName)
+	return err
+}
+
+// MigrationTx is false: MySQL commits DDL statements implicitly
+func (mysqlDialect) MigrationTx() bool {
+	return false
+}
@@ -1,7 +1,6 @@
--- E-commerce Database Schema for Go-Cart
--- MySQL 8.4.6
+-- Initial Go-Cart schema
+-- Uses IF NOT EXISTS so databases created from the former init.sql are adopted as-is
 
--- Products table
 CREATE TABLE IF NOT EXISTS products (
   product_id INT PRIMARY KEY,
   sku VARCHAR(100) NOT NULL UNIQUE,
@@ -15,7 +14,6 @@ CREATE TABLE IF NOT EXISTS products (
   INDEX idx_sku (sku)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
 
--- Carts table
 CREATE TABLE IF NOT EXISTS carts (
   cart_id INT PRIMARY KEY AUTO_INCREMENT,
   customer_id INT NOT NULL,
@@ -23,7 +21,6 @@ CREATE TABLE IF NOT EXISTS carts (
   INDEX idx_customer (customer_id)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
 
--- Cart items table
 CREATE TABLE IF NOT EXISTS cart_items (
   cart_id INT NOT NULL,
   product_id INT NOT NULL,
@@ -0,0 +1,2 @@
+-- Product price in minor currency units (e.g. cents)
+ALTER TABLE products ADD COLUMN price BIGINT NOT NULL DEFAULT 0 AFTER weight;
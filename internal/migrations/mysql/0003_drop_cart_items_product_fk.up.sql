@@ -0,0 +1,3 @@
+-- Cart repositories no longer require items to reference a stored product;
+-- the cart service checks that products exist before adding them
+ALTER TABLE cart_items DROP FOREIGN KEY cart_items_ibfk_2;
@@ -0,0 +1,4 @@
+ALTER TABLE carts
+  DROP INDEX idx_carts_expires_at,
+  DROP COLUMN expires_at,
+  DROP COLUMN updated_at;
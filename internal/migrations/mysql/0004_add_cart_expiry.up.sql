@@ -0,0 +1,8 @@
+-- Carts record when they last changed and when they expire, both in UTC.
+-- Existing carts get no expiry until they next change.
+ALTER TABLE carts
+  ADD COLUMN updated_at DATETIME NULL,
+  ADD COLUMN expires_at DATETIME NULL,
+  ADD INDEX idx_carts_expires_at (expires_at);
+
+UPDATE carts SET updated_at = created_at;
@@ -0,0 +1,3 @@
+ALTER TABLE carts
+  DROP INDEX idx_carts_updated_at,
+  DROP COLUMN reminded_at;
@@ -0,0 +1,4 @@
+-- Carts record when the customer was reminded of them after they were abandoned, in UTC.
+ALTER TABLE carts
+  ADD COLUMN reminded_at DATETIME NULL,
+  ADD INDEX idx_carts_updated_at (updated_at);
@@ -0,0 +1,3 @@
+ALTER TABLE carts
+  DROP INDEX uq_carts_active_customer_id,
+  DROP COLUMN active_customer_id;
@@ -0,0 +1,5 @@
+-- A customer's active cart holds their ID in active_customer_id; the unique index
+-- keeps concurrent requests from making two carts active for one customer.
+ALTER TABLE carts
+  ADD COLUMN active_customer_id INT NULL,
+  ADD UNIQUE INDEX uq_carts_active_customer_id (active_customer_id);
@@ -0,0 +1,2 @@
+-- Most of a product one cart may hold; 0 means no limit beyond the cart's own.
+ALTER TABLE products ADD COLUMN purchase_limit INT NOT NULL DEFAULT 0 AFTER some_other_id;
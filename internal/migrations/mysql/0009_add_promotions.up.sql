@@ -0,0 +1,31 @@
+-- Promotions are looked up by their coupon code. Their limits and amounts are 0 when
+-- unused, and their validity window is open-ended where starts_at or ends_at is NULL.
+CREATE TABLE IF NOT EXISTS promotions (
+  code VARCHAR(32) PRIMARY KEY,
+  type VARCHAR(20) NOT NULL,
+  percent_off INT NOT NULL DEFAULT 0,
+  amount_off BIGINT NOT NULL DEFAULT 0,
+  buy_quantity INT NOT NULL DEFAULT 0,
+  get_quantity INT NOT NULL DEFAULT 0,
+  category_id INT NOT NULL DEFAULT 0,
+  product_id INT NOT NULL DEFAULT 0,
+  starts_at DATETIME NULL,
+  ends_at DATETIME NULL,
+  max_uses INT NOT NULL DEFAULT 0,
+  max_uses_per_customer INT NOT NULL DEFAULT 0,
+  uses INT NOT NULL DEFAULT 0,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- How many times each customer has redeemed each code
+CREATE TABLE IF NOT EXISTS promotion_redemptions (
+  code VARCHAR(32) NOT NULL,
+  customer_id INT NOT NULL,
+  uses INT NOT NULL DEFAULT 0,
+  PRIMARY KEY (code, customer_id),
+  FOREIGN KEY (code) REFERENCES promotions(code) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- The coupon codes applied to a cart, comma-separated in the order they were applied
+ALTER TABLE carts ADD COLUMN coupons VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- The ID of the shipping option chosen for a cart; empty until one is chosen
+ALTER TABLE carts ADD COLUMN shipping_option_id VARCHAR(64) NOT NULL DEFAULT '';
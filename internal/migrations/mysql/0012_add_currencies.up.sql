@@ -0,0 +1,5 @@
+-- A product's price in each other currency it is sold in, as JSON; NULL if none
+ALTER TABLE products ADD COLUMN prices TEXT NULL;
+
+-- The ISO 4217 code a cart is priced in; empty for carts in the base currency made before carts had one
+ALTER TABLE carts ADD COLUMN currency CHAR(3) NOT NULL DEFAULT '';
@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"strconv"
+	"time"
+)
+
+//go:embed postgres/*.sql
+var postgresFiles embed.FS
+
+// postgresLockKey is the advisory lock key shared by every instance migrating the same database
+const postgresLockKey = 0x676f63617274 // "gocart"
+
+// postgresLockTimeout bounds how long an instance waits for another to finish migrating
+const postgresLockTimeout = 300 * time.Second
+
+// NewPostgres creates a Migrator for the embedded PostgreSQL migrations
+func NewPostgres(db *sql.DB) (*Migrator, error) {
+	return New(db, postgresDialect{}, postgresFiles, "postgres")
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) CreateVersionTable() string {
+	return `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL
+		)
+	`
+}
+
+func (postgresDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// Lock polls pg_try_advisory_lock so the wait is bounded like MySQL's GET_LOCK timeout
+func (postgresDialect) Lock(ctx context.Context, conn *sql.Conn) error {
+	ctx, cancel := context.WithTimeout(ctx, postgresLockTimeout)
+	defer cancel()
+
+	for {
+		var acquired bool
+		err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", postgresLockKey).Scan(&acquired)
+		if ctx.Err() != nil {
+			return ErrLockTimeout
+		}
+		if err != nil {
+			return err
+		}
+		if acquired {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ErrLockTimeout
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (postgresDialect) Unlock(ctx context.Context, conn *sql.Conn, failed bool) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", postgresLockKey)
+	return err
+}
+
+// MigrationTx is true: PostgreSQL's DDL is transactional
+func (postgresDialect) MigrationTx() bool {
+	return true
+}
@@ -0,0 +1,33 @@
+-- Initial Go-Cart schema
+
+CREATE TABLE IF NOT EXISTS products (
+  product_id INTEGER PRIMARY KEY,
+  sku VARCHAR(100) NOT NULL UNIQUE,
+  manufacturer VARCHAR(200) NOT NULL,
+  category_id INTEGER NOT NULL,
+  weight INTEGER NOT NULL,
+  -- Product price in minor currency units (e.g. cents)
+  price BIGINT NOT NULL DEFAULT 0,
+  some_other_id INTEGER NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_products_category ON products (category_id);
+
+CREATE TABLE IF NOT EXISTS carts (
+  cart_id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  customer_id INTEGER NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_carts_customer ON carts (customer_id);
+
+CREATE TABLE IF NOT EXISTS cart_items (
+  cart_id INTEGER NOT NULL REFERENCES carts (cart_id) ON DELETE CASCADE,
+  product_id INTEGER NOT NULL REFERENCES products (product_id) ON DELETE CASCADE,
+  quantity INTEGER NOT NULL CHECK (quantity > 0),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (cart_id, product_id)
+);
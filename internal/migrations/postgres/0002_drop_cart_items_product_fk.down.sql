@@ -0,0 +1,5 @@
+-- Items for products that no longer exist cannot satisfy the restored constraint
+DELETE FROM cart_items WHERE product_id NOT IN (SELECT product_id FROM products);
+
+ALTER TABLE cart_items
+  ADD CONSTRAINT cart_items_product_id_fkey FOREIGN KEY (product_id) REFERENCES products (product_id) ON DELETE CASCADE;
@@ -0,0 +1,3 @@
+-- Cart repositories no longer require items to reference a stored product;
+-- the cart service checks that products exist before adding them
+ALTER TABLE cart_items DROP CONSTRAINT IF EXISTS cart_items_product_id_fkey;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_carts_expires_at;
+
+ALTER TABLE carts
+  DROP COLUMN IF EXISTS expires_at,
+  DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,9 @@
+-- Carts record when they last changed and when they expire.
+-- Existing carts get no expiry until they next change.
+ALTER TABLE carts
+  ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+UPDATE carts SET updated_at = created_at WHERE updated_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_carts_expires_at ON carts (expires_at);
@@ -0,0 +1,4 @@
+-- Carts record when the customer was reminded of them after they were abandoned.
+ALTER TABLE carts ADD COLUMN IF NOT EXISTS reminded_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_carts_updated_at ON carts (updated_at);
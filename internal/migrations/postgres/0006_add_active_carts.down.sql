@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS uq_carts_active_customer_id;
+
+ALTER TABLE carts DROP COLUMN IF EXISTS active_customer_id;
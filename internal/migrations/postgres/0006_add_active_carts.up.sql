@@ -0,0 +1,5 @@
+-- A customer's active cart holds their ID in active_customer_id; the unique index
+-- keeps concurrent requests from making two carts active for one customer.
+ALTER TABLE carts ADD COLUMN IF NOT EXISTS active_customer_id INTEGER;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_carts_active_customer_id ON carts (active_customer_id);
@@ -0,0 +1,30 @@
+-- Promotions are looked up by their coupon code. Their limits and amounts are 0 when
+-- unused, and their validity window is open-ended where starts_at or ends_at is NULL.
+CREATE TABLE IF NOT EXISTS promotions (
+  code VARCHAR(32) PRIMARY KEY,
+  type VARCHAR(20) NOT NULL,
+  percent_off INTEGER NOT NULL DEFAULT 0,
+  amount_off BIGINT NOT NULL DEFAULT 0,
+  buy_quantity INTEGER NOT NULL DEFAULT 0,
+  get_quantity INTEGER NOT NULL DEFAULT 0,
+  category_id INTEGER NOT NULL DEFAULT 0,
+  product_id INTEGER NOT NULL DEFAULT 0,
+  starts_at TIMESTAMPTZ,
+  ends_at TIMESTAMPTZ,
+  max_uses INTEGER NOT NULL DEFAULT 0,
+  max_uses_per_customer INTEGER NOT NULL DEFAULT 0,
+  uses INTEGER NOT NULL DEFAULT 0,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- How many times each customer has redeemed each code
+CREATE TABLE IF NOT EXISTS promotion_redemptions (
+  code VARCHAR(32) NOT NULL REFERENCES promotions (code) ON DELETE CASCADE,
+  customer_id INTEGER NOT NULL,
+  uses INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (code, customer_id)
+);
+
+-- The coupon codes applied to a cart, comma-separated in the order they were applied
+ALTER TABLE carts ADD COLUMN IF NOT EXISTS coupons VARCHAR(255) NOT NULL DEFAULT '';
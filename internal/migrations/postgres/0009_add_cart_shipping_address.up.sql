@@ -0,0 +1,2 @@
+-- The address a cart is shipped to, as JSON; NULL until one is set
+ALTER TABLE carts ADD COLUMN shipping_address TEXT NULL;
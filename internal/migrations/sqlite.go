@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+)
+
+//go:embed sqlite/*.sql
+var sqliteFiles embed.FS
+
+// NewSQLite creates a Migrator for the embedded SQLite migrations
+func NewSQLite(db *sql.DB) (*Migrator, error) {
+	return New(db, sqliteDialect{}, sqliteFiles, "sqlite")
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) CreateVersionTable() string {
+	return `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME NOT NULL
+		)
+	`
+}
+
+func (sqliteDialect) Placeholder(int) string {
+	return "?"
+}
+
+// Lock starts an IMMEDIATE transaction, which takes the database write lock
+// (waiting up to the connection's busy_timeout), so processes sharing the file
+// migrate one at a time. Migration statements then run inside that transaction.
+func (sqliteDialect) Lock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE")
+	return err
+}
+
+// Unlock commits the lock's transaction, or rolls it back if the migrations failed
+// so that none of a half-applied migration is kept
+func (sqliteDialect) Unlock(ctx context.Context, conn *sql.Conn, failed bool) error {
+	if failed {
+		_, err := conn.ExecContext(ctx, "ROLLBACK")
+		return err
+	}
+	_, err := conn.ExecContext(ctx, "COMMIT")
+	return err
+}
+
+// MigrationTx is false: migrations already run inside the lock's transaction
+func (sqliteDialect) MigrationTx() bool {
+	return false
+}
@@ -0,0 +1,33 @@
+-- Initial Go-Cart schema
+
+CREATE TABLE IF NOT EXISTS products (
+  product_id INTEGER PRIMARY KEY,
+  sku TEXT NOT NULL UNIQUE,
+  manufacturer TEXT NOT NULL,
+  category_id INTEGER NOT NULL,
+  weight INTEGER NOT NULL,
+  -- Product price in minor currency units (e.g. cents)
+  price INTEGER NOT NULL DEFAULT 0,
+  some_other_id INTEGER NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_products_category ON products (category_id);
+
+CREATE TABLE IF NOT EXISTS carts (
+  cart_id INTEGER PRIMARY KEY AUTOINCREMENT,
+  customer_id INTEGER NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_carts_customer ON carts (customer_id);
+
+CREATE TABLE IF NOT EXISTS cart_items (
+  cart_id INTEGER NOT NULL REFERENCES carts (cart_id) ON DELETE CASCADE,
+  product_id INTEGER NOT NULL REFERENCES products (product_id) ON DELETE CASCADE,
+  quantity INTEGER NOT NULL CHECK (quantity > 0),
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (cart_id, product_id)
+);
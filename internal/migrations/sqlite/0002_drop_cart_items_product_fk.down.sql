@@ -0,0 +1,17 @@
+CREATE TABLE cart_items_old (
+  cart_id INTEGER NOT NULL REFERENCES carts (cart_id) ON DELETE CASCADE,
+  product_id INTEGER NOT NULL REFERENCES products (product_id) ON DELETE CASCADE,
+  quantity INTEGER NOT NULL CHECK (quantity > 0),
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (cart_id, product_id)
+);
+
+INSERT INTO cart_items_old (cart_id, product_id, quantity, created_at, updated_at)
+SELECT cart_id, product_id, quantity, created_at, updated_at FROM cart_items
+-- Items for products that no longer exist cannot satisfy the restored constraint
+WHERE product_id IN (SELECT product_id FROM products);
+
+DROP TABLE cart_items;
+
+ALTER TABLE cart_items_old RENAME TO cart_items;
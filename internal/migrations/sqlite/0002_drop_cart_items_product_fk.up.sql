@@ -0,0 +1,19 @@
+-- Cart repositories no longer require items to reference a stored product;
+-- the cart service checks that products exist before adding them.
+-- SQLite cannot drop a constraint, so the table is rebuilt without it.
+
+CREATE TABLE cart_items_new (
+  cart_id INTEGER NOT NULL REFERENCES carts (cart_id) ON DELETE CASCADE,
+  product_id INTEGER NOT NULL,
+  quantity INTEGER NOT NULL CHECK (quantity > 0),
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (cart_id, product_id)
+);
+
+INSERT INTO cart_items_new (cart_id, product_id, quantity, created_at, updated_at)
+SELECT cart_id, product_id, quantity, created_at, updated_at FROM cart_items;
+
+DROP TABLE cart_items;
+
+ALTER TABLE cart_items_new RENAME TO cart_items;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_carts_expires_at;
+
+ALTER TABLE carts DROP COLUMN expires_at;
+ALTER TABLE carts DROP COLUMN updated_at;
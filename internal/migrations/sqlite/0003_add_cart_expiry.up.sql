@@ -0,0 +1,8 @@
+-- Carts record when they last changed and when they expire, both in UTC.
+-- Existing carts get no expiry until they next change.
+ALTER TABLE carts ADD COLUMN updated_at DATETIME;
+ALTER TABLE carts ADD COLUMN expires_at DATETIME;
+
+UPDATE carts SET updated_at = created_at;
+
+CREATE INDEX IF NOT EXISTS idx_carts_expires_at ON carts (expires_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_carts_updated_at;
+
+ALTER TABLE carts DROP COLUMN reminded_at;
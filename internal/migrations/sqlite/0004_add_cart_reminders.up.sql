@@ -0,0 +1,4 @@
+-- Carts record when the customer was reminded of them after they were abandoned, in UTC.
+ALTER TABLE carts ADD COLUMN reminded_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_carts_updated_at ON carts (updated_at);
@@ -0,0 +1 @@
+ALTER TABLE carts DROP COLUMN guest_token_hash;
@@ -0,0 +1,2 @@
+-- Guest carts have customer_id 0 and store the SHA-256 of their access token in hex.
+ALTER TABLE carts ADD COLUMN guest_token_hash TEXT;
@@ -0,0 +1,4 @@
+ALTER TABLE carts DROP COLUMN coupons;
+
+DROP TABLE IF EXISTS promotion_redemptions;
+DROP TABLE IF EXISTS promotions;
@@ -0,0 +1,3 @@
+ALTER TABLE carts DROP COLUMN currency;
+
+ALTER TABLE products DROP COLUMN prices;
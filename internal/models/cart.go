@@ -1,11 +1,58 @@
 package models
 
-// Cart represents a shopping cart
+import "time"
+
+// Cart represents a shopping cart.
+// Carts stored before timestamps were recorded have zero times and never expire.
+// A guest cart has no customer and is accessed with the token whose hash it stores.
 // @name Cart
 type Cart struct {
 	CartID     int        `json:"cart_id" dynamodbav:"cart_id"`
-	CustomerID int        `json:"customer_id" dynamodbav:"customer_id"`
+	CustomerID int        `json:"customer_id" dynamodbav:"customer_id"` // 0 for a guest cart
 	Items      []CartItem `json:"items,omitempty" dynamodbav:"items,omitempty"`
+	CreatedAt  time.Time  `json:"created_at,omitzero" dynamodbav:"created_at,omitempty,unixtime"`
+	UpdatedAt  time.Time  `json:"updated_at,omitzero" dynamodbav:"updated_at,omitempty,unixtime"`
+	// ExpiresAt is when the cart is discarded unless it changes again; zero if it never expires
+	ExpiresAt time.Time `json:"expires_at,omitzero" dynamodbav:"expires_at,omitempty,unixtime"`
+	// RemindedAt is when the customer was reminded of the abandoned cart; zero if never
+	RemindedAt time.Time `json:"reminded_at,omitzero" dynamodbav:"reminded_at,omitempty,unixtime"`
+	// GuestTokenHash is the hex SHA-256 of a guest cart's token; never sent to clients
+	GuestTokenHash string `json:"-" dynamodbav:"guest_token_hash,omitempty"`
+	// Coupons are the codes applied to the cart, in the order they were applied
+	Coupons []string `json:"coupons,omitempty" dynamodbav:"coupons,omitempty"`
+	// ShippingAddress is where the order is to be shipped, and so how it is taxed; nil until set
+	ShippingAddress *Address `json:"shipping_address,omitempty" dynamodbav:"shipping_address,omitempty"`
+	// ShippingOptionID is the shipping option chosen for the cart; empty until one is
+	ShippingOptionID string `json:"shipping_option_id,omitempty" example:"ups-ground" dynamodbav:"shipping_option_id,omitempty"`
+	// Currency is the ISO 4217 code the cart is priced in, fixed when it is created.
+	// Carts stored before carts had currencies have none and are in the base currency.
+	Currency string `json:"currency" example:"USD" dynamodbav:"currency,omitempty"`
+	// Active marks its customer's active cart, the one CreateActive returns. It is set
+	// by List and kept by Put, so bulk copies carry it; never sent to clients.
+	Active bool `json:"-" dynamodbav:"-"`
+}
+
+// Address is a postal address. Country is an ISO 3166-1 alpha-2 code and State, where
+// the country has them, the subdivision part of an ISO 3166-2 code.
+// @name Address
+type Address struct {
+	Name       string `json:"name,omitempty" binding:"max=100" example:"Jane Doe" dynamodbav:"name,omitempty"`
+	Line1      string `json:"line1" binding:"required,max=100" example:"400 Broad St" dynamodbav:"line1"`
+	Line2      string `json:"line2,omitempty" binding:"max=100" dynamodbav:"line2,omitempty"`
+	City       string `json:"city" binding:"required,max=100" example:"Seattle" dynamodbav:"city"`
+	State      string `json:"state,omitempty" binding:"max=3" example:"WA" dynamodbav:"state,omitempty"`
+	PostalCode string `json:"postal_code,omitempty" binding:"max=20" example:"98109" dynamodbav:"postal_code,omitempty"`
+	Country    string `json:"country" binding:"required,len=2,alpha" example:"US" dynamodbav:"country"`
+}
+
+// Guest reports whether the cart belongs to a visitor who has not signed in
+func (c *Cart) Guest() bool {
+	return c.GuestTokenHash != ""
+}
+
+// Expired reports whether the cart's expiry has passed at now
+func (c *Cart) Expired(now time.Time) bool {
+	return !c.ExpiresAt.IsZero() && !now.Before(c.ExpiresAt)
 }
 
 // CartItem represents an item in a shopping cart
@@ -15,16 +62,27 @@ type CartItem struct {
 	Quantity  int `json:"quantity" dynamodbav:"quantity"`
 }
 
-// CreateCartRequest represents a request to create a new cart
+// CreateCartRequest represents a request to create a new cart.
+// Omitting the customer creates a guest cart, and omitting the currency prices the
+// cart in the store's base currency.
 // @name CreateCartRequest
 type CreateCartRequest struct {
-	CustomerID int `json:"customer_id" binding:"required,min=1" example:"1"`
+	CustomerID int    `json:"customer_id" binding:"omitempty,min=1" example:"1"`
+	Currency   string `json:"currency,omitempty" binding:"omitempty,len=3,alpha" example:"EUR"`
 }
 
 // CreateCartResponse represents a response after creating a cart
 // @name CreateCartResponse
 type CreateCartResponse struct {
 	CartID int `json:"shopping_cart_id" example:"0"`
+	// GuestToken is returned only for a guest cart and must be sent as X-Guest-Token to use it
+	GuestToken string `json:"guest_token,omitempty" example:"17.kq3W0bX9yZ_p1aRb2sT4uV6wX8yZ0aBc"`
+}
+
+// MergeCartRequest represents a request to fold a guest cart into a customer's cart
+// @name MergeCartRequest
+type MergeCartRequest struct {
+	GuestToken string `json:"guest_token" binding:"required" example:"17.kq3W0bX9yZ_p1aRb2sT4uV6wX8yZ0aBc"`
 }
 
 // AddItemRequest represents a request to add an item to a cart
@@ -34,8 +92,22 @@ type AddItemRequest struct {
 	Quantity  int `json:"quantity" binding:"required,min=1" example:"1"`
 }
 
+// AddItemsRequest represents a batch of items to add to a cart, sent as a JSON array.
+// It wraps the array so that each item is validated and reported by its index.
+type AddItemsRequest struct {
+	Items []AddItemRequest `json:"items" binding:"required,min=1,max=100,dive"`
+}
+
 // CheckoutResponse represents a response after checkout
 // @name CheckoutResponse
 type CheckoutResponse struct {
-	OrderID int `json:"order_id" example:"0"`
+	OrderID  int    `json:"order_id" example:"0"`
+	Currency string `json:"currency" example:"USD"` // ISO 4217 code the amounts are in, in its minor units
+	// Subtotal, Discounts, Shipping and Taxes are the cart's pricing as frozen at checkout
+	Subtotal  int64           `json:"subtotal" example:"2500"`
+	Discounts []Discount      `json:"discounts,omitempty"`
+	Shipping  *ShippingOption `json:"shipping,omitempty"` // if shipping is charged
+	Taxes     []TaxLine       `json:"taxes,omitempty"`
+	// Total is what the order came to after discounts, shipping and taxes, in minor currency units
+	Total int64 `json:"total" example:"2250"`
 }
@@ -3,7 +3,18 @@ package models
 // Error represents an error response
 // @name Error
 type Error struct {
-	Error   string `json:"error" example:"INVALID_INPUT"`
-	Message string `json:"message" example:"The provided input data is invalid"`
-	Details string `json:"details,omitempty" example:"Product ID must be a positive integer"`
+	Error     string       `json:"error" example:"INVALID_INPUT"`
+	Message   string       `json:"message" example:"The provided input data is invalid"`
+	Details   string       `json:"details,omitempty" example:"Product ID must be a positive integer"`
+	RequestID string       `json:"request_id,omitempty" example:"4f1c2a9be0d34c7f8a6b5e2d1c0f9a8b"`
+	Fields    []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError describes why a single request field was rejected
+// @name FieldError
+type FieldError struct {
+	Field   string `json:"field" example:"quantity"`
+	Rule    string `json:"rule" example:"min"`
+	Param   string `json:"param,omitempty" example:"1"`
+	Message string `json:"message" example:"quantity must be at least 1"`
 }
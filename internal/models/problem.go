@@ -0,0 +1,15 @@
+package models
+
+// Problem represents an RFC 7807 problem details document,
+// returned instead of Error when the client accepts application/problem+json
+// @name Problem
+type Problem struct {
+	Type          string       `json:"type" example:"/problems/not-found"`
+	Title         string       `json:"title" example:"Not Found"`
+	Status        int          `json:"status" example:"404"`
+	Detail        string       `json:"detail,omitempty" example:"No cart exists with the specified ID"`
+	Instance      string       `json:"instance,omitempty" example:"/v1/shopping-carts/42"`
+	Code          string       `json:"code" example:"NOT_FOUND"`
+	RequestID     string       `json:"request_id,omitempty" example:"4f1c2a9be0d34c7f8a6b5e2d1c0f9a8b"`
+	InvalidParams []FieldError `json:"invalid-params,omitempty"`
+}
@@ -3,10 +3,27 @@ package models
 // Product represents a product
 // @name Product
 type Product struct {
-	ProductID    int    `json:"product_id" binding:"required,min=1" example:"12345" dynamodbav:"product_id"`
-	SKU          string `json:"sku" binding:"required,min=1,max=100" example:"ABC-123-XYZ" dynamodbav:"sku"`
-	Manufacturer string `json:"manufacturer" binding:"required,min=1,max=200" example:"Acme Corporation" dynamodbav:"manufacturer"`
-	CategoryID   int    `json:"category_id" binding:"required,min=1" example:"456" dynamodbav:"category_id"`
-	Weight       int    `json:"weight" binding:"required,min=0" example:"1250" dynamodbav:"weight"`
-	SomeOtherID  int    `json:"some_other_id" binding:"required,min=1" example:"789" dynamodbav:"some_other_id"`
+	ProductID     int    `json:"product_id" binding:"required,min=1" example:"12345" dynamodbav:"product_id"`
+	SKU           string `json:"sku" binding:"required,min=1,max=100" example:"ABC-123-XYZ" dynamodbav:"sku"`
+	Manufacturer  string `json:"manufacturer" binding:"required,min=1,max=200" example:"Acme Corporation" dynamodbav:"manufacturer"`
+	CategoryID    int    `json:"category_id" binding:"required,min=1" example:"456" dynamodbav:"category_id"`
+	Weight        int    `json:"weight" binding:"required,min=0" example:"1250" dynamodbav:"weight"`
+	Price         int64  `json:"price" binding:"min=0" example:"1999" dynamodbav:"price"` // in minor units (e.g. cents) of the store's base currency
+	SomeOtherID   int    `json:"some_other_id" binding:"required,min=1" example:"789" dynamodbav:"some_other_id"`
+	PurchaseLimit int    `json:"purchase_limit,omitempty" binding:"min=0" example:"5" dynamodbav:"purchase_limit,omitempty"` // most one cart may hold; 0 for no limit of its own
+	// Prices is the product's price in each other currency it is sold in, by ISO 4217
+	// code, in minor units of that currency (yen for JPY)
+	Prices map[string]int64 `json:"prices,omitempty" binding:"omitempty,dive,keys,len=3,uppercase,endkeys,min=0" dynamodbav:"prices,omitempty"`
+}
+
+// PriceIn returns the product's price in currency, where base is the currency of
+// Price, and whether it is sold in currency at all
+func (p *Product) PriceIn(currency, base string) (int64, bool) {
+	if price, ok := p.Prices[currency]; ok {
+		return price, true
+	}
+	if currency != base {
+		return 0, false
+	}
+	return p.Price, true
 }
@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+// PromotionType is the rule by which a promotion discounts a cart
+type PromotionType string
+
+const (
+	// PromotionPercentOff takes PercentOff percent off the items it covers
+	PromotionPercentOff PromotionType = "percent_off"
+	// PromotionFixedOff takes AmountOff off the items it covers, down to zero
+	PromotionFixedOff PromotionType = "fixed_off"
+	// PromotionBuyXGetY makes GetQuantity of a product free for every BuyQuantity of it bought
+	PromotionBuyXGetY PromotionType = "buy_x_get_y"
+)
+
+// Promotion is a discount a customer gets by applying its coupon code to a cart.
+// It covers every item, or only those of CategoryID or ProductID when set.
+// Zero times leave the validity window open and zero limits allow any number of uses.
+// @name Promotion
+type Promotion struct {
+	Code               string        `json:"code" example:"SPRING10" dynamodbav:"code"`
+	Type               PromotionType `json:"type" binding:"required,oneof=percent_off fixed_off buy_x_get_y" example:"percent_off" dynamodbav:"type"`
+	PercentOff         int           `json:"percent_off,omitempty" binding:"min=0,max=100" example:"10" dynamodbav:"percent_off"`
+	AmountOff          int64         `json:"amount_off,omitempty" binding:"min=0" example:"500" dynamodbav:"amount_off"` // in minor units (e.g. cents) of the base currency
+	BuyQuantity        int           `json:"buy_quantity,omitempty" binding:"min=0" example:"2" dynamodbav:"buy_quantity"`
+	GetQuantity        int           `json:"get_quantity,omitempty" binding:"min=0" example:"1" dynamodbav:"get_quantity"`
+	CategoryID         int           `json:"category_id,omitempty" binding:"min=0" example:"456" dynamodbav:"category_id"`
+	ProductID          int           `json:"product_id,omitempty" binding:"min=0" example:"12345" dynamodbav:"product_id"`
+	StartsAt           time.Time     `json:"starts_at,omitzero" dynamodbav:"starts_at,omitempty,unixtime"`
+	EndsAt             time.Time     `json:"ends_at,omitzero" dynamodbav:"ends_at,omitempty,unixtime"`
+	MaxUses            int           `json:"max_uses,omitempty" binding:"min=0" example:"1000" dynamodbav:"max_uses"`
+	MaxUsesPerCustomer int           `json:"max_uses_per_customer,omitempty" binding:"min=0" example:"1" dynamodbav:"max_uses_per_customer"`
+	// Uses is how many checkouts have redeemed the code; it is kept when the promotion is updated
+	Uses int `json:"uses" example:"0" dynamodbav:"uses"`
+}
+
+// Redemption is how many times a customer has redeemed a coupon code
+type Redemption struct {
+	Code       string `json:"code"`
+	CustomerID int    `json:"customer_id"`
+	Uses       int    `json:"uses"`
+}
+
+// Active reports whether the promotion's validity window includes now
+func (p *Promotion) Active(now time.Time) bool {
+	return (p.StartsAt.IsZero() || !now.Before(p.StartsAt)) && (p.EndsAt.IsZero() || now.Before(p.EndsAt))
+}
+
+// Covers reports whether the promotion applies to a product of the given category
+func (p *Promotion) Covers(productID, categoryID int) bool {
+	return (p.ProductID == 0 || p.ProductID == productID) && (p.CategoryID == 0 || p.CategoryID == categoryID)
+}
+
+// ApplyCouponRequest represents a request to apply a coupon code to a cart
+// @name ApplyCouponRequest
+type ApplyCouponRequest struct {
+	Code string `json:"code" binding:"required" example:"SPRING10"`
+}
+
+// Discount is what one coupon takes off a cart
+// @name Discount
+type Discount struct {
+	Code   string `json:"code" example:"SPRING10"`
+	Amount int64  `json:"amount" example:"250"` // in minor currency units (e.g. cents)
+}
+
+// PricedCart is a cart priced at its products' current prices with its coupons applied
+// and, once it has a shipping address, taxed and charged for shipping
+// @name PricedCart
+type PricedCart struct {
+	Cart
+	Subtotal int64 `json:"subtotal" example:"2500"` // before discounts, in minor currency units
+	// Discounts lists, in the order they were applied, the coupons that are currently valid
+	Discounts []Discount `json:"discounts,omitempty"`
+	// Shipping is the chosen shipping option, while it is available for the cart
+	Shipping *ShippingOption `json:"shipping,omitempty"`
+	// Taxes lists the taxes due on the discounted items; only exclusive ones add to Total
+	Taxes []TaxLine `json:"taxes,omitempty"`
+	Total int64     `json:"total" example:"2250"`
+}
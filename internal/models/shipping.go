@@ -0,0 +1,24 @@
+package models
+
+// ShippingOption is one way a cart can be shipped and what it costs
+// @name ShippingOption
+type ShippingOption struct {
+	ID      string `json:"id" example:"ups-ground"`
+	Carrier string `json:"carrier" example:"UPS"`
+	Service string `json:"service" example:"Ground"`
+	Price   int64  `json:"price" example:"999"` // in minor currency units, 0 when shipping is free
+}
+
+// ShippingOptionsResponse lists the ways a cart can be shipped to its address
+// @name ShippingOptionsResponse
+type ShippingOptionsResponse struct {
+	Weight   int              `json:"weight" example:"3750"`  // of all the cart's items, in product weight units
+	Currency string           `json:"currency" example:"USD"` // ISO 4217 code the prices are in, in its minor units
+	Options  []ShippingOption `json:"options"`
+}
+
+// SelectShippingOptionRequest represents a request to choose how a cart is shipped
+// @name SelectShippingOptionRequest
+type SelectShippingOptionRequest struct {
+	OptionID string `json:"option_id" binding:"required,max=64" example:"ups-ground"`
+}
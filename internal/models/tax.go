@@ -0,0 +1,13 @@
+package models
+
+// TaxLine is one tax due on a cart: Rate applied to the Taxable amount of the items it
+// covers. An inclusive tax is already part of the items' prices; an exclusive one is
+// added to them.
+// @name TaxLine
+type TaxLine struct {
+	Name      string `json:"name" example:"WA sales tax"`
+	Rate      string `json:"rate" example:"10.25"` // percent
+	Inclusive bool   `json:"inclusive,omitempty"`
+	Taxable   int64  `json:"taxable" example:"2250"` // in minor currency units
+	Amount    int64  `json:"amount" example:"231"`   // in minor currency units
+}
@@ -0,0 +1,61 @@
+// Package notify delivers events about customers' carts to other systems:
+// the log, a webhook or an SMTP server.
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// AbandonedCart is the event sent when a customer leaves items in a cart
+type AbandonedCart struct {
+	CartID     int                 `json:"cart_id"`
+	CustomerID int                 `json:"customer_id"`
+	Items      []AbandonedCartItem `json:"items"`
+	// Currency is the ISO 4217 code of the cart's prices
+	Currency string `json:"currency"`
+	// Value is the items' total at current prices, in minor units of Currency
+	Value int64 `json:"value"`
+	// IdleSince is when the cart last changed
+	IdleSince time.Time `json:"idle_since"`
+}
+
+// AbandonedCartItem is one product left in an abandoned cart
+type AbandonedCartItem struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+	// UnitPrice is the product's current price in the cart's currency, or 0 if it no
+	// longer exists or has no price in it
+	UnitPrice int64 `json:"unit_price"`
+}
+
+// Notifier delivers events
+type Notifier interface {
+	// NotifyAbandonedCart delivers an AbandonedCart event, returning an error if it may not have arrived
+	NotifyAbandonedCart(ctx context.Context, event AbandonedCart) error
+}
+
+// Log writes events to a structured logger, for development or when another
+// system collects the logs
+type Log struct {
+	logger *slog.Logger
+}
+
+// NewLog creates a notifier that writes to logger
+func NewLog(logger *slog.Logger) *Log {
+	return &Log{logger: logger}
+}
+
+// NotifyAbandonedCart logs the event
+func (l *Log) NotifyAbandonedCart(ctx context.Context, event AbandonedCart) error {
+	l.logger.InfoContext(ctx, "abandoned cart",
+		"cart_id", event.CartID,
+		"customer_id", event.CustomerID,
+		"items", len(event.Items),
+		"value", event.Value,
+		"currency", event.Currency,
+		"idle_since", event.IdleSince,
+	)
+	return nil
+}
@@ -0,0 +1,161 @@
+package notify_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/notify"
+)
+
+var event = notify.AbandonedCart{
+	CartID:     42,
+	CustomerID: 7,
+	Items:      []notify.AbandonedCartItem{{ProductID: 1, Quantity: 2, UnitPrice: 1999}},
+	Currency:   "USD",
+	Value:      3998,
+	IdleSince:  time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+}
+
+func TestWebhookPostsEvent(t *testing.T) {
+	var got struct {
+		Type string               `json:"type"`
+		Data notify.AbandonedCart `json:"data"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Event-Type") != "AbandonedCart" {
+			t.Errorf("X-Event-Type = %q", r.Header.Get("X-Event-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding body: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	if err := notify.NewWebhook(server.URL, time.Second).NotifyAbandonedCart(t.Context(), event); err != nil {
+		t.Fatalf("NotifyAbandonedCart: %v", err)
+	}
+	if got.Type != "AbandonedCart" || got.Data.CartID != 42 || got.Data.Value != 3998 || got.Data.Currency != "USD" || len(got.Data.Items) != 1 {
+		t.Errorf("posted %+v", got)
+	}
+}
+
+func TestWebhookFailsOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	if err := notify.NewWebhook(server.URL, time.Second).NotifyAbandonedCart(t.Context(), event); err == nil {
+		t.Fatal("NotifyAbandonedCart succeeded on a 503")
+	}
+}
+
+func TestSMTPSendsReminder(t *testing.T) {
+	addr, messages := serveSMTP(t)
+
+	smtp := notify.NewSMTP(notify.SMTPOptions{
+		Addr:    addr,
+		From:    "carts@example.com",
+		To:      "customer-{customer_id}@example.com",
+		Timeout: time.Second,
+	})
+	if err := smtp.NotifyAbandonedCart(t.Context(), event); err != nil {
+		t.Fatalf("NotifyAbandonedCart: %v", err)
+	}
+
+	msg := <-messages
+	for _, want := range []string{"RCPT TO:<customer-7@example.com>", "Subject: You left items in your cart", "2 x product 1 at 19.99 USD", "Total: 39.98 USD"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("session does not contain %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestSMTPFormatsAmountsInCurrency(t *testing.T) {
+	tests := []struct {
+		currency string
+		want     []string
+	}{
+		{currency: "JPY", want: []string{"at 1999 JPY", "Total: 3998 JPY"}},
+		{currency: "KWD", want: []string{"at 1.999 KWD", "Total: 3.998 KWD"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.currency, func(t *testing.T) {
+			addr, messages := serveSMTP(t)
+			smtp := notify.NewSMTP(notify.SMTPOptions{Addr: addr, From: "carts@example.com", To: "customer@example.com", Timeout: time.Second})
+
+			event := event
+			event.Currency = tt.currency
+			if err := smtp.NotifyAbandonedCart(t.Context(), event); err != nil {
+				t.Fatalf("NotifyAbandonedCart: %v", err)
+			}
+
+			msg := <-messages
+			for _, want := range tt.want {
+				if !strings.Contains(msg, want) {
+					t.Errorf("session does not contain %q:\n%s", want, msg)
+				}
+			}
+		})
+	}
+}
+
+// serveSMTP accepts one SMTP session and sends everything the client wrote on messages
+func serveSMTP(t *testing.T) (string, <-chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var session strings.Builder
+		defer func() { messages <- session.String() }()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			session.WriteString(line)
+			switch {
+			case inData:
+				if line == ".\r\n" {
+					inData = false
+					reply("250 OK")
+				}
+			case strings.HasPrefix(line, "DATA"):
+				inData = true
+				reply("354 Go ahead")
+			case strings.HasPrefix(line, "QUIT"):
+				reply("221 Bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	return ln.Addr().String(), messages
+}
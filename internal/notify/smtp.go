@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/pricing"
+)
+
+// SMTPOptions configures an SMTP notifier
+type SMTPOptions struct {
+	Addr string
+	From string
+	// To is the recipient address, with {customer_id} replaced by the customer's ID
+	To string
+	// Timeout bounds delivering one message, connection included
+	Timeout time.Duration
+}
+
+// SMTP emails customers through an SMTP server without authentication or TLS, such
+// as a local stand-in like MailHog or Mailpit that captures the messages
+type SMTP struct {
+	opts SMTPOptions
+}
+
+// NewSMTP creates a notifier that sends mail through opts.Addr
+func NewSMTP(opts SMTPOptions) *SMTP {
+	return &SMTP{opts: opts}
+}
+
+// NotifyAbandonedCart emails the customer a reminder listing the cart's items
+func (s *SMTP) NotifyAbandonedCart(ctx context.Context, event AbandonedCart) error {
+	var body strings.Builder
+	fmt.Fprintf(&body, "You left these items in cart %d:\r\n\r\n", event.CartID)
+	for _, item := range event.Items {
+		fmt.Fprintf(&body, "  %d x product %d at %s\r\n", item.Quantity, item.ProductID, formatAmount(item.UnitPrice, event.Currency))
+	}
+	fmt.Fprintf(&body, "\r\nTotal: %s\r\n", formatAmount(event.Value, event.Currency))
+
+	to := strings.ReplaceAll(s.opts.To, "{customer_id}", strconv.Itoa(event.CustomerID))
+	return s.send(ctx, to, "You left items in your cart", body.String())
+}
+
+// send delivers one plain text message
+func (s *SMTP) send(ctx context.Context, to, subject, body string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.opts.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	host, _, err := net.SplitHostPort(s.opts.Addr)
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.Mail(s.opts.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
+		s.opts.From, to, subject, time.Now().Format(time.RFC1123Z), body)
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// formatAmount formats an amount in minor units of currency with the currency's
+// decimals, two if it is not known, followed by its code
+func formatAmount(amount int64, currency string) string {
+	units, ok := pricing.MinorUnits(currency)
+	if !ok {
+		units = 2
+	}
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+
+	formatted := sign + strconv.FormatInt(amount, 10)
+	if units > 0 {
+		scale := int64(math.Pow10(units))
+		formatted = fmt.Sprintf("%s%d.%0*d", sign, amount/scale, units, amount%scale)
+	}
+	return strings.TrimSpace(formatted + " " + currency)
+}
@@ -0,0 +1,64 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// webhookEvent is the body posted to a webhook
+type webhookEvent struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+// Webhook posts each event as JSON to a URL
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook creates a notifier that posts to url, giving up on a request after timeout
+func NewWebhook(url string, timeout time.Duration) *Webhook {
+	return &Webhook{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// NotifyAbandonedCart posts {"type": "AbandonedCart", "data": event}.
+// Any status other than 2xx is an error.
+func (w *Webhook) NotifyAbandonedCart(ctx context.Context, event AbandonedCart) error {
+	return w.post(ctx, webhookEvent{Type: "AbandonedCart", Data: event})
+}
+
+func (w *Webhook) post(ctx context.Context, event webhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Type", event.Type)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: %s", event.Type, resp.Status)
+	}
+	return nil
+}
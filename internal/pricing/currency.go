@@ -0,0 +1,213 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// minorUnits holds the decimals of the ISO 4217 currencies prices may be given in;
+// amounts are kept in units of the last decimal, such as cents, or yen for JPY
+var minorUnits = map[string]int{
+	"AUD": 2,
+	"BHD": 3,
+	"CAD": 2,
+	"CHF": 2,
+	"CNY": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"HKD": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"MXN": 2,
+	"NZD": 2,
+	"SEK": 2,
+	"SGD": 2,
+	"USD": 2,
+}
+
+// MinorUnits returns the number of decimals of an ISO 4217 currency code, and whether
+// it is a currency prices may be given in
+func MinorUnits(currency string) (int, bool) {
+	units, ok := minorUnits[currency]
+	return units, ok
+}
+
+// Converter converts an amount in minor units of one currency to another, at the
+// exchange rates in effect at a time
+type Converter interface {
+	Convert(amount int64, from, to string, at time.Time) (int64, error)
+}
+
+// ErrNoExchangeRate means an amount cannot be converted between two currencies
+var ErrNoExchangeRate = errors.New("no exchange rate")
+
+// ExchangeTable is one set of ExchangeRates and the date they take effect. Each rate
+// is how much of its currency one unit of the base currency buys, such as 0.92 for EUR
+// against USD; rates are decimal strings so that they are kept exactly.
+type ExchangeTable struct {
+	Effective time.Time         `yaml:"effective"`
+	Rates     map[string]string `yaml:"rates"`
+}
+
+// ExchangeRates is a Converter that converts through a base currency, using the
+// latest table that took effect at or before the time of the conversion
+type ExchangeRates struct {
+	base string
+	// tables are in order of when they take effect
+	tables []exchangeTable
+}
+
+// exchangeTable is an ExchangeTable with its rates parsed
+type exchangeTable struct {
+	effective time.Time
+	rates     map[string]*big.Rat
+}
+
+// NewExchangeRates checks tables and builds exchange rates against base from them
+func NewExchangeRates(base string, tables []ExchangeTable) (*ExchangeRates, error) {
+	base = strings.ToUpper(base)
+	if _, ok := MinorUnits(base); !ok {
+		return nil, fmt.Errorf("base currency %q is not a supported ISO 4217 code", base)
+	}
+
+	r := &ExchangeRates{base: base}
+	var errs []error
+	for i, table := range tables {
+		if table.Effective.IsZero() {
+			errs = append(errs, fmt.Errorf("table %d: effective date is required", i))
+		}
+		parsed := exchangeTable{effective: table.Effective, rates: make(map[string]*big.Rat)}
+		for currency, rate := range table.Rates {
+			currency = strings.ToUpper(currency)
+			if _, ok := MinorUnits(currency); !ok || currency == base {
+				errs = append(errs, fmt.Errorf("table %d: %q is not a supported ISO 4217 code other than the base currency", i, currency))
+			}
+			value, ok := new(big.Rat).SetString(rate)
+			if !ok || value.Sign() <= 0 {
+				errs = append(errs, fmt.Errorf("table %d: rate for %s must be a positive number, got %q", i, currency, rate))
+				continue
+			}
+			parsed.rates[currency] = value
+		}
+		r.tables = append(r.tables, parsed)
+	}
+	slices.SortStableFunc(r.tables, func(a, b exchangeTable) int {
+		return a.effective.Compare(b.effective)
+	})
+	for i := 1; i < len(r.tables); i++ {
+		if r.tables[i].effective.Equal(r.tables[i-1].effective) {
+			errs = append(errs, fmt.Errorf("more than one table takes effect on %s", r.tables[i].effective.Format(time.DateOnly)))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// LoadExchangeRates reads exchange rates from a YAML file with the base currency
+// under "base" and a list of tables under "tables"
+func LoadExchangeRates(path string) (*ExchangeRates, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open exchange rates file: %w", err)
+	}
+	defer f.Close()
+
+	var file struct {
+		Base   string          `yaml:"base"`
+		Tables []ExchangeTable `yaml:"tables"`
+	}
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse exchange rates file %s: %w", path, err)
+	}
+
+	rates, err := NewExchangeRates(file.Base, file.Tables)
+	if err != nil {
+		return nil, fmt.Errorf("exchange rates file %s: %w", path, err)
+	}
+	return rates, nil
+}
+
+// Base returns the currency the rates are against
+func (r *ExchangeRates) Base() string {
+	return r.base
+}
+
+// Convert converts amount from one currency to another at the rates in effect at
+// at, rounding half away from zero to the minor units of to. It returns an error
+// wrapping ErrNoExchangeRate if either currency has no rate then.
+func (r *ExchangeRates) Convert(amount int64, from, to string, at time.Time) (int64, error) {
+	if from == to {
+		return amount, nil
+	}
+	fromRate, err := r.rate(from, at)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := r.rate(to, at)
+	if err != nil {
+		return 0, err
+	}
+
+	// amount / 10^from's units / fromRate * toRate * 10^to's units
+	value := new(big.Rat).SetInt64(amount)
+	value.Quo(value, scale(from))
+	value.Quo(value, fromRate)
+	value.Mul(value, toRate)
+	value.Mul(value, scale(to))
+	return roundHalfAway(value), nil
+}
+
+// rate returns how much of currency one unit of the base currency buys at at
+func (r *ExchangeRates) rate(currency string, at time.Time) (*big.Rat, error) {
+	if currency == r.base {
+		return big.NewRat(1, 1), nil
+	}
+
+	// The last table to take effect at or before at
+	i, _ := slices.BinarySearchFunc(r.tables, at, func(table exchangeTable, at time.Time) int {
+		if table.effective.After(at) {
+			return 1
+		}
+		return -1
+	})
+	if i == 0 {
+		return nil, fmt.Errorf("%w for %s: no table in effect on %s", ErrNoExchangeRate, currency, at.Format(time.DateOnly))
+	}
+	rate, ok := r.tables[i-1].rates[currency]
+	if !ok {
+		return nil, fmt.Errorf("%w for %s in the table in effect on %s", ErrNoExchangeRate, currency, at.Format(time.DateOnly))
+	}
+	return rate, nil
+}
+
+// scale returns how many minor units make one unit of currency
+func scale(currency string) *big.Rat {
+	units, _ := MinorUnits(currency)
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(units)), nil))
+}
+
+// roundHalfAway rounds value to the nearest integer, halves away from zero
+func roundHalfAway(value *big.Rat) int64 {
+	num, den := new(big.Int).Abs(value.Num()), value.Denom()
+	// (2|num| + den) / 2den, truncated, is |value| rounded half up
+	num.Mul(num, big.NewInt(2)).Add(num, den)
+	num.Quo(num, new(big.Int).Mul(den, big.NewInt(2)))
+	if value.Sign() < 0 {
+		num.Neg(num)
+	}
+	return num.Int64()
+}
@@ -0,0 +1,168 @@
+package pricing_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/pricing"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// exchangeTables are two sets of rates against USD, the later one listed first
+var exchangeTables = []pricing.ExchangeTable{
+	{Effective: date(2026, 7, 1), Rates: map[string]string{"EUR": "0.90", "JPY": "150"}},
+	{Effective: date(2026, 1, 1), Rates: map[string]string{"EUR": "0.92", "JPY": "148.5", "KWD": "0.307"}},
+}
+
+func TestExchangeRatesConvert(t *testing.T) {
+	rates, err := pricing.NewExchangeRates("usd", exchangeTables)
+	if err != nil {
+		t.Fatalf("NewExchangeRates: %v", err)
+	}
+	if rates.Base() != "USD" {
+		t.Fatalf("Base = %s, want USD", rates.Base())
+	}
+
+	tests := []struct {
+		name     string
+		amount   int64
+		from, to string
+		at       time.Time
+		want     int64
+	}{
+		{name: "same currency", amount: 1999, from: "EUR", to: "EUR", at: date(2025, 1, 1), want: 1999},
+		{name: "base to cents", amount: 1999, from: "USD", to: "EUR", at: date(2026, 3, 1), want: 1839},
+		{name: "base to no decimals", amount: 1999, from: "USD", to: "JPY", at: date(2026, 3, 1), want: 2969},
+		{name: "base to three decimals", amount: 1999, from: "USD", to: "KWD", at: date(2026, 3, 1), want: 6137},
+		{name: "to the base", amount: 1839, from: "EUR", to: "USD", at: date(2026, 3, 1), want: 1999},
+		{name: "through the base", amount: 1000, from: "EUR", to: "JPY", at: date(2026, 7, 1), want: 1667},
+		{name: "later table", amount: 1999, from: "USD", to: "EUR", at: date(2026, 8, 1), want: 1799},
+		{name: "half rounds away from zero", amount: 1, from: "USD", to: "JPY", at: date(2026, 7, 1), want: 2},
+		{name: "negative half rounds away from zero", amount: -1, from: "USD", to: "JPY", at: date(2026, 7, 1), want: -2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rates.Convert(tt.amount, tt.from, tt.to, tt.at)
+			if err != nil {
+				t.Fatalf("Convert: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Convert(%d, %s, %s) = %d, want %d", tt.amount, tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExchangeRatesConvertWithoutRate(t *testing.T) {
+	rates, err := pricing.NewExchangeRates("USD", exchangeTables)
+	if err != nil {
+		t.Fatalf("NewExchangeRates: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		from, to string
+		at       time.Time
+	}{
+		{name: "before the first table", from: "USD", to: "EUR", at: date(2025, 12, 31)},
+		{name: "not in the table in effect", from: "USD", to: "KWD", at: date(2026, 7, 1)},
+		{name: "never listed", from: "GBP", to: "USD", at: date(2026, 3, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := rates.Convert(100, tt.from, tt.to, tt.at); !errors.Is(err, pricing.ErrNoExchangeRate) {
+				t.Fatalf("Convert error = %v, want ErrNoExchangeRate", err)
+			}
+		})
+	}
+}
+
+func TestNewExchangeRatesRejectsInvalidTables(t *testing.T) {
+	tests := []struct {
+		name   string
+		base   string
+		tables []pricing.ExchangeTable
+		want   string
+	}{
+		{
+			name: "unknown base",
+			base: "XXX",
+			want: `base currency "XXX"`,
+		},
+		{
+			name:   "no effective date",
+			base:   "USD",
+			tables: []pricing.ExchangeTable{{Rates: map[string]string{"EUR": "0.92"}}},
+			want:   "effective date is required",
+		},
+		{
+			name:   "rate for the base",
+			base:   "USD",
+			tables: []pricing.ExchangeTable{{Effective: date(2026, 1, 1), Rates: map[string]string{"usd": "1"}}},
+			want:   "other than the base currency",
+		},
+		{
+			name:   "rate not positive",
+			base:   "USD",
+			tables: []pricing.ExchangeTable{{Effective: date(2026, 1, 1), Rates: map[string]string{"EUR": "0"}}},
+			want:   "must be a positive number",
+		},
+		{
+			name: "same effective date",
+			base: "USD",
+			tables: []pricing.ExchangeTable{
+				{Effective: date(2026, 1, 1), Rates: map[string]string{"EUR": "0.92"}},
+				{Effective: date(2026, 1, 1), Rates: map[string]string{"EUR": "0.93"}},
+			},
+			want: "more than one table takes effect on 2026-01-01",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := pricing.NewExchangeRates(tt.base, tt.tables)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("NewExchangeRates error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadExchangeRates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exchange.yaml")
+	data := `base: USD
+tables:
+  - effective: 2026-01-01
+    rates:
+      EUR: "0.92"
+      JPY: "148.5"
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rates, err := pricing.LoadExchangeRates(path)
+	if err != nil {
+		t.Fatalf("LoadExchangeRates: %v", err)
+	}
+	got, err := rates.Convert(1999, "USD", "JPY", date(2026, 3, 1))
+	if err != nil || got != 2969 {
+		t.Fatalf("Convert = %d, %v, want 2969", got, err)
+	}
+
+	if err := os.WriteFile(path, []byte("base: USD\nrates:\n  EUR: \"0.92\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pricing.LoadExchangeRates(path); err == nil {
+		t.Fatal("LoadExchangeRates with an unknown field succeeded, want an error")
+	}
+}
@@ -0,0 +1,75 @@
+// Package pricing totals a cart at its products' prices and applies its promotions.
+package pricing
+
+import "github.com/LuoZihYuan/Go-Cart/internal/models"
+
+// Line is a cart item with the details of its product that pricing needs
+type Line struct {
+	ProductID  int
+	CategoryID int
+	Quantity   int
+	UnitPrice  int64 // in minor currency units
+	UnitWeight int
+}
+
+// Lines joins a cart's items with their products at their prices in currency, where
+// base is the currency of Product.Price; an item whose product is missing, or has no
+// price in currency, costs nothing
+func Lines(items []models.CartItem, products map[int]*models.Product, currency, base string) []Line {
+	lines := make([]Line, len(items))
+	for i, item := range items {
+		lines[i] = Line{ProductID: item.ProductID, Quantity: item.Quantity}
+		if product := products[item.ProductID]; product != nil {
+			lines[i].CategoryID = product.CategoryID
+			lines[i].UnitPrice, _ = product.PriceIn(currency, base)
+			lines[i].UnitWeight = product.Weight
+		}
+	}
+	return lines
+}
+
+// Price totals lines and applies promotions in order. Each discount is worked out on
+// the undiscounted lines it covers but never takes more than the earlier discounts
+// left, so the total does not go below zero. Every promotion gets a Discount, even
+// when it takes nothing off.
+func Price(lines []Line, promotions []models.Promotion) (subtotal int64, discounts []models.Discount, total int64) {
+	for _, line := range lines {
+		subtotal += line.UnitPrice * int64(line.Quantity)
+	}
+
+	total = subtotal
+	for i := range promotions {
+		amount := min(discount(lines, &promotions[i]), total)
+		discounts = append(discounts, models.Discount{Code: promotions[i].Code, Amount: amount})
+		total -= amount
+	}
+	return subtotal, discounts, total
+}
+
+// discount returns what promotion takes off the lines it covers
+func discount(lines []Line, promotion *models.Promotion) int64 {
+	var covered, amount int64
+	for _, line := range lines {
+		if !promotion.Covers(line.ProductID, line.CategoryID) {
+			continue
+		}
+		covered += line.UnitPrice * int64(line.Quantity)
+
+		if promotion.Type == models.PromotionBuyXGetY && promotion.BuyQuantity > 0 && promotion.GetQuantity > 0 {
+			// Of every BuyQuantity+GetQuantity units in the line, the last GetQuantity are free
+			free := line.Quantity / (promotion.BuyQuantity + promotion.GetQuantity) * promotion.GetQuantity
+			amount += int64(free) * line.UnitPrice
+		}
+	}
+
+	switch promotion.Type {
+	case models.PromotionPercentOff:
+		return covered * int64(promotion.PercentOff) / 100
+	case models.PromotionFixedOff:
+		return min(promotion.AmountOff, covered)
+	case models.PromotionBuyXGetY:
+		return amount
+	default:
+		return 0
+	}
+}
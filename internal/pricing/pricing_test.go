@@ -0,0 +1,123 @@
+package pricing_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/pricing"
+)
+
+// lines is a cart of two products in category 1 and one in category 2
+var lines = []pricing.Line{
+	{ProductID: 1, CategoryID: 1, Quantity: 2, UnitPrice: 1000},
+	{ProductID: 2, CategoryID: 1, Quantity: 5, UnitPrice: 300},
+	{ProductID: 3, CategoryID: 2, Quantity: 1, UnitPrice: 2500},
+}
+
+func TestPrice(t *testing.T) {
+	tests := []struct {
+		name       string
+		promotions []models.Promotion
+		want       []int64 // each discount's amount
+		wantTotal  int64
+	}{
+		{
+			name:      "no promotions",
+			wantTotal: 6000,
+		},
+		{
+			name:       "percent off the cart",
+			promotions: []models.Promotion{{Code: "TEN", Type: models.PromotionPercentOff, PercentOff: 10}},
+			want:       []int64{600},
+			wantTotal:  5400,
+		},
+		{
+			name:       "percent off a category",
+			promotions: []models.Promotion{{Code: "CAT", Type: models.PromotionPercentOff, PercentOff: 50, CategoryID: 1}},
+			want:       []int64{1750},
+			wantTotal:  4250,
+		},
+		{
+			name:       "fixed off a product is capped at its price",
+			promotions: []models.Promotion{{Code: "FIX", Type: models.PromotionFixedOff, AmountOff: 5000, ProductID: 3}},
+			want:       []int64{2500},
+			wantTotal:  3500,
+		},
+		{
+			name:       "buy two get one free",
+			promotions: []models.Promotion{{Code: "B2G1", Type: models.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1, CategoryID: 1}},
+			want:       []int64{300}, // one free unit of product 2; product 1 has too few
+			wantTotal:  5700,
+		},
+		{
+			name: "discounts stack but stop at zero",
+			promotions: []models.Promotion{
+				{Code: "BIG", Type: models.PromotionFixedOff, AmountOff: 5500},
+				{Code: "HALF", Type: models.PromotionPercentOff, PercentOff: 50},
+			},
+			want:      []int64{5500, 500},
+			wantTotal: 0,
+		},
+		{
+			name:       "promotion covering nothing",
+			promotions: []models.Promotion{{Code: "NONE", Type: models.PromotionPercentOff, PercentOff: 10, CategoryID: 9}},
+			want:       []int64{0},
+			wantTotal:  6000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subtotal, discounts, total := pricing.Price(lines, tt.promotions)
+			if subtotal != 6000 {
+				t.Errorf("subtotal = %d, want 6000", subtotal)
+			}
+
+			var got []int64
+			for i, discount := range discounts {
+				if discount.Code != tt.promotions[i].Code {
+					t.Errorf("discount %d code = %q, want %q", i, discount.Code, tt.promotions[i].Code)
+				}
+				got = append(got, discount.Amount)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("discounts = %v, want %v", got, tt.want)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestLinesPricesMissingProductsAtZero(t *testing.T) {
+	items := []models.CartItem{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 1}}
+	products := map[int]*models.Product{1: {ProductID: 1, CategoryID: 7, Price: 150}}
+
+	got := pricing.Lines(items, products, "USD", "USD")
+	want := []pricing.Line{
+		{ProductID: 1, CategoryID: 7, Quantity: 2, UnitPrice: 150},
+		{ProductID: 2, Quantity: 1},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Lines = %+v, want %+v", got, want)
+	}
+}
+
+func TestLinesPricesInCurrency(t *testing.T) {
+	items := []models.CartItem{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 1}}
+	products := map[int]*models.Product{
+		1: {ProductID: 1, Price: 1999, Prices: map[string]int64{"JPY": 2980}},
+		2: {ProductID: 2, Price: 500},
+	}
+
+	got := pricing.Lines(items, products, "JPY", "USD")
+	want := []pricing.Line{
+		{ProductID: 1, Quantity: 1, UnitPrice: 2980},
+		{ProductID: 2, Quantity: 1}, // not sold in JPY
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Lines = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,230 @@
+package pricing
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"go.yaml.in/yaml/v3"
+)
+
+// ShippingCalculator lists the ways a cart weighing weight, whose goods cost goods
+// after discounts, can be shipped to address, cheapest first
+type ShippingCalculator interface {
+	Options(address models.Address, weight int, goods int64) []models.ShippingOption
+}
+
+// Weight returns what lines weigh altogether
+func Weight(lines []Line) int {
+	var weight int
+	for _, line := range lines {
+		weight += line.UnitWeight * line.Quantity
+	}
+	return weight
+}
+
+// ShippingZone groups the regions a ShippingTable charges alike. A region is a
+// country code such as "US", a country and state such as "US-AK", or "*" for
+// everywhere not in another zone.
+type ShippingZone struct {
+	Name    string   `yaml:"name"`
+	Regions []string `yaml:"regions"`
+}
+
+// ShippingMethod is a carrier's service and what it charges in each zone it ships to
+type ShippingMethod struct {
+	ID      string         `yaml:"id"`
+	Carrier string         `yaml:"carrier"`
+	Service string         `yaml:"service"`
+	Rates   []ShippingRate `yaml:"rates"`
+}
+
+// ShippingRate is what a method charges in Zone. A cart whose goods cost FreeOver or
+// more ships free; zero means never.
+type ShippingRate struct {
+	Zone     string          `yaml:"zone"`
+	FreeOver int64           `yaml:"free_over"`
+	Brackets []WeightBracket `yaml:"brackets"`
+}
+
+// WeightBracket is the price of shipping a cart weighing up to UpTo, in product weight units
+type WeightBracket struct {
+	UpTo  int   `yaml:"up_to"`
+	Price int64 `yaml:"price"`
+}
+
+// ShippingTable is a ShippingCalculator that looks prices up in a table. A method
+// is offered where it has a rate for the address's zone and a bracket the cart's
+// weight fits in, at the price of the first such bracket.
+type ShippingTable struct {
+	methods []ShippingMethod
+	// zones maps each region to its zone's name
+	zones map[string]string
+}
+
+// NewShippingTable checks zones and methods and builds a table of them
+func NewShippingTable(zones []ShippingZone, methods []ShippingMethod) (*ShippingTable, error) {
+	t := &ShippingTable{zones: make(map[string]string)}
+	var errs []error
+
+	names := make(map[string]bool)
+	for i, zone := range zones {
+		if zone.Name == "" {
+			errs = append(errs, fmt.Errorf("zone %d: name is required", i))
+		}
+		if names[zone.Name] {
+			errs = append(errs, fmt.Errorf("zone %d: duplicates an earlier zone named %q", i, zone.Name))
+		}
+		names[zone.Name] = true
+
+		for _, region := range zone.Regions {
+			region = strings.ToUpper(region)
+			if country, _, _ := strings.Cut(region, "-"); region != "*" && len(country) != 2 {
+				errs = append(errs, fmt.Errorf("zone %q: region must be a two-letter country code, optionally followed by -STATE, or *, got %q", zone.Name, region))
+			}
+			if other, ok := t.zones[region]; ok {
+				errs = append(errs, fmt.Errorf("zone %q: region %s is already in zone %q", zone.Name, region, other))
+			}
+			t.zones[region] = zone.Name
+		}
+	}
+
+	ids := make(map[string]bool)
+	for i, method := range methods {
+		if method.ID == "" || len(method.ID) > 64 {
+			errs = append(errs, fmt.Errorf("method %d: id is required and at most 64 characters", i))
+		}
+		if ids[method.ID] {
+			errs = append(errs, fmt.Errorf("method %d: duplicates an earlier method with id %q", i, method.ID))
+		}
+		ids[method.ID] = true
+		if method.Carrier == "" || method.Service == "" {
+			errs = append(errs, fmt.Errorf("method %q: carrier and service are required", method.ID))
+		}
+
+		rated := make(map[string]bool)
+		for _, rate := range method.Rates {
+			if !names[rate.Zone] {
+				errs = append(errs, fmt.Errorf("method %q: no zone named %q", method.ID, rate.Zone))
+			}
+			if rated[rate.Zone] {
+				errs = append(errs, fmt.Errorf("method %q: more than one rate for zone %q", method.ID, rate.Zone))
+			}
+			rated[rate.Zone] = true
+			if rate.FreeOver < 0 {
+				errs = append(errs, fmt.Errorf("method %q zone %q: free_over must not be negative", method.ID, rate.Zone))
+			}
+			if len(rate.Brackets) == 0 {
+				errs = append(errs, fmt.Errorf("method %q zone %q: at least one bracket is required", method.ID, rate.Zone))
+			}
+			for j, bracket := range rate.Brackets {
+				if bracket.UpTo <= 0 || j > 0 && bracket.UpTo <= rate.Brackets[j-1].UpTo {
+					errs = append(errs, fmt.Errorf("method %q zone %q: bracket %d: up_to must be positive and greater than the bracket before", method.ID, rate.Zone, j))
+				}
+				if bracket.Price < 0 {
+					errs = append(errs, fmt.Errorf("method %q zone %q: bracket %d: price must not be negative", method.ID, rate.Zone, j))
+				}
+			}
+		}
+
+		t.methods = append(t.methods, method)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// LoadShippingTable reads a table from a YAML file with its zones under "zones" and
+// its methods under "methods"
+func LoadShippingTable(path string) (*ShippingTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open shipping rates file: %w", err)
+	}
+	defer f.Close()
+
+	var file struct {
+		Zones   []ShippingZone   `yaml:"zones"`
+		Methods []ShippingMethod `yaml:"methods"`
+	}
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse shipping rates file %s: %w", path, err)
+	}
+
+	table, err := NewShippingTable(file.Zones, file.Methods)
+	if err != nil {
+		return nil, fmt.Errorf("shipping rates file %s: %w", path, err)
+	}
+	return table, nil
+}
+
+// Options lists the methods that ship to address and take weight, cheapest first;
+// methods that cost the same keep the table's order
+func (t *ShippingTable) Options(address models.Address, weight int, goods int64) []models.ShippingOption {
+	zone, ok := t.zone(address)
+	if !ok {
+		return nil
+	}
+
+	var options []models.ShippingOption
+	for _, method := range t.methods {
+		price, ok := method.price(zone, weight, goods)
+		if !ok {
+			continue
+		}
+		options = append(options, models.ShippingOption{
+			ID:      method.ID,
+			Carrier: method.Carrier,
+			Service: method.Service,
+			Price:   price,
+		})
+	}
+	slices.SortStableFunc(options, func(a, b models.ShippingOption) int {
+		return cmp.Compare(a.Price, b.Price)
+	})
+	return options
+}
+
+// zone returns the name of address's zone: its state's if it has one, else its
+// country's, else the catch-all's
+func (t *ShippingTable) zone(address models.Address) (string, bool) {
+	country, state := strings.ToUpper(address.Country), strings.ToUpper(address.State)
+	if state != "" {
+		if zone, ok := t.zones[country+"-"+state]; ok {
+			return zone, true
+		}
+	}
+	if zone, ok := t.zones[country]; ok {
+		return zone, true
+	}
+	zone, ok := t.zones["*"]
+	return zone, ok
+}
+
+// price returns what the method charges to ship weight to zone, and whether it does
+func (m *ShippingMethod) price(zone string, weight int, goods int64) (int64, bool) {
+	for _, rate := range m.Rates {
+		if rate.Zone != zone {
+			continue
+		}
+		for _, bracket := range rate.Brackets {
+			if weight > bracket.UpTo {
+				continue
+			}
+			if rate.FreeOver > 0 && goods >= rate.FreeOver {
+				return 0, true
+			}
+			return bracket.Price, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,208 @@
+package pricing_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/pricing"
+)
+
+func TestWeight(t *testing.T) {
+	weighed := []pricing.Line{
+		{ProductID: 1, Quantity: 2, UnitWeight: 1250},
+		{ProductID: 2, Quantity: 1, UnitWeight: 500},
+	}
+	if got := pricing.Weight(weighed); got != 3000 {
+		t.Fatalf("Weight = %d, want 3000", got)
+	}
+}
+
+// shippingZones splits the US mainland from Alaska and Hawaii and everywhere else
+var shippingZones = []pricing.ShippingZone{
+	{Name: "domestic", Regions: []string{"US"}},
+	{Name: "remote", Regions: []string{"us-ak", "US-HI"}},
+	{Name: "international", Regions: []string{"*"}},
+}
+
+// shippingMethods is a ground service for the mainland, free over $50, and an
+// express service everywhere
+var shippingMethods = []pricing.ShippingMethod{
+	{
+		ID: "express", Carrier: "DHL", Service: "Express",
+		Rates: []pricing.ShippingRate{
+			{Zone: "domestic", Brackets: []pricing.WeightBracket{{UpTo: 5000, Price: 1999}}},
+			{Zone: "remote", Brackets: []pricing.WeightBracket{{UpTo: 5000, Price: 2999}}},
+			{Zone: "international", Brackets: []pricing.WeightBracket{{UpTo: 2000, Price: 3999}, {UpTo: 5000, Price: 5999}}},
+		},
+	},
+	{
+		ID: "ground", Carrier: "UPS", Service: "Ground",
+		Rates: []pricing.ShippingRate{
+			{Zone: "domestic", FreeOver: 5000, Brackets: []pricing.WeightBracket{{UpTo: 1000, Price: 599}, {UpTo: 10000, Price: 999}}},
+		},
+	},
+}
+
+func TestShippingTable(t *testing.T) {
+	table, err := pricing.NewShippingTable(shippingZones, shippingMethods)
+	if err != nil {
+		t.Fatalf("NewShippingTable: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		address models.Address
+		weight  int
+		goods   int64
+		want    []models.ShippingOption
+	}{
+		{
+			name:    "cheapest first",
+			address: models.Address{Country: "US", State: "WA"},
+			weight:  800,
+			goods:   2000,
+			want: []models.ShippingOption{
+				{ID: "ground", Carrier: "UPS", Service: "Ground", Price: 599},
+				{ID: "express", Carrier: "DHL", Service: "Express", Price: 1999},
+			},
+		},
+		{
+			name:    "heavier bracket and too heavy",
+			address: models.Address{Country: "US", State: "WA"},
+			weight:  6000,
+			goods:   2000,
+			want:    []models.ShippingOption{{ID: "ground", Carrier: "UPS", Service: "Ground", Price: 999}},
+		},
+		{
+			name:    "free over the threshold",
+			address: models.Address{Country: "US", State: "WA"},
+			weight:  800,
+			goods:   5000,
+			want: []models.ShippingOption{
+				{ID: "ground", Carrier: "UPS", Service: "Ground", Price: 0},
+				{ID: "express", Carrier: "DHL", Service: "Express", Price: 1999},
+			},
+		},
+		{
+			name:    "state zone beats country zone",
+			address: models.Address{Country: "us", State: "ak"},
+			weight:  800,
+			want:    []models.ShippingOption{{ID: "express", Carrier: "DHL", Service: "Express", Price: 2999}},
+		},
+		{
+			name:    "catch-all zone",
+			address: models.Address{Country: "FR"},
+			weight:  3000,
+			want:    []models.ShippingOption{{ID: "express", Carrier: "DHL", Service: "Express", Price: 5999}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := table.Options(tt.address, tt.weight, tt.goods)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Options = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewShippingTableRejectsInvalidTables(t *testing.T) {
+	zones := []pricing.ShippingZone{{Name: "domestic", Regions: []string{"US"}}}
+	brackets := []pricing.WeightBracket{{UpTo: 1000, Price: 599}}
+
+	tests := []struct {
+		name    string
+		zones   []pricing.ShippingZone
+		methods []pricing.ShippingMethod
+		want    string
+	}{
+		{
+			name:  "bad region",
+			zones: []pricing.ShippingZone{{Name: "domestic", Regions: []string{"USA"}}},
+			want:  "two-letter country code",
+		},
+		{
+			name:  "region in two zones",
+			zones: []pricing.ShippingZone{{Name: "a", Regions: []string{"US"}}, {Name: "b", Regions: []string{"us"}}},
+			want:  `region US is already in zone "a"`,
+		},
+		{
+			name:  "unknown zone",
+			zones: zones,
+			methods: []pricing.ShippingMethod{{
+				ID: "ground", Carrier: "UPS", Service: "Ground",
+				Rates: []pricing.ShippingRate{{Zone: "remote", Brackets: brackets}},
+			}},
+			want: `no zone named "remote"`,
+		},
+		{
+			name:  "duplicate id",
+			zones: zones,
+			methods: []pricing.ShippingMethod{
+				{ID: "ground", Carrier: "UPS", Service: "Ground"},
+				{ID: "ground", Carrier: "FedEx", Service: "Ground"},
+			},
+			want: `duplicates an earlier method with id "ground"`,
+		},
+		{
+			name:  "brackets out of order",
+			zones: zones,
+			methods: []pricing.ShippingMethod{{
+				ID: "ground", Carrier: "UPS", Service: "Ground",
+				Rates: []pricing.ShippingRate{{Zone: "domestic", Brackets: []pricing.WeightBracket{{UpTo: 1000, Price: 599}, {UpTo: 1000, Price: 999}}}},
+			}},
+			want: "greater than the bracket before",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := pricing.NewShippingTable(tt.zones, tt.methods)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("NewShippingTable error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadShippingTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shipping.yaml")
+	data := `zones:
+  - name: domestic
+    regions: [US]
+methods:
+  - id: ground
+    carrier: UPS
+    service: Ground
+    rates:
+      - zone: domestic
+        brackets:
+          - up_to: 1000
+            price: 599
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := pricing.LoadShippingTable(path)
+	if err != nil {
+		t.Fatalf("LoadShippingTable: %v", err)
+	}
+	got := table.Options(models.Address{Country: "US", State: "NY"}, 500, 0)
+	want := []models.ShippingOption{{ID: "ground", Carrier: "UPS", Service: "Ground", Price: 599}}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Options = %+v, want %+v", got, want)
+	}
+
+	if err := os.WriteFile(path, []byte("zones:\n  - name: domestic\n    countries: [US]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pricing.LoadShippingTable(path); err == nil {
+		t.Fatal("LoadShippingTable with an unknown field succeeded, want an error")
+	}
+}
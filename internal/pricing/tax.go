@@ -0,0 +1,220 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"go.yaml.in/yaml/v3"
+)
+
+// TaxCalculator works out the taxes due on lines shipped to address. amounts holds
+// what each line costs after discounts, as returned by Allocate.
+type TaxCalculator interface {
+	Taxes(address models.Address, lines []Line, amounts []int64) ([]models.TaxLine, error)
+}
+
+// Allocate spreads the discounts that took subtotal down to total over lines in
+// proportion to their cost, returning what each line costs after them. The amounts
+// add up to total exactly; the cents lost to rounding go to the lines that lost the most.
+func Allocate(lines []Line, subtotal, total int64) []int64 {
+	amounts := make([]int64, len(lines))
+	if subtotal <= 0 {
+		return amounts
+	}
+
+	remainders := make([]int64, len(lines))
+	left := total
+	for i, line := range lines {
+		// cost*total can overflow 64 bits, but never its quotient as cost <= subtotal
+		hi, lo := bits.Mul64(uint64(line.UnitPrice*int64(line.Quantity)), uint64(total))
+		quotient, remainder := bits.Div64(hi, lo, uint64(subtotal))
+		amounts[i], remainders[i] = int64(quotient), int64(remainder)
+		left -= amounts[i]
+	}
+	for ; left > 0; left-- {
+		largest := 0
+		for i := range remainders {
+			if remainders[i] > remainders[largest] {
+				largest = i
+			}
+		}
+		amounts[largest]++
+		remainders[largest] = -1
+	}
+	return amounts
+}
+
+// TaxRate is one row of a TaxTable: the rate charged on products of CategoryID shipped
+// to State in Country. An empty State covers the whole country and a zero CategoryID
+// every category.
+type TaxRate struct {
+	Country    string `yaml:"country"`
+	State      string `yaml:"state"`
+	CategoryID int    `yaml:"category_id"`
+	Name       string `yaml:"name"`
+	// Rate is a percentage with up to three decimals, such as 8.875
+	Rate float64 `yaml:"rate"`
+	// Inclusive means product prices already include the tax, as is usual for VAT
+	Inclusive bool `yaml:"inclusive"`
+}
+
+// TaxTable is a TaxCalculator that looks rates up in a table. Of the rows matching a
+// line, one naming its category beats one naming its state, which beats one naming
+// neither. Lines with no matching row, or a rate of zero, are untaxed.
+type TaxTable struct {
+	rates []TaxRate
+	// millis holds each rate in thousandths of a percent
+	millis []int64
+}
+
+// NewTaxTable checks rates and builds a table of them. Every country's rates must
+// agree on whether they are inclusive, since a product has one price.
+func NewTaxTable(rates []TaxRate) (*TaxTable, error) {
+	t := &TaxTable{}
+	var errs []error
+	seen := make(map[[3]string]bool)
+	inclusive := make(map[string]bool)
+	for i, rate := range rates {
+		rate.Country = strings.ToUpper(rate.Country)
+		rate.State = strings.ToUpper(rate.State)
+
+		if len(rate.Country) != 2 {
+			errs = append(errs, fmt.Errorf("rate %d: country must be a two-letter code, got %q", i, rate.Country))
+		}
+		if rate.Name == "" {
+			errs = append(errs, fmt.Errorf("rate %d: name is required", i))
+		}
+		if rate.CategoryID < 0 {
+			errs = append(errs, fmt.Errorf("rate %d: category_id must not be negative", i))
+		}
+		milli := math.Round(rate.Rate * 1000)
+		if milli < 0 || milli > 100_000 {
+			errs = append(errs, fmt.Errorf("rate %d: rate must be between 0 and 100, got %v", i, rate.Rate))
+		}
+
+		key := [3]string{rate.Country, rate.State, strconv.Itoa(rate.CategoryID)}
+		if seen[key] {
+			errs = append(errs, fmt.Errorf("rate %d: duplicates an earlier rate for %s", i, describeRate(rate)))
+		}
+		seen[key] = true
+		if first, ok := inclusive[rate.Country]; ok && first != rate.Inclusive {
+			errs = append(errs, fmt.Errorf("rate %d: rates for %s must all be inclusive or all exclusive", i, rate.Country))
+		}
+		inclusive[rate.Country] = rate.Inclusive
+
+		t.rates = append(t.rates, rate)
+		t.millis = append(t.millis, int64(milli))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// LoadTaxTable reads a table from a YAML file with a list of rates under "rates"
+func LoadTaxTable(path string) (*TaxTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open tax rates file: %w", err)
+	}
+	defer f.Close()
+
+	var file struct {
+		Rates []TaxRate `yaml:"rates"`
+	}
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse tax rates file %s: %w", path, err)
+	}
+
+	table, err := NewTaxTable(file.Rates)
+	if err != nil {
+		return nil, fmt.Errorf("tax rates file %s: %w", path, err)
+	}
+	return table, nil
+}
+
+// Taxes sums the amounts taxed at each rate and works out each tax once on the sum,
+// rounding half up, so that rounding does not add up across lines. Taxes are returned
+// in the order of the table's rows.
+func (t *TaxTable) Taxes(address models.Address, lines []Line, amounts []int64) ([]models.TaxLine, error) {
+	taxable := make([]int64, len(t.rates))
+	for i, line := range lines {
+		if row := t.lookup(address, line.CategoryID); row >= 0 {
+			taxable[row] += amounts[i]
+		}
+	}
+
+	var taxes []models.TaxLine
+	for row, amount := range taxable {
+		if amount <= 0 || t.millis[row] == 0 {
+			continue
+		}
+		taxes = append(taxes, models.TaxLine{
+			Name:      t.rates[row].Name,
+			Rate:      strconv.FormatFloat(float64(t.millis[row])/1000, 'f', -1, 64),
+			Inclusive: t.rates[row].Inclusive,
+			Taxable:   amount,
+			Amount:    taxOn(amount, t.millis[row], t.rates[row].Inclusive),
+		})
+	}
+	return taxes, nil
+}
+
+// lookup returns the row of the rate for a product of the category shipped to
+// address, or -1 if there is none
+func (t *TaxTable) lookup(address models.Address, categoryID int) int {
+	country, state := strings.ToUpper(address.Country), strings.ToUpper(address.State)
+
+	best, bestScore := -1, -1
+	for row, rate := range t.rates {
+		if rate.Country != country ||
+			rate.State != "" && rate.State != state ||
+			rate.CategoryID != 0 && rate.CategoryID != categoryID {
+			continue
+		}
+
+		score := 0
+		if rate.CategoryID != 0 {
+			score += 2
+		}
+		if rate.State != "" {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = row, score
+		}
+	}
+	return best
+}
+
+// taxOn returns the tax at milli thousandths of a percent on amount, rounded half up.
+// An inclusive tax is the part of amount that is tax rather than tax added to it.
+func taxOn(amount, milli int64, inclusive bool) int64 {
+	const whole = 100_000 // 100% in thousandths of a percent
+	if inclusive {
+		net := (amount*whole*2 + whole + milli) / ((whole + milli) * 2)
+		return amount - net
+	}
+	return (amount*milli*2 + whole) / (whole * 2)
+}
+
+// describeRate names where and to what a rate applies, for error messages
+func describeRate(rate TaxRate) string {
+	where := rate.Country
+	if rate.State != "" {
+		where += "-" + rate.State
+	}
+	if rate.CategoryID != 0 {
+		where += fmt.Sprintf(" category %d", rate.CategoryID)
+	}
+	return where
+}
@@ -0,0 +1,167 @@
+package pricing_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/pricing"
+)
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name  string
+		total int64
+		want  []int64
+	}{
+		{name: "no discount", total: 6000, want: []int64{2000, 1500, 2500}},
+		{name: "ten percent off", total: 5400, want: []int64{1800, 1350, 2250}},
+		{name: "rounding goes to the largest remainders", total: 1001, want: []int64{334, 250, 417}},
+		{name: "everything off", total: 0, want: []int64{0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pricing.Allocate(lines, 6000, tt.total)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Allocate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// taxRates is a US state with a grocery exemption and a VAT country with a reduced rate
+var taxRates = []pricing.TaxRate{
+	{Country: "US", State: "WA", Name: "WA sales tax", Rate: 10.25},
+	{Country: "US", CategoryID: 2, Name: "Groceries", Rate: 0},
+	{Country: "DE", Name: "VAT", Rate: 19, Inclusive: true},
+	{Country: "DE", CategoryID: 2, Name: "Reduced VAT", Rate: 7, Inclusive: true},
+}
+
+func TestTaxTable(t *testing.T) {
+	table, err := pricing.NewTaxTable(taxRates)
+	if err != nil {
+		t.Fatalf("NewTaxTable: %v", err)
+	}
+	amounts := []int64{2000, 1500, 2500} // categories 1, 1 and 2
+
+	tests := []struct {
+		name    string
+		address models.Address
+		want    []models.TaxLine
+	}{
+		{
+			name:    "state rate, category exempt",
+			address: models.Address{Country: "us", State: "wa"},
+			want:    []models.TaxLine{{Name: "WA sales tax", Rate: "10.25", Taxable: 3500, Amount: 359}},
+		},
+		{
+			name:    "state without a rate",
+			address: models.Address{Country: "US", State: "OR"},
+		},
+		{
+			name:    "inclusive rates by category",
+			address: models.Address{Country: "DE"},
+			want: []models.TaxLine{
+				{Name: "VAT", Rate: "19", Inclusive: true, Taxable: 3500, Amount: 559},
+				{Name: "Reduced VAT", Rate: "7", Inclusive: true, Taxable: 2500, Amount: 164},
+			},
+		},
+		{
+			name:    "country without rates",
+			address: models.Address{Country: "JP"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := table.Taxes(tt.address, lines, amounts)
+			if err != nil {
+				t.Fatalf("Taxes: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Taxes = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTaxTableRejectsInvalidRates(t *testing.T) {
+	tests := []struct {
+		name  string
+		rates []pricing.TaxRate
+		want  string
+	}{
+		{
+			name:  "bad country",
+			rates: []pricing.TaxRate{{Country: "USA", Name: "Tax", Rate: 5}},
+			want:  "two-letter code",
+		},
+		{
+			name:  "missing name",
+			rates: []pricing.TaxRate{{Country: "US", Rate: 5}},
+			want:  "name is required",
+		},
+		{
+			name:  "rate over 100",
+			rates: []pricing.TaxRate{{Country: "US", Name: "Tax", Rate: 101}},
+			want:  "between 0 and 100",
+		},
+		{
+			name: "duplicate",
+			rates: []pricing.TaxRate{
+				{Country: "US", State: "WA", Name: "Tax", Rate: 5},
+				{Country: "us", State: "wa", Name: "Other", Rate: 6},
+			},
+			want: "duplicates an earlier rate for US-WA",
+		},
+		{
+			name: "mixed inclusive",
+			rates: []pricing.TaxRate{
+				{Country: "DE", Name: "VAT", Rate: 19, Inclusive: true},
+				{Country: "DE", CategoryID: 2, Name: "Reduced VAT", Rate: 7},
+			},
+			want: "all be inclusive or all exclusive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := pricing.NewTaxTable(tt.rates)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("NewTaxTable error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadTaxTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tax.yaml")
+	data := "rates:\n  - country: US\n    state: NY\n    name: NY sales tax\n    rate: 8.875\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := pricing.LoadTaxTable(path)
+	if err != nil {
+		t.Fatalf("LoadTaxTable: %v", err)
+	}
+	got, err := table.Taxes(models.Address{Country: "US", State: "NY"}, lines[:1], []int64{2000})
+	if err != nil {
+		t.Fatalf("Taxes: %v", err)
+	}
+	want := []models.TaxLine{{Name: "NY sales tax", Rate: "8.875", Taxable: 2000, Amount: 178}}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Taxes = %+v, want %+v", got, want)
+	}
+
+	if err := os.WriteFile(path, []byte("rates:\n  - country: US\n    percent: 5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pricing.LoadTaxTable(path); err == nil {
+		t.Fatal("LoadTaxTable with an unknown field succeeded, want an error")
+	}
+}
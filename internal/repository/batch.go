@@ -0,0 +1,15 @@
+package repository
+
+// uniqueIDs returns ids without repeats, in the order they first appear, since
+// batched lookups reject or double count a repeated key
+func uniqueIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	unique := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
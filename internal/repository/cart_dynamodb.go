@@ -2,6 +2,9 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
 	"strconv"
 	"sync/atomic"
 	"time"
@@ -14,59 +17,247 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
+const (
+	// cartUpdateMaxAttempts bounds how often an update retries after losing a race
+	cartUpdateMaxAttempts = 20
+	// cartUpdateBackoff is the upper bound of the random delay between attempts, scaled by attempt
+	cartUpdateBackoff = 10 * time.Millisecond
+)
+
+// ErrCartConflict is returned when a cart changes too often concurrently for an update to apply
+var ErrCartConflict = errors.New("cart was modified concurrently")
+
+// cartRecord is a cart as stored, with the version used for optimistic locking.
+// Its times are stored as epoch seconds, as the table's TTL on expires_at requires.
+type cartRecord struct {
+	models.Cart
+	Version int64 `dynamodbav:"version"`
+}
+
+// activeCartClaim names a customer's active cart. It is kept in the carts table under
+// the negated customer ID, which no cart uses, so that creating a cart and claiming it
+// can be one transaction. Claims outlive their carts and are ignored once the cart is
+// gone or expired.
+type activeCartClaim struct {
+	Key          int `dynamodbav:"cart_id"`
+	ActiveCartID int `dynamodbav:"active_cart_id"`
+}
+
+// activeCartClaimKey returns the key of the customer's active cart claim
+func activeCartClaimKey(customerID int) int {
+	return -customerID
+}
+
+// normalize fixes up a record read from the table
+func (r *cartRecord) normalize() {
+	// Ensure items is never nil
+	if r.Items == nil {
+		r.Items = []models.CartItem{}
+	}
+	// Epoch seconds are decoded in the local time zone
+	r.CreatedAt = r.CreatedAt.UTC()
+	r.UpdatedAt = r.UpdatedAt.UTC()
+	r.ExpiresAt = r.ExpiresAt.UTC()
+	r.RemindedAt = r.RemindedAt.UTC()
+}
+
 type CartDynamoDBRepository struct {
-	client     *dynamodb.Client
+	client     DynamoDBAPI
 	tableName  string
 	nextCartID int64
 }
 
-func NewCartDynamoDBRepository(client *dynamodb.Client) *CartDynamoDBRepository {
+func NewCartDynamoDBRepository(client DynamoDBAPI, tableName string) *CartDynamoDBRepository {
 	// Initialize with timestamp-based ID
 	return &CartDynamoDBRepository{
 		client:     client,
-		tableName:  "Carts",
+		tableName:  tableName,
 		nextCartID: time.Now().Unix(),
 	}
 }
 
 // Create creates a new cart
-func (r *CartDynamoDBRepository) Create(customerID int) (*models.Cart, error) {
-	cartID := int(atomic.AddInt64(&r.nextCartID, 1))
+func (r *CartDynamoDBRepository) Create(ctx context.Context, customerID int, guestTokenHash, currency string, expiresAt time.Time) (*models.Cart, error) {
+	now := cartTimestamp()
+	for {
+		record, item, err := r.newCart(customerID, guestTokenHash, currency, now, expiresAt)
+		if err != nil {
+			return nil, err
+		}
 
-	cart := models.Cart{
-		CartID:     cartID,
-		CustomerID: customerID,
-		Items:      []models.CartItem{},
+		// Another instance may have issued the same timestamp-based ID
+		input := &dynamodb.PutItemInput{
+			TableName:           aws.String(r.tableName),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(cart_id)"),
+		}
+
+		_, err = r.client.PutItem(ctx, input)
+		if isConditionalCheckFailed(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &record.Cart, nil
 	}
+}
 
-	item, err := attributevalue.MarshalMap(cart)
+// newCart returns a new cart record with the next timestamp-based ID, and its item
+func (r *CartDynamoDBRepository) newCart(customerID int, guestTokenHash, currency string, now, expiresAt time.Time) (*cartRecord, map[string]types.AttributeValue, error) {
+	record := &cartRecord{
+		Cart: models.Cart{
+			CartID:         int(atomic.AddInt64(&r.nextCartID, 1)),
+			CustomerID:     customerID,
+			Items:          []models.CartItem{},
+			CreatedAt:      now,
+			UpdatedAt:      now,
+			ExpiresAt:      expiresAt,
+			GuestTokenHash: guestTokenHash,
+			Currency:       currency,
+		},
+		Version: 1,
+	}
+
+	// A cart that never expires has no expires_at, so the TTL leaves it alone
+	item, err := attributevalue.MarshalMapWithOptions(record, func(o *attributevalue.EncoderOptions) {
+		o.OmitEmptyTime = true
+	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	return record, item, nil
+}
 
-	input := &dynamodb.PutItemInput{
-		TableName: aws.String(r.tableName),
-		Item:      item,
+// CreateActive returns the customer's active cart, creating one if there is none.
+// The new cart is written together with the customer's claim, on condition that the
+// claim is unchanged since it was read; if another request got there first, the
+// claim is read again and names that request's cart.
+func (r *CartDynamoDBRepository) CreateActive(ctx context.Context, customerID int, currency string, now, expiresAt time.Time) (*models.Cart, bool, error) {
+	for attempt := 0; ; attempt++ {
+		claim, cart, err := r.active(ctx, customerID, now)
+		if err != nil {
+			return nil, false, err
+		}
+		if cart != nil {
+			return cart, false, nil
+		}
+
+		record, item, err := r.newCart(customerID, "", currency, cartTimestamp(), expiresAt)
+		if err != nil {
+			return nil, false, err
+		}
+		claimItem, err := attributevalue.MarshalMap(activeCartClaim{
+			Key:          activeCartClaimKey(customerID),
+			ActiveCartID: record.CartID,
+		})
+		if err != nil {
+			return nil, false, err
+		}
+
+		claimCondition := expression.AttributeNotExists(expression.Name("cart_id"))
+		if claim != nil {
+			claimCondition = expression.Name("active_cart_id").Equal(expression.Value(claim.ActiveCartID))
+		}
+		claimExpr, err := expression.NewBuilder().WithCondition(claimCondition).Build()
+		if err != nil {
+			return nil, false, err
+		}
+
+		_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{
+				{Put: &types.Put{
+					TableName: aws.String(r.tableName),
+					Item:      item,
+					// Another instance may have issued the same timestamp-based ID
+					ConditionExpression: aws.String("attribute_not_exists(cart_id)"),
+				}},
+				{Put: &types.Put{
+					TableName:                 aws.String(r.tableName),
+					Item:                      claimItem,
+					ConditionExpression:       claimExpr.Condition(),
+					ExpressionAttributeNames:  claimExpr.Names(),
+					ExpressionAttributeValues: claimExpr.Values(),
+				}},
+			},
+		})
+		var canceled *types.TransactionCanceledException
+		if !errors.As(err, &canceled) {
+			if err != nil {
+				return nil, false, err
+			}
+			return &record.Cart, true, nil
+		}
+		if attempt == cartUpdateMaxAttempts-1 {
+			return nil, false, ErrCartConflict
+		}
 	}
+}
 
-	_, err = r.client.PutItem(context.TODO(), input)
+// GetActive retrieves the customer's active cart
+func (r *CartDynamoDBRepository) GetActive(ctx context.Context, customerID int, now time.Time) (*models.Cart, error) {
+	_, cart, err := r.active(ctx, customerID, now)
 	if err != nil {
 		return nil, err
 	}
+	if cart == nil {
+		return nil, ErrCartNotFound
+	}
+	return cart, nil
+}
+
+// active reads the customer's claim, if any, and the cart it names unless that is
+// gone or has expired at now
+func (r *CartDynamoDBRepository) active(ctx context.Context, customerID int, now time.Time) (*activeCartClaim, *models.Cart, error) {
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            cartKey(activeCartClaimKey(customerID)),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if result.Item == nil {
+		return nil, nil, nil
+	}
+
+	var claim activeCartClaim
+	if err := attributevalue.UnmarshalMap(result.Item, &claim); err != nil {
+		return nil, nil, err
+	}
 
-	return &cart, nil
+	record, err := r.get(ctx, claim.ActiveCartID)
+	if errors.Is(err, ErrCartNotFound) {
+		return &claim, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	// A cart Put for another customer no longer answers the claim
+	if record.Expired(now) || record.CustomerID != customerID {
+		return &claim, nil, nil
+	}
+	return &claim, &record.Cart, nil
 }
 
 // GetByID retrieves a cart by its ID
-func (r *CartDynamoDBRepository) GetByID(cartID int) (*models.Cart, error) {
+func (r *CartDynamoDBRepository) GetByID(ctx context.Context, cartID int) (*models.Cart, error) {
+	record, err := r.get(ctx, cartID)
+	if err != nil {
+		return nil, err
+	}
+	return &record.Cart, nil
+}
+
+func (r *CartDynamoDBRepository) get(ctx context.Context, cartID int) (*cartRecord, error) {
 	input := &dynamodb.GetItemInput{
-		TableName: aws.String(r.tableName),
-		Key: map[string]types.AttributeValue{
-			"cart_id": &types.AttributeValueMemberN{Value: strconv.Itoa(cartID)},
-		},
+		TableName:      aws.String(r.tableName),
+		Key:            cartKey(cartID),
+		ConsistentRead: aws.Bool(true),
 	}
 
-	result, err := r.client.GetItem(context.TODO(), input)
+	result, err := r.client.GetItem(ctx, input)
 	if err != nil {
 		return nil, err
 	}
@@ -75,92 +266,459 @@ func (r *CartDynamoDBRepository) GetByID(cartID int) (*models.Cart, error) {
 		return nil, ErrCartNotFound
 	}
 
-	var cart models.Cart
-	err = attributevalue.UnmarshalMap(result.Item, &cart)
+	var record cartRecord
+	err = attributevalue.UnmarshalMap(result.Item, &record)
 	if err != nil {
 		return nil, err
 	}
+	record.normalize()
 
-	// Ensure items is never nil
-	if cart.Items == nil {
-		cart.Items = []models.CartItem{}
+	return &record, nil
+}
+
+// AddItem adds an item to a cart
+func (r *CartDynamoDBRepository) AddItem(ctx context.Context, cartID int, item models.CartItem, expiresAt time.Time) error {
+	return r.AddItems(ctx, cartID, []models.CartItem{item}, expiresAt)
+}
+
+// AddItems adds items to a cart in one write.
+// The items list is rewritten only if the cart's version is unchanged since it was read;
+// if another writer got there first, the cart is read again and the update retried.
+func (r *CartDynamoDBRepository) AddItems(ctx context.Context, cartID int, items []models.CartItem, expiresAt time.Time) error {
+	return r.retryVersioned(ctx, func() error {
+		record, err := r.get(ctx, cartID)
+		if err != nil {
+			return err
+		}
+
+		record.Items = mergeCartItems(record.Items, items)
+		record.UpdatedAt = cartTimestamp()
+		record.ExpiresAt = expiresAt
+
+		return r.putItems(ctx, record)
+	})
+}
+
+// retryVersioned runs attempt, which reads a cart and writes it back on condition that
+// its version is unchanged, until the condition holds
+func (r *CartDynamoDBRepository) retryVersioned(ctx context.Context, attempt func() error) error {
+	for i := 0; ; i++ {
+		err := attempt()
+		if !isConditionalCheckFailed(err) {
+			return err
+		}
+		if i == cartUpdateMaxAttempts-1 {
+			return ErrCartConflict
+		}
+
+		// Back off a little, with jitter, so competing writers spread out
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(i+1) * time.Duration(rand.Int64N(int64(cartUpdateBackoff)))):
+		}
 	}
+}
 
-	return &cart, nil
+// versionCondition requires the cart to exist at the given version
+func versionCondition(version int64) expression.ConditionBuilder {
+	// Carts written before versioning have no version attribute and count as version 0
+	condition := expression.AttributeExists(expression.Name("cart_id"))
+	if version == 0 {
+		return condition.And(expression.AttributeNotExists(expression.Name("version")))
+	}
+	return condition.And(expression.Name("version").Equal(expression.Value(version)))
 }
 
-// AddItem adds an item to a cart
-func (r *CartDynamoDBRepository) AddItem(cartID int, item models.CartItem) error {
-	// First, get the current cart to check if product exists
-	cart, err := r.GetByID(cartID)
+// putItems writes the record's items and times if its version still matches the stored one
+func (r *CartDynamoDBRepository) putItems(ctx context.Context, record *cartRecord) error {
+	itemsAttr, err := attributevalue.Marshal(record.Items)
 	if err != nil {
 		return err
 	}
 
-	// Check if product already exists in cart items
-	found := false
-	for i, existingItem := range cart.Items {
-		if existingItem.ProductID == item.ProductID {
-			cart.Items[i].Quantity += item.Quantity
-			found = true
-			break
+	update := expression.Set(expression.Name("items"), expression.Value(itemsAttr)).
+		Set(expression.Name("version"), expression.Value(record.Version+1))
+	update = setUnixTime(update, "updated_at", record.UpdatedAt)
+	update = setUnixTime(update, "expires_at", record.ExpiresAt)
+
+	return r.updateCart(ctx, record.CartID, update, versionCondition(record.Version))
+}
+
+// AddCoupon appends a coupon code to a cart unless it is already there
+func (r *CartDynamoDBRepository) AddCoupon(ctx context.Context, cartID int, code string) error {
+	return r.updateCoupons(ctx, cartID, func(coupons []string) []string {
+		if slices.Contains(coupons, code) {
+			return coupons
 		}
+		return append(coupons, code)
+	})
+}
+
+// RemoveCoupon removes a coupon code from a cart
+func (r *CartDynamoDBRepository) RemoveCoupon(ctx context.Context, cartID int, code string) error {
+	return r.updateCoupons(ctx, cartID, func(coupons []string) []string {
+		return slices.DeleteFunc(coupons, func(c string) bool { return c == code })
+	})
+}
+
+// updateCoupons replaces a cart's coupons with what change returns for them, on
+// condition that the cart's version is unchanged since it was read
+func (r *CartDynamoDBRepository) updateCoupons(ctx context.Context, cartID int, change func(coupons []string) []string) error {
+	return r.retryVersioned(ctx, func() error {
+		record, err := r.get(ctx, cartID)
+		if err != nil {
+			return err
+		}
+
+		coupons := change(slices.Clone(record.Coupons))
+		if slices.Equal(coupons, record.Coupons) {
+			return nil
+		}
+
+		update := setCartCoupons(expression.Set(expression.Name("version"), expression.Value(record.Version+1)), coupons)
+		return r.updateCart(ctx, cartID, update, versionCondition(record.Version))
+	})
+}
+
+// SetShippingAddress replaces a cart's shipping address
+func (r *CartDynamoDBRepository) SetShippingAddress(ctx context.Context, cartID int, address *models.Address) error {
+	update, err := setCartShippingAddress(expression.UpdateBuilder{}, address)
+	if err != nil {
+		return err
+	}
+
+	// Without the condition a missing cart would be created with only its key
+	err = r.updateCart(ctx, cartID, update, expression.AttributeExists(expression.Name("cart_id")))
+	if isConditionalCheckFailed(err) {
+		return ErrCartNotFound
 	}
+	return err
+}
 
-	if !found {
-		cart.Items = append(cart.Items, item)
+// SetShippingOption replaces the ID of a cart's shipping option
+func (r *CartDynamoDBRepository) SetShippingOption(ctx context.Context, cartID int, optionID string) error {
+	update := setCartString(expression.UpdateBuilder{}, "shipping_option_id", optionID)
+
+	// Without the condition a missing cart would be created with only its key
+	err := r.updateCart(ctx, cartID, update, expression.AttributeExists(expression.Name("cart_id")))
+	if isConditionalCheckFailed(err) {
+		return ErrCartNotFound
 	}
+	return err
+}
 
-	// Marshal updated items
-	itemsAttr, err := attributevalue.Marshal(cart.Items)
+// Delete removes a cart (used after checkout)
+func (r *CartDynamoDBRepository) Delete(ctx context.Context, cartID int) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName:    aws.String(r.tableName),
+		Key:          cartKey(cartID),
+		ReturnValues: types.ReturnValueAllOld,
+	}
+
+	result, err := r.client.DeleteItem(ctx, input)
 	if err != nil {
 		return err
 	}
 
-	// Update the cart with new items list
-	update := expression.Set(
-		expression.Name("items"),
-		expression.Value(itemsAttr),
-	)
+	// Check if item existed before deletion
+	if len(result.Attributes) == 0 {
+		return ErrCartNotFound
+	}
 
-	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	return nil
+}
+
+// List returns carts in table scan order
+func (r *CartDynamoDBRepository) List(ctx context.Context, cursor string, limit int) ([]models.Cart, string, error) {
+	startKey, err := dynamoDBStartKey("cart_id", cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:         aws.String(r.tableName),
+		Limit:             aws.Int32(int32(limit)),
+		ExclusiveStartKey: startKey,
+		ConsistentRead:    aws.Bool(true),
+	}
+
+	result, err := r.client.Scan(ctx, input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var records []cartRecord
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &records); err != nil {
+		return nil, "", err
+	}
+
+	carts := make([]models.Cart, 0, len(records))
+	for _, record := range records {
+		// Active cart claims share the table under negative keys
+		if record.CartID < 1 {
+			continue
+		}
+		record.normalize()
+		carts = append(carts, record.Cart)
+	}
+	if err := r.markActive(ctx, carts); err != nil {
+		return nil, "", err
+	}
+
+	return carts, dynamoDBCursor("cart_id", result.LastEvaluatedKey), nil
+}
+
+// markActive reads the claims of the customers of carts and marks the carts they name Active
+func (r *CartDynamoDBRepository) markActive(ctx context.Context, carts []models.Cart) error {
+	var customerIDs []int
+	for _, cart := range carts {
+		if cart.CustomerID > 0 {
+			customerIDs = append(customerIDs, cart.CustomerID)
+		}
+	}
+	customerIDs = uniqueIDs(customerIDs)
+	if len(customerIDs) == 0 {
+		return nil
+	}
+
+	keys := make([]map[string]types.AttributeValue, len(customerIDs))
+	for i, customerID := range customerIDs {
+		keys[i] = cartKey(activeCartClaimKey(customerID))
+	}
+	items, err := dynamoDBBatchGet(ctx, r.client, r.tableName, keys, true)
+	if err != nil {
+		return err
+	}
+	var claims []activeCartClaim
+	if err := attributevalue.UnmarshalListOfMaps(items, &claims); err != nil {
+		return err
+	}
+
+	active := make(map[int]int, len(claims)) // customer ID → ID of their active cart
+	for _, claim := range claims {
+		active[-claim.Key] = claim.ActiveCartID
+	}
+	for i := range carts {
+		carts[i].Active = carts[i].CustomerID > 0 && active[carts[i].CustomerID] == carts[i].CartID
+	}
+	return nil
+}
+
+// ListAbandoned returns up to limit abandoned carts in table scan order.
+// There is no index on updated_at, so this scans the table until it has found enough.
+func (r *CartDynamoDBRepository) ListAbandoned(ctx context.Context, idleSince, now time.Time, limit int) ([]models.Cart, error) {
+	// The TTL deletes expired carts only eventually, so they are filtered out here
+	filter := expression.Name("customer_id").GreaterThan(expression.Value(0)).
+		And(expression.Size(expression.Name("items")).GreaterThan(expression.Value(0))).
+		And(expression.Name("updated_at").LessThanEqual(expression.Value(attributevalue.UnixTime(idleSince)))).
+		And(expression.AttributeNotExists(expression.Name("reminded_at"))).
+		And(expression.Or(
+			expression.AttributeNotExists(expression.Name("expires_at")),
+			expression.Name("expires_at").GreaterThan(expression.Value(attributevalue.UnixTime(now))),
+		))
+
+	expr, err := expression.NewBuilder().WithFilter(filter).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	carts := []models.Cart{}
+	var startKey map[string]types.AttributeValue
+	for {
+		input := &dynamodb.ScanInput{
+			TableName:                 aws.String(r.tableName),
+			FilterExpression:          expr.Filter(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			ExclusiveStartKey:         startKey,
+			ConsistentRead:            aws.Bool(true),
+		}
+
+		result, err := r.client.Scan(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		var records []cartRecord
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &records); err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			record.normalize()
+			carts = append(carts, record.Cart)
+		}
+
+		if len(carts) >= limit || len(result.LastEvaluatedKey) == 0 {
+			return carts[:min(limit, len(carts))], nil
+		}
+		startKey = result.LastEvaluatedKey
+	}
+}
+
+// MarkReminded records a reminder about an abandoned cart unless one was already recorded
+func (r *CartDynamoDBRepository) MarkReminded(ctx context.Context, cartID int, idleSince, remindedAt time.Time) (bool, error) {
+	update := expression.Set(expression.Name("reminded_at"), expression.Value(attributevalue.UnixTime(remindedAt)))
+	condition := expression.AttributeExists(expression.Name("cart_id")).
+		And(expression.AttributeNotExists(expression.Name("reminded_at"))).
+		And(expression.Name("updated_at").LessThanEqual(expression.Value(attributevalue.UnixTime(idleSince))))
+
+	err := r.updateCart(ctx, cartID, update, condition)
+	if isConditionalCheckFailed(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// UnmarkReminded forgets a reminder that could not be sent
+func (r *CartDynamoDBRepository) UnmarkReminded(ctx context.Context, cartID int) error {
+	// Without the condition a deleted cart would be recreated with only its key
+	update := expression.Remove(expression.Name("reminded_at"))
+	condition := expression.AttributeExists(expression.Name("cart_id"))
+
+	err := r.updateCart(ctx, cartID, update, condition)
+	if isConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
+
+// updateCart applies update to the cart if condition holds
+func (r *CartDynamoDBRepository) updateCart(ctx context.Context, cartID int, update expression.UpdateBuilder, condition expression.ConditionBuilder) error {
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
 	if err != nil {
 		return err
 	}
 
 	input := &dynamodb.UpdateItemInput{
-		TableName: aws.String(r.tableName),
-		Key: map[string]types.AttributeValue{
-			"cart_id": &types.AttributeValueMemberN{Value: strconv.Itoa(cartID)},
-		},
+		TableName:                 aws.String(r.tableName),
+		Key:                       cartKey(cartID),
 		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
 		ExpressionAttributeNames:  expr.Names(),
 		ExpressionAttributeValues: expr.Values(),
 	}
 
-	_, err = r.client.UpdateItem(context.TODO(), input)
+	_, err = r.client.UpdateItem(ctx, input)
 	return err
 }
 
-// Delete removes a cart (used after checkout)
-func (r *CartDynamoDBRepository) Delete(cartID int) error {
-	input := &dynamodb.DeleteItemInput{
-		TableName: aws.String(r.tableName),
-		Key: map[string]types.AttributeValue{
-			"cart_id": &types.AttributeValueMemberN{Value: strconv.Itoa(cartID)},
-		},
-		ReturnValues: types.ReturnValueAllOld,
+// Put stores a cart as given, replacing any cart with its ID.
+// The version is still incremented so concurrent AddItem calls notice the change.
+// Created carts take timestamp-based IDs and skip any that are taken. An Active cart
+// is written with its customer's claim in one transaction; otherwise a claim on a
+// replaced cart is kept, and ignored if its customer has changed.
+func (r *CartDynamoDBRepository) Put(ctx context.Context, cart *models.Cart) error {
+	items := cart.Items
+	if items == nil {
+		items = []models.CartItem{}
+	}
+	itemsAttr, err := attributevalue.Marshal(items)
+	if err != nil {
+		return err
 	}
 
-	result, err := r.client.DeleteItem(context.TODO(), input)
+	version := expression.Name("version")
+	update := expression.Set(expression.Name("customer_id"), expression.Value(cart.CustomerID)).
+		Set(expression.Name("items"), expression.Value(itemsAttr)).
+		Set(version, expression.Plus(expression.IfNotExists(version, expression.Value(0)), expression.Value(1)))
+	update = setUnixTime(update, "created_at", cart.CreatedAt)
+	update = setUnixTime(update, "updated_at", cart.UpdatedAt)
+	update = setUnixTime(update, "expires_at", cart.ExpiresAt)
+	update = setUnixTime(update, "reminded_at", cart.RemindedAt)
+	update = setCartString(update, "guest_token_hash", cart.GuestTokenHash)
+	update = setCartString(update, "shipping_option_id", cart.ShippingOptionID)
+	update = setCartString(update, "currency", cart.Currency)
+	update = setCartCoupons(update, cart.Coupons)
+	update, err = setCartShippingAddress(update, cart.ShippingAddress)
 	if err != nil {
 		return err
 	}
 
-	// Check if item existed before deletion
-	if len(result.Attributes) == 0 {
-		return ErrCartNotFound
+	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	if err != nil {
+		return err
 	}
 
-	return nil
+	if !cart.Active {
+		_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(r.tableName),
+			Key:                       cartKey(cart.CartID),
+			UpdateExpression:          expr.Update(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+		})
+		return err
+	}
+
+	claimItem, err := attributevalue.MarshalMap(activeCartClaim{
+		Key:          activeCartClaimKey(cart.CustomerID),
+		ActiveCartID: cart.CartID,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Update: &types.Update{
+				TableName:                 aws.String(r.tableName),
+				Key:                       cartKey(cart.CartID),
+				UpdateExpression:          expr.Update(),
+				ExpressionAttributeNames:  expr.Names(),
+				ExpressionAttributeValues: expr.Values(),
+			}},
+			{Put: &types.Put{
+				TableName: aws.String(r.tableName),
+				Item:      claimItem,
+			}},
+		},
+	})
+	return err
+}
+
+// setUnixTime sets a time attribute as epoch seconds, or removes it for a zero time
+func setUnixTime(update expression.UpdateBuilder, name string, t time.Time) expression.UpdateBuilder {
+	if t.IsZero() {
+		return update.Remove(expression.Name(name))
+	}
+	return update.Set(expression.Name(name), expression.Value(attributevalue.UnixTime(t)))
+}
+
+// setCartString sets a string attribute, or removes it when empty
+func setCartString(update expression.UpdateBuilder, name, value string) expression.UpdateBuilder {
+	if value == "" {
+		return update.Remove(expression.Name(name))
+	}
+	return update.Set(expression.Name(name), expression.Value(value))
+}
+
+// setCartCoupons sets the coupons attribute, or removes it when there are none
+func setCartCoupons(update expression.UpdateBuilder, coupons []string) expression.UpdateBuilder {
+	if len(coupons) == 0 {
+		return update.Remove(expression.Name("coupons"))
+	}
+	return update.Set(expression.Name("coupons"), expression.Value(coupons))
+}
+
+// setCartShippingAddress sets the shipping_address attribute, or removes it for nil
+func setCartShippingAddress(update expression.UpdateBuilder, address *models.Address) (expression.UpdateBuilder, error) {
+	if address == nil {
+		return update.Remove(expression.Name("shipping_address")), nil
+	}
+	addressAttr, err := attributevalue.Marshal(address)
+	if err != nil {
+		return update, err
+	}
+	return update.Set(expression.Name("shipping_address"), expression.Value(addressAttr)), nil
+}
+
+func cartKey(cartID int) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"cart_id": &types.AttributeValueMemberN{Value: strconv.Itoa(cartID)},
+	}
+}
+
+func isConditionalCheckFailed(err error) bool {
+	var conditionFailed *types.ConditionalCheckFailedException
+	return errors.As(err, &conditionFailed)
 }
@@ -1,8 +1,13 @@
 package repository
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/LuoZihYuan/Go-Cart/internal/models"
 )
@@ -13,36 +18,186 @@ var (
 
 type CartMemoryRepository struct {
 	carts      map[int]*models.Cart
+	active     map[int]int // customer ID → ID of their active cart
 	mu         sync.RWMutex
 	nextCartID int
+	journal    *memoryJournal // nil unless persistent
+}
+
+// cartSnapshot is the on-disk form of the whole repository
+type cartSnapshot struct {
+	NextCartID int          `json:"next_cart_id"`
+	Carts      []storedCart `json:"carts"`
+}
+
+// cartEntry is one journal line: either a cart's state after a change or a deletion
+type cartEntry struct {
+	Cart    *storedCart `json:"cart,omitempty"`
+	Deleted int         `json:"deleted,omitempty"`
+}
+
+// storedCart is the on-disk form of a cart, including the fields never sent to clients
+type storedCart struct {
+	models.Cart
+	GuestTokenHash string `json:"guest_token_hash,omitempty"`
+	// Active is set on the cart that is its customer's active cart
+	Active bool `json:"active,omitempty"`
+}
+
+func newStoredCart(cart *models.Cart, active bool) *storedCart {
+	return &storedCart{Cart: *cart, GuestTokenHash: cart.GuestTokenHash, Active: active}
+}
+
+func (s *storedCart) cart() *models.Cart {
+	cart := s.Cart
+	cart.GuestTokenHash = s.GuestTokenHash
+	return &cart
 }
 
 func NewCartMemoryRepository() *CartMemoryRepository {
 	return &CartMemoryRepository{
 		carts:      make(map[int]*models.Cart),
+		active:     make(map[int]int),
 		nextCartID: 1,
 	}
 }
 
+// NewPersistentCartMemoryRepository creates a memory repository that restores
+// its state from dir and journals every change there. With sync, each change is
+// flushed to disk before the call returns.
+func NewPersistentCartMemoryRepository(dir string, sync bool) (*CartMemoryRepository, error) {
+	r := NewCartMemoryRepository()
+
+	restore := func(data []byte) error {
+		var snapshot cartSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return err
+		}
+		for i := range snapshot.Carts {
+			r.putCart(snapshot.Carts[i].cart(), snapshot.Carts[i].Active)
+		}
+		r.nextCartID = max(r.nextCartID, snapshot.NextCartID)
+		return nil
+	}
+	replay := func(data []byte) error {
+		var entry cartEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return err
+		}
+		if entry.Cart != nil {
+			r.putCart(entry.Cart.cart(), entry.Cart.Active)
+		} else {
+			r.removeCart(entry.Deleted)
+		}
+		return nil
+	}
+
+	journal, err := openMemoryJournal(dir, "carts", sync, restore, replay)
+	if err != nil {
+		return nil, err
+	}
+	r.journal = journal
+
+	return r, nil
+}
+
+// putCart stores a restored or imported cart, keeping cart IDs from being reused
+func (r *CartMemoryRepository) putCart(cart *models.Cart, active bool) {
+	if cart.Items == nil {
+		cart.Items = []models.CartItem{}
+	}
+	r.removeCart(cart.CartID)
+	r.carts[cart.CartID] = cart
+	if active {
+		r.active[cart.CustomerID] = cart.CartID
+	}
+	r.nextCartID = max(r.nextCartID, cart.CartID+1)
+}
+
+// removeCart deletes a cart, which stops being its customer's active cart
+func (r *CartMemoryRepository) removeCart(cartID int) {
+	if cart, exists := r.carts[cartID]; exists && r.isActive(cart) {
+		delete(r.active, cart.CustomerID)
+	}
+	delete(r.carts, cartID)
+}
+
+// isActive reports whether the cart is its customer's active cart
+func (r *CartMemoryRepository) isActive(cart *models.Cart) bool {
+	cartID, exists := r.active[cart.CustomerID]
+	return exists && cartID == cart.CartID
+}
+
+// stored returns the on-disk form of a cart held by the repository
+func (r *CartMemoryRepository) stored(cart *models.Cart) *storedCart {
+	return newStoredCart(cart, r.isActive(cart))
+}
+
+// record journals a change before it is applied to memory
+func (r *CartMemoryRepository) record(entry cartEntry) error {
+	if r.journal == nil {
+		return nil
+	}
+	return r.journal.append(entry)
+}
+
 // Create creates a new cart
-func (r *CartMemoryRepository) Create(customerID int) (*models.Cart, error) {
+func (r *CartMemoryRepository) Create(ctx context.Context, customerID int, guestTokenHash, currency string, expiresAt time.Time) (*models.Cart, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	return r.create(customerID, guestTokenHash, currency, expiresAt, false)
+}
+
+// CreateActive returns the customer's active cart, creating one if there is none
+func (r *CartMemoryRepository) CreateActive(ctx context.Context, customerID int, currency string, now, expiresAt time.Time) (*models.Cart, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cart := r.activeCart(customerID, now); cart != nil {
+		return cloneCart(cart), false, nil
+	}
+
+	// An expired active cart is replaced; it stays behind until it is deleted
+	cart, err := r.create(customerID, "", currency, expiresAt, true)
+	if err != nil {
+		return nil, false, err
+	}
+	return cart, true, nil
+}
+
+// create adds a cart, making it its customer's active cart if active is set;
+// the caller must hold the write lock
+func (r *CartMemoryRepository) create(customerID int, guestTokenHash, currency string, expiresAt time.Time, active bool) (*models.Cart, error) {
+	now := cartTimestamp()
 	cart := &models.Cart{
-		CartID:     r.nextCartID,
-		CustomerID: customerID,
-		Items:      []models.CartItem{},
+		CartID:         r.nextCartID,
+		CustomerID:     customerID,
+		Items:          []models.CartItem{},
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		ExpiresAt:      expiresAt,
+		GuestTokenHash: guestTokenHash,
+		Currency:       currency,
+	}
+	if err := r.record(cartEntry{Cart: newStoredCart(cart, active)}); err != nil {
+		return nil, err
 	}
 
 	r.carts[r.nextCartID] = cart
+	if active {
+		r.active[customerID] = cart.CartID
+	}
 	r.nextCartID++
 
-	return cart, nil
+	// Return a copy so callers cannot modify the stored cart
+	cartCopy := *cart
+	cartCopy.Items = []models.CartItem{}
+	return &cartCopy, nil
 }
 
 // GetByID retrieves a cart by its ID
-func (r *CartMemoryRepository) GetByID(cartID int) (*models.Cart, error) {
+func (r *CartMemoryRepository) GetByID(ctx context.Context, cartID int) (*models.Cart, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -51,16 +206,61 @@ func (r *CartMemoryRepository) GetByID(cartID int) (*models.Cart, error) {
 		return nil, ErrCartNotFound
 	}
 
-	// Return a copy
+	return cloneCart(cart), nil
+}
+
+// GetActive retrieves the customer's active cart
+func (r *CartMemoryRepository) GetActive(ctx context.Context, customerID int, now time.Time) (*models.Cart, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cart := r.activeCart(customerID, now)
+	if cart == nil {
+		return nil, ErrCartNotFound
+	}
+
+	return cloneCart(cart), nil
+}
+
+// activeCart returns the customer's active cart unless there is none or it has expired at now
+func (r *CartMemoryRepository) activeCart(customerID int, now time.Time) *models.Cart {
+	cartID, exists := r.active[customerID]
+	if !exists {
+		return nil
+	}
+	cart := r.carts[cartID]
+	if cart.Expired(now) {
+		return nil
+	}
+	return cart
+}
+
+// cloneCart returns a copy of a stored cart that callers may modify
+func cloneCart(cart *models.Cart) *models.Cart {
 	cartCopy := *cart
 	cartCopy.Items = make([]models.CartItem, len(cart.Items))
 	copy(cartCopy.Items, cart.Items)
+	cartCopy.Coupons = slices.Clone(cart.Coupons)
+	cartCopy.ShippingAddress = cloneAddress(cart.ShippingAddress)
+	return &cartCopy
+}
 
-	return &cartCopy, nil
+// cloneAddress returns a copy of address, or nil
+func cloneAddress(address *models.Address) *models.Address {
+	if address == nil {
+		return nil
+	}
+	addressCopy := *address
+	return &addressCopy
 }
 
 // AddItem adds an item to a cart
-func (r *CartMemoryRepository) AddItem(cartID int, item models.CartItem) error {
+func (r *CartMemoryRepository) AddItem(ctx context.Context, cartID int, item models.CartItem, expiresAt time.Time) error {
+	return r.AddItems(ctx, cartID, []models.CartItem{item}, expiresAt)
+}
+
+// AddItems adds items to a cart
+func (r *CartMemoryRepository) AddItems(ctx context.Context, cartID int, added []models.CartItem, expiresAt time.Time) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -69,32 +269,321 @@ func (r *CartMemoryRepository) AddItem(cartID int, item models.CartItem) error {
 		return ErrCartNotFound
 	}
 
-	// Check if product already exists in cart, if so update quantity
-	found := false
-	for i, existingItem := range cart.Items {
-		if existingItem.ProductID == item.ProductID {
-			cart.Items[i].Quantity += item.Quantity
-			found = true
-			break
+	// Build the updated items on a copy so a failed journal write leaves the cart unchanged
+	items := make([]models.CartItem, len(cart.Items), len(cart.Items)+len(added))
+	copy(items, cart.Items)
+	items = mergeCartItems(items, added)
+
+	updated := *cart
+	updated.Items = items
+	updated.UpdatedAt = cartTimestamp()
+	updated.ExpiresAt = expiresAt
+	if err := r.record(cartEntry{Cart: r.stored(&updated)}); err != nil {
+		return err
+	}
+
+	*cart = updated
+	return nil
+}
+
+// mergeCartItems adds each of added to items, adding to the quantity of a product
+// already there, and returns the result
+func mergeCartItems(items, added []models.CartItem) []models.CartItem {
+	for _, item := range added {
+		// Check if product already exists in cart, if so update quantity
+		found := false
+		for i, existingItem := range items {
+			if existingItem.ProductID == item.ProductID {
+				items[i].Quantity += item.Quantity
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// AddCoupon appends a coupon code to a cart unless it is already there
+func (r *CartMemoryRepository) AddCoupon(ctx context.Context, cartID int, code string) error {
+	return r.updateCoupons(cartID, func(coupons []string) []string {
+		if slices.Contains(coupons, code) {
+			return coupons
 		}
+		return append(slices.Clip(coupons), code)
+	})
+}
+
+// RemoveCoupon removes a coupon code from a cart
+func (r *CartMemoryRepository) RemoveCoupon(ctx context.Context, cartID int, code string) error {
+	return r.updateCoupons(cartID, func(coupons []string) []string {
+		return slices.DeleteFunc(slices.Clone(coupons), func(c string) bool { return c == code })
+	})
+}
+
+// updateCoupons replaces a cart's coupons with what change returns for them
+func (r *CartMemoryRepository) updateCoupons(cartID int, change func(coupons []string) []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cart, exists := r.carts[cartID]
+	if !exists {
+		return ErrCartNotFound
 	}
 
-	if !found {
-		cart.Items = append(cart.Items, item)
+	updated := *cart
+	updated.Coupons = change(cart.Coupons)
+	if len(updated.Coupons) == 0 {
+		updated.Coupons = nil
+	}
+	if err := r.record(cartEntry{Cart: r.stored(&updated)}); err != nil {
+		return err
 	}
 
+	*cart = updated
+	return nil
+}
+
+// SetShippingAddress replaces a cart's shipping address
+func (r *CartMemoryRepository) SetShippingAddress(ctx context.Context, cartID int, address *models.Address) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cart, exists := r.carts[cartID]
+	if !exists {
+		return ErrCartNotFound
+	}
+
+	updated := *cart
+	updated.ShippingAddress = cloneAddress(address)
+	if err := r.record(cartEntry{Cart: r.stored(&updated)}); err != nil {
+		return err
+	}
+
+	*cart = updated
+	return nil
+}
+
+// SetShippingOption replaces the ID of a cart's shipping option
+func (r *CartMemoryRepository) SetShippingOption(ctx context.Context, cartID int, optionID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cart, exists := r.carts[cartID]
+	if !exists {
+		return ErrCartNotFound
+	}
+
+	updated := *cart
+	updated.ShippingOptionID = optionID
+	if err := r.record(cartEntry{Cart: r.stored(&updated)}); err != nil {
+		return err
+	}
+
+	*cart = updated
 	return nil
 }
 
 // Delete removes a cart (used after checkout)
-func (r *CartMemoryRepository) Delete(cartID int) error {
+func (r *CartMemoryRepository) Delete(ctx context.Context, cartID int) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	if _, exists := r.carts[cartID]; !exists {
 		return ErrCartNotFound
 	}
+	if err := r.record(cartEntry{Deleted: cartID}); err != nil {
+		return err
+	}
 
-	delete(r.carts, cartID)
+	r.removeCart(cartID)
+	return nil
+}
+
+// DeleteExpired deletes up to limit expired carts, soonest expired first
+func (r *CartMemoryRepository) DeleteExpired(ctx context.Context, now time.Time, limit int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var expired []*models.Cart
+	for _, cart := range r.carts {
+		if cart.Expired(now) {
+			expired = append(expired, cart)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].ExpiresAt.Before(expired[j].ExpiresAt)
+	})
+	expired = expired[:min(limit, len(expired))]
+
+	for i, cart := range expired {
+		if err := r.record(cartEntry{Deleted: cart.CartID}); err != nil {
+			return i, err
+		}
+		r.removeCart(cart.CartID)
+	}
+	return len(expired), nil
+}
+
+// ListAbandoned returns up to limit abandoned carts, least recently changed first
+func (r *CartMemoryRepository) ListAbandoned(ctx context.Context, idleSince, now time.Time, limit int) ([]models.Cart, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var abandoned []*models.Cart
+	for _, cart := range r.carts {
+		if cart.Guest() || cart.UpdatedAt.IsZero() || cart.UpdatedAt.After(idleSince) || !cart.RemindedAt.IsZero() ||
+			cart.Expired(now) || len(cart.Items) == 0 {
+			continue
+		}
+		abandoned = append(abandoned, cart)
+	}
+	sort.Slice(abandoned, func(i, j int) bool {
+		if !abandoned[i].UpdatedAt.Equal(abandoned[j].UpdatedAt) {
+			return abandoned[i].UpdatedAt.Before(abandoned[j].UpdatedAt)
+		}
+		return abandoned[i].CartID < abandoned[j].CartID
+	})
+	abandoned = abandoned[:min(limit, len(abandoned))]
+
+	carts := make([]models.Cart, len(abandoned))
+	for i, cart := range abandoned {
+		carts[i] = *cart
+		carts[i].Items = make([]models.CartItem, len(cart.Items))
+		copy(carts[i].Items, cart.Items)
+	}
+	return carts, nil
+}
+
+// MarkReminded records a reminder about an abandoned cart unless one was already recorded
+func (r *CartMemoryRepository) MarkReminded(ctx context.Context, cartID int, idleSince, remindedAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cart, exists := r.carts[cartID]
+	if !exists || !cart.RemindedAt.IsZero() || cart.UpdatedAt.IsZero() || cart.UpdatedAt.After(idleSince) {
+		return false, nil
+	}
+
+	updated := *cart
+	updated.RemindedAt = remindedAt.UTC().Truncate(time.Second)
+	if err := r.record(cartEntry{Cart: r.stored(&updated)}); err != nil {
+		return false, err
+	}
+
+	*cart = updated
+	return true, nil
+}
+
+// UnmarkReminded forgets a reminder that could not be sent
+func (r *CartMemoryRepository) UnmarkReminded(ctx context.Context, cartID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cart, exists := r.carts[cartID]
+	if !exists || cart.RemindedAt.IsZero() {
+		return nil
+	}
+
+	updated := *cart
+	updated.RemindedAt = time.Time{}
+	if err := r.record(cartEntry{Cart: r.stored(&updated)}); err != nil {
+		return err
+	}
+
+	*cart = updated
+	return nil
+}
+
+// List returns carts in ID order
+func (r *CartMemoryRepository) List(ctx context.Context, cursor string, limit int) ([]models.Cart, string, error) {
+	after, err := parseIDCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]int, 0, len(r.carts))
+	for id := range r.carts {
+		if id > after {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	ids = ids[:min(limit, len(ids))]
+
+	carts := make([]models.Cart, len(ids))
+	for i, id := range ids {
+		carts[i] = *cloneCart(r.carts[id])
+		carts[i].Active = r.isActive(&carts[i])
+	}
+	if len(carts) == 0 {
+		return carts, "", nil
+	}
+	return carts, nextIDCursor(ids[len(ids)-1], len(ids), limit), nil
+}
+
+// Put stores a cart as given, replacing any cart with its ID.
+// An Active cart takes over as its customer's active cart; otherwise a replaced
+// active cart stays active if its customer is unchanged.
+func (r *CartMemoryRepository) Put(ctx context.Context, cart *models.Cart) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := cloneCart(cart)
+	// Whether a held cart is active is kept in r.active alone
+	stored.Active = false
+	active := cart.Active || r.isActive(stored)
+	if err := r.record(cartEntry{Cart: newStoredCart(stored, active)}); err != nil {
+		return err
+	}
+
+	r.putCart(stored, active)
 	return nil
 }
+
+// Compact writes a snapshot of every cart and empties the journal.
+// It does nothing for a repository that is not persistent.
+func (r *CartMemoryRepository) Compact() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.journal == nil {
+		return nil
+	}
+
+	snapshot := cartSnapshot{
+		NextCartID: r.nextCartID,
+		Carts:      make([]storedCart, 0, len(r.carts)),
+	}
+	for _, cart := range r.carts {
+		snapshot.Carts = append(snapshot.Carts, *r.stored(cart))
+	}
+	sort.Slice(snapshot.Carts, func(i, j int) bool {
+		return snapshot.Carts[i].CartID < snapshot.Carts[j].CartID
+	})
+
+	return r.journal.compact(snapshot)
+}
+
+// Close compacts and closes the journal of a persistent repository
+func (r *CartMemoryRepository) Close() error {
+	if err := r.Compact(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.journal == nil {
+		return nil
+	}
+	err := r.journal.close()
+	r.journal = nil
+	return err
+}
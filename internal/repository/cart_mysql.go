@@ -1,10 +1,21 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"slices"
+	"time"
 
 	"github.com/LuoZihYuan/Go-Cart/internal/models"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	// mysqlErrNoReferencedRow is ER_NO_REFERENCED_ROW_2, raised when a foreign key has no parent row
+	mysqlErrNoReferencedRow = 1452
+	// mysqlErrDupEntry is ER_DUP_ENTRY, raised when a row would duplicate a unique key
+	mysqlErrDupEntry = 1062
 )
 
 type CartMySQLRepository struct {
@@ -18,13 +29,14 @@ func NewCartMySQLRepository(db *sql.DB) *CartMySQLRepository {
 }
 
 // Create creates a new cart
-func (r *CartMySQLRepository) Create(customerID int) (*models.Cart, error) {
+func (r *CartMySQLRepository) Create(ctx context.Context, customerID int, guestTokenHash, currency string, expiresAt time.Time) (*models.Cart, error) {
 	query := `
-		INSERT INTO carts (customer_id)
-		VALUES (?)
+		INSERT INTO carts (customer_id, created_at, updated_at, expires_at, guest_token_hash, currency)
+		VALUES (?, ?, ?, ?, ?, ?)
 	`
 
-	result, err := r.db.Exec(query, customerID)
+	now := cartTimestamp()
+	result, err := r.db.ExecContext(ctx, query, customerID, now, now, nullTime(expiresAt), nullString(guestTokenHash), currency)
 	if err != nil {
 		return nil, err
 	}
@@ -35,26 +47,63 @@ func (r *CartMySQLRepository) Create(customerID int) (*models.Cart, error) {
 	}
 
 	return &models.Cart{
-		CartID:     int(cartID),
-		CustomerID: customerID,
-		Items:      []models.CartItem{},
+		CartID:         int(cartID),
+		CustomerID:     customerID,
+		Items:          []models.CartItem{},
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		ExpiresAt:      expiresAt,
+		GuestTokenHash: guestTokenHash,
+		Currency:       currency,
 	}, nil
 }
 
+// CreateActive returns the customer's active cart, creating one if there is none
+func (r *CartMySQLRepository) CreateActive(ctx context.Context, customerID int, currency string, now, expiresAt time.Time) (*models.Cart, bool, error) {
+	return createActiveCartSQL(ctx, r.db, r.activeCartStatements(), customerID, currency, now, expiresAt, func(err error) bool {
+		var mysqlErr *mysql.MySQLError
+		return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDupEntry
+	})
+}
+
+// GetActive retrieves the customer's active cart
+func (r *CartMySQLRepository) GetActive(ctx context.Context, customerID int, now time.Time) (*models.Cart, error) {
+	return getActiveCartSQL(ctx, r.db, r.activeCartStatements(), customerID, now)
+}
+
+func (r *CartMySQLRepository) activeCartStatements() activeCartStatements {
+	return activeCartStatements{
+		selectActive: `
+			SELECT cart_id, customer_id, created_at, updated_at, expires_at, reminded_at, guest_token_hash, coupons, shipping_address, shipping_option_id, currency
+			FROM carts
+			WHERE active_customer_id = ?
+		`,
+		release: `UPDATE carts SET active_customer_id = NULL WHERE cart_id = ?`,
+		insertActive: `
+			INSERT INTO carts (customer_id, created_at, updated_at, expires_at, active_customer_id, currency)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`,
+		lastInsertID: true,
+		items: `
+			SELECT product_id, quantity
+			FROM cart_items
+			WHERE cart_id = ?
+			ORDER BY created_at, product_id
+		`,
+	}
+}
+
 // GetByID retrieves a cart by its ID
-func (r *CartMySQLRepository) GetByID(cartID int) (*models.Cart, error) {
+func (r *CartMySQLRepository) GetByID(ctx context.Context, cartID int) (*models.Cart, error) {
 	// First, get the cart
 	cartQuery := `
-		SELECT cart_id, customer_id
+		SELECT cart_id, customer_id, created_at, updated_at, expires_at, reminded_at, guest_token_hash, coupons, shipping_address, shipping_option_id, currency
 		FROM carts
 		WHERE cart_id = ?
 	`
 
 	var cart models.Cart
-	err := r.db.QueryRow(cartQuery, cartID).Scan(
-		&cart.CartID,
-		&cart.CustomerID,
-	)
+	err := scanCart(r.db.QueryRowContext(ctx, cartQuery, cartID), &cart)
 
 	if err == sql.ErrNoRows {
 		return nil, ErrCartNotFound
@@ -70,7 +119,7 @@ func (r *CartMySQLRepository) GetByID(cartID int) (*models.Cart, error) {
 		WHERE cart_id = ?
 	`
 
-	rows, err := r.db.Query(itemsQuery, cartID)
+	rows, err := r.db.QueryContext(ctx, itemsQuery, cartID)
 	if err != nil {
 		return nil, err
 	}
@@ -93,23 +142,71 @@ func (r *CartMySQLRepository) GetByID(cartID int) (*models.Cart, error) {
 }
 
 // AddItem adds an item to a cart
-func (r *CartMySQLRepository) AddItem(cartID int, item models.CartItem) error {
-	query := `
+func (r *CartMySQLRepository) AddItem(ctx context.Context, cartID int, item models.CartItem, expiresAt time.Time) error {
+	return r.AddItems(ctx, cartID, []models.CartItem{item}, expiresAt)
+}
+
+// AddItems adds items to a cart in one transaction
+func (r *CartMySQLRepository) AddItems(ctx context.Context, cartID int, items []models.CartItem, expiresAt time.Time) error {
+	touchCart := `UPDATE carts SET updated_at = ?, expires_at = ? WHERE cart_id = ?`
+	upsertItem := `
 		INSERT INTO cart_items (cart_id, product_id, quantity)
 		VALUES (?, ?, ?)
 		ON DUPLICATE KEY UPDATE
 			quantity = quantity + VALUES(quantity)
 	`
 
-	_, err := r.db.Exec(query, cartID, item.ProductID, item.Quantity)
-	return err
+	return addCartItemsSQL(ctx, r.db, touchCart, upsertItem, cartID, items, expiresAt, func(err error) bool {
+		var mysqlErr *mysql.MySQLError
+		return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrNoReferencedRow
+	})
+}
+
+// AddCoupon appends a coupon code to a cart unless it is already there
+func (r *CartMySQLRepository) AddCoupon(ctx context.Context, cartID int, code string) error {
+	return r.updateCoupons(ctx, cartID, func(coupons []string) []string {
+		if slices.Contains(coupons, code) {
+			return coupons
+		}
+		return append(coupons, code)
+	})
+}
+
+// RemoveCoupon removes a coupon code from a cart
+func (r *CartMySQLRepository) RemoveCoupon(ctx context.Context, cartID int, code string) error {
+	return r.updateCoupons(ctx, cartID, func(coupons []string) []string {
+		return slices.DeleteFunc(coupons, func(c string) bool { return c == code })
+	})
+}
+
+func (r *CartMySQLRepository) updateCoupons(ctx context.Context, cartID int, change func(coupons []string) []string) error {
+	selectCoupons := `SELECT coupons FROM carts WHERE cart_id = ?`
+	updateCoupons := `UPDATE carts SET coupons = ? WHERE cart_id = ? AND coupons = ?`
+
+	return updateCouponsSQL(ctx, r.db, selectCoupons, updateCoupons, cartID, change)
+}
+
+// SetShippingAddress replaces a cart's shipping address
+func (r *CartMySQLRepository) SetShippingAddress(ctx context.Context, cartID int, address *models.Address) error {
+	setAddress := `UPDATE carts SET shipping_address = ? WHERE cart_id = ?`
+	cartExists := `SELECT 1 FROM carts WHERE cart_id = ?`
+
+	return setShippingAddressSQL(ctx, r.db, setAddress, cartExists, cartID, address)
+}
+
+// SetShippingOption replaces the ID of a cart's shipping option
+func (r *CartMySQLRepository) SetShippingOption(ctx context.Context, cartID int, optionID string) error {
+	setOption := `UPDATE carts SET shipping_option_id = ? WHERE cart_id = ?`
+	cartExists := `SELECT 1 FROM carts WHERE cart_id = ?`
+
+	return setCartColumnSQL(ctx, r.db, setOption, cartExists, cartID, optionID)
 }
 
 // Delete removes a cart (used after checkout)
-func (r *CartMySQLRepository) Delete(cartID int) error {
+func (r *CartMySQLRepository) Delete(ctx context.Context, cartID int) error {
 	query := `DELETE FROM carts WHERE cart_id = ?`
 
-	result, err := r.db.Exec(query, cartID)
+	result, err := r.db.ExecContext(ctx, query, cartID)
 	if err != nil {
 		return err
 	}
@@ -125,3 +222,106 @@ func (r *CartMySQLRepository) Delete(cartID int) error {
 
 	return nil
 }
+
+// DeleteExpired deletes up to limit expired carts, soonest expired first; their items cascade
+func (r *CartMySQLRepository) DeleteExpired(ctx context.Context, now time.Time, limit int) (int, error) {
+	query := `
+		DELETE FROM carts
+		WHERE expires_at <= ?
+		ORDER BY expires_at
+		LIMIT ?
+	`
+
+	return deleteExpiredSQL(ctx, r.db, query, now, limit)
+}
+
+// ListAbandoned returns up to limit abandoned carts, least recently changed first
+func (r *CartMySQLRepository) ListAbandoned(ctx context.Context, idleSince, now time.Time, limit int) ([]models.Cart, error) {
+	cartsQuery := `
+		SELECT cart_id, customer_id, created_at, updated_at, expires_at, reminded_at, guest_token_hash, coupons, shipping_address, shipping_option_id, currency
+		FROM carts
+		WHERE customer_id > 0
+			AND updated_at <= ?
+			AND reminded_at IS NULL
+			AND (expires_at IS NULL OR expires_at > ?)
+			AND EXISTS (SELECT 1 FROM cart_items WHERE cart_items.cart_id = carts.cart_id)
+		ORDER BY updated_at, cart_id
+		LIMIT ?
+	`
+	itemsQuery := `
+		SELECT product_id, quantity
+		FROM cart_items
+		WHERE cart_id = ?
+		ORDER BY created_at, product_id
+	`
+
+	return listAbandonedSQL(ctx, r.db, cartsQuery, itemsQuery, idleSince, now, limit)
+}
+
+// MarkReminded records a reminder about an abandoned cart unless one was already recorded
+func (r *CartMySQLRepository) MarkReminded(ctx context.Context, cartID int, idleSince, remindedAt time.Time) (bool, error) {
+	query := `
+		UPDATE carts SET reminded_at = ?
+		WHERE cart_id = ? AND reminded_at IS NULL AND updated_at <= ?
+	`
+
+	return markRemindedSQL(ctx, r.db, query, cartID, idleSince, remindedAt)
+}
+
+// UnmarkReminded forgets a reminder that could not be sent
+func (r *CartMySQLRepository) UnmarkReminded(ctx context.Context, cartID int) error {
+	query := `UPDATE carts SET reminded_at = NULL WHERE cart_id = ?`
+
+	_, err := r.db.ExecContext(ctx, query, cartID)
+	return err
+}
+
+// List returns carts in ID order
+func (r *CartMySQLRepository) List(ctx context.Context, cursor string, limit int) ([]models.Cart, string, error) {
+	cartsQuery := `
+		SELECT cart_id, customer_id, created_at, updated_at, expires_at, reminded_at, guest_token_hash, coupons, shipping_address, shipping_option_id, currency, active_customer_id IS NOT NULL
+		FROM carts
+		WHERE cart_id > ?
+		ORDER BY cart_id
+		LIMIT ?
+	`
+	itemsQuery := `
+		SELECT cart_id, product_id, quantity
+		FROM cart_items
+		WHERE cart_id BETWEEN ? AND ?
+		ORDER BY cart_id, created_at, product_id
+	`
+
+	return listCartsSQL(ctx, r.db, cartsQuery, itemsQuery, cursor, limit)
+}
+
+// Put stores a cart as given, replacing any cart with its ID.
+// Inserting an explicit ID moves AUTO_INCREMENT past it. An Active cart takes
+// over as its customer's active cart; otherwise a replaced active cart stays active
+// if its customer is unchanged.
+func (r *CartMySQLRepository) Put(ctx context.Context, cart *models.Cart) error {
+	return putCartSQL(ctx, r.db, cartPutStatements{
+		releaseActive: `UPDATE carts SET active_customer_id = NULL WHERE active_customer_id = ? AND cart_id <> ?`,
+		upsertCart: `
+			INSERT INTO carts (cart_id, customer_id, created_at, updated_at, expires_at, reminded_at, guest_token_hash, coupons, shipping_address, shipping_option_id, currency, active_customer_id)
+			VALUES (?, ?, COALESCE(?, CURRENT_TIMESTAMP), ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE
+				active_customer_id = COALESCE(VALUES(active_customer_id), IF(customer_id = VALUES(customer_id), active_customer_id, NULL)),
+				customer_id = VALUES(customer_id),
+				created_at = VALUES(created_at),
+				updated_at = VALUES(updated_at),
+				expires_at = VALUES(expires_at),
+				reminded_at = VALUES(reminded_at),
+				guest_token_hash = VALUES(guest_token_hash),
+				coupons = VALUES(coupons),
+				shipping_address = VALUES(shipping_address),
+				shipping_option_id = VALUES(shipping_option_id),
+				currency = VALUES(currency)
+		`,
+		deleteItems: `DELETE FROM cart_items WHERE cart_id = ?`,
+		insertItem: `
+			INSERT INTO cart_items (cart_id, product_id, quantity)
+			VALUES (?, ?, ?)
+		`,
+	}, cart)
+}
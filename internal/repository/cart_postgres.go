@@ -0,0 +1,335 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+const (
+	// postgresForeignKeyViolation is the SQLSTATE raised when a foreign key has no parent row
+	postgresForeignKeyViolation = "23503"
+	// postgresUniqueViolation is the SQLSTATE raised when a row would duplicate a unique key
+	postgresUniqueViolation = "23505"
+)
+
+type CartPostgresRepository struct {
+	db *sql.DB
+}
+
+func NewCartPostgresRepository(db *sql.DB) *CartPostgresRepository {
+	return &CartPostgresRepository{
+		db: db,
+	}
+}
+
+// Create creates a new cart
+func (r *CartPostgresRepository) Create(ctx context.Context, customerID int, guestTokenHash, currency string, expiresAt time.Time) (*models.Cart, error) {
+	query := `
+		INSERT INTO carts (customer_id, created_at, updated_at, expires_at, guest_token_hash, currency)
+		VALUES ($1, $2, $2, $3, $4, $5)
+		RETURNING cart_id
+	`
+
+	now := cartTimestamp()
+	var cartID int
+	if err := r.db.QueryRowContext(ctx, query, customerID, now, nullTime(expiresAt), nullString(guestTokenHash), currency).Scan(&cartID); err != nil {
+		return nil, err
+	}
+
+	return &models.Cart{
+		CartID:         cartID,
+		CustomerID:     customerID,
+		Items:          []models.CartItem{},
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		ExpiresAt:      expiresAt,
+		GuestTokenHash: guestTokenHash,
+		Currency:       currency,
+	}, nil
+}
+
+// CreateActive returns the customer's active cart, creating one if there is none
+func (r *CartPostgresRepository) CreateActive(ctx context.Context, customerID int, currency string, now, expiresAt time.Time) (*models.Cart, bool, error) {
+	return createActiveCartSQL(ctx, r.db, r.activeCartStatements(), customerID, currency, now, expiresAt, func(err error) bool {
+		var pgErr *pgconn.PgError
+		return errors.As(err, &pgErr) && pgErr.Code == postgresUniqueViolation
+	})
+}
+
+// GetActive retrieves the customer's active cart
+func (r *CartPostgresRepository) GetActive(ctx context.Context, customerID int, now time.Time) (*models.Cart, error) {
+	return getActiveCartSQL(ctx, r.db, r.activeCartStatements(), customerID, now)
+}
+
+func (r *CartPostgresRepository) activeCartStatements() activeCartStatements {
+	return activeCartStatements{
+		selectActive: `
+			SELECT cart_id, customer_id, created_at, updated_at, expires_at, reminded_at, guest_token_hash, coupons, shipping_address, shipping_option_id, currency
+			FROM carts
+			WHERE active_customer_id = $1
+		`,
+		release: `UPDATE carts SET active_customer_id = NULL WHERE cart_id = $1`,
+		insertActive: `
+			INSERT INTO carts (customer_id, created_at, updated_at, expires_at, active_customer_id, currency)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING cart_id
+		`,
+		items: `
+			SELECT product_id, quantity
+			FROM cart_items
+			WHERE cart_id = $1
+			ORDER BY created_at, product_id
+		`,
+	}
+}
+
+// GetByID retrieves a cart by its ID
+func (r *CartPostgresRepository) GetByID(ctx context.Context, cartID int) (*models.Cart, error) {
+	// First, get the cart
+	cartQuery := `
+		SELECT cart_id, customer_id, created_at, updated_at, expires_at, reminded_at, guest_token_hash, coupons, shipping_address, shipping_option_id, currency
+		FROM carts
+		WHERE cart_id = $1
+	`
+
+	var cart models.Cart
+	err := scanCart(r.db.QueryRowContext(ctx, cartQuery, cartID), &cart)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrCartNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Then, get all items in the cart
+	itemsQuery := `
+		SELECT product_id, quantity
+		FROM cart_items
+		WHERE cart_id = $1
+		ORDER BY created_at, product_id
+	`
+
+	rows, err := r.db.QueryContext(ctx, itemsQuery, cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cart.Items = []models.CartItem{}
+	for rows.Next() {
+		var item models.CartItem
+		if err := rows.Scan(&item.ProductID, &item.Quantity); err != nil {
+			return nil, err
+		}
+		cart.Items = append(cart.Items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &cart, nil
+}
+
+// AddItem adds an item to a cart
+func (r *CartPostgresRepository) AddItem(ctx context.Context, cartID int, item models.CartItem, expiresAt time.Time) error {
+	return r.AddItems(ctx, cartID, []models.CartItem{item}, expiresAt)
+}
+
+// AddItems adds items to a cart in one transaction
+func (r *CartPostgresRepository) AddItems(ctx context.Context, cartID int, items []models.CartItem, expiresAt time.Time) error {
+	touchCart := `UPDATE carts SET updated_at = $1, expires_at = $2 WHERE cart_id = $3`
+	upsertItem := `
+		INSERT INTO cart_items (cart_id, product_id, quantity)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (cart_id, product_id) DO UPDATE SET
+			quantity = cart_items.quantity + EXCLUDED.quantity,
+			updated_at = now()
+	`
+
+	return addCartItemsSQL(ctx, r.db, touchCart, upsertItem, cartID, items, expiresAt, func(err error) bool {
+		var pgErr *pgconn.PgError
+		return errors.As(err, &pgErr) && pgErr.Code == postgresForeignKeyViolation
+	})
+}
+
+// AddCoupon appends a coupon code to a cart unless it is already there
+func (r *CartPostgresRepository) AddCoupon(ctx context.Context, cartID int, code string) error {
+	return r.updateCoupons(ctx, cartID, func(coupons []string) []string {
+		if slices.Contains(coupons, code) {
+			return coupons
+		}
+		return append(coupons, code)
+	})
+}
+
+// RemoveCoupon removes a coupon code from a cart
+func (r *CartPostgresRepository) RemoveCoupon(ctx context.Context, cartID int, code string) error {
+	return r.updateCoupons(ctx, cartID, func(coupons []string) []string {
+		return slices.DeleteFunc(coupons, func(c string) bool { return c == code })
+	})
+}
+
+func (r *CartPostgresRepository) updateCoupons(ctx context.Context, cartID int, change func(coupons []string) []string) error {
+	selectCoupons := `SELECT coupons FROM carts WHERE cart_id = $1`
+	updateCoupons := `UPDATE carts SET coupons = $1 WHERE cart_id = $2 AND coupons = $3`
+
+	return updateCouponsSQL(ctx, r.db, selectCoupons, updateCoupons, cartID, change)
+}
+
+// SetShippingAddress replaces a cart's shipping address
+func (r *CartPostgresRepository) SetShippingAddress(ctx context.Context, cartID int, address *models.Address) error {
+	setAddress := `UPDATE carts SET shipping_address = $1 WHERE cart_id = $2`
+	cartExists := `SELECT 1 FROM carts WHERE cart_id = $1`
+
+	return setShippingAddressSQL(ctx, r.db, setAddress, cartExists, cartID, address)
+}
+
+// SetShippingOption replaces the ID of a cart's shipping option
+func (r *CartPostgresRepository) SetShippingOption(ctx context.Context, cartID int, optionID string) error {
+	setOption := `UPDATE carts SET shipping_option_id = $1 WHERE cart_id = $2`
+	cartExists := `SELECT 1 FROM carts WHERE cart_id = $1`
+
+	return setCartColumnSQL(ctx, r.db, setOption, cartExists, cartID, optionID)
+}
+
+// Delete removes a cart (used after checkout)
+func (r *CartPostgresRepository) Delete(ctx context.Context, cartID int) error {
+	query := `DELETE FROM carts WHERE cart_id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, cartID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrCartNotFound
+	}
+
+	return nil
+}
+
+// DeleteExpired deletes up to limit expired carts, soonest expired first; their items cascade.
+// Carts locked by a concurrent AddItem are skipped rather than waited for.
+func (r *CartPostgresRepository) DeleteExpired(ctx context.Context, now time.Time, limit int) (int, error) {
+	query := `
+		DELETE FROM carts
+		WHERE cart_id IN (
+			SELECT cart_id FROM carts
+			WHERE expires_at <= $1
+			ORDER BY expires_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+	`
+
+	return deleteExpiredSQL(ctx, r.db, query, now, limit)
+}
+
+// ListAbandoned returns up to limit abandoned carts, least recently changed first
+func (r *CartPostgresRepository) ListAbandoned(ctx context.Context, idleSince, now time.Time, limit int) ([]models.Cart, error) {
+	cartsQuery := `
+		SELECT cart_id, customer_id, created_at, updated_at, expires_at, reminded_at, guest_token_hash, coupons, shipping_address, shipping_option_id, currency
+		FROM carts
+		WHERE customer_id > 0
+			AND updated_at <= $1
+			AND reminded_at IS NULL
+			AND (expires_at IS NULL OR expires_at > $2)
+			AND EXISTS (SELECT 1 FROM cart_items WHERE cart_items.cart_id = carts.cart_id)
+		ORDER BY updated_at, cart_id
+		LIMIT $3
+	`
+	itemsQuery := `
+		SELECT product_id, quantity
+		FROM cart_items
+		WHERE cart_id = $1
+		ORDER BY created_at, product_id
+	`
+
+	return listAbandonedSQL(ctx, r.db, cartsQuery, itemsQuery, idleSince, now, limit)
+}
+
+// MarkReminded records a reminder about an abandoned cart unless one was already recorded
+func (r *CartPostgresRepository) MarkReminded(ctx context.Context, cartID int, idleSince, remindedAt time.Time) (bool, error) {
+	query := `
+		UPDATE carts SET reminded_at = $1
+		WHERE cart_id = $2 AND reminded_at IS NULL AND updated_at <= $3
+	`
+
+	return markRemindedSQL(ctx, r.db, query, cartID, idleSince, remindedAt)
+}
+
+// UnmarkReminded forgets a reminder that could not be sent
+func (r *CartPostgresRepository) UnmarkReminded(ctx context.Context, cartID int) error {
+	query := `UPDATE carts SET reminded_at = NULL WHERE cart_id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, cartID)
+	return err
+}
+
+// List returns carts in ID order
+func (r *CartPostgresRepository) List(ctx context.Context, cursor string, limit int) ([]models.Cart, string, error) {
+	cartsQuery := `
+		SELECT cart_id, customer_id, created_at, updated_at, expires_at, reminded_at, guest_token_hash, coupons, shipping_address, shipping_option_id, currency, active_customer_id IS NOT NULL
+		FROM carts
+		WHERE cart_id > $1
+		ORDER BY cart_id
+		LIMIT $2
+	`
+	itemsQuery := `
+		SELECT cart_id, product_id, quantity
+		FROM cart_items
+		WHERE cart_id BETWEEN $1 AND $2
+		ORDER BY cart_id, created_at, product_id
+	`
+
+	return listCartsSQL(ctx, r.db, cartsQuery, itemsQuery, cursor, limit)
+}
+
+// Put stores a cart as given, replacing any cart with its ID.
+// The identity sequence is moved past the ID so Create does not collide with it.
+// An Active cart takes over as its customer's active cart; otherwise a replaced
+// active cart stays active if its customer is unchanged.
+func (r *CartPostgresRepository) Put(ctx context.Context, cart *models.Cart) error {
+	return putCartSQL(ctx, r.db, cartPutStatements{
+		releaseActive: `UPDATE carts SET active_customer_id = NULL WHERE active_customer_id = $1 AND cart_id <> $2`,
+		upsertCart: `
+			INSERT INTO carts (cart_id, customer_id, created_at, updated_at, expires_at, reminded_at, guest_token_hash, coupons, shipping_address, shipping_option_id, currency, active_customer_id)
+			VALUES ($1, $2, COALESCE($3, now()), $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			ON CONFLICT (cart_id) DO UPDATE SET
+				active_customer_id = COALESCE(EXCLUDED.active_customer_id, CASE WHEN carts.customer_id = EXCLUDED.customer_id THEN carts.active_customer_id END),
+				customer_id = EXCLUDED.customer_id,
+				created_at = EXCLUDED.created_at,
+				updated_at = EXCLUDED.updated_at,
+				expires_at = EXCLUDED.expires_at,
+				reminded_at = EXCLUDED.reminded_at,
+				guest_token_hash = EXCLUDED.guest_token_hash,
+				coupons = EXCLUDED.coupons,
+				shipping_address = EXCLUDED.shipping_address,
+				shipping_option_id = EXCLUDED.shipping_option_id,
+				currency = EXCLUDED.currency
+		`,
+		deleteItems: `DELETE FROM cart_items WHERE cart_id = $1`,
+		insertItem: `
+			INSERT INTO cart_items (cart_id, product_id, quantity)
+			VALUES ($1, $2, $3)
+		`,
+		afterPut: `
+			SELECT setval(pg_get_serial_sequence('carts', 'cart_id'), $1::bigint)
+			WHERE $1::bigint > COALESCE(pg_sequence_last_value(pg_get_serial_sequence('carts', 'cart_id')::regclass), 0)
+		`,
+	}, cart)
+}
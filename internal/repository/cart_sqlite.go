@@ -0,0 +1,322 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+type CartSQLiteRepository struct {
+	db *sql.DB
+}
+
+func NewCartSQLiteRepository(db *sql.DB) *CartSQLiteRepository {
+	return &CartSQLiteRepository{
+		db: db,
+	}
+}
+
+// Create creates a new cart
+func (r *CartSQLiteRepository) Create(ctx context.Context, customerID int, guestTokenHash, currency string, expiresAt time.Time) (*models.Cart, error) {
+	query := `
+		INSERT INTO carts (customer_id, created_at, updated_at, expires_at, guest_token_hash, currency)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING cart_id
+	`
+
+	now := cartTimestamp()
+	var cartID int
+	if err := r.db.QueryRowContext(ctx, query, customerID, now, now, nullTime(expiresAt), nullString(guestTokenHash), currency).Scan(&cartID); err != nil {
+		return nil, err
+	}
+
+	return &models.Cart{
+		CartID:         cartID,
+		CustomerID:     customerID,
+		Items:          []models.CartItem{},
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		ExpiresAt:      expiresAt,
+		GuestTokenHash: guestTokenHash,
+		Currency:       currency,
+	}, nil
+}
+
+// CreateActive returns the customer's active cart, creating one if there is none
+func (r *CartSQLiteRepository) CreateActive(ctx context.Context, customerID int, currency string, now, expiresAt time.Time) (*models.Cart, bool, error) {
+	return createActiveCartSQL(ctx, r.db, r.activeCartStatements(), customerID, currency, now, expiresAt, func(err error) bool {
+		var sqliteErr *sqlite.Error
+		return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+	})
+}
+
+// GetActive retrieves the customer's active cart
+func (r *CartSQLiteRepository) GetActive(ctx context.Context, customerID int, now time.Time) (*models.Cart, error) {
+	return getActiveCartSQL(ctx, r.db, r.activeCartStatements(), customerID, now)
+}
+
+func (r *CartSQLiteRepository) activeCartStatements() activeCartStatements {
+	return activeCartStatements{
+		selectActive: `
+			SELECT cart_id, customer_id, created_at, updated_at, expires_at, reminded_at, guest_token_hash, coupons, shipping_address, shipping_option_id, currency
+			FROM carts
+			WHERE active_customer_id = ?
+		`,
+		release: `UPDATE carts SET active_customer_id = NULL WHERE cart_id = ?`,
+		insertActive: `
+			INSERT INTO carts (customer_id, created_at, updated_at, expires_at, active_customer_id, currency)
+			VALUES (?, ?, ?, ?, ?, ?)
+			RETURNING cart_id
+		`,
+		items: `
+			SELECT product_id, quantity
+			FROM cart_items
+			WHERE cart_id = ?
+			ORDER BY created_at, product_id
+		`,
+	}
+}
+
+// GetByID retrieves a cart by its ID
+func (r *CartSQLiteRepository) GetByID(ctx context.Context, cartID int) (*models.Cart, error) {
+	// First, get the cart
+	cartQuery := `
+		SELECT cart_id, customer_id, created_at, updated_at, expires_at, reminded_at, guest_token_hash, coupons, shipping_address, shipping_option_id, currency
+		FROM carts
+		WHERE cart_id = ?
+	`
+
+	var cart models.Cart
+	err := scanCart(r.db.QueryRowContext(ctx, cartQuery, cartID), &cart)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrCartNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Then, get all items in the cart
+	itemsQuery := `
+		SELECT product_id, quantity
+		FROM cart_items
+		WHERE cart_id = ?
+		ORDER BY created_at, product_id
+	`
+
+	rows, err := r.db.QueryContext(ctx, itemsQuery, cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cart.Items = []models.CartItem{}
+	for rows.Next() {
+		var item models.CartItem
+		if err := rows.Scan(&item.ProductID, &item.Quantity); err != nil {
+			return nil, err
+		}
+		cart.Items = append(cart.Items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &cart, nil
+}
+
+// AddItem adds an item to a cart
+func (r *CartSQLiteRepository) AddItem(ctx context.Context, cartID int, item models.CartItem, expiresAt time.Time) error {
+	return r.AddItems(ctx, cartID, []models.CartItem{item}, expiresAt)
+}
+
+// AddItems adds items to a cart in one transaction
+func (r *CartSQLiteRepository) AddItems(ctx context.Context, cartID int, items []models.CartItem, expiresAt time.Time) error {
+	touchCart := `UPDATE carts SET updated_at = ?, expires_at = ? WHERE cart_id = ?`
+	upsertItem := `
+		INSERT INTO cart_items (cart_id, product_id, quantity)
+		VALUES (?, ?, ?)
+		ON CONFLICT (cart_id, product_id) DO UPDATE SET
+			quantity = cart_items.quantity + excluded.quantity,
+			updated_at = CURRENT_TIMESTAMP
+	`
+
+	return addCartItemsSQL(ctx, r.db, touchCart, upsertItem, cartID, items, expiresAt, func(err error) bool {
+		var sqliteErr *sqlite.Error
+		return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+	})
+}
+
+// AddCoupon appends a coupon code to a cart unless it is already there
+func (r *CartSQLiteRepository) AddCoupon(ctx context.Context, cartID int, code string) error {
+	return r.updateCoupons(ctx, cartID, func(coupons []string) []string {
+		if slices.Contains(coupons, code) {
+			return coupons
+		}
+		return append(coupons, code)
+	})
+}
+
+// RemoveCoupon removes a coupon code from a cart
+func (r *CartSQLiteRepository) RemoveCoupon(ctx context.Context, cartID int, code string) error {
+	return r.updateCoupons(ctx, cartID, func(coupons []string) []string {
+		return slices.DeleteFunc(coupons, func(c string) bool { return c == code })
+	})
+}
+
+func (r *CartSQLiteRepository) updateCoupons(ctx context.Context, cartID int, change func(coupons []string) []string) error {
+	selectCoupons := `SELECT coupons FROM carts WHERE cart_id = ?`
+	updateCoupons := `UPDATE carts SET coupons = ? WHERE cart_id = ? AND coupons = ?`
+
+	return updateCouponsSQL(ctx, r.db, selectCoupons, updateCoupons, cartID, change)
+}
+
+// SetShippingAddress replaces a cart's shipping address
+func (r *CartSQLiteRepository) SetShippingAddress(ctx context.Context, cartID int, address *models.Address) error {
+	setAddress := `UPDATE carts SET shipping_address = ? WHERE cart_id = ?`
+	cartExists := `SELECT 1 FROM carts WHERE cart_id = ?`
+
+	return setShippingAddressSQL(ctx, r.db, setAddress, cartExists, cartID, address)
+}
+
+// SetShippingOption replaces the ID of a cart's shipping option
+func (r *CartSQLiteRepository) SetShippingOption(ctx context.Context, cartID int, optionID string) error {
+	setOption := `UPDATE carts SET shipping_option_id = ? WHERE cart_id = ?`
+	cartExists := `SELECT 1 FROM carts WHERE cart_id = ?`
+
+	return setCartColumnSQL(ctx, r.db, setOption, cartExists, cartID, optionID)
+}
+
+// Delete removes a cart (used after checkout)
+func (r *CartSQLiteRepository) Delete(ctx context.Context, cartID int) error {
+	query := `DELETE FROM carts WHERE cart_id = ?`
+
+	result, err := r.db.ExecContext(ctx, query, cartID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrCartNotFound
+	}
+
+	return nil
+}
+
+// DeleteExpired deletes up to limit expired carts, soonest expired first; their items cascade
+func (r *CartSQLiteRepository) DeleteExpired(ctx context.Context, now time.Time, limit int) (int, error) {
+	query := `
+		DELETE FROM carts
+		WHERE cart_id IN (
+			SELECT cart_id FROM carts
+			WHERE expires_at <= ?
+			ORDER BY expires_at
+			LIMIT ?
+		)
+	`
+
+	return deleteExpiredSQL(ctx, r.db, query, now, limit)
+}
+
+// ListAbandoned returns up to limit abandoned carts, least recently changed first
+func (r *CartSQLiteRepository) ListAbandoned(ctx context.Context, idleSince, now time.Time, limit int) ([]models.Cart, error) {
+	cartsQuery := `
+		SELECT cart_id, customer_id, created_at, updated_at, expires_at, reminded_at, guest_token_hash, coupons, shipping_address, shipping_option_id, currency
+		FROM carts
+		WHERE customer_id > 0
+			AND updated_at <= ?
+			AND reminded_at IS NULL
+			AND (expires_at IS NULL OR expires_at > ?)
+			AND EXISTS (SELECT 1 FROM cart_items WHERE cart_items.cart_id = carts.cart_id)
+		ORDER BY updated_at, cart_id
+		LIMIT ?
+	`
+	itemsQuery := `
+		SELECT product_id, quantity
+		FROM cart_items
+		WHERE cart_id = ?
+		ORDER BY created_at, product_id
+	`
+
+	return listAbandonedSQL(ctx, r.db, cartsQuery, itemsQuery, idleSince, now, limit)
+}
+
+// MarkReminded records a reminder about an abandoned cart unless one was already recorded
+func (r *CartSQLiteRepository) MarkReminded(ctx context.Context, cartID int, idleSince, remindedAt time.Time) (bool, error) {
+	query := `
+		UPDATE carts SET reminded_at = ?
+		WHERE cart_id = ? AND reminded_at IS NULL AND updated_at <= ?
+	`
+
+	return markRemindedSQL(ctx, r.db, query, cartID, idleSince, remindedAt)
+}
+
+// UnmarkReminded forgets a reminder that could not be sent
+func (r *CartSQLiteRepository) UnmarkReminded(ctx context.Context, cartID int) error {
+	query := `UPDATE carts SET reminded_at = NULL WHERE cart_id = ?`
+
+	_, err := r.db.ExecContext(ctx, query, cartID)
+	return err
+}
+
+// List returns carts in ID order
+func (r *CartSQLiteRepository) List(ctx context.Context, cursor string, limit int) ([]models.Cart, string, error) {
+	cartsQuery := `
+		SELECT cart_id, customer_id, created_at, updated_at, expires_at, reminded_at, guest_token_hash, coupons, shipping_address, shipping_option_id, currency, active_customer_id IS NOT NULL
+		FROM carts
+		WHERE cart_id > ?
+		ORDER BY cart_id
+		LIMIT ?
+	`
+	itemsQuery := `
+		SELECT cart_id, product_id, quantity
+		FROM cart_items
+		WHERE cart_id BETWEEN ? AND ?
+		ORDER BY cart_id, created_at, product_id
+	`
+
+	return listCartsSQL(ctx, r.db, cartsQuery, itemsQuery, cursor, limit)
+}
+
+// Put stores a cart as given, replacing any cart with its ID.
+// AUTOINCREMENT never issues an ID below one already inserted. An Active cart
+// takes over as its customer's active cart; otherwise a replaced active cart stays
+// active if its customer is unchanged.
+func (r *CartSQLiteRepository) Put(ctx context.Context, cart *models.Cart) error {
+	return putCartSQL(ctx, r.db, cartPutStatements{
+		releaseActive: `UPDATE carts SET active_customer_id = NULL WHERE active_customer_id = ? AND cart_id <> ?`,
+		upsertCart: `
+			INSERT INTO carts (cart_id, customer_id, created_at, updated_at, expires_at, reminded_at, guest_token_hash, coupons, shipping_address, shipping_option_id, currency, active_customer_id)
+			VALUES (?, ?, COALESCE(?, CURRENT_TIMESTAMP), ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (cart_id) DO UPDATE SET
+				active_customer_id = COALESCE(excluded.active_customer_id, CASE WHEN carts.customer_id = excluded.customer_id THEN carts.active_customer_id END),
+				customer_id = excluded.customer_id,
+				created_at = excluded.created_at,
+				updated_at = excluded.updated_at,
+				expires_at = excluded.expires_at,
+				reminded_at = excluded.reminded_at,
+				guest_token_hash = excluded.guest_token_hash,
+				coupons = excluded.coupons,
+				shipping_address = excluded.shipping_address,
+				shipping_option_id = excluded.shipping_option_id,
+				currency = excluded.currency
+		`,
+		deleteItems: `DELETE FROM cart_items WHERE cart_id = ?`,
+		insertItem: `
+			INSERT INTO cart_items (cart_id, product_id, quantity)
+			VALUES (?, ?, ?)
+		`,
+	}, cart)
+}
@@ -0,0 +1,9 @@
+package repository
+
+import "time"
+
+// cartTimestamp returns the time recorded as a cart's creation or last update.
+// It is kept to the second in UTC so every backend stores and returns it alike.
+func cartTimestamp() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
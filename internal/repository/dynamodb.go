@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// dynamoDBBatchGetLimit is the most keys DynamoDB accepts in one BatchGetItem call
+	dynamoDBBatchGetLimit = 100
+	// dynamoDBBatchGetMaxAttempts bounds how often dynamoDBBatchGet asks again for unprocessed keys
+	dynamoDBBatchGetMaxAttempts = 8
+	// dynamoDBBatchGetBackoff is the upper bound of the random delay before asking again, scaled by attempt
+	dynamoDBBatchGetBackoff = 50 * time.Millisecond
+)
+
+// errUnprocessedKeys is returned when DynamoDB keeps leaving keys of a batch unprocessed
+var errUnprocessedKeys = errors.New("dynamodb left keys unprocessed")
+
+// DynamoDBAPI is the subset of the DynamoDB client the repositories use.
+// *dynamodb.Client satisfies it, as does the in-process fake in package dynamodbfake.
+type DynamoDBAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+// DynamoDBTableAPI is the subset of the DynamoDB client EnsureDynamoDBTables uses
+type DynamoDBTableAPI interface {
+	dynamodb.DescribeTableAPIClient
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error)
+	DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error)
+	UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error)
+}
+
+// dynamoDBBatchGet reads the items that exist among keys of the table with BatchGetItem,
+// up to its limit of keys per call, asking again for any keys it did not process
+func dynamoDBBatchGet(ctx context.Context, client DynamoDBAPI, tableName string, keys []map[string]types.AttributeValue, consistentRead bool) ([]map[string]types.AttributeValue, error) {
+	items := []map[string]types.AttributeValue{}
+	for start := 0; start < len(keys); start += dynamoDBBatchGetLimit {
+		batch := keys[start:min(start+dynamoDBBatchGetLimit, len(keys))]
+		requests := map[string]types.KeysAndAttributes{tableName: {Keys: batch, ConsistentRead: aws.Bool(consistentRead)}}
+		for attempt := 0; len(requests) > 0; attempt++ {
+			if attempt == dynamoDBBatchGetMaxAttempts {
+				return nil, errUnprocessedKeys
+			}
+			if attempt > 0 {
+				// Unprocessed keys mean the table is throttling, so back off before asking again
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(time.Duration(attempt) * time.Duration(rand.Int64N(int64(dynamoDBBatchGetBackoff)))):
+				}
+			}
+
+			result, err := client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: requests})
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, result.Responses[tableName]...)
+			requests = result.UnprocessedKeys
+		}
+	}
+	return items, nil
+}
+
+// A List cursor for a DynamoDB table is the numeric hash key of the last item
+// evaluated by the scan, which is where the next page starts
+
+func dynamoDBStartKey(hashKey, cursor string) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	if _, err := parseIDCursor(cursor); err != nil {
+		return nil, err
+	}
+	return map[string]types.AttributeValue{
+		hashKey: &types.AttributeValueMemberN{Value: cursor},
+	}, nil
+}
+
+func dynamoDBCursor(hashKey string, lastEvaluatedKey map[string]types.AttributeValue) string {
+	if key, ok := lastEvaluatedKey[hashKey].(*types.AttributeValueMemberN); ok {
+		return key.Value
+	}
+	return ""
+}
+
+// dynamoDBStringStartKey is dynamoDBStartKey for a table with a string hash key,
+// whose cursors are the hash key itself
+func dynamoDBStringStartKey(hashKey, cursor string) map[string]types.AttributeValue {
+	if cursor == "" {
+		return nil
+	}
+	return map[string]types.AttributeValue{
+		hashKey: &types.AttributeValueMemberS{Value: cursor},
+	}
+}
+
+func dynamoDBStringCursor(hashKey string, lastEvaluatedKey map[string]types.AttributeValue) string {
+	if key, ok := lastEvaluatedKey[hashKey].(*types.AttributeValueMemberS); ok {
+		return key.Value
+	}
+	return ""
+}
@@ -0,0 +1,291 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// dynamoDBTableWaitTimeout bounds how long provisioning waits for a table or index to become active
+const dynamoDBTableWaitTimeout = 2 * time.Minute
+
+// DynamoDBTable describes the key schema a repository expects of its table
+type DynamoDBTable struct {
+	Name        string
+	HashKey     string
+	HashKeyType types.ScalarAttributeType
+	Indexes     []DynamoDBIndex
+	// TTLAttribute, if set, is the epoch-seconds attribute DynamoDB deletes items by
+	TTLAttribute string
+}
+
+// DynamoDBIndex describes a global secondary index a repository queries
+type DynamoDBIndex struct {
+	Name        string
+	HashKey     string
+	HashKeyType types.ScalarAttributeType
+}
+
+// CartsCustomerIndex is the GSI used to look up carts by customer
+const CartsCustomerIndex = "customer_id-index"
+
+// ProductsTable returns the schema ProductDynamoDBRepository expects
+func ProductsTable(name string) DynamoDBTable {
+	return DynamoDBTable{
+		Name:        name,
+		HashKey:     "product_id",
+		HashKeyType: types.ScalarAttributeTypeN,
+	}
+}
+
+// CartsTable returns the schema CartDynamoDBRepository expects
+func CartsTable(name string) DynamoDBTable {
+	return DynamoDBTable{
+		Name:        name,
+		HashKey:     "cart_id",
+		HashKeyType: types.ScalarAttributeTypeN,
+		Indexes: []DynamoDBIndex{
+			{Name: CartsCustomerIndex, HashKey: "customer_id", HashKeyType: types.ScalarAttributeTypeN},
+		},
+		TTLAttribute: "expires_at",
+	}
+}
+
+// PromotionsTable returns the schema PromotionDynamoDBRepository expects
+func PromotionsTable(name string) DynamoDBTable {
+	return DynamoDBTable{
+		Name:        name,
+		HashKey:     "code",
+		HashKeyType: types.ScalarAttributeTypeS,
+	}
+}
+
+// ErrDynamoDBSchemaMismatch is returned when an existing table's keys differ from what the repositories expect
+var ErrDynamoDBSchemaMismatch = errors.New("dynamodb table schema mismatch")
+
+// EnsureDynamoDBTables checks that every table exists with the expected key schema and indexes.
+// When create is true, missing tables and indexes are created (intended for DynamoDB Local);
+// otherwise they are reported as errors. Tables with a different key schema always fail.
+// Time to live is enabled when create is true and only warned about otherwise, since
+// expired items are still recognised when read.
+func EnsureDynamoDBTables(ctx context.Context, client DynamoDBTableAPI, create bool, tables ...DynamoDBTable) error {
+	for _, table := range tables {
+		if err := ensureDynamoDBTable(ctx, client, create, table); err != nil {
+			return fmt.Errorf("table %s: %w", table.Name, err)
+		}
+	}
+	return nil
+}
+
+func ensureDynamoDBTable(ctx context.Context, client DynamoDBTableAPI, create bool, table DynamoDBTable) error {
+	output, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(table.Name),
+	})
+
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		if !create {
+			return errors.New("table does not exist")
+		}
+		if err := createDynamoDBTable(ctx, client, table); err != nil {
+			return err
+		}
+		return ensureTimeToLive(ctx, client, create, table)
+	}
+	if err != nil {
+		return err
+	}
+
+	description := output.Table
+	if err := checkKeySchema(description.KeySchema, description.AttributeDefinitions, table.HashKey, table.HashKeyType); err != nil {
+		return err
+	}
+
+	for _, index := range table.Indexes {
+		existing := findIndex(description.GlobalSecondaryIndexes, index.Name)
+		if existing == nil {
+			if !create {
+				return fmt.Errorf("global secondary index %s does not exist", index.Name)
+			}
+			if err := createDynamoDBIndex(ctx, client, table.Name, index); err != nil {
+				return fmt.Errorf("create index %s: %w", index.Name, err)
+			}
+			continue
+		}
+		if err := checkKeySchema(existing.KeySchema, description.AttributeDefinitions, index.HashKey, index.HashKeyType); err != nil {
+			return fmt.Errorf("index %s: %w", index.Name, err)
+		}
+	}
+
+	return ensureTimeToLive(ctx, client, create, table)
+}
+
+// ensureTimeToLive checks that time to live is enabled on the table's TTL attribute
+func ensureTimeToLive(ctx context.Context, client DynamoDBTableAPI, create bool, table DynamoDBTable) error {
+	if table.TTLAttribute == "" {
+		return nil
+	}
+
+	output, err := client.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(table.Name)})
+	if err != nil {
+		return fmt.Errorf("describe time to live: %w", err)
+	}
+
+	description := output.TimeToLiveDescription
+	switch {
+	case description != nil && (description.TimeToLiveStatus == types.TimeToLiveStatusEnabled ||
+		description.TimeToLiveStatus == types.TimeToLiveStatusEnabling):
+		if attribute := aws.ToString(description.AttributeName); attribute != table.TTLAttribute {
+			return fmt.Errorf("%w: expected time to live on %s, found %s", ErrDynamoDBSchemaMismatch, table.TTLAttribute, attribute)
+		}
+		return nil
+	case !create:
+		slog.WarnContext(ctx, "DynamoDB time to live is not enabled; expired items will not be deleted",
+			"table", table.Name, "attribute", table.TTLAttribute)
+		return nil
+	}
+
+	_, err = client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(table.Name),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(table.TTLAttribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("enable time to live: %w", err)
+	}
+
+	slog.InfoContext(ctx, "enabled DynamoDB time to live", "table", table.Name, "attribute", table.TTLAttribute)
+	return nil
+}
+
+// checkKeySchema verifies a key schema is exactly one hash key of the given name and type
+func checkKeySchema(keys []types.KeySchemaElement, attributes []types.AttributeDefinition, hashKey string, hashKeyType types.ScalarAttributeType) error {
+	if len(keys) != 1 || aws.ToString(keys[0].AttributeName) != hashKey || keys[0].KeyType != types.KeyTypeHash {
+		return fmt.Errorf("%w: expected hash key %s only, found %s", ErrDynamoDBSchemaMismatch, hashKey, describeKeys(keys))
+	}
+
+	for _, attribute := range attributes {
+		if aws.ToString(attribute.AttributeName) == hashKey && attribute.AttributeType != hashKeyType {
+			return fmt.Errorf("%w: expected %s to have type %s, found %s",
+				ErrDynamoDBSchemaMismatch, hashKey, hashKeyType, attribute.AttributeType)
+		}
+	}
+
+	return nil
+}
+
+func describeKeys(keys []types.KeySchemaElement) string {
+	if len(keys) == 0 {
+		return "none"
+	}
+	described := make([]string, len(keys))
+	for i, key := range keys {
+		described[i] = fmt.Sprintf("%s (%s)", aws.ToString(key.AttributeName), key.KeyType)
+	}
+	return strings.Join(described, ", ")
+}
+
+func findIndex(indexes []types.GlobalSecondaryIndexDescription, name string) *types.GlobalSecondaryIndexDescription {
+	for i := range indexes {
+		if aws.ToString(indexes[i].IndexName) == name {
+			return &indexes[i]
+		}
+	}
+	return nil
+}
+
+func createDynamoDBTable(ctx context.Context, client DynamoDBTableAPI, table DynamoDBTable) error {
+	attributes := []types.AttributeDefinition{
+		{AttributeName: aws.String(table.HashKey), AttributeType: table.HashKeyType},
+	}
+	var indexes []types.GlobalSecondaryIndex
+	for _, index := range table.Indexes {
+		if index.HashKey != table.HashKey {
+			attributes = append(attributes, types.AttributeDefinition{
+				AttributeName: aws.String(index.HashKey),
+				AttributeType: index.HashKeyType,
+			})
+		}
+		indexes = append(indexes, globalSecondaryIndex(index))
+	}
+
+	_, err := client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:              aws.String(table.Name),
+		AttributeDefinitions:   attributes,
+		KeySchema:              hashKeySchema(table.HashKey),
+		GlobalSecondaryIndexes: indexes,
+		BillingMode:            types.BillingModePayPerRequest,
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "created DynamoDB table", "table", table.Name)
+
+	waiter := dynamodb.NewTableExistsWaiter(client)
+	return waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table.Name)}, dynamoDBTableWaitTimeout)
+}
+
+func createDynamoDBIndex(ctx context.Context, client DynamoDBTableAPI, tableName string, index DynamoDBIndex) error {
+	_, err := client.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+		TableName: aws.String(tableName),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String(index.HashKey), AttributeType: index.HashKeyType},
+		},
+		GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{
+			{Create: &types.CreateGlobalSecondaryIndexAction{
+				IndexName:  aws.String(index.Name),
+				KeySchema:  hashKeySchema(index.HashKey),
+				Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+			}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "created DynamoDB index", "table", tableName, "index", index.Name)
+
+	// Only one index can be created at a time, so wait for this one to finish backfilling
+	ctx, cancel := context.WithTimeout(ctx, dynamoDBTableWaitTimeout)
+	defer cancel()
+	for {
+		output, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+		if err != nil {
+			return err
+		}
+		if existing := findIndex(output.Table.GlobalSecondaryIndexes, index.Name); existing != nil &&
+			existing.IndexStatus == types.IndexStatusActive {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func globalSecondaryIndex(index DynamoDBIndex) types.GlobalSecondaryIndex {
+	return types.GlobalSecondaryIndex{
+		IndexName:  aws.String(index.Name),
+		KeySchema:  hashKeySchema(index.HashKey),
+		Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+	}
+}
+
+func hashKeySchema(attribute string) []types.KeySchemaElement {
+	return []types.KeySchemaElement{
+		{AttributeName: aws.String(attribute), KeyType: types.KeyTypeHash},
+	}
+}
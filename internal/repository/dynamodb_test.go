@@ -0,0 +1,134 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository/dynamodbfake"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository/repotest"
+)
+
+var dynamoDBTableCount atomic.Int64
+
+// dynamoDBTable creates a fresh table in the DynamoDB Local instance at
+// GOCART_TEST_DYNAMODB_ENDPOINT, e.g. "http://localhost:8000" with the docker
+// compose dynamo profile, and deletes it when the test ends.
+// The test is skipped when the variable is not set.
+func dynamoDBTable(t *testing.T, schema func(name string) repository.DynamoDBTable) (*dynamodb.Client, string) {
+	t.Helper()
+
+	endpoint := os.Getenv("GOCART_TEST_DYNAMODB_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("GOCART_TEST_DYNAMODB_ENDPOINT not set")
+	}
+
+	client := dynamodb.New(dynamodb.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(endpoint),
+		Credentials:  credentials.NewStaticCredentialsProvider("fakekey", "fakesecret", ""),
+	})
+
+	name := fmt.Sprintf("gocart-test-%d-%d", time.Now().UnixNano(), dynamoDBTableCount.Add(1))
+	if err := repository.EnsureDynamoDBTables(t.Context(), client, true, schema(name)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.DeleteTable(context.Background(), &dynamodb.DeleteTableInput{TableName: aws.String(name)})
+	})
+
+	return client, name
+}
+
+func TestProductDynamoDBRepository(t *testing.T) {
+	repotest.TestProductRepository(t, func(t *testing.T) repository.ProductRepository {
+		return repository.NewProductDynamoDBRepository(dynamoDBTable(t, repository.ProductsTable))
+	})
+}
+
+func TestCartDynamoDBRepository(t *testing.T) {
+	repotest.TestCartRepository(t, func(t *testing.T) repository.CartRepository {
+		return repository.NewCartDynamoDBRepository(dynamoDBTable(t, repository.CartsTable))
+	})
+}
+
+func TestPromotionDynamoDBRepository(t *testing.T) {
+	repotest.TestPromotionRepository(t, func(t *testing.T) repository.PromotionRepository {
+		return repository.NewPromotionDynamoDBRepository(dynamoDBTable(t, repository.PromotionsTable))
+	})
+}
+
+// fakeDynamoDBTable provisions a table in a fresh in-process fake
+func fakeDynamoDBTable(t *testing.T, schema func(name string) repository.DynamoDBTable) (*dynamodbfake.Client, string) {
+	t.Helper()
+
+	client := dynamodbfake.New()
+	if err := repository.EnsureDynamoDBTables(t.Context(), client, true, schema("gocart-test")); err != nil {
+		t.Fatal(err)
+	}
+
+	return client, "gocart-test"
+}
+
+func TestProductDynamoDBRepositoryFake(t *testing.T) {
+	repotest.TestProductRepository(t, func(t *testing.T) repository.ProductRepository {
+		return repository.NewProductDynamoDBRepository(fakeDynamoDBTable(t, repository.ProductsTable))
+	})
+}
+
+func TestCartDynamoDBRepositoryFake(t *testing.T) {
+	repotest.TestCartRepository(t, func(t *testing.T) repository.CartRepository {
+		return repository.NewCartDynamoDBRepository(fakeDynamoDBTable(t, repository.CartsTable))
+	})
+}
+
+func TestPromotionDynamoDBRepositoryFake(t *testing.T) {
+	repotest.TestPromotionRepository(t, func(t *testing.T) repository.PromotionRepository {
+		return repository.NewPromotionDynamoDBRepository(fakeDynamoDBTable(t, repository.PromotionsTable))
+	})
+}
+
+func TestEnsureDynamoDBTablesEnablesTimeToLive(t *testing.T) {
+	client, name := fakeDynamoDBTable(t, repository.CartsTable)
+
+	// Checking again finds time to live already enabled
+	if err := repository.EnsureDynamoDBTables(t.Context(), client, true, repository.CartsTable(name)); err != nil {
+		t.Fatalf("second EnsureDynamoDBTables: %v", err)
+	}
+
+	output, err := client.DescribeTimeToLive(t.Context(), &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(name)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl := output.TimeToLiveDescription; ttl.TimeToLiveStatus != types.TimeToLiveStatusEnabled || aws.ToString(ttl.AttributeName) != "expires_at" {
+		t.Fatalf("time to live = %s on %q, want ENABLED on expires_at", ttl.TimeToLiveStatus, aws.ToString(ttl.AttributeName))
+	}
+
+	// The TTL attribute must be a number of epoch seconds
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	cart, err := repository.NewCartDynamoDBRepository(client, name).Create(t.Context(), 1, "", "USD", expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := client.GetItem(t.Context(), &dynamodb.GetItemInput{
+		TableName: aws.String(name),
+		Key:       map[string]types.AttributeValue{"cart_id": &types.AttributeValueMemberN{Value: strconv.Itoa(cart.CartID)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, ok := item.Item["expires_at"].(*types.AttributeValueMemberN)
+	if !ok || stored.Value != strconv.FormatInt(expiresAt.Unix(), 10) {
+		t.Fatalf("stored expires_at = %#v, want the number %d", item.Item["expires_at"], expiresAt.Unix())
+	}
+}
@@ -0,0 +1,1233 @@
+package dynamodbfake
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// This file parses and evaluates the DynamoDB expression language:
+// condition, filter and key condition expressions, and update expressions
+// with SET (including +, -, if_not_exists and list_append), REMOVE, ADD and DELETE.
+
+// ---------------------------------------------------------------------------
+// Tokens
+
+type tokenKind int
+
+const (
+	tokEOF    tokenKind = iota
+	tokIdent            // bare word: keyword, function, attribute name or list index
+	tokName             // #placeholder
+	tokValue            // :placeholder
+	tokSymbol           // punctuation and comparators
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expr); {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '#' || c == ':':
+			j := i + 1
+			for j < len(expr) && isWordChar(rune(expr[j])) {
+				j++
+			}
+			if j == i+1 {
+				return nil, validationError("invalid expression %q: empty placeholder at %d", expr, i)
+			}
+			kind := tokName
+			if c == ':' {
+				kind = tokValue
+			}
+			tokens = append(tokens, token{kind: kind, text: expr[i:j]})
+			i = j
+		case isWordChar(c):
+			j := i
+			for j < len(expr) && isWordChar(rune(expr[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: expr[i:j]})
+			i = j
+		case strings.HasPrefix(expr[i:], "<>"), strings.HasPrefix(expr[i:], "<="), strings.HasPrefix(expr[i:], ">="):
+			tokens = append(tokens, token{kind: tokSymbol, text: expr[i : i+2]})
+			i += 2
+		case strings.ContainsRune("=<>(),.[]+-", c):
+			tokens = append(tokens, token{kind: tokSymbol, text: string(c)})
+			i++
+		default:
+			return nil, validationError("invalid expression %q: unexpected character %q", expr, c)
+		}
+	}
+	return append(tokens, token{kind: tokEOF}), nil
+}
+
+func isWordChar(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// ---------------------------------------------------------------------------
+// Parser
+
+// parser turns tokens into an AST, substituting #name placeholders as it goes
+// and recording which placeholders were used
+type parser struct {
+	expr   string
+	tokens []token
+	pos    int
+	env    *env
+}
+
+// env holds the placeholders of one request
+type env struct {
+	names      map[string]string
+	values     map[string]types.AttributeValue
+	usedNames  map[string]bool
+	usedValues map[string]bool
+}
+
+func newEnv(names map[string]string, values map[string]types.AttributeValue) *env {
+	return &env{
+		names:      names,
+		values:     values,
+		usedNames:  make(map[string]bool),
+		usedValues: make(map[string]bool),
+	}
+}
+
+// checkUnused reports placeholders that no expression of the request referenced, as DynamoDB does
+func (e *env) checkUnused() error {
+	for name := range e.names {
+		if !e.usedNames[name] {
+			return validationError("Value provided in ExpressionAttributeNames unused in expressions: keys: {%s}", name)
+		}
+	}
+	for name := range e.values {
+		if !e.usedValues[name] {
+			return validationError("Value provided in ExpressionAttributeValues unused in expressions: keys: {%s}", name)
+		}
+	}
+	return nil
+}
+
+func newParser(expr string, e *env) (*parser, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{expr: expr, tokens: tokens, env: e}, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// keyword consumes the next token if it is the given keyword (case-insensitive)
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	if t.kind == tokIdent && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// symbol consumes the next token if it is the given symbol
+func (p *parser) symbol(s string) bool {
+	t := p.peek()
+	if t.kind == tokSymbol && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.symbol(s) {
+		return p.errorf("expected %q", s)
+	}
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	t := p.peek()
+	near := t.text
+	if t.kind == tokEOF {
+		near = "end of expression"
+	}
+	return validationError("invalid expression %q: %s near %s", p.expr, fmt.Sprintf(format, args...), near)
+}
+
+func (p *parser) done() error {
+	if p.peek().kind != tokEOF {
+		return p.errorf("unexpected token")
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Paths and operands
+
+type pathSegment struct {
+	name  string
+	index int // used when name is ""
+}
+
+type path []pathSegment
+
+func (p path) String() string {
+	var b strings.Builder
+	for i, segment := range p {
+		switch {
+		case segment.name == "":
+			b.WriteString("[" + strconv.Itoa(segment.index) + "]")
+		case i > 0:
+			b.WriteString("." + segment.name)
+		default:
+			b.WriteString(segment.name)
+		}
+	}
+	return b.String()
+}
+
+// topLevel returns the attribute a path starts at
+func (p path) topLevel() string {
+	return p[0].name
+}
+
+func (p *parser) parsePath() (path, error) {
+	name, err := p.attributeName()
+	if err != nil {
+		return nil, err
+	}
+	result := path{{name: name}}
+
+	for {
+		switch {
+		case p.symbol("."):
+			name, err := p.attributeName()
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, pathSegment{name: name})
+		case p.symbol("["):
+			t := p.next()
+			index, err := strconv.Atoi(t.text)
+			if t.kind != tokIdent || err != nil || index < 0 {
+				return nil, p.errorf("invalid list index")
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			result = append(result, pathSegment{index: index})
+		default:
+			return result, nil
+		}
+	}
+}
+
+func (p *parser) attributeName() (string, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokName:
+		p.pos++
+		name, ok := p.env.names[t.text]
+		if !ok {
+			return "", validationError("An expression attribute name used in the document path is not defined; attribute name: %s", t.text)
+		}
+		p.env.usedNames[t.text] = true
+		return name, nil
+	case tokIdent:
+		p.pos++
+		if isReserved(t.text) {
+			return "", validationError("Attribute name is a reserved keyword; reserved keyword: %s", t.text)
+		}
+		return t.text, nil
+	default:
+		return "", p.errorf("expected attribute name")
+	}
+}
+
+func (p *parser) valueRef() (types.AttributeValue, error) {
+	t := p.next()
+	value, ok := p.env.values[t.text]
+	if !ok {
+		return nil, validationError("An expression attribute value used in expression is not defined; attribute value: %s", t.text)
+	}
+	p.env.usedValues[t.text] = true
+	return value, nil
+}
+
+// operand evaluates to a value, or to nothing when it names a missing attribute
+type operand interface {
+	eval(it item) (types.AttributeValue, bool, error)
+}
+
+type pathOperand struct{ path path }
+
+func (o pathOperand) eval(it item) (types.AttributeValue, bool, error) {
+	value, ok := get(it, o.path)
+	return value, ok, nil
+}
+
+type valueOperand struct{ value types.AttributeValue }
+
+func (o valueOperand) eval(item) (types.AttributeValue, bool, error) {
+	return o.value, true, nil
+}
+
+// sizeOperand is size(path)
+type sizeOperand struct{ path path }
+
+func (o sizeOperand) eval(it item) (types.AttributeValue, bool, error) {
+	value, ok := get(it, o.path)
+	if !ok {
+		return nil, false, nil
+	}
+	var n int
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		n = len(v.Value)
+	case *types.AttributeValueMemberB:
+		n = len(v.Value)
+	case *types.AttributeValueMemberSS:
+		n = len(v.Value)
+	case *types.AttributeValueMemberNS:
+		n = len(v.Value)
+	case *types.AttributeValueMemberBS:
+		n = len(v.Value)
+	case *types.AttributeValueMemberL:
+		n = len(v.Value)
+	case *types.AttributeValueMemberM:
+		n = len(v.Value)
+	default:
+		return nil, false, nil
+	}
+	return &types.AttributeValueMemberN{Value: strconv.Itoa(n)}, true, nil
+}
+
+// conditionOperand parses a path, a :value or size(path)
+func (p *parser) conditionOperand() (operand, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokValue:
+		value, err := p.valueRef()
+		return valueOperand{value}, err
+	case t.kind == tokIdent && strings.EqualFold(t.text, "size") && p.tokens[p.pos+1].text == "(":
+		p.pos += 2
+		target, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		return sizeOperand{target}, p.expect(")")
+	default:
+		target, err := p.parsePath()
+		return pathOperand{target}, err
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Conditions
+
+type condition interface {
+	eval(it item) (bool, error)
+}
+
+type andCondition struct{ left, right condition }
+
+func (c andCondition) eval(it item) (bool, error) {
+	ok, err := c.left.eval(it)
+	if err != nil || !ok {
+		return false, err
+	}
+	return c.right.eval(it)
+}
+
+type orCondition struct{ left, right condition }
+
+func (c orCondition) eval(it item) (bool, error) {
+	ok, err := c.left.eval(it)
+	if err != nil || ok {
+		return ok, err
+	}
+	return c.right.eval(it)
+}
+
+type notCondition struct{ inner condition }
+
+func (c notCondition) eval(it item) (bool, error) {
+	ok, err := c.inner.eval(it)
+	return !ok, err
+}
+
+type comparison struct {
+	op          string
+	left, right operand
+}
+
+func (c comparison) eval(it item) (bool, error) {
+	left, okLeft, err := c.left.eval(it)
+	if err != nil {
+		return false, err
+	}
+	right, okRight, err := c.right.eval(it)
+	if err != nil {
+		return false, err
+	}
+	if !okLeft || !okRight {
+		// A missing attribute only satisfies <>
+		return c.op == "<>" && okLeft != okRight, nil
+	}
+
+	switch c.op {
+	case "=":
+		return equal(left, right), nil
+	case "<>":
+		return !equal(left, right), nil
+	}
+
+	order, ok := compare(left, right)
+	if !ok {
+		return false, nil
+	}
+	switch c.op {
+	case "<":
+		return order < 0, nil
+	case "<=":
+		return order <= 0, nil
+	case ">":
+		return order > 0, nil
+	default: // ">="
+		return order >= 0, nil
+	}
+}
+
+type betweenCondition struct{ value, low, high operand }
+
+func (c betweenCondition) eval(it item) (bool, error) {
+	low, err := comparison{op: ">=", left: c.value, right: c.low}.eval(it)
+	if err != nil || !low {
+		return false, err
+	}
+	return comparison{op: "<=", left: c.value, right: c.high}.eval(it)
+}
+
+type inCondition struct {
+	value   operand
+	choices []operand
+}
+
+func (c inCondition) eval(it item) (bool, error) {
+	for _, choice := range c.choices {
+		ok, err := comparison{op: "=", left: c.value, right: choice}.eval(it)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+type functionCondition struct {
+	name     string
+	path     path
+	argument operand // nil for attribute_exists and attribute_not_exists
+}
+
+func (c functionCondition) eval(it item) (bool, error) {
+	value, exists := get(it, c.path)
+	switch c.name {
+	case "attribute_exists":
+		return exists, nil
+	case "attribute_not_exists":
+		return !exists, nil
+	}
+
+	argument, ok, err := c.argument.eval(it)
+	if err != nil || !exists || !ok {
+		return false, err
+	}
+
+	switch c.name {
+	case "attribute_type":
+		want, isS := argument.(*types.AttributeValueMemberS)
+		return isS && typeName(value) == want.Value, nil
+	case "begins_with":
+		switch v := value.(type) {
+		case *types.AttributeValueMemberS:
+			prefix, isS := argument.(*types.AttributeValueMemberS)
+			return isS && strings.HasPrefix(v.Value, prefix.Value), nil
+		case *types.AttributeValueMemberB:
+			prefix, isB := argument.(*types.AttributeValueMemberB)
+			return isB && strings.HasPrefix(string(v.Value), string(prefix.Value)), nil
+		}
+		return false, nil
+	default: // contains
+		switch v := value.(type) {
+		case *types.AttributeValueMemberS:
+			sub, isS := argument.(*types.AttributeValueMemberS)
+			return isS && strings.Contains(v.Value, sub.Value), nil
+		case *types.AttributeValueMemberSS:
+			element, isS := argument.(*types.AttributeValueMemberS)
+			return isS && slices.Contains(v.Value, element.Value), nil
+		case *types.AttributeValueMemberNS:
+			element, isN := argument.(*types.AttributeValueMemberN)
+			if !isN {
+				return false, nil
+			}
+			for _, n := range v.Value {
+				if numbersEqual(n, element.Value) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case *types.AttributeValueMemberL:
+			for _, element := range v.Value {
+				if equal(element, argument) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return false, nil
+	}
+}
+
+// parseCondition parses a complete condition, filter or key condition expression
+func parseCondition(expr string, e *env) (condition, error) {
+	p, err := newParser(expr, e)
+	if err != nil {
+		return nil, err
+	}
+	c, err := p.orCondition()
+	if err != nil {
+		return nil, err
+	}
+	return c, p.done()
+}
+
+func (p *parser) orCondition() (condition, error) {
+	left, err := p.andCondition()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.andCondition()
+		if err != nil {
+			return nil, err
+		}
+		left = orCondition{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) andCondition() (condition, error) {
+	left, err := p.notCondition()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.notCondition()
+		if err != nil {
+			return nil, err
+		}
+		left = andCondition{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) notCondition() (condition, error) {
+	if p.keyword("NOT") {
+		inner, err := p.notCondition()
+		if err != nil {
+			return nil, err
+		}
+		return notCondition{inner}, nil
+	}
+	return p.primaryCondition()
+}
+
+var conditionFunctions = map[string]bool{
+	"attribute_exists":     true,
+	"attribute_not_exists": true,
+	"attribute_type":       true,
+	"begins_with":          true,
+	"contains":             true,
+}
+
+func (p *parser) primaryCondition() (condition, error) {
+	if p.symbol("(") {
+		c, err := p.orCondition()
+		if err != nil {
+			return nil, err
+		}
+		return c, p.expect(")")
+	}
+
+	t := p.peek()
+	if name := strings.ToLower(t.text); t.kind == tokIdent && conditionFunctions[name] && p.tokens[p.pos+1].text == "(" {
+		p.pos += 2
+		target, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		c := functionCondition{name: name, path: target}
+		if name != "attribute_exists" && name != "attribute_not_exists" {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+			if c.argument, err = p.conditionOperand(); err != nil {
+				return nil, err
+			}
+		}
+		return c, p.expect(")")
+	}
+
+	left, err := p.conditionOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.keyword("BETWEEN"):
+		low, err := p.conditionOperand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword("AND") {
+			return nil, p.errorf("expected AND in BETWEEN")
+		}
+		high, err := p.conditionOperand()
+		if err != nil {
+			return nil, err
+		}
+		return betweenCondition{value: left, low: low, high: high}, nil
+
+	case p.keyword("IN"):
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		c := inCondition{value: left}
+		for {
+			choice, err := p.conditionOperand()
+			if err != nil {
+				return nil, err
+			}
+			c.choices = append(c.choices, choice)
+			if !p.symbol(",") {
+				break
+			}
+		}
+		return c, p.expect(")")
+	}
+
+	t = p.peek()
+	switch t.text {
+	case "=", "<>", "<", "<=", ">", ">=":
+		if t.kind != tokSymbol {
+			break
+		}
+		p.pos++
+		right, err := p.conditionOperand()
+		if err != nil {
+			return nil, err
+		}
+		return comparison{op: t.text, left: left, right: right}, nil
+	}
+	return nil, p.errorf("expected comparison")
+}
+
+// ---------------------------------------------------------------------------
+// Updates
+
+type updateAction interface {
+	apply(it item) error
+	target() path
+}
+
+type setAction struct {
+	path  path
+	value operand
+}
+
+func (a setAction) target() path { return a.path }
+
+func (a setAction) apply(it item) error {
+	value, ok, err := a.value.eval(it)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return validationError("The provided expression refers to an attribute that does not exist in the item")
+	}
+	return set(it, a.path, copyValue(value))
+}
+
+type removeAction struct{ path path }
+
+func (a removeAction) target() path { return a.path }
+
+func (a removeAction) apply(it item) error {
+	remove(it, a.path)
+	return nil
+}
+
+// addAction is ADD: numeric increment or set union, creating the attribute if missing
+type addAction struct {
+	path  path
+	value types.AttributeValue
+}
+
+func (a addAction) target() path { return a.path }
+
+func (a addAction) apply(it item) error {
+	current, exists := get(it, a.path)
+	if !exists {
+		switch a.value.(type) {
+		case *types.AttributeValueMemberN, *types.AttributeValueMemberSS, *types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+			return set(it, a.path, copyValue(a.value))
+		}
+		return validationError("ADD action only supports numbers and sets, got %s", typeName(a.value))
+	}
+
+	switch add := a.value.(type) {
+	case *types.AttributeValueMemberN:
+		result, err := arithmetic("+", current, add)
+		if err != nil {
+			return err
+		}
+		return set(it, a.path, result)
+	case *types.AttributeValueMemberSS:
+		existing, ok := current.(*types.AttributeValueMemberSS)
+		if !ok {
+			return typeMismatch("ADD", current, a.value)
+		}
+		union := slicesUnion(existing.Value, add.Value, func(a, b string) bool { return a == b })
+		return set(it, a.path, &types.AttributeValueMemberSS{Value: union})
+	case *types.AttributeValueMemberNS:
+		existing, ok := current.(*types.AttributeValueMemberNS)
+		if !ok {
+			return typeMismatch("ADD", current, a.value)
+		}
+		return set(it, a.path, &types.AttributeValueMemberNS{Value: slicesUnion(existing.Value, add.Value, numbersEqual)})
+	}
+	return validationError("ADD action only supports numbers and sets, got %s", typeName(a.value))
+}
+
+// deleteAction is DELETE: removes elements from a set, removing the attribute when it empties
+type deleteAction struct {
+	path  path
+	value types.AttributeValue
+}
+
+func (a deleteAction) target() path { return a.path }
+
+func (a deleteAction) apply(it item) error {
+	current, exists := get(it, a.path)
+	if !exists {
+		return nil
+	}
+
+	var remaining int
+	switch del := a.value.(type) {
+	case *types.AttributeValueMemberSS:
+		existing, ok := current.(*types.AttributeValueMemberSS)
+		if !ok {
+			return typeMismatch("DELETE", current, a.value)
+		}
+		existing.Value = slicesDifference(existing.Value, del.Value, func(a, b string) bool { return a == b })
+		remaining = len(existing.Value)
+	case *types.AttributeValueMemberNS:
+		existing, ok := current.(*types.AttributeValueMemberNS)
+		if !ok {
+			return typeMismatch("DELETE", current, a.value)
+		}
+		existing.Value = slicesDifference(existing.Value, del.Value, numbersEqual)
+		remaining = len(existing.Value)
+	default:
+		return validationError("DELETE action only supports sets, got %s", typeName(a.value))
+	}
+
+	if remaining == 0 {
+		remove(it, a.path)
+	}
+	return nil
+}
+
+func slicesUnion[T any](a, b []T, eq func(T, T) bool) []T {
+	out := append([]T(nil), a...)
+	for _, x := range b {
+		found := false
+		for _, y := range out {
+			if eq(x, y) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+func slicesDifference[T any](a, b []T, eq func(T, T) bool) []T {
+	var out []T
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if eq(x, y) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// ifNotExists is if_not_exists(path, operand)
+type ifNotExists struct {
+	path     path
+	fallback operand
+}
+
+func (o ifNotExists) eval(it item) (types.AttributeValue, bool, error) {
+	if value, ok := get(it, o.path); ok {
+		return value, true, nil
+	}
+	return o.fallback.eval(it)
+}
+
+// listAppend is list_append(a, b)
+type listAppend struct{ first, second operand }
+
+func (o listAppend) eval(it item) (types.AttributeValue, bool, error) {
+	first, okFirst, err := o.first.eval(it)
+	if err != nil {
+		return nil, false, err
+	}
+	second, okSecond, err := o.second.eval(it)
+	if err != nil {
+		return nil, false, err
+	}
+	if !okFirst || !okSecond {
+		return nil, false, nil
+	}
+
+	a, isListA := first.(*types.AttributeValueMemberL)
+	b, isListB := second.(*types.AttributeValueMemberL)
+	if !isListA || !isListB {
+		return nil, false, typeMismatch("list_append", first, second)
+	}
+	combined := make([]types.AttributeValue, 0, len(a.Value)+len(b.Value))
+	combined = append(combined, a.Value...)
+	combined = append(combined, b.Value...)
+	return &types.AttributeValueMemberL{Value: combined}, true, nil
+}
+
+// arithmeticOperand is a + b or a - b
+type arithmeticOperand struct {
+	op          string
+	left, right operand
+}
+
+func (o arithmeticOperand) eval(it item) (types.AttributeValue, bool, error) {
+	left, okLeft, err := o.left.eval(it)
+	if err != nil {
+		return nil, false, err
+	}
+	right, okRight, err := o.right.eval(it)
+	if err != nil {
+		return nil, false, err
+	}
+	if !okLeft || !okRight {
+		return nil, false, nil
+	}
+	result, err := arithmetic(o.op, left, right)
+	return result, err == nil, err
+}
+
+func arithmetic(op string, left, right types.AttributeValue) (types.AttributeValue, error) {
+	a, isNA := left.(*types.AttributeValueMemberN)
+	b, isNB := right.(*types.AttributeValueMemberN)
+	if !isNA || !isNB {
+		return nil, typeMismatch("arithmetic", left, right)
+	}
+	x, err := parseNumber(a.Value)
+	if err != nil {
+		return nil, err
+	}
+	y, err := parseNumber(b.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(big.Rat)
+	if op == "+" {
+		result.Add(x, y)
+	} else {
+		result.Sub(x, y)
+	}
+	return &types.AttributeValueMemberN{Value: formatNumber(result)}, nil
+}
+
+func typeMismatch(operation string, a, b types.AttributeValue) error {
+	return validationError("An operand in the update expression has an incorrect data type: %s of %s and %s",
+		operation, typeName(a), typeName(b))
+}
+
+// parseUpdate parses a complete update expression
+func parseUpdate(expr string, e *env) ([]updateAction, error) {
+	p, err := newParser(expr, e)
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []updateAction
+	seen := make(map[string]bool)
+	for p.peek().kind != tokEOF {
+		clause := strings.ToUpper(p.next().text)
+		switch clause {
+		case "SET", "REMOVE", "ADD", "DELETE":
+		default:
+			return nil, validationError("invalid update expression %q: expected SET, REMOVE, ADD or DELETE, got %q", expr, clause)
+		}
+		if seen[clause] {
+			return nil, p.errorf("the %s section can only be used once", clause)
+		}
+		seen[clause] = true
+
+		for {
+			action, err := p.updateAction(clause)
+			if err != nil {
+				return nil, err
+			}
+			actions = append(actions, action)
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+	if len(actions) == 0 {
+		return nil, validationError("invalid update expression %q: no actions", expr)
+	}
+
+	// Two actions may not touch the same path or one inside the other
+	for i := range actions {
+		for j := i + 1; j < len(actions); j++ {
+			if overlaps(actions[i].target(), actions[j].target()) {
+				return nil, validationError("Two document paths overlap with each other; path one: %s, path two: %s",
+					actions[i].target(), actions[j].target())
+			}
+		}
+	}
+
+	return actions, nil
+}
+
+func (p *parser) updateAction(clause string) (updateAction, error) {
+	target, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+
+	switch clause {
+	case "SET":
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+		value, err := p.setValue()
+		if err != nil {
+			return nil, err
+		}
+		return setAction{path: target, value: value}, nil
+	case "REMOVE":
+		return removeAction{path: target}, nil
+	case "ADD":
+		if p.peek().kind != tokValue {
+			return nil, p.errorf("expected value placeholder")
+		}
+		value, err := p.valueRef()
+		if err != nil {
+			return nil, err
+		}
+		return addAction{path: target, value: value}, nil
+	default: // DELETE
+		if p.peek().kind != tokValue {
+			return nil, p.errorf("expected value placeholder")
+		}
+		value, err := p.valueRef()
+		if err != nil {
+			return nil, err
+		}
+		return deleteAction{path: target, value: value}, nil
+	}
+}
+
+func (p *parser) setValue() (operand, error) {
+	left, err := p.setOperand()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"+", "-"} {
+		if p.symbol(op) {
+			right, err := p.setOperand()
+			if err != nil {
+				return nil, err
+			}
+			return arithmeticOperand{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) setOperand() (operand, error) {
+	t := p.peek()
+	if t.kind == tokIdent && p.tokens[p.pos+1].text == "(" {
+		switch strings.ToLower(t.text) {
+		case "if_not_exists":
+			p.pos += 2
+			target, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+			fallback, err := p.setOperand()
+			if err != nil {
+				return nil, err
+			}
+			return ifNotExists{path: target, fallback: fallback}, p.expect(")")
+		case "list_append":
+			p.pos += 2
+			first, err := p.setOperand()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+			second, err := p.setOperand()
+			if err != nil {
+				return nil, err
+			}
+			return listAppend{first: first, second: second}, p.expect(")")
+		}
+	}
+	if t.kind == tokValue {
+		value, err := p.valueRef()
+		return valueOperand{value}, err
+	}
+	target, err := p.parsePath()
+	return pathOperand{target}, err
+}
+
+func overlaps(a, b path) bool {
+	n := min(len(a), len(b))
+	for i := range n {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ---------------------------------------------------------------------------
+// Document paths
+
+// parseProjection parses a comma-separated list of paths
+func parseProjection(expr string, e *env) ([]path, error) {
+	p, err := newParser(expr, e)
+	if err != nil {
+		return nil, err
+	}
+	var paths []path
+	for {
+		projected, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range paths {
+			if overlaps(projected, other) {
+				return nil, p.errorf("two document paths overlap: %s and %s", other, projected)
+			}
+		}
+		paths = append(paths, projected)
+		if !p.symbol(",") {
+			return paths, p.done()
+		}
+	}
+}
+
+// project copies the attributes at the given paths into a new item.
+// Selected list elements are packed into a list in the order they are named.
+func project(it item, paths []path) item {
+	if it == nil {
+		return nil
+	}
+	out := make(item)
+	for _, p := range paths {
+		value, ok := get(it, p)
+		if !ok {
+			continue
+		}
+		projectValue(out, p, copyValue(value))
+	}
+	return out
+}
+
+func projectValue(out item, p path, value types.AttributeValue) {
+	if len(p) == 1 {
+		out[p[0].name] = value
+		return
+	}
+	// Build the container the next segment needs, then descend into it
+	var container types.AttributeValue
+	if p[1].name != "" {
+		container = &types.AttributeValueMemberM{Value: make(item)}
+	} else {
+		container = &types.AttributeValueMemberL{}
+	}
+	if existing, ok := out[p[0].name]; ok && typeName(existing) == typeName(container) {
+		container = existing
+	}
+	out[p[0].name] = container
+
+	switch c := container.(type) {
+	case *types.AttributeValueMemberM:
+		projectValue(c.Value, p[1:], value)
+	case *types.AttributeValueMemberL:
+		if len(p) == 2 {
+			c.Value = append(c.Value, value)
+			return
+		}
+		element := make(item)
+		projectValue(element, append(path{{name: "_"}}, p[2:]...), value)
+		c.Value = append(c.Value, element["_"])
+	}
+}
+
+// get returns the value at a path, if present
+func get(it item, p path) (types.AttributeValue, bool) {
+	value, ok := it[p[0].name]
+	if !ok {
+		return nil, false
+	}
+	for _, segment := range p[1:] {
+		value, ok = child(value, segment)
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func child(value types.AttributeValue, segment pathSegment) (types.AttributeValue, bool) {
+	if segment.name != "" {
+		m, ok := value.(*types.AttributeValueMemberM)
+		if !ok {
+			return nil, false
+		}
+		v, ok := m.Value[segment.name]
+		return v, ok
+	}
+	l, ok := value.(*types.AttributeValueMemberL)
+	if !ok || segment.index >= len(l.Value) {
+		return nil, false
+	}
+	return l.Value[segment.index], true
+}
+
+// set stores a value at a path; every parent must already exist.
+// Setting a list index past the end appends, as DynamoDB does.
+func set(it item, p path, value types.AttributeValue) error {
+	if len(p) == 1 {
+		it[p[0].name] = value
+		return nil
+	}
+
+	parent, ok := get(it, p[:len(p)-1])
+	if !ok {
+		return invalidPath(p)
+	}
+	last := p[len(p)-1]
+	if last.name != "" {
+		m, ok := parent.(*types.AttributeValueMemberM)
+		if !ok {
+			return invalidPath(p)
+		}
+		m.Value[last.name] = value
+		return nil
+	}
+	l, ok := parent.(*types.AttributeValueMemberL)
+	if !ok {
+		return invalidPath(p)
+	}
+	if last.index >= len(l.Value) {
+		l.Value = append(l.Value, value)
+	} else {
+		l.Value[last.index] = value
+	}
+	return nil
+}
+
+// remove deletes the value at a path if present; list elements after it shift down
+func remove(it item, p path) {
+	if len(p) == 1 {
+		delete(it, p[0].name)
+		return
+	}
+
+	parent, ok := get(it, p[:len(p)-1])
+	if !ok {
+		return
+	}
+	last := p[len(p)-1]
+	switch v := parent.(type) {
+	case *types.AttributeValueMemberM:
+		if last.name != "" {
+			delete(v.Value, last.name)
+		}
+	case *types.AttributeValueMemberL:
+		if last.name == "" && last.index < len(v.Value) {
+			v.Value = append(v.Value[:last.index], v.Value[last.index+1:]...)
+		}
+	}
+}
+
+func invalidPath(p path) error {
+	return validationError("The document path provided in the update expression is invalid for update: %s", p)
+}
+
+// isReserved reports whether a bare word must be written with a #placeholder.
+// Only the words this parser treats specially are checked; DynamoDB reserves many more.
+func isReserved(word string) bool {
+	switch strings.ToUpper(word) {
+	case "AND", "OR", "NOT", "BETWEEN", "IN", "SET", "REMOVE", "ADD", "DELETE", "SIZE":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,953 @@
+// Package dynamodbfake is an in-process stand-in for DynamoDB, for unit tests.
+//
+// Client implements the operations the repositories use (repository.DynamoDBAPI)
+// and the table management calls used by repository.EnsureDynamoDBTables.
+// It supports hash-key tables with global secondary indexes, condition, filter,
+// key condition, projection and update expressions, ReturnValues, paged Query
+// and Scan, batch gets, and transactions. Every call is applied atomically under one lock,
+// so conditional writes behave as they do against DynamoDB. Time to live can be
+// enabled and described, but expired items are never deleted, just as DynamoDB
+// may keep them for a while. Sort keys, parallel scans and capacity accounting
+// are not implemented.
+package dynamodbfake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	// maxTransactItems is the DynamoDB limit on actions in one transaction
+	maxTransactItems = 100
+	// maxBatchGetKeys is the DynamoDB limit on keys in one BatchGetItem
+	maxBatchGetKeys = 100
+)
+
+// Client is an in-memory DynamoDB. The zero value is not usable; call New.
+type Client struct {
+	mu     sync.Mutex
+	tables map[string]*table
+}
+
+// New returns a Client with no tables
+func New() *Client {
+	return &Client{tables: make(map[string]*table)}
+}
+
+type table struct {
+	name        string
+	hashKey     string
+	attributes  map[string]types.ScalarAttributeType
+	indexes     map[string]string // index name → hash key
+	items       map[string]item   // keyString(hash key) → item
+	createdAt   time.Time
+	keySchema   []types.KeySchemaElement
+	indexSchema map[string][]types.KeySchemaElement
+	ttlEnabled  bool
+	ttlName     string
+}
+
+func validationError(format string, args ...any) error {
+	return &smithy.GenericAPIError{
+		Code:    "ValidationException",
+		Message: fmt.Sprintf(format, args...),
+		Fault:   smithy.FaultClient,
+	}
+}
+
+func (c *Client) table(name *string) (*table, error) {
+	t, ok := c.tables[aws.ToString(name)]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("Requested resource not found")}
+	}
+	return t, nil
+}
+
+// ---------------------------------------------------------------------------
+// Table management
+
+// CreateTable creates a table with a single hash key and optional global secondary indexes
+func (c *Client) CreateTable(_ context.Context, input *dynamodb.CreateTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name := aws.ToString(input.TableName)
+	if _, exists := c.tables[name]; exists {
+		return nil, &types.ResourceInUseException{Message: aws.String("Table already exists: " + name)}
+	}
+
+	t := &table{
+		name:        name,
+		attributes:  make(map[string]types.ScalarAttributeType),
+		indexes:     make(map[string]string),
+		items:       make(map[string]item),
+		createdAt:   time.Now(),
+		keySchema:   input.KeySchema,
+		indexSchema: make(map[string][]types.KeySchemaElement),
+	}
+	for _, attribute := range input.AttributeDefinitions {
+		t.attributes[aws.ToString(attribute.AttributeName)] = attribute.AttributeType
+	}
+
+	hashKey, err := hashKeyOf(input.KeySchema)
+	if err != nil {
+		return nil, err
+	}
+	t.hashKey = hashKey
+
+	for _, index := range input.GlobalSecondaryIndexes {
+		if err := t.addIndex(aws.ToString(index.IndexName), index.KeySchema); err != nil {
+			return nil, err
+		}
+	}
+
+	c.tables[name] = t
+	return &dynamodb.CreateTableOutput{TableDescription: t.describe()}, nil
+}
+
+// UpdateTable adds global secondary indexes; other changes are accepted and ignored
+func (c *Client) UpdateTable(_ context.Context, input *dynamodb.UpdateTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, attribute := range input.AttributeDefinitions {
+		t.attributes[aws.ToString(attribute.AttributeName)] = attribute.AttributeType
+	}
+	for _, update := range input.GlobalSecondaryIndexUpdates {
+		if update.Create != nil {
+			if err := t.addIndex(aws.ToString(update.Create.IndexName), update.Create.KeySchema); err != nil {
+				return nil, err
+			}
+		}
+		if update.Delete != nil {
+			name := aws.ToString(update.Delete.IndexName)
+			delete(t.indexes, name)
+			delete(t.indexSchema, name)
+		}
+	}
+
+	return &dynamodb.UpdateTableOutput{TableDescription: t.describe()}, nil
+}
+
+// DescribeTable reports a table and its indexes, always ACTIVE
+func (c *Client) DescribeTable(_ context.Context, input *dynamodb.DescribeTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.DescribeTableOutput{Table: t.describe()}, nil
+}
+
+// DescribeTimeToLive reports whether time to live is enabled, and on which attribute
+func (c *Client) DescribeTimeToLive(_ context.Context, input *dynamodb.DescribeTimeToLiveInput, _ ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	description := &types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusDisabled}
+	if t.ttlEnabled {
+		description = &types.TimeToLiveDescription{
+			TimeToLiveStatus: types.TimeToLiveStatusEnabled,
+			AttributeName:    aws.String(t.ttlName),
+		}
+	}
+	return &dynamodb.DescribeTimeToLiveOutput{TimeToLiveDescription: description}, nil
+}
+
+// UpdateTimeToLive enables or disables time to live, taking effect immediately
+func (c *Client) UpdateTimeToLive(_ context.Context, input *dynamodb.UpdateTimeToLiveInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := input.TimeToLiveSpecification
+	if spec == nil || aws.ToString(spec.AttributeName) == "" {
+		return nil, validationError("TimeToLiveSpecification with an AttributeName is required")
+	}
+	enabled := aws.ToBool(spec.Enabled)
+	if enabled == t.ttlEnabled {
+		state := "disabled"
+		if enabled {
+			state = "enabled"
+		}
+		return nil, validationError("TimeToLive is already %s", state)
+	}
+
+	t.ttlEnabled = enabled
+	t.ttlName = aws.ToString(spec.AttributeName)
+	return &dynamodb.UpdateTimeToLiveOutput{TimeToLiveSpecification: spec}, nil
+}
+
+// DeleteTable drops a table and its items
+func (c *Client) DeleteTable(_ context.Context, input *dynamodb.DeleteTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteTableOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	delete(c.tables, t.name)
+	return &dynamodb.DeleteTableOutput{TableDescription: t.describe()}, nil
+}
+
+func hashKeyOf(schema []types.KeySchemaElement) (string, error) {
+	if len(schema) != 1 || schema[0].KeyType != types.KeyTypeHash {
+		return "", validationError("dynamodbfake supports hash keys only")
+	}
+	return aws.ToString(schema[0].AttributeName), nil
+}
+
+func (t *table) addIndex(name string, schema []types.KeySchemaElement) error {
+	hashKey, err := hashKeyOf(schema)
+	if err != nil {
+		return err
+	}
+	if _, ok := t.attributes[hashKey]; !ok {
+		return validationError("index %s: attribute %s is not defined in AttributeDefinitions", name, hashKey)
+	}
+	t.indexes[name] = hashKey
+	t.indexSchema[name] = schema
+	return nil
+}
+
+func (t *table) describe() *types.TableDescription {
+	description := &types.TableDescription{
+		TableName:        aws.String(t.name),
+		TableStatus:      types.TableStatusActive,
+		KeySchema:        t.keySchema,
+		CreationDateTime: aws.Time(t.createdAt),
+		ItemCount:        aws.Int64(int64(len(t.items))),
+	}
+
+	names := make([]string, 0, len(t.attributes))
+	for name := range t.attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		description.AttributeDefinitions = append(description.AttributeDefinitions, types.AttributeDefinition{
+			AttributeName: aws.String(name),
+			AttributeType: t.attributes[name],
+		})
+	}
+
+	indexes := make([]string, 0, len(t.indexes))
+	for name := range t.indexes {
+		indexes = append(indexes, name)
+	}
+	sort.Strings(indexes)
+	for _, name := range indexes {
+		description.GlobalSecondaryIndexes = append(description.GlobalSecondaryIndexes, types.GlobalSecondaryIndexDescription{
+			IndexName:   aws.String(name),
+			KeySchema:   t.indexSchema[name],
+			IndexStatus: types.IndexStatusActive,
+			Projection:  &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		})
+	}
+
+	return description
+}
+
+// ---------------------------------------------------------------------------
+// Keys
+
+// keyOf validates that key holds exactly the table's hash key and returns its map key
+func (t *table) keyOf(key item) (string, error) {
+	if len(key) != 1 {
+		return "", validationError("The provided key element does not match the schema")
+	}
+	return t.itemKey(key)
+}
+
+// itemKey validates the key attributes of a full item and returns its map key
+func (t *table) itemKey(it item) (string, error) {
+	value, ok := it[t.hashKey]
+	if !ok {
+		return "", validationError("One or more parameter values were invalid: Missing the key %s in the item", t.hashKey)
+	}
+	if typeName(value) != string(t.attributes[t.hashKey]) {
+		return "", validationError("One of the required keys was not given a value of type %s", t.attributes[t.hashKey])
+	}
+	for _, hashKey := range t.indexes {
+		if indexValue, ok := it[hashKey]; ok && typeName(indexValue) != string(t.attributes[hashKey]) {
+			return "", validationError("One or more parameter values were invalid: Type mismatch for Index Key %s Expected: %s Actual: %s",
+				hashKey, t.attributes[hashKey], typeName(indexValue))
+		}
+	}
+	return keyString(value)
+}
+
+func (t *table) keyAttributes(it item) item {
+	return item{t.hashKey: copyValue(it[t.hashKey])}
+}
+
+// ---------------------------------------------------------------------------
+// Conditions shared by the write operations
+
+// checkCondition parses and evaluates an optional condition against the current item
+// (empty when the item does not exist)
+func checkCondition(expr *string, e *env, current item) (bool, error) {
+	if expr == nil {
+		return true, nil
+	}
+	c, err := parseCondition(*expr, e)
+	if err != nil {
+		return false, err
+	}
+	if current == nil {
+		current = item{}
+	}
+	return c.eval(current)
+}
+
+func conditionFailed(current item, returnOld types.ReturnValuesOnConditionCheckFailure) error {
+	err := &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	if returnOld == types.ReturnValuesOnConditionCheckFailureAllOld && current != nil {
+		err.Item = copyItem(current)
+	}
+	return err
+}
+
+// ---------------------------------------------------------------------------
+// Item operations
+
+// GetItem returns a copy of the item with the given key
+func (c *Client) GetItem(_ context.Context, input *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.keyOf(input.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	e := newEnv(input.ExpressionAttributeNames, nil)
+	var projection []path
+	if input.ProjectionExpression != nil {
+		if projection, err = parseProjection(*input.ProjectionExpression, e); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.GetItemOutput{Item: selectAttributes(t.items[key], projection)}, nil
+}
+
+// BatchGetItem returns copies of the items with the given keys, leaving out missing
+// ones. Every key is always processed, so UnprocessedKeys is never set.
+func (c *Client) BatchGetItem(_ context.Context, input *dynamodb.BatchGetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	total := 0
+	for _, request := range input.RequestItems {
+		total += len(request.Keys)
+	}
+	if total == 0 || total > maxBatchGetKeys {
+		return nil, validationError("Too many items requested for the BatchGetItem call")
+	}
+
+	responses := make(map[string][]map[string]types.AttributeValue, len(input.RequestItems))
+	for tableName, request := range input.RequestItems {
+		t, err := c.table(aws.String(tableName))
+		if err != nil {
+			return nil, err
+		}
+
+		e := newEnv(request.ExpressionAttributeNames, nil)
+		var projection []path
+		if request.ProjectionExpression != nil {
+			if projection, err = parseProjection(*request.ProjectionExpression, e); err != nil {
+				return nil, err
+			}
+		}
+		if err := e.checkUnused(); err != nil {
+			return nil, err
+		}
+
+		seen := make(map[string]bool, len(request.Keys))
+		found := []map[string]types.AttributeValue{}
+		for _, keyItem := range request.Keys {
+			key, err := t.keyOf(keyItem)
+			if err != nil {
+				return nil, err
+			}
+			if seen[key] {
+				return nil, validationError("Provided list of item keys contains duplicates")
+			}
+			seen[key] = true
+			if current, ok := t.items[key]; ok {
+				found = append(found, selectAttributes(current, projection))
+			}
+		}
+		responses[tableName] = found
+	}
+
+	return &dynamodb.BatchGetItemOutput{Responses: responses}, nil
+}
+
+// PutItem stores an item, replacing any item with the same key
+func (c *Client) PutItem(_ context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	p, err := t.preparePut(input.Item, input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues, input.ReturnValuesOnConditionCheckFailure)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &dynamodb.PutItemOutput{}
+	switch input.ReturnValues {
+	case "", types.ReturnValueNone:
+	case types.ReturnValueAllOld:
+		output.Attributes = copyItem(p.old)
+	default:
+		return nil, validationError("ReturnValues %s is not valid for PutItem", input.ReturnValues)
+	}
+
+	p.commit()
+	return output, nil
+}
+
+// UpdateItem edits an item with an update expression, creating it if missing
+func (c *Client) UpdateItem(_ context.Context, input *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	p, err := t.prepareUpdate(input.Key, input.UpdateExpression, input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues, input.ReturnValuesOnConditionCheckFailure)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &dynamodb.UpdateItemOutput{}
+	switch input.ReturnValues {
+	case "", types.ReturnValueNone:
+	case types.ReturnValueAllOld:
+		output.Attributes = copyItem(p.old)
+	case types.ReturnValueAllNew:
+		output.Attributes = copyItem(p.new)
+	case types.ReturnValueUpdatedOld:
+		output.Attributes = updatedAttributes(p.old, p.updated)
+	case types.ReturnValueUpdatedNew:
+		output.Attributes = updatedAttributes(p.new, p.updated)
+	default:
+		return nil, validationError("ReturnValues %s is not valid for UpdateItem", input.ReturnValues)
+	}
+
+	p.commit()
+	return output, nil
+}
+
+// DeleteItem removes an item if it exists
+func (c *Client) DeleteItem(_ context.Context, input *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	p, err := t.prepareDelete(input.Key, input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues, input.ReturnValuesOnConditionCheckFailure)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &dynamodb.DeleteItemOutput{}
+	switch input.ReturnValues {
+	case "", types.ReturnValueNone:
+	case types.ReturnValueAllOld:
+		output.Attributes = copyItem(p.old)
+	default:
+		return nil, validationError("ReturnValues %s is not valid for DeleteItem", input.ReturnValues)
+	}
+
+	p.commit()
+	return output, nil
+}
+
+// write is a validated change to one item, applied by commit
+type write struct {
+	table   *table
+	key     string
+	old     item // nil if the item did not exist
+	new     item // nil to delete
+	updated []string
+}
+
+func (w *write) commit() {
+	if w.new == nil {
+		delete(w.table.items, w.key)
+		return
+	}
+	w.table.items[w.key] = w.new
+}
+
+func (t *table) preparePut(newItem item, condition *string, names map[string]string, values map[string]types.AttributeValue,
+	returnOld types.ReturnValuesOnConditionCheckFailure) (*write, error) {
+	key, err := t.itemKey(newItem)
+	if err != nil {
+		return nil, err
+	}
+	current := t.items[key]
+
+	e := newEnv(names, values)
+	ok, err := checkCondition(condition, e, current)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.checkUnused(); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, conditionFailed(current, returnOld)
+	}
+
+	return &write{table: t, key: key, old: current, new: copyItem(newItem)}, nil
+}
+
+func (t *table) prepareUpdate(keyItem item, update, condition *string, names map[string]string, values map[string]types.AttributeValue,
+	returnOld types.ReturnValuesOnConditionCheckFailure) (*write, error) {
+	key, err := t.keyOf(keyItem)
+	if err != nil {
+		return nil, err
+	}
+	current := t.items[key]
+
+	e := newEnv(names, values)
+	ok, err := checkCondition(condition, e, current)
+	if err != nil {
+		return nil, err
+	}
+	var actions []updateAction
+	if update != nil {
+		if actions, err = parseUpdate(*update, e); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.checkUnused(); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, conditionFailed(current, returnOld)
+	}
+
+	next := copyItem(current)
+	if next == nil {
+		next = t.keyAttributes(keyItem)
+	}
+	var updated []string
+	for _, action := range actions {
+		if action.target().topLevel() == t.hashKey {
+			return nil, validationError("Cannot update attribute %s. This attribute is part of the key", t.hashKey)
+		}
+		if err := action.apply(next); err != nil {
+			return nil, err
+		}
+		updated = append(updated, action.target().topLevel())
+	}
+	if _, err := t.itemKey(next); err != nil {
+		return nil, err
+	}
+
+	return &write{table: t, key: key, old: current, new: next, updated: updated}, nil
+}
+
+func (t *table) prepareDelete(keyItem item, condition *string, names map[string]string, values map[string]types.AttributeValue,
+	returnOld types.ReturnValuesOnConditionCheckFailure) (*write, error) {
+	key, err := t.keyOf(keyItem)
+	if err != nil {
+		return nil, err
+	}
+	current := t.items[key]
+
+	e := newEnv(names, values)
+	ok, err := checkCondition(condition, e, current)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.checkUnused(); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, conditionFailed(current, returnOld)
+	}
+
+	return &write{table: t, key: key, old: current}, nil
+}
+
+// selectAttributes copies an item, keeping only the projected paths if there are any
+func selectAttributes(it item, projection []path) item {
+	if projection == nil {
+		return copyItem(it)
+	}
+	return project(it, projection)
+}
+
+// updatedAttributes returns copies of the named top-level attributes that are present
+func updatedAttributes(it item, names []string) item {
+	out := make(item)
+	for _, name := range names {
+		if value, ok := it[name]; ok {
+			out[name] = copyValue(value)
+		}
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// Query and Scan
+
+// Query returns the items whose hash key, in the table or a global secondary
+// index, equals the value in the key condition. Results are ordered by table key.
+func (c *Client) Query(_ context.Context, input *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if input.KeyConditionExpression == nil {
+		return nil, validationError("Either the KeyConditions or KeyConditionExpression parameter must be specified in the request")
+	}
+
+	hashKey := t.hashKey
+	if input.IndexName != nil {
+		var ok bool
+		if hashKey, ok = t.indexes[aws.ToString(input.IndexName)]; !ok {
+			return nil, validationError("The table does not have the specified index: %s", aws.ToString(input.IndexName))
+		}
+	}
+
+	e := newEnv(input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	keyCondition, err := parseCondition(*input.KeyConditionExpression, e)
+	if err != nil {
+		return nil, err
+	}
+	keyCompare, ok := keyCondition.(comparison)
+	keyPath, isPath := keyCompare.left.(pathOperand)
+	keyValue, isValue := keyCompare.right.(valueOperand)
+	if !ok || keyCompare.op != "=" || !isPath || !isValue || len(keyPath.path) != 1 || keyPath.path.topLevel() != hashKey {
+		return nil, validationError("dynamodbfake supports key conditions of the form %s = :value only", hashKey)
+	}
+	var filter condition
+	if input.FilterExpression != nil {
+		if filter, err = parseCondition(*input.FilterExpression, e); err != nil {
+			return nil, err
+		}
+	}
+	var projection []path
+	if input.ProjectionExpression != nil {
+		if projection, err = parseProjection(*input.ProjectionExpression, e); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(t.items))
+	for key, it := range t.items {
+		if value, ok := it[hashKey]; ok && equal(value, keyValue.value) {
+			keys = append(keys, key)
+		}
+	}
+
+	page, err := t.read(keys, input.ExclusiveStartKey, input.Limit, input.IndexName, filter, projection, input.Select == types.SelectCount)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.QueryOutput{
+		Items:            page.items,
+		Count:            page.count,
+		ScannedCount:     page.scanned,
+		LastEvaluatedKey: page.lastKey,
+	}, nil
+}
+
+// Scan returns every item in the table, or in a global secondary index, ordered by table key
+func (c *Client) Scan(_ context.Context, input *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if input.Segment != nil || input.TotalSegments != nil {
+		return nil, validationError("dynamodbfake does not support parallel scans")
+	}
+
+	hashKey := t.hashKey
+	if input.IndexName != nil {
+		var ok bool
+		if hashKey, ok = t.indexes[aws.ToString(input.IndexName)]; !ok {
+			return nil, validationError("The table does not have the specified index: %s", aws.ToString(input.IndexName))
+		}
+	}
+
+	e := newEnv(input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	var filter condition
+	if input.FilterExpression != nil {
+		if filter, err = parseCondition(*input.FilterExpression, e); err != nil {
+			return nil, err
+		}
+	}
+	var projection []path
+	if input.ProjectionExpression != nil {
+		if projection, err = parseProjection(*input.ProjectionExpression, e); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(t.items))
+	for key, it := range t.items {
+		// Items without the index key are not in the index
+		if _, ok := it[hashKey]; ok {
+			keys = append(keys, key)
+		}
+	}
+
+	page, err := t.read(keys, input.ExclusiveStartKey, input.Limit, input.IndexName, filter, projection, input.Select == types.SelectCount)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.ScanOutput{
+		Items:            page.items,
+		Count:            page.count,
+		ScannedCount:     page.scanned,
+		LastEvaluatedKey: page.lastKey,
+	}, nil
+}
+
+// readPage is one page of Query or Scan results
+type readPage struct {
+	items   []map[string]types.AttributeValue
+	count   int32
+	scanned int32
+	lastKey map[string]types.AttributeValue
+}
+
+// read pages through the items with the given map keys in key order, starting after
+// exclusiveStart. Limit counts items read, before the filter is applied.
+func (t *table) read(keys []string, exclusiveStart map[string]types.AttributeValue, limit *int32, indexName *string,
+	filter condition, projection []path, countOnly bool) (*readPage, error) {
+	sort.Strings(keys)
+
+	if exclusiveStart != nil {
+		start, err := keyString(exclusiveStart[t.hashKey])
+		if err != nil {
+			return nil, err
+		}
+		keys = keys[sort.SearchStrings(keys, start):]
+		if len(keys) > 0 && keys[0] == start {
+			keys = keys[1:]
+		}
+	}
+
+	page := &readPage{}
+	for i, key := range keys {
+		if n := int(aws.ToInt32(limit)); n > 0 && i == n {
+			last := t.items[keys[i-1]]
+			page.lastKey = t.keyAttributes(last)
+			if indexName != nil {
+				indexKey := t.indexes[aws.ToString(indexName)]
+				page.lastKey[indexKey] = copyValue(last[indexKey])
+			}
+			break
+		}
+		page.scanned++
+
+		it := t.items[key]
+		if filter != nil {
+			ok, err := filter.eval(it)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		page.count++
+		if !countOnly {
+			page.items = append(page.items, selectAttributes(it, projection))
+		}
+	}
+
+	return page, nil
+}
+
+// ---------------------------------------------------------------------------
+// Transactions
+
+// TransactWriteItems applies up to 100 writes atomically. If any condition fails,
+// nothing is written and a TransactionCanceledException lists the reason per action.
+func (c *Client) TransactWriteItems(_ context.Context, input *dynamodb.TransactWriteItemsInput, _ ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(input.TransactItems) == 0 || len(input.TransactItems) > maxTransactItems {
+		return nil, validationError("TransactItems must contain between 1 and %d actions", maxTransactItems)
+	}
+
+	writes := make([]*write, len(input.TransactItems))
+	reasons := make([]types.CancellationReason, len(input.TransactItems))
+	touched := make(map[string]bool)
+	failed := false
+
+	for i, action := range input.TransactItems {
+		w, tableName, err := c.prepareTransactAction(action)
+		reasons[i] = types.CancellationReason{Code: aws.String("None")}
+
+		var conditionErr *types.ConditionalCheckFailedException
+		switch {
+		case asConditionFailed(err, &conditionErr):
+			failed = true
+			reasons[i] = types.CancellationReason{
+				Code:    aws.String("ConditionalCheckFailed"),
+				Message: conditionErr.Message,
+				Item:    conditionErr.Item,
+			}
+			continue
+		case err != nil:
+			return nil, err
+		}
+
+		id := tableName + "/" + w.key
+		if touched[id] {
+			return nil, validationError("Transaction request cannot include multiple operations on one item")
+		}
+		touched[id] = true
+		writes[i] = w
+	}
+
+	if failed {
+		return nil, &types.TransactionCanceledException{
+			Message:             aws.String("Transaction cancelled, please refer cancellation reasons for specific reasons"),
+			CancellationReasons: reasons,
+		}
+	}
+
+	for _, w := range writes {
+		if w != nil {
+			w.commit()
+		}
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func (c *Client) prepareTransactAction(action types.TransactWriteItem) (*write, string, error) {
+	switch {
+	case action.Put != nil:
+		put := action.Put
+		t, err := c.table(put.TableName)
+		if err != nil {
+			return nil, "", err
+		}
+		w, err := t.preparePut(put.Item, put.ConditionExpression, put.ExpressionAttributeNames, put.ExpressionAttributeValues,
+			put.ReturnValuesOnConditionCheckFailure)
+		return w, t.name, err
+
+	case action.Update != nil:
+		update := action.Update
+		t, err := c.table(update.TableName)
+		if err != nil {
+			return nil, "", err
+		}
+		w, err := t.prepareUpdate(update.Key, update.UpdateExpression, update.ConditionExpression, update.ExpressionAttributeNames,
+			update.ExpressionAttributeValues, update.ReturnValuesOnConditionCheckFailure)
+		return w, t.name, err
+
+	case action.Delete != nil:
+		del := action.Delete
+		t, err := c.table(del.TableName)
+		if err != nil {
+			return nil, "", err
+		}
+		w, err := t.prepareDelete(del.Key, del.ConditionExpression, del.ExpressionAttributeNames, del.ExpressionAttributeValues,
+			del.ReturnValuesOnConditionCheckFailure)
+		return w, t.name, err
+
+	case action.ConditionCheck != nil:
+		check := action.ConditionCheck
+		t, err := c.table(check.TableName)
+		if err != nil {
+			return nil, "", err
+		}
+		key, err := t.keyOf(check.Key)
+		if err != nil {
+			return nil, "", err
+		}
+		current := t.items[key]
+
+		e := newEnv(check.ExpressionAttributeNames, check.ExpressionAttributeValues)
+		ok, err := checkCondition(check.ConditionExpression, e, current)
+		if err == nil {
+			err = e.checkUnused()
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if !ok {
+			return nil, "", conditionFailed(current, check.ReturnValuesOnConditionCheckFailure)
+		}
+		// Leaving the item as it is makes the check a no-op write
+		return &write{table: t, key: key, old: current, new: current}, t.name, nil
+
+	default:
+		return nil, "", validationError("TransactWriteItem must contain exactly one action")
+	}
+}
+
+func asConditionFailed(err error, target **types.ConditionalCheckFailedException) bool {
+	if err == nil {
+		return false
+	}
+	failed, ok := err.(*types.ConditionalCheckFailedException)
+	if ok {
+		*target = failed
+	}
+	return ok
+}
@@ -0,0 +1,387 @@
+package dynamodbfake_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/repository/dynamodbfake"
+)
+
+const tableName = "carts"
+
+func newClient(t *testing.T) *dynamodbfake.Client {
+	t.Helper()
+
+	client := dynamodbfake.New()
+	_, err := client.CreateTable(t.Context(), &dynamodb.CreateTableInput{
+		TableName: aws.String(tableName),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("cart_id"), AttributeType: types.ScalarAttributeTypeN},
+			{AttributeName: aws.String("customer_id"), AttributeType: types.ScalarAttributeTypeN},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("cart_id"), KeyType: types.KeyTypeHash},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{{
+			IndexName: aws.String("customer_id-index"),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("customer_id"), KeyType: types.KeyTypeHash},
+			},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func n(v string) *types.AttributeValueMemberN { return &types.AttributeValueMemberN{Value: v} }
+func s(v string) *types.AttributeValueMemberS { return &types.AttributeValueMemberS{Value: v} }
+
+func key(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{"cart_id": n(id)}
+}
+
+func put(t *testing.T, client *dynamodbfake.Client, it map[string]types.AttributeValue) {
+	t.Helper()
+	if _, err := client.PutItem(t.Context(), &dynamodb.PutItemInput{TableName: aws.String(tableName), Item: it}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func get(t *testing.T, client *dynamodbfake.Client, id string) map[string]types.AttributeValue {
+	t.Helper()
+	output, err := client.GetItem(t.Context(), &dynamodb.GetItemInput{TableName: aws.String(tableName), Key: key(id)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return output.Item
+}
+
+func update(t *testing.T, client *dynamodbfake.Client, id, expr string, values map[string]types.AttributeValue) error {
+	t.Helper()
+	_, err := client.UpdateItem(t.Context(), &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(tableName),
+		Key:                       key(id),
+		UpdateExpression:          aws.String(expr),
+		ExpressionAttributeValues: values,
+	})
+	return err
+}
+
+func isConditionFailed(err error) bool {
+	var conditionFailed *types.ConditionalCheckFailedException
+	return errors.As(err, &conditionFailed)
+}
+
+func isValidationError(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "ValidationException"
+}
+
+func TestPutItemCondition(t *testing.T) {
+	client := newClient(t)
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(tableName),
+		Item:                map[string]types.AttributeValue{"cart_id": n("1"), "customer_id": n("7")},
+		ConditionExpression: aws.String("attribute_not_exists(cart_id)"),
+	}
+
+	if _, err := client.PutItem(t.Context(), input); err != nil {
+		t.Fatalf("first put: %v", err)
+	}
+	if _, err := client.PutItem(t.Context(), input); !isConditionFailed(err) {
+		t.Fatalf("second put: got %v, want ConditionalCheckFailedException", err)
+	}
+}
+
+func TestUpdateItemListAppend(t *testing.T) {
+	client := newClient(t)
+
+	expr := "SET items = list_append(if_not_exists(items, :empty), :item)"
+	first := map[string]types.AttributeValue{
+		":empty": &types.AttributeValueMemberL{},
+		":item":  &types.AttributeValueMemberL{Value: []types.AttributeValue{s("a")}},
+	}
+	second := map[string]types.AttributeValue{
+		":empty": &types.AttributeValueMemberL{},
+		":item":  &types.AttributeValueMemberL{Value: []types.AttributeValue{s("b")}},
+	}
+	if err := update(t, client, "1", expr, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := update(t, client, "1", expr, second); err != nil {
+		t.Fatal(err)
+	}
+
+	items, ok := get(t, client, "1")["items"].(*types.AttributeValueMemberL)
+	if !ok || len(items.Value) != 2 ||
+		items.Value[0].(*types.AttributeValueMemberS).Value != "a" ||
+		items.Value[1].(*types.AttributeValueMemberS).Value != "b" {
+		t.Fatalf("items = %#v, want [a b]", items)
+	}
+}
+
+func TestUpdateItemSetRemoveAdd(t *testing.T) {
+	client := newClient(t)
+	put(t, client, map[string]types.AttributeValue{
+		"cart_id": n("1"),
+		"count":   n("1.5"),
+		"note":    s("x"),
+		"items": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+			&types.AttributeValueMemberM{Value: map[string]types.AttributeValue{"quantity": n("1")}},
+		}},
+	})
+
+	err := update(t, client, "1", "SET items[0].quantity = items[0].quantity + :one REMOVE note ADD #count :one",
+		map[string]types.AttributeValue{":one": n("1")})
+	if !isValidationError(err) {
+		t.Fatalf("undefined #count: got %v, want ValidationException", err)
+	}
+
+	_, err = client.UpdateItem(t.Context(), &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(tableName),
+		Key:                       key("1"),
+		UpdateExpression:          aws.String("SET items[0].quantity = items[0].quantity + :one REMOVE note ADD #count :one"),
+		ExpressionAttributeNames:  map[string]string{"#count": "count"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":one": n("1")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it := get(t, client, "1")
+	if _, ok := it["note"]; ok {
+		t.Error("note was not removed")
+	}
+	if got := it["count"].(*types.AttributeValueMemberN).Value; got != "2.5" {
+		t.Errorf("count = %s, want 2.5", got)
+	}
+	element := it["items"].(*types.AttributeValueMemberL).Value[0].(*types.AttributeValueMemberM)
+	if got := element.Value["quantity"].(*types.AttributeValueMemberN).Value; got != "2" {
+		t.Errorf("quantity = %s, want 2", got)
+	}
+}
+
+func TestUpdateItemRejectsKeyAndUnusedValues(t *testing.T) {
+	client := newClient(t)
+	put(t, client, map[string]types.AttributeValue{"cart_id": n("1")})
+
+	if err := update(t, client, "1", "SET cart_id = :v", map[string]types.AttributeValue{":v": n("2")}); !isValidationError(err) {
+		t.Errorf("updating the key: got %v, want ValidationException", err)
+	}
+	if err := update(t, client, "1", "SET a = :v", map[string]types.AttributeValue{":v": n("2"), ":unused": n("3")}); !isValidationError(err) {
+		t.Errorf("unused value: got %v, want ValidationException", err)
+	}
+}
+
+func TestUpdateItemConditionAndReturnValues(t *testing.T) {
+	client := newClient(t)
+	put(t, client, map[string]types.AttributeValue{"cart_id": n("1"), "version": n("1")})
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:           aws.String(tableName),
+		Key:                 key("1"),
+		UpdateExpression:    aws.String("SET version = version + :one"),
+		ConditionExpression: aws.String("version = :expected"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one":      n("1"),
+			":expected": n("1"),
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	}
+
+	output, err := client.UpdateItem(t.Context(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := output.Attributes["version"].(*types.AttributeValueMemberN).Value; got != "2" || len(output.Attributes) != 1 {
+		t.Errorf("UPDATED_NEW = %v, want version 2 only", output.Attributes)
+	}
+
+	if _, err := client.UpdateItem(t.Context(), input); !isConditionFailed(err) {
+		t.Errorf("stale version: got %v, want ConditionalCheckFailedException", err)
+	}
+}
+
+func TestDeleteItemReturnsOldValues(t *testing.T) {
+	client := newClient(t)
+	put(t, client, map[string]types.AttributeValue{"cart_id": n("1")})
+
+	for _, want := range []int{1, 0} {
+		output, err := client.DeleteItem(t.Context(), &dynamodb.DeleteItemInput{
+			TableName:    aws.String(tableName),
+			Key:          key("1"),
+			ReturnValues: types.ReturnValueAllOld,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(output.Attributes) != want {
+			t.Errorf("ALL_OLD = %v, want %d attributes", output.Attributes, want)
+		}
+	}
+}
+
+func TestBatchGetItemSkipsMissingKeys(t *testing.T) {
+	client := newClient(t)
+	put(t, client, map[string]types.AttributeValue{"cart_id": n("1"), "customer_id": n("7")})
+	put(t, client, map[string]types.AttributeValue{"cart_id": n("2"), "customer_id": n("8")})
+
+	output, err := client.BatchGetItem(t.Context(), &dynamodb.BatchGetItemInput{
+		RequestItems: map[string]types.KeysAndAttributes{
+			tableName: {
+				Keys:                 []map[string]types.AttributeValue{key("1"), key("2"), key("3")},
+				ProjectionExpression: aws.String("cart_id"),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := output.Responses[tableName]
+	if len(found) != 2 || len(output.UnprocessedKeys) != 0 {
+		t.Fatalf("BatchGetItem = %v, unprocessed %v; want the 2 existing items", found, output.UnprocessedKeys)
+	}
+	for _, it := range found {
+		if len(it) != 1 {
+			t.Errorf("projected item = %v, want only cart_id", it)
+		}
+	}
+
+	_, err = client.BatchGetItem(t.Context(), &dynamodb.BatchGetItemInput{
+		RequestItems: map[string]types.KeysAndAttributes{
+			tableName: {Keys: []map[string]types.AttributeValue{key("1"), key("1")}},
+		},
+	})
+	if !isValidationError(err) {
+		t.Errorf("duplicate keys: got %v, want ValidationException", err)
+	}
+}
+
+func TestQueryIndexWithPaging(t *testing.T) {
+	client := newClient(t)
+	for _, id := range []string{"1", "2", "3"} {
+		put(t, client, map[string]types.AttributeValue{"cart_id": n(id), "customer_id": n("7")})
+	}
+	put(t, client, map[string]types.AttributeValue{"cart_id": n("4"), "customer_id": n("8")})
+
+	var found []string
+	var startKey map[string]types.AttributeValue
+	for {
+		output, err := client.Query(t.Context(), &dynamodb.QueryInput{
+			TableName:                 aws.String(tableName),
+			IndexName:                 aws.String("customer_id-index"),
+			KeyConditionExpression:    aws.String("customer_id = :customer"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":customer": n("7")},
+			Limit:                     aws.Int32(2),
+			ExclusiveStartKey:         startKey,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, it := range output.Items {
+			found = append(found, it["cart_id"].(*types.AttributeValueMemberN).Value)
+		}
+		if output.LastEvaluatedKey == nil {
+			break
+		}
+		startKey = output.LastEvaluatedKey
+	}
+
+	if len(found) != 3 || found[0] != "1" || found[1] != "2" || found[2] != "3" {
+		t.Errorf("found %v, want [1 2 3]", found)
+	}
+}
+
+func TestScanWithFilterAndPaging(t *testing.T) {
+	client := newClient(t)
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		put(t, client, map[string]types.AttributeValue{"cart_id": n(id), "customer_id": n("7")})
+	}
+	put(t, client, map[string]types.AttributeValue{"cart_id": n("6"), "customer_id": n("8")})
+
+	var found []string
+	var startKey map[string]types.AttributeValue
+	pages := 0
+	for {
+		output, err := client.Scan(t.Context(), &dynamodb.ScanInput{
+			TableName:                 aws.String(tableName),
+			FilterExpression:          aws.String("customer_id = :customer"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":customer": n("7")},
+			Limit:                     aws.Int32(2),
+			ExclusiveStartKey:         startKey,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, it := range output.Items {
+			found = append(found, it["cart_id"].(*types.AttributeValueMemberN).Value)
+		}
+		if output.LastEvaluatedKey == nil {
+			break
+		}
+		startKey = output.LastEvaluatedKey
+	}
+
+	if len(found) != 5 {
+		t.Errorf("found %v, want the 5 carts of customer 7", found)
+	}
+	// Limit counts items read before the filter, so the 6 items take 3 pages
+	if pages != 3 {
+		t.Errorf("read %d pages, want 3", pages)
+	}
+}
+
+func TestTransactWriteItemsIsAtomic(t *testing.T) {
+	client := newClient(t)
+	put(t, client, map[string]types.AttributeValue{"cart_id": n("1")})
+
+	_, err := client.TransactWriteItems(t.Context(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Put: &types.Put{
+				TableName: aws.String(tableName),
+				Item:      map[string]types.AttributeValue{"cart_id": n("2")},
+			}},
+			{Put: &types.Put{
+				TableName:           aws.String(tableName),
+				Item:                map[string]types.AttributeValue{"cart_id": n("1"), "replaced": s("yes")},
+				ConditionExpression: aws.String("attribute_not_exists(cart_id)"),
+			}},
+		},
+	})
+
+	var canceled *types.TransactionCanceledException
+	if !errors.As(err, &canceled) {
+		t.Fatalf("got %v, want TransactionCanceledException", err)
+	}
+	if len(canceled.CancellationReasons) != 2 ||
+		aws.ToString(canceled.CancellationReasons[0].Code) != "None" ||
+		aws.ToString(canceled.CancellationReasons[1].Code) != "ConditionalCheckFailed" {
+		t.Errorf("reasons = %+v", canceled.CancellationReasons)
+	}
+	if it := get(t, client, "2"); it != nil {
+		t.Errorf("cart 2 was written by a canceled transaction: %v", it)
+	}
+	if _, ok := get(t, client, "1")["replaced"]; ok {
+		t.Error("cart 1 was replaced by a canceled transaction")
+	}
+}
+
+func TestReturnedItemsAreCopies(t *testing.T) {
+	client := newClient(t)
+	put(t, client, map[string]types.AttributeValue{"cart_id": n("1"), "note": s("original")})
+
+	get(t, client, "1")["note"].(*types.AttributeValueMemberS).Value = "changed"
+
+	if got := get(t, client, "1")["note"].(*types.AttributeValueMemberS).Value; got != "original" {
+		t.Errorf("note = %s, want stored item unaffected by caller edits", got)
+	}
+}
@@ -1,30 +1,151 @@
 package repository
 
-import "github.com/LuoZihYuan/Go-Cart/internal/models"
+import (
+	"context"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+)
 
 // ProductRepository defines the interface for product data operations
 type ProductRepository interface {
 	// GetByID retrieves a product by its ID
-	GetByID(productID int) (*models.Product, error)
+	GetByID(ctx context.Context, productID int) (*models.Product, error)
+
+	// GetByIDs retrieves in one batch those of the products that exist, in no
+	// particular order; missing products are left out rather than reported
+	GetByIDs(ctx context.Context, productIDs []int) ([]models.Product, error)
 
 	// Upsert creates or updates a product's details
-	Upsert(product *models.Product) error
+	Upsert(ctx context.Context, product *models.Product) error
 
 	// Exists checks if a product exists
-	Exists(productID int) (bool, error)
+	Exists(ctx context.Context, productID int) (bool, error)
+
+	// List returns up to limit products following cursor, for bulk export.
+	// Pass an empty cursor to start; next is empty once every product has been returned.
+	List(ctx context.Context, cursor string, limit int) (products []models.Product, next string, err error)
 }
 
 // CartRepository defines the interface for cart data operations
 type CartRepository interface {
-	// Create creates a new cart
-	Create(customerID int) (*models.Cart, error)
+	// Create creates a new cart priced in currency that expires at expiresAt, or never
+	// if it is zero. A guest cart has customerID 0 and the hash of its token in guestTokenHash.
+	Create(ctx context.Context, customerID int, guestTokenHash, currency string, expiresAt time.Time) (*models.Cart, error)
+
+	// CreateActive returns the customer's active cart if it has not expired at now, and
+	// otherwise creates a cart priced in currency that expires at expiresAt and makes it
+	// the active one; created reports which. An existing cart is returned whatever its
+	// currency. Concurrent calls for one customer return the same cart. Only carts made
+	// by CreateActive are active, until they are deleted or expire.
+	CreateActive(ctx context.Context, customerID int, currency string, now, expiresAt time.Time) (cart *models.Cart, created bool, err error)
 
 	// GetByID retrieves a cart by its ID
-	GetByID(cartID int) (*models.Cart, error)
+	GetByID(ctx context.Context, cartID int) (*models.Cart, error)
+
+	// GetActive retrieves the customer's active cart, returning ErrCartNotFound if
+	// there is none or it has expired at now
+	GetActive(ctx context.Context, customerID int, now time.Time) (*models.Cart, error)
+
+	// AddItem adds an item to a cart, adding to the quantity if the product is already in it,
+	// and moves the cart's expiry to expiresAt.
+	// It returns ErrCartNotFound for a missing cart; the product is not checked.
+	AddItem(ctx context.Context, cartID int, item models.CartItem, expiresAt time.Time) error
+
+	// AddItems adds several items to a cart as AddItem adds each, all of them or none
+	AddItems(ctx context.Context, cartID int, items []models.CartItem, expiresAt time.Time) error
 
-	// AddItem adds an item to a cart
-	AddItem(cartID int, item models.CartItem) error
+	// AddCoupon appends a coupon code to the cart's coupons unless it is already there.
+	// It returns ErrCartNotFound for a missing cart; the code is not checked.
+	AddCoupon(ctx context.Context, cartID int, code string) error
+
+	// RemoveCoupon removes a coupon code from the cart's coupons if it is there.
+	// It returns ErrCartNotFound for a missing cart.
+	RemoveCoupon(ctx context.Context, cartID int, code string) error
+
+	// SetShippingAddress replaces the cart's shipping address; nil clears it.
+	// It returns ErrCartNotFound for a missing cart.
+	SetShippingAddress(ctx context.Context, cartID int, address *models.Address) error
+
+	// SetShippingOption replaces the ID of the cart's shipping option; "" clears it.
+	// It returns ErrCartNotFound for a missing cart.
+	SetShippingOption(ctx context.Context, cartID int, optionID string) error
 
 	// Delete removes a cart (used after checkout)
-	Delete(cartID int) error
+	Delete(ctx context.Context, cartID int) error
+
+	// List returns up to limit carts with their items following cursor, for bulk export.
+	// Pass an empty cursor to start; next is empty once every cart has been returned.
+	// Each customer's active cart is marked Active.
+	List(ctx context.Context, cursor string, limit int) (carts []models.Cart, next string, err error)
+
+	// Put stores a cart exactly as given, keeping its ID and replacing any cart with that ID,
+	// for bulk import. Carts created later never reuse the ID. Backends that require a
+	// creation time record the current time for a cart without one. An Active cart
+	// becomes its customer's active cart in place of any other; otherwise a replaced
+	// active cart stays active if its customer is unchanged.
+	Put(ctx context.Context, cart *models.Cart) error
+}
+
+// PromotionRepository defines the interface for promotions and their redemptions
+type PromotionRepository interface {
+	// GetByCode retrieves a promotion by its coupon code
+	GetByCode(ctx context.Context, code string) (*models.Promotion, error)
+
+	// Upsert creates or updates a promotion, keeping the uses recorded for its code
+	Upsert(ctx context.Context, promotion *models.Promotion) error
+
+	// CustomerUses returns how many times the customer has redeemed the code
+	CustomerUses(ctx context.Context, code string, customerID int) (int, error)
+
+	// Redeem records one use of the code by the customer, or by a guest if customerID
+	// is 0, even when called concurrently. Recording nothing, it returns ErrPromotionUsedUp
+	// if the code has been used MaxUses times, ErrPromotionCustomerUsedUp if the customer
+	// has used it MaxUsesPerCustomer times and ErrPromotionNotFound for a missing code.
+	Redeem(ctx context.Context, code string, customerID int) error
+
+	// Release undoes one Redeem of the code by the customer, after a failed checkout
+	Release(ctx context.Context, code string, customerID int) error
+
+	// List returns up to limit promotions following cursor, for bulk export.
+	// Pass an empty cursor to start; next is empty once every promotion has been returned.
+	List(ctx context.Context, cursor string, limit int) (promotions []models.Promotion, next string, err error)
+
+	// Put stores a promotion exactly as given, uses included, replacing any promotion
+	// with its code, for bulk import
+	Put(ctx context.Context, promotion *models.Promotion) error
+
+	// ListRedemptions returns up to limit customers' uses of codes following cursor, for
+	// bulk export. Pass an empty cursor to start; next is empty once every one has been returned.
+	ListRedemptions(ctx context.Context, cursor string, limit int) (redemptions []models.Redemption, next string, err error)
+
+	// PutRedemption sets how many times a customer has redeemed a code, for bulk import.
+	// The promotion must be put first.
+	PutRedemption(ctx context.Context, redemption *models.Redemption) error
+}
+
+// ExpiredCartDeleter is implemented by cart repositories that delete expired carts
+// on request; DynamoDB instead deletes them itself through the table's TTL
+type ExpiredCartDeleter interface {
+	// DeleteExpired deletes up to limit carts whose expiry is at or before now,
+	// returning how many it deleted
+	DeleteExpired(ctx context.Context, now time.Time, limit int) (int, error)
+}
+
+// AbandonedCartStore is implemented by cart repositories that can find carts left
+// idle with items in them and record the reminders sent about them
+type AbandonedCartStore interface {
+	// ListAbandoned returns up to limit carts with items that have not changed since
+	// idleSince, have not expired at now and have not been reminded of, least recently
+	// changed first where the backend can order them. Guest carts, with no one to remind,
+	// and carts without an update time are never abandoned.
+	ListAbandoned(ctx context.Context, idleSince, now time.Time, limit int) ([]models.Cart, error)
+
+	// MarkReminded records that a reminder about the cart is sent at remindedAt. It reports
+	// false, recording nothing, if the cart is gone, changed after idleSince or was already
+	// reminded of, so that of several callers only one sends the reminder.
+	MarkReminded(ctx context.Context, cartID int, idleSince, remindedAt time.Time) (bool, error)
+
+	// UnmarkReminded forgets a reminder that could not be sent, so it is tried again
+	UnmarkReminded(ctx context.Context, cartID int) error
 }
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+)
+
+// ErrInvalidCursor is returned by List for a cursor it did not issue
+var ErrInvalidCursor = errors.New("invalid list cursor")
+
+// Backends that list in ID order use the last ID returned as the cursor
+
+func parseIDCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
+
+// nextIDCursor returns the cursor after a page whose last ID is lastID, or
+// "" if the page was not full and so nothing follows it
+func nextIDCursor(lastID, count, limit int) string {
+	if count < limit {
+		return ""
+	}
+	return strconv.Itoa(lastID)
+}
+
+// Backends list promotions in code order and use the last code returned as the cursor
+
+// nextCodeCursor returns the cursor after a page whose last code is lastCode, or
+// "" if the page was not full and so nothing follows it
+func nextCodeCursor(lastCode string, count, limit int) string {
+	if count < limit {
+		return ""
+	}
+	return lastCode
+}
+
+// Backends list redemptions in code and then customer order and use the last
+// redemption's "code#customerID" as the cursor
+
+func parseRedemptionCursor(cursor string) (code string, customerID int, err error) {
+	if cursor == "" {
+		return "", 0, nil
+	}
+	i := strings.LastIndexByte(cursor, '#')
+	if i < 1 {
+		return "", 0, ErrInvalidCursor
+	}
+	customerID, err = strconv.Atoi(cursor[i+1:])
+	if err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+	return cursor[:i], customerID, nil
+}
+
+// nextRedemptionCursor returns the cursor after a page whose last redemption is last,
+// or "" if the page was not full and so nothing follows it
+func nextRedemptionCursor(last models.Redemption, count, limit int) string {
+	if count < limit {
+		return ""
+	}
+	return last.Code + "#" + strconv.Itoa(last.CustomerID)
+}
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// memoryJournal persists a memory repository as a JSON snapshot plus an
+// append-only log of JSON lines written after it.
+//
+// Log entries hold the full state of the record they change, so replaying an
+// entry twice is harmless. That keeps recovery correct if the process stops
+// between replacing the snapshot and truncating the log during compaction.
+//
+// The journal does no locking of its own: the owning repository appends and
+// compacts while holding its write lock, so the log order matches memory.
+type memoryJournal struct {
+	snapshotPath string
+	logPath      string
+	log          journalFile
+	sync         bool
+	entries      int   // entries appended since the last compaction
+	size         int64 // length of the log up to the end of its last complete entry
+	// broken is set when a failed append could not be undone, leaving part of an
+	// entry in the log; every later append fails with it rather than extend the log
+	broken error
+}
+
+// journalFile is the part of *os.File the journal uses to write its log
+type journalFile interface {
+	io.WriteSeeker
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+// openMemoryJournal loads the snapshot and log for name in dir, passing them to
+// restore and replay, and opens the log for appending.
+// A partially written final log line, left by a crash mid-append, is discarded.
+func openMemoryJournal(dir, name string, sync bool, restore func(snapshot []byte) error, replay func(entry []byte) error) (*memoryJournal, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	j := &memoryJournal{
+		snapshotPath: filepath.Join(dir, name+".snapshot.json"),
+		logPath:      filepath.Join(dir, name+".log"),
+		sync:         sync,
+	}
+
+	snapshot, err := os.ReadFile(j.snapshotPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := restore(snapshot); err != nil {
+			return nil, fmt.Errorf("restore %s: %w", j.snapshotPath, err)
+		}
+	}
+
+	valid, err := j.replay(replay)
+	if err != nil {
+		return nil, err
+	}
+
+	j.log, err = os.OpenFile(j.logPath, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	// Drop anything after the last complete entry so new entries start on a fresh line
+	if err := j.log.Truncate(valid); err != nil {
+		j.log.Close()
+		return nil, err
+	}
+	if _, err := j.log.Seek(valid, io.SeekStart); err != nil {
+		j.log.Close()
+		return nil, err
+	}
+	j.size = valid
+
+	return j, nil
+}
+
+// replay applies every complete log entry and returns the length of the valid prefix
+func (j *memoryJournal) replay(apply func(entry []byte) error) (int64, error) {
+	f, err := os.Open(j.logPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var valid int64
+	for line := 1; ; line++ {
+		entry, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(entry)) > 0 {
+				slog.Warn("discarding incomplete memory journal entry", "file", j.logPath, "line", line)
+			}
+			return valid, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if err := apply(entry); err != nil {
+			return 0, fmt.Errorf("replay %s line %d: %w", j.logPath, line, err)
+		}
+		valid += int64(len(entry))
+		j.entries++
+	}
+}
+
+// append writes one entry to the log. If the write fails, the log is cut back to
+// its last complete entry so that it still matches memory.
+func (j *memoryJournal) append(entry any) error {
+	if j.broken != nil {
+		return j.broken
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if err := j.write(data); err != nil {
+		if undoErr := j.rewind(); undoErr != nil {
+			j.broken = fmt.Errorf("memory journal %s has an incomplete entry: %w", j.logPath, undoErr)
+			return errors.Join(err, j.broken)
+		}
+		return err
+	}
+	j.size += int64(len(data))
+	j.entries++
+	return nil
+}
+
+// write writes data at the end of the log, flushing it to disk if the journal syncs
+func (j *memoryJournal) write(data []byte) error {
+	if _, err := j.log.Write(data); err != nil {
+		return err
+	}
+	if j.sync {
+		return j.log.Sync()
+	}
+	return nil
+}
+
+// rewind drops anything written to the log after its last complete entry
+func (j *memoryJournal) rewind() error {
+	if err := j.log.Truncate(j.size); err != nil {
+		return err
+	}
+	_, err := j.log.Seek(j.size, io.SeekStart)
+	return err
+}
+
+// compact replaces the snapshot with state and empties the log.
+// It does nothing if no entries were appended since the last compaction.
+func (j *memoryJournal) compact(state any) error {
+	if j.entries == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	// Write the new snapshot beside the old one and rename it into place,
+	// so a crash leaves either the old or the new snapshot intact
+	tmp := j.snapshotPath + ".tmp"
+	if err := writeFileSync(tmp, data); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, j.snapshotPath); err != nil {
+		return err
+	}
+
+	if err := j.log.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := j.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	j.entries = 0
+	j.size = 0
+	j.broken = nil
+	return nil
+}
+
+// close flushes and closes the log
+func (j *memoryJournal) close() error {
+	if err := j.log.Sync(); err != nil {
+		j.log.Close()
+		return err
+	}
+	return j.log.Close()
+}
+
+func writeFileSync(path string, data []byte) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var (
+	errDiskFull  = errors.New("no space left on device")
+	errReadOnly  = errors.New("read-only file system")
+	errSyncFault = errors.New("input/output error")
+)
+
+// faultyFile writes a log through to disk until told to fail
+type faultyFile struct {
+	*os.File
+	// shortWrites makes each write put half its data in the file before failing
+	shortWrites bool
+	// failSyncs makes Sync fail after the data is written
+	failSyncs bool
+	// failTruncates makes Truncate fail without changing the file
+	failTruncates bool
+}
+
+func (f *faultyFile) Write(p []byte) (int, error) {
+	if !f.shortWrites {
+		return f.File.Write(p)
+	}
+	n, err := f.File.Write(p[:len(p)/2])
+	if err != nil {
+		return n, err
+	}
+	return n, errDiskFull
+}
+
+func (f *faultyFile) Sync() error {
+	if f.failSyncs {
+		return errSyncFault
+	}
+	return f.File.Sync()
+}
+
+func (f *faultyFile) Truncate(size int64) error {
+	if f.failTruncates {
+		return errReadOnly
+	}
+	return f.File.Truncate(size)
+}
+
+// openTestJournal opens the journal in dir, returning it with the entries it replayed
+func openTestJournal(t *testing.T, dir string, sync bool) (*memoryJournal, []string) {
+	t.Helper()
+
+	var entries []string
+	j, err := openMemoryJournal(dir, "test", sync,
+		func([]byte) error { return nil },
+		func(entry []byte) error {
+			entries = append(entries, strings.TrimSpace(string(entry)))
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("openMemoryJournal: %v", err)
+	}
+	return j, entries
+}
+
+// faulty routes the journal's writes through a faultyFile
+func faulty(j *memoryJournal) *faultyFile {
+	f := &faultyFile{File: j.log.(*os.File)}
+	j.log = f
+	return f
+}
+
+func mustAppend(t *testing.T, j *memoryJournal, entry string) {
+	t.Helper()
+	if err := j.append(entry); err != nil {
+		t.Fatalf("append %q: %v", entry, err)
+	}
+}
+
+func TestMemoryJournalRewindsFailedAppend(t *testing.T) {
+	tests := []struct {
+		name  string
+		sync  bool
+		fault func(f *faultyFile)
+		want  error
+	}{
+		{name: "short write", fault: func(f *faultyFile) { f.shortWrites = true }, want: errDiskFull},
+		{name: "failed sync", sync: true, fault: func(f *faultyFile) { f.failSyncs = true }, want: errSyncFault},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			j, _ := openTestJournal(t, dir, tt.sync)
+			mustAppend(t, j, "first")
+
+			f := faulty(j)
+			tt.fault(f)
+			if err := j.append("lost"); !errors.Is(err, tt.want) {
+				t.Fatalf("append error = %v, want %v", err, tt.want)
+			}
+			if j.broken != nil {
+				t.Fatalf("journal broken after a failed append it rewound: %v", j.broken)
+			}
+
+			// The log is back to its last complete entry, so the next entry starts a fresh line
+			*f = faultyFile{File: f.File}
+			mustAppend(t, j, "second")
+			if err := j.close(); err != nil {
+				t.Fatal(err)
+			}
+
+			j, entries := openTestJournal(t, dir, tt.sync)
+			defer j.close()
+			if want := []string{`"first"`, `"second"`}; !reflect.DeepEqual(entries, want) {
+				t.Fatalf("replayed %v, want %v", entries, want)
+			}
+		})
+	}
+}
+
+func TestMemoryJournalBreaksWhenRewindFails(t *testing.T) {
+	dir := t.TempDir()
+	j, _ := openTestJournal(t, dir, false)
+	mustAppend(t, j, "first")
+
+	f := faulty(j)
+	f.shortWrites, f.failTruncates = true, true
+	err := j.append("torn")
+	if !errors.Is(err, errDiskFull) || !errors.Is(err, errReadOnly) {
+		t.Fatalf("append error = %v, want the write and rewind errors", err)
+	}
+
+	// Once broken, the journal refuses entries rather than write after the torn one
+	f.shortWrites, f.failTruncates = false, false
+	info, err := os.Stat(filepath.Join(dir, "test.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.append("refused"); j.broken == nil || err != j.broken {
+		t.Fatalf("append to a broken journal error = %v, want %v", err, j.broken)
+	}
+	after, err := os.Stat(filepath.Join(dir, "test.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() != info.Size() {
+		t.Fatalf("broken journal's log grew from %d to %d bytes", info.Size(), after.Size())
+	}
+	if err := j.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopening discards the torn entry and the log takes new entries again
+	j, entries := openTestJournal(t, dir, false)
+	if want := []string{`"first"`}; !reflect.DeepEqual(entries, want) {
+		t.Fatalf("replayed %v, want %v", entries, want)
+	}
+	mustAppend(t, j, "second")
+	if err := j.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	j, entries = openTestJournal(t, dir, false)
+	defer j.close()
+	if want := []string{`"first"`, `"second"`}; !reflect.DeepEqual(entries, want) {
+		t.Fatalf("replayed %v, want %v", entries, want)
+	}
+}
+
+func TestMemoryJournalCompactionRepairsBrokenLog(t *testing.T) {
+	dir := t.TempDir()
+	j, _ := openTestJournal(t, dir, false)
+	mustAppend(t, j, "first")
+
+	f := faulty(j)
+	f.shortWrites, f.failTruncates = true, true
+	if err := j.append("torn"); err == nil {
+		t.Fatal("append succeeded, want an error")
+	}
+
+	// Compaction snapshots memory and empties the log, leaving nothing torn behind
+	f.shortWrites, f.failTruncates = false, false
+	if err := j.compact("state"); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	mustAppend(t, j, "second")
+	if err := j.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var snapshot string
+	j, err := openMemoryJournal(dir, "test", false,
+		func(data []byte) error {
+			snapshot = string(data)
+			return nil
+		},
+		func(entry []byte) error {
+			if got := strings.TrimSpace(string(entry)); got != `"second"` {
+				t.Errorf("replayed %s, want only \"second\"", got)
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("openMemoryJournal: %v", err)
+	}
+	defer j.close()
+	if snapshot != `"state"` {
+		t.Fatalf("snapshot = %s, want \"state\"", snapshot)
+	}
+}
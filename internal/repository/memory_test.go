@@ -0,0 +1,191 @@
+package repository_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository/repotest"
+)
+
+func TestProductMemoryRepository(t *testing.T) {
+	repotest.TestProductRepository(t, func(t *testing.T) repository.ProductRepository {
+		return repository.NewProductMemoryRepository()
+	})
+}
+
+func TestCartMemoryRepository(t *testing.T) {
+	repotest.TestCartRepository(t, func(t *testing.T) repository.CartRepository {
+		return repository.NewCartMemoryRepository()
+	})
+}
+
+func TestPromotionMemoryRepository(t *testing.T) {
+	repotest.TestPromotionRepository(t, func(t *testing.T) repository.PromotionRepository {
+		return repository.NewPromotionMemoryRepository()
+	})
+}
+
+func TestPersistentProductMemoryRepository(t *testing.T) {
+	repotest.TestProductRepository(t, func(t *testing.T) repository.ProductRepository {
+		repo, err := repository.NewPersistentProductMemoryRepository(t.TempDir(), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestPersistentCartMemoryRepository(t *testing.T) {
+	repotest.TestCartRepository(t, func(t *testing.T) repository.CartRepository {
+		repo, err := repository.NewPersistentCartMemoryRepository(t.TempDir(), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestPersistentPromotionMemoryRepository(t *testing.T) {
+	repotest.TestPromotionRepository(t, func(t *testing.T) repository.PromotionRepository {
+		repo, err := repository.NewPersistentPromotionMemoryRepository(t.TempDir(), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		return repo
+	})
+}
+
+func TestPersistentMemoryRepositoriesRestore(t *testing.T) {
+	dir := t.TempDir()
+
+	products, err := repository.NewPersistentProductMemoryRepository(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	carts, err := repository.NewPersistentCartMemoryRepository(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	product := models.Product{ProductID: 1, SKU: "SKU-1", Manufacturer: "Acme", CategoryID: 2, Weight: 3, Price: 4, SomeOtherID: 5}
+	if err := products.Upsert(t.Context(), &product); err != nil {
+		t.Fatal(err)
+	}
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	kept, _ := carts.Create(t.Context(), 10, "", "USD", expiresAt)
+	deleted, _ := carts.Create(t.Context(), 20, "", "USD", expiresAt)
+	expired, _ := carts.Create(t.Context(), 50, "", "USD", time.Now().Add(-time.Hour))
+	if err := carts.AddItem(t.Context(), kept.CartID, models.CartItem{ProductID: 1, Quantity: 2}, expiresAt); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fold the changes so far into a snapshot, then leave later ones in the log only
+	if err := carts.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err := carts.AddItem(t.Context(), kept.CartID, models.CartItem{ProductID: 1, Quantity: 3}, expiresAt); err != nil {
+		t.Fatal(err)
+	}
+	if err := carts.Delete(t.Context(), deleted.CartID); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := carts.DeleteExpired(t.Context(), time.Now(), 10); err != nil || n != 1 {
+		t.Fatalf("DeleteExpired = %d, %v; want the one expired cart", n, err)
+	}
+	imported := models.Cart{CartID: 100, CustomerID: 40, Items: []models.CartItem{{ProductID: 1, Quantity: 1}}}
+	if err := carts.Put(t.Context(), &imported); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen without closing, as after a crash
+	products, err = repository.NewPersistentProductMemoryRepository(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	carts, err = repository.NewPersistentCartMemoryRepository(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := products.GetByID(t.Context(), 1)
+	if err != nil || !reflect.DeepEqual(*got, product) {
+		t.Fatalf("restored product = %+v, %v; want %+v", got, err, product)
+	}
+
+	cart, err := carts.GetByID(t.Context(), kept.CartID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cart.Items) != 1 || cart.Items[0].Quantity != 5 {
+		t.Fatalf("restored cart items = %+v, want product 1 × 5", cart.Items)
+	}
+	if !cart.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("restored cart expires at %v, want %v", cart.ExpiresAt, expiresAt)
+	}
+	for _, cartID := range []int{deleted.CartID, expired.CartID} {
+		if _, err := carts.GetByID(t.Context(), cartID); err != repository.ErrCartNotFound {
+			t.Fatalf("deleted cart %d restored: %v", cartID, err)
+		}
+	}
+
+	if cart, err := carts.GetByID(t.Context(), imported.CartID); err != nil || cart.CustomerID != 40 {
+		t.Fatalf("restored imported cart = %+v, %v; want cart 100 for customer 40", cart, err)
+	}
+
+	// IDs of deleted and imported carts are not reused
+	next, err := carts.Create(t.Context(), 30, "", "USD", expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.CartID <= imported.CartID {
+		t.Fatalf("new cart ID %d reuses an earlier ID", next.CartID)
+	}
+}
+
+func TestPersistentPromotionMemoryRepositoryRestore(t *testing.T) {
+	dir := t.TempDir()
+
+	promotions, err := repository.NewPersistentPromotionMemoryRepository(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	promotion := models.Promotion{Code: "SPRING", Type: models.PromotionPercentOff, PercentOff: 10, MaxUsesPerCustomer: 2}
+	if err := promotions.Upsert(t.Context(), &promotion); err != nil {
+		t.Fatal(err)
+	}
+	if err := promotions.Redeem(t.Context(), "SPRING", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fold the changes so far into a snapshot, then leave later ones in the log only
+	if err := promotions.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err := promotions.Redeem(t.Context(), "SPRING", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := promotions.Redeem(t.Context(), "SPRING", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen without closing, as after a crash
+	promotions, err = repository.NewPersistentPromotionMemoryRepository(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := promotions.GetByCode(t.Context(), "SPRING")
+	if err != nil || got.Uses != 3 {
+		t.Fatalf("restored promotion = %+v, %v; want 3 uses", got, err)
+	}
+	if err := promotions.Redeem(t.Context(), "SPRING", 1); err != repository.ErrPromotionCustomerUsedUp {
+		t.Fatalf("Redeem after restore error = %v, want ErrPromotionCustomerUsedUp", err)
+	}
+}
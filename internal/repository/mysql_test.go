@@ -0,0 +1,69 @@
+package repository_test
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/migrations"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository/repotest"
+)
+
+// openMySQL connects to the database in GOCART_TEST_MYSQL_DSN, e.g.
+// "gocart:secret@tcp(localhost:3306)/gocart_test?parseTime=true" with the
+// docker compose mysql profile, and skips the test when it is not set.
+// The schema is migrated and every table emptied, so use a dedicated database.
+func openMySQL(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("GOCART_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("GOCART_TEST_MYSQL_DSN not set")
+	}
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrations.NewMySQL(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	truncate(t, db)
+
+	return db
+}
+
+func TestProductMySQLRepository(t *testing.T) {
+	repotest.TestProductRepository(t, func(t *testing.T) repository.ProductRepository {
+		return repository.NewProductMySQLRepository(openMySQL(t))
+	})
+}
+
+func TestCartMySQLRepository(t *testing.T) {
+	repotest.TestCartRepository(t, func(t *testing.T) repository.CartRepository {
+		return repository.NewCartMySQLRepository(openMySQL(t))
+	})
+}
+
+func TestPromotionMySQLRepository(t *testing.T) {
+	repotest.TestPromotionRepository(t, func(t *testing.T) repository.PromotionRepository {
+		return repository.NewPromotionMySQLRepository(openMySQL(t))
+	})
+}
+
+// truncate empties the application tables, children first
+func truncate(t *testing.T, db *sql.DB) {
+	t.Helper()
+	for _, table := range []string{"cart_items", "carts", "products", "promotion_redemptions", "promotions"} {
+		if _, err := db.Exec("DELETE FROM " + table); err != nil {
+			t.Fatalf("empty %s: %v", table, err)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/cache"
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+)
+
+// productCacheKeyPrefix namespaces product entries in a shared cache
+const productCacheKeyPrefix = "gocart:product:"
+
+// productLoadTimeout bounds a shared load from the repository, which no single
+// caller's context does
+const productLoadTimeout = 10 * time.Second
+
+// CachedProductRepository is a read-through cache in front of another ProductRepository.
+// Concurrent misses for the same product, whether read alone or in a batch, share
+// one load from the repository. The load outlives the caller that started it, up to
+// productLoadTimeout, so each caller only gives up when its own context is done.
+// Upsert invalidates the product's entry; a read racing an upsert can still
+// cache the old value, so other readers may see it for up to the TTL.
+// Missing products are not cached. Cache failures are logged and fall back to
+// the repository, so a cache outage only costs latency.
+type CachedProductRepository struct {
+	repo  ProductRepository
+	cache cache.Cache
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	loading map[int]*productLoad // loads in flight, by product ID
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	loads  atomic.Uint64
+	errors atomic.Uint64
+}
+
+// ProductCacheStats counts cache activity since the repository was created
+type ProductCacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	// Loads is how many misses reached the repository; the rest shared another caller's load
+	Loads uint64 `json:"loads"`
+	// Errors counts failed cache reads, writes and invalidations
+	Errors uint64 `json:"errors"`
+}
+
+// productLoad is one product being loaded from the repository; callers that miss
+// it meanwhile wait for done instead of loading it again
+type productLoad struct {
+	done    chan struct{}
+	product *models.Product
+	err     error
+}
+
+func NewCachedProductRepository(repo ProductRepository, c cache.Cache, ttl time.Duration) *CachedProductRepository {
+	return &CachedProductRepository{
+		repo:    repo,
+		cache:   c,
+		ttl:     ttl,
+		loading: make(map[int]*productLoad),
+	}
+}
+
+// GetByID retrieves a product by its ID, from the cache when possible
+func (r *CachedProductRepository) GetByID(ctx context.Context, productID int) (*models.Product, error) {
+	key := productCacheKey(productID)
+
+	if product, ok := r.cached(key); ok {
+		r.hits.Add(1)
+		return product, nil
+	}
+	r.misses.Add(1)
+
+	loads, err := r.load(ctx, []int{productID})
+	if err != nil {
+		return nil, err
+	}
+	load := loads[productID]
+	if load.err != nil {
+		return nil, load.err
+	}
+
+	// Every caller sharing the load gets its own copy
+	return cloneProduct(load.product), nil
+}
+
+// GetByIDs retrieves the products that exist among productIDs, loading those not
+// cached, and not already being loaded, from the repository in one batch and caching them
+func (r *CachedProductRepository) GetByIDs(ctx context.Context, productIDs []int) ([]models.Product, error) {
+	products := []models.Product{}
+	var missing []int
+	for _, productID := range uniqueIDs(productIDs) {
+		if product, ok := r.cached(productCacheKey(productID)); ok {
+			r.hits.Add(1)
+			products = append(products, *product)
+			continue
+		}
+		r.misses.Add(1)
+		missing = append(missing, productID)
+	}
+	if len(missing) == 0 {
+		return products, nil
+	}
+
+	loads, err := r.load(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for _, productID := range missing {
+		load := loads[productID]
+		if errors.Is(load.err, ErrProductNotFound) {
+			continue
+		}
+		if load.err != nil {
+			return nil, load.err
+		}
+		products = append(products, *cloneProduct(load.product))
+	}
+	return products, nil
+}
+
+// load loads productIDs from the repository, joining the loads other callers already
+// have in flight and starting one for the rest, and waits for them all. A missing
+// product's load fails with ErrProductNotFound.
+func (r *CachedProductRepository) load(ctx context.Context, productIDs []int) (map[int]*productLoad, error) {
+	loads := make(map[int]*productLoad, len(productIDs))
+	var started []int
+	r.mu.Lock()
+	for _, productID := range productIDs {
+		load, ok := r.loading[productID]
+		if !ok {
+			load = &productLoad{done: make(chan struct{})}
+			r.loading[productID] = load
+			started = append(started, productID)
+		}
+		loads[productID] = load
+	}
+	r.mu.Unlock()
+
+	// Callers joining the load must not see it fail because this caller went away
+	if len(started) > 0 {
+		go r.fetch(context.WithoutCancel(ctx), started, loads)
+	}
+
+	for _, load := range loads {
+		select {
+		case <-load.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return loads, nil
+}
+
+// fetch reads productIDs from the repository in one call, within productLoadTimeout,
+// caches them and finishes their loads, failing them if the repository panics
+func (r *CachedProductRepository) fetch(ctx context.Context, productIDs []int, loads map[int]*productLoad) {
+	r.loads.Add(uint64(len(productIDs)))
+	ctx, cancel := context.WithTimeout(ctx, productLoadTimeout)
+	defer cancel()
+
+	byID := make(map[int]*models.Product, len(productIDs))
+	var err error
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("product load panicked: %v", recovered)
+			slog.Error("product load panicked", "product_ids", productIDs, "panic", recovered)
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for _, productID := range productIDs {
+			load := loads[productID]
+			load.product, load.err = byID[productID], err
+			if err == nil && load.product == nil {
+				load.err = ErrProductNotFound
+			}
+			// Upsert may already have replaced it
+			if r.loading[productID] == load {
+				delete(r.loading, productID)
+			}
+			close(load.done)
+		}
+	}()
+
+	var products []models.Product
+	if len(productIDs) == 1 {
+		var product *models.Product
+		product, err = r.repo.GetByID(ctx, productIDs[0])
+		if errors.Is(err, ErrProductNotFound) {
+			err = nil
+		} else if err == nil {
+			products = []models.Product{*product}
+		}
+	} else {
+		products, err = r.repo.GetByIDs(ctx, productIDs)
+	}
+	if err != nil {
+		return
+	}
+
+	for i := range products {
+		product := &products[i]
+		byID[product.ProductID] = product
+
+		data, err := json.Marshal(product)
+		if err == nil {
+			err = r.cache.Set(productCacheKey(product.ProductID), data, r.ttl)
+		}
+		if err != nil {
+			r.errors.Add(1)
+			slog.Warn("failed to cache product", "product_id", product.ProductID, "error", err)
+		}
+	}
+}
+
+// cached decodes the product stored under key, if any
+func (r *CachedProductRepository) cached(key string) (*models.Product, bool) {
+	data, ok, err := r.cache.Get(key)
+	if err != nil {
+		r.errors.Add(1)
+		slog.Warn("failed to read product cache", "key", key, "error", err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+
+	var product models.Product
+	if err := json.Unmarshal(data, &product); err != nil {
+		r.errors.Add(1)
+		slog.Warn("discarding undecodable product cache entry", "key", key, "error", err)
+		return nil, false
+	}
+	return &product, true
+}
+
+// Upsert creates or updates a product's details and invalidates its cache entry
+func (r *CachedProductRepository) Upsert(ctx context.Context, product *models.Product) error {
+	if err := r.repo.Upsert(ctx, product); err != nil {
+		return err
+	}
+
+	// Loads already in flight may have read the old value; don't let new callers join them
+	r.mu.Lock()
+	delete(r.loading, product.ProductID)
+	r.mu.Unlock()
+	if err := r.cache.Delete(productCacheKey(product.ProductID)); err != nil {
+		r.errors.Add(1)
+		slog.Warn("failed to invalidate product cache", "product_id", product.ProductID, "error", err)
+	}
+
+	return nil
+}
+
+// Exists checks if a product exists, loading it into the cache if it does
+func (r *CachedProductRepository) Exists(ctx context.Context, productID int) (bool, error) {
+	_, err := r.GetByID(ctx, productID)
+	if errors.Is(err, ErrProductNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// List reads straight from the repository; bulk exports would only evict the products being served
+func (r *CachedProductRepository) List(ctx context.Context, cursor string, limit int) ([]models.Product, string, error) {
+	return r.repo.List(ctx, cursor, limit)
+}
+
+// Stats returns the cache counters
+func (r *CachedProductRepository) Stats() ProductCacheStats {
+	return ProductCacheStats{
+		Hits:   r.hits.Load(),
+		Misses: r.misses.Load(),
+		Loads:  r.loads.Load(),
+		Errors: r.errors.Load(),
+	}
+}
+
+func productCacheKey(productID int) string {
+	return productCacheKeyPrefix + strconv.Itoa(productID)
+}
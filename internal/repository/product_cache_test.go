@@ -0,0 +1,283 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/cache"
+	"github.com/LuoZihYuan/Go-Cart/internal/cache/redistest"
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository/repotest"
+)
+
+// countingProductRepository counts reads and can hold them until released;
+// a held read fails if its context is done by the time it is released
+type countingProductRepository struct {
+	repository.ProductRepository
+	reads   atomic.Int64
+	batched atomic.Int64  // products read by GetByIDs
+	release chan struct{} // nil to never block
+}
+
+func (r *countingProductRepository) GetByID(ctx context.Context, productID int) (*models.Product, error) {
+	r.reads.Add(1)
+	if r.release != nil {
+		<-r.release
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return r.ProductRepository.GetByID(ctx, productID)
+}
+
+func (r *countingProductRepository) GetByIDs(ctx context.Context, productIDs []int) ([]models.Product, error) {
+	r.batched.Add(int64(len(productIDs)))
+	if r.release != nil {
+		<-r.release
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return r.ProductRepository.GetByIDs(ctx, productIDs)
+}
+
+func newRedisStandIn(t *testing.T) (*redistest.Server, *cache.Redis) {
+	server := redistest.NewServer(t, "")
+	client := cache.NewRedis(cache.RedisOptions{Addr: server.Addr(), Timeout: time.Second, PoolSize: 4})
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func TestCachedProductRepositoryLRU(t *testing.T) {
+	repotest.TestProductRepository(t, func(t *testing.T) repository.ProductRepository {
+		return repository.NewCachedProductRepository(repository.NewProductMemoryRepository(), cache.NewLRU(100), time.Minute)
+	})
+}
+
+func TestCachedProductRepositoryRedis(t *testing.T) {
+	repotest.TestProductRepository(t, func(t *testing.T) repository.ProductRepository {
+		_, client := newRedisStandIn(t)
+		return repository.NewCachedProductRepository(repository.NewProductMemoryRepository(), client, time.Minute)
+	})
+}
+
+func TestCachedProductRepositoryHitsAndInvalidation(t *testing.T) {
+	inner := &countingProductRepository{ProductRepository: repository.NewProductMemoryRepository()}
+	repo := repository.NewCachedProductRepository(inner, cache.NewLRU(100), time.Minute)
+
+	product := models.Product{ProductID: 1, SKU: "SKU-1", Manufacturer: "Acme", CategoryID: 1, Weight: 1, SomeOtherID: 1}
+	if err := repo.Upsert(t.Context(), &product); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 3 {
+		if _, err := repo.GetByID(t.Context(), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := inner.reads.Load(); got != 1 {
+		t.Errorf("repository read %d times for 3 lookups, want 1", got)
+	}
+
+	product.SKU = "SKU-1-v2"
+	if err := repo.Upsert(t.Context(), &product); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.GetByID(t.Context(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SKU != "SKU-1-v2" {
+		t.Errorf("SKU after upsert = %s, want SKU-1-v2", got.SKU)
+	}
+
+	want := repository.ProductCacheStats{Hits: 2, Misses: 2, Loads: 2}
+	if stats := repo.Stats(); stats != want {
+		t.Errorf("Stats() = %+v, want %+v", stats, want)
+	}
+}
+
+func TestCachedProductRepositoryDoesNotCacheMissingProducts(t *testing.T) {
+	inner := &countingProductRepository{ProductRepository: repository.NewProductMemoryRepository()}
+	repo := repository.NewCachedProductRepository(inner, cache.NewLRU(100), time.Minute)
+
+	for range 2 {
+		if _, err := repo.GetByID(t.Context(), 1); !errors.Is(err, repository.ErrProductNotFound) {
+			t.Fatalf("GetByID(missing) = %v, want ErrProductNotFound", err)
+		}
+	}
+	if got := inner.reads.Load(); got != 2 {
+		t.Errorf("repository read %d times, want 2", got)
+	}
+}
+
+func TestCachedProductRepositorySharesConcurrentLoads(t *testing.T) {
+	const callers = 16
+
+	inner := &countingProductRepository{
+		ProductRepository: repository.NewProductMemoryRepository(),
+		release:           make(chan struct{}),
+	}
+	product := models.Product{ProductID: 1, SKU: "SKU-1", Manufacturer: "Acme", CategoryID: 1, Weight: 1, SomeOtherID: 1}
+	if err := inner.Upsert(t.Context(), &product); err != nil {
+		t.Fatal(err)
+	}
+	repo := repository.NewCachedProductRepository(inner, cache.NewLRU(100), time.Minute)
+
+	var wg sync.WaitGroup
+	for range callers {
+		wg.Go(func() {
+			if _, err := repo.GetByID(t.Context(), 1); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	// Hold the first load until every caller has missed and joined it
+	for repo.Stats().Misses < callers {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(inner.release)
+	wg.Wait()
+
+	if got := inner.reads.Load(); got != 1 {
+		t.Errorf("repository read %d times for %d concurrent misses, want 1", got, callers)
+	}
+}
+
+func TestCachedProductRepositoryBatchJoinsConcurrentLoads(t *testing.T) {
+	inner := &countingProductRepository{
+		ProductRepository: repository.NewProductMemoryRepository(),
+		release:           make(chan struct{}),
+	}
+	for id := 1; id <= 3; id++ {
+		product := models.Product{ProductID: id, SKU: "SKU", Manufacturer: "Acme", CategoryID: 1, Weight: 1, SomeOtherID: 1}
+		if err := inner.Upsert(t.Context(), &product); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo := repository.NewCachedProductRepository(inner, cache.NewLRU(100), time.Minute)
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		if _, err := repo.GetByID(t.Context(), 1); err != nil {
+			t.Error(err)
+		}
+	})
+	for repo.Stats().Loads < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Two batches miss product 1 while it is loading; the first loads the rest
+	// and the second joins every load
+	batches := make([][]models.Product, 2)
+	for i := range batches {
+		wg.Go(func() {
+			products, err := repo.GetByIDs(t.Context(), []int{1, 2, 3, 4})
+			if err != nil {
+				t.Error(err)
+			}
+			batches[i] = products
+		})
+	}
+	for repo.Stats().Misses < 9 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(inner.release)
+	wg.Wait()
+
+	if reads, batched := inner.reads.Load(), inner.batched.Load(); reads != 1 || batched != 3 {
+		t.Errorf("repository read %d products alone and %d in batches, want 1 and 3", reads, batched)
+	}
+	for i, products := range batches {
+		if len(products) != 3 {
+			t.Errorf("batch %d = %+v, want products 1, 2 and 3", i, products)
+		}
+	}
+	if stats := repo.Stats(); stats.Loads != 4 {
+		t.Errorf("Stats() = %+v, want 4 loads", stats)
+	}
+}
+
+func TestCachedProductRepositoryLoadOutlivesItsStarter(t *testing.T) {
+	inner := &countingProductRepository{
+		ProductRepository: repository.NewProductMemoryRepository(),
+		release:           make(chan struct{}),
+	}
+	product := models.Product{ProductID: 1, SKU: "SKU-1", Manufacturer: "Acme", CategoryID: 1, Weight: 1, SomeOtherID: 1}
+	if err := inner.Upsert(t.Context(), &product); err != nil {
+		t.Fatal(err)
+	}
+	repo := repository.NewCachedProductRepository(inner, cache.NewLRU(100), time.Minute)
+
+	// The starter gives up while the load is held
+	ctx, cancel := context.WithCancel(t.Context())
+	starterErr := make(chan error, 1)
+	go func() {
+		_, err := repo.GetByID(ctx, 1)
+		starterErr <- err
+	}()
+	for repo.Stats().Loads < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		if _, err := repo.GetByID(t.Context(), 1); err != nil {
+			t.Errorf("joined GetByID: %v", err)
+		}
+	})
+	wg.Go(func() {
+		if products, err := repo.GetByIDs(t.Context(), []int{1}); err != nil || len(products) != 1 {
+			t.Errorf("joined GetByIDs = %+v, %v; want product 1", products, err)
+		}
+	})
+	for repo.Stats().Misses < 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-starterErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("starter GetByID error = %v, want context.Canceled", err)
+	}
+	close(inner.release)
+	wg.Wait()
+
+	if got := inner.reads.Load(); got != 1 {
+		t.Errorf("repository read %d times, want 1", got)
+	}
+}
+
+func TestCachedProductRepositorySurvivesCacheOutage(t *testing.T) {
+	server, client := newRedisStandIn(t)
+	repo := repository.NewCachedProductRepository(repository.NewProductMemoryRepository(), client, time.Minute)
+
+	product := models.Product{ProductID: 1, SKU: "SKU-1", Manufacturer: "Acme", CategoryID: 1, Weight: 1, SomeOtherID: 1}
+	if err := repo.Upsert(t.Context(), &product); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetByID(t.Context(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if server.Keys() != 1 {
+		t.Fatalf("stand-in holds %d keys, want 1", server.Keys())
+	}
+
+	server.Close()
+	if _, err := repo.GetByID(t.Context(), 1); err != nil {
+		t.Errorf("GetByID during a cache outage: %v", err)
+	}
+	if err := repo.Upsert(t.Context(), &product); err != nil {
+		t.Errorf("Upsert during a cache outage: %v", err)
+	}
+	if stats := repo.Stats(); stats.Errors == 0 {
+		t.Errorf("Stats() = %+v, want cache errors counted", stats)
+	}
+}
@@ -12,19 +12,19 @@ import (
 )
 
 type ProductDynamoDBRepository struct {
-	client    *dynamodb.Client
+	client    DynamoDBAPI
 	tableName string
 }
 
-func NewProductDynamoDBRepository(client *dynamodb.Client) *ProductDynamoDBRepository {
+func NewProductDynamoDBRepository(client DynamoDBAPI, tableName string) *ProductDynamoDBRepository {
 	return &ProductDynamoDBRepository{
 		client:    client,
-		tableName: "Products",
+		tableName: tableName,
 	}
 }
 
 // GetByID retrieves a product by its ID
-func (r *ProductDynamoDBRepository) GetByID(productID int) (*models.Product, error) {
+func (r *ProductDynamoDBRepository) GetByID(ctx context.Context, productID int) (*models.Product, error) {
 	input := &dynamodb.GetItemInput{
 		TableName: aws.String(r.tableName),
 		Key: map[string]types.AttributeValue{
@@ -32,7 +32,7 @@ func (r *ProductDynamoDBRepository) GetByID(productID int) (*models.Product, err
 		},
 	}
 
-	result, err := r.client.GetItem(context.TODO(), input)
+	result, err := r.client.GetItem(ctx, input)
 	if err != nil {
 		return nil, err
 	}
@@ -50,8 +50,30 @@ func (r *ProductDynamoDBRepository) GetByID(productID int) (*models.Product, err
 	return &product, nil
 }
 
+// GetByIDs retrieves the products that exist among productIDs with BatchGetItem
+func (r *ProductDynamoDBRepository) GetByIDs(ctx context.Context, productIDs []int) ([]models.Product, error) {
+	productIDs = uniqueIDs(productIDs)
+
+	keys := make([]map[string]types.AttributeValue, len(productIDs))
+	for i, productID := range productIDs {
+		keys[i] = map[string]types.AttributeValue{
+			"product_id": &types.AttributeValueMemberN{Value: strconv.Itoa(productID)},
+		}
+	}
+	items, err := dynamoDBBatchGet(ctx, r.client, r.tableName, keys, false)
+	if err != nil {
+		return nil, err
+	}
+
+	products := []models.Product{}
+	if err := attributevalue.UnmarshalListOfMaps(items, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
 // Upsert creates or updates a product's details
-func (r *ProductDynamoDBRepository) Upsert(product *models.Product) error {
+func (r *ProductDynamoDBRepository) Upsert(ctx context.Context, product *models.Product) error {
 	item, err := attributevalue.MarshalMap(product)
 	if err != nil {
 		return err
@@ -62,12 +84,12 @@ func (r *ProductDynamoDBRepository) Upsert(product *models.Product) error {
 		Item:      item,
 	}
 
-	_, err = r.client.PutItem(context.TODO(), input)
+	_, err = r.client.PutItem(ctx, input)
 	return err
 }
 
 // Exists checks if a product exists
-func (r *ProductDynamoDBRepository) Exists(productID int) (bool, error) {
+func (r *ProductDynamoDBRepository) Exists(ctx context.Context, productID int) (bool, error) {
 	input := &dynamodb.GetItemInput{
 		TableName: aws.String(r.tableName),
 		Key: map[string]types.AttributeValue{
@@ -76,10 +98,36 @@ func (r *ProductDynamoDBRepository) Exists(productID int) (bool, error) {
 		ProjectionExpression: aws.String("product_id"),
 	}
 
-	result, err := r.client.GetItem(context.TODO(), input)
+	result, err := r.client.GetItem(ctx, input)
 	if err != nil {
 		return false, err
 	}
 
 	return result.Item != nil, nil
 }
+
+// List returns products in table scan order
+func (r *ProductDynamoDBRepository) List(ctx context.Context, cursor string, limit int) ([]models.Product, string, error) {
+	startKey, err := dynamoDBStartKey("product_id", cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:         aws.String(r.tableName),
+		Limit:             aws.Int32(int32(limit)),
+		ExclusiveStartKey: startKey,
+	}
+
+	result, err := r.client.Scan(ctx, input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	products := []models.Product{}
+	if err := attributevalue.UnmarshalListOfMaps(result.Items, &products); err != nil {
+		return nil, "", err
+	}
+
+	return products, dynamoDBCursor("product_id", result.LastEvaluatedKey), nil
+}
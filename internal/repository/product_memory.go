@@ -1,7 +1,11 @@
 package repository
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"maps"
+	"sort"
 	"sync"
 
 	"github.com/LuoZihYuan/Go-Cart/internal/models"
@@ -14,6 +18,17 @@ var (
 type ProductMemoryRepository struct {
 	products map[int]*models.Product
 	mu       sync.RWMutex
+	journal  *memoryJournal // nil unless persistent
+}
+
+// productSnapshot is the on-disk form of the whole repository
+type productSnapshot struct {
+	Products []models.Product `json:"products"`
+}
+
+// productEntry is one journal line: the product's state after an upsert
+type productEntry struct {
+	Product models.Product `json:"product"`
 }
 
 func NewProductMemoryRepository() *ProductMemoryRepository {
@@ -22,8 +37,42 @@ func NewProductMemoryRepository() *ProductMemoryRepository {
 	}
 }
 
+// NewPersistentProductMemoryRepository creates a memory repository that restores
+// its state from dir and journals every change there. With sync, each change is
+// flushed to disk before the call returns.
+func NewPersistentProductMemoryRepository(dir string, sync bool) (*ProductMemoryRepository, error) {
+	r := NewProductMemoryRepository()
+
+	restore := func(data []byte) error {
+		var snapshot productSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return err
+		}
+		for i := range snapshot.Products {
+			r.products[snapshot.Products[i].ProductID] = &snapshot.Products[i]
+		}
+		return nil
+	}
+	replay := func(data []byte) error {
+		var entry productEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return err
+		}
+		r.products[entry.Product.ProductID] = &entry.Product
+		return nil
+	}
+
+	journal, err := openMemoryJournal(dir, "products", sync, restore, replay)
+	if err != nil {
+		return nil, err
+	}
+	r.journal = journal
+
+	return r, nil
+}
+
 // GetByID retrieves a product by its ID
-func (r *ProductMemoryRepository) GetByID(productID int) (*models.Product, error) {
+func (r *ProductMemoryRepository) GetByID(ctx context.Context, productID int) (*models.Product, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -33,27 +82,120 @@ func (r *ProductMemoryRepository) GetByID(productID int) (*models.Product, error
 	}
 
 	// Return a copy to prevent external modifications
-	productCopy := *product
-	return &productCopy, nil
+	return cloneProduct(product), nil
+}
+
+// GetByIDs retrieves the products that exist among productIDs
+func (r *ProductMemoryRepository) GetByIDs(ctx context.Context, productIDs []int) ([]models.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	products := []models.Product{}
+	for _, productID := range uniqueIDs(productIDs) {
+		if product, exists := r.products[productID]; exists {
+			products = append(products, *cloneProduct(product))
+		}
+	}
+	return products, nil
 }
 
 // Upsert creates or updates a product's details
-func (r *ProductMemoryRepository) Upsert(product *models.Product) error {
+func (r *ProductMemoryRepository) Upsert(ctx context.Context, product *models.Product) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	// Store a copy to prevent external modifications
-	productCopy := *product
-	r.products[product.ProductID] = &productCopy
+	productCopy := cloneProduct(product)
+
+	if r.journal != nil {
+		if err := r.journal.append(productEntry{Product: *productCopy}); err != nil {
+			return err
+		}
+	}
+	r.products[product.ProductID] = productCopy
 
 	return nil
 }
 
+// cloneProduct returns a copy of product that shares no memory with it
+func cloneProduct(product *models.Product) *models.Product {
+	productCopy := *product
+	productCopy.Prices = maps.Clone(product.Prices)
+	return &productCopy
+}
+
 // Exists checks if a product exists
-func (r *ProductMemoryRepository) Exists(productID int) (bool, error) {
+func (r *ProductMemoryRepository) Exists(ctx context.Context, productID int) (bool, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	_, exists := r.products[productID]
 	return exists, nil
 }
+
+// List returns products in ID order
+func (r *ProductMemoryRepository) List(ctx context.Context, cursor string, limit int) ([]models.Product, string, error) {
+	after, err := parseIDCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]int, 0, len(r.products))
+	for id := range r.products {
+		if id > after {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	ids = ids[:min(limit, len(ids))]
+
+	products := make([]models.Product, len(ids))
+	for i, id := range ids {
+		products[i] = *cloneProduct(r.products[id])
+	}
+	if len(products) == 0 {
+		return products, "", nil
+	}
+	return products, nextIDCursor(ids[len(ids)-1], len(ids), limit), nil
+}
+
+// Compact writes a snapshot of every product and empties the journal.
+// It does nothing for a repository that is not persistent.
+func (r *ProductMemoryRepository) Compact() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.journal == nil {
+		return nil
+	}
+
+	snapshot := productSnapshot{Products: make([]models.Product, 0, len(r.products))}
+	for _, product := range r.products {
+		snapshot.Products = append(snapshot.Products, *product)
+	}
+	sort.Slice(snapshot.Products, func(i, j int) bool {
+		return snapshot.Products[i].ProductID < snapshot.Products[j].ProductID
+	})
+
+	return r.journal.compact(snapshot)
+}
+
+// Close compacts and closes the journal of a persistent repository
+func (r *ProductMemoryRepository) Close() error {
+	if err := r.Compact(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.journal == nil {
+		return nil
+	}
+	err := r.journal.close()
+	r.journal = nil
+	return err
+}
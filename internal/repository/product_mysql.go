@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 
 	"github.com/LuoZihYuan/Go-Cart/internal/models"
@@ -18,22 +19,15 @@ func NewProductMySQLRepository(db *sql.DB) *ProductMySQLRepository {
 }
 
 // GetByID retrieves a product by its ID
-func (r *ProductMySQLRepository) GetByID(productID int) (*models.Product, error) {
+func (r *ProductMySQLRepository) GetByID(ctx context.Context, productID int) (*models.Product, error) {
 	query := `
-		SELECT product_id, sku, manufacturer, category_id, weight, some_other_id
+		SELECT product_id, sku, manufacturer, category_id, weight, price, some_other_id, purchase_limit, prices
 		FROM products
 		WHERE product_id = ?
 	`
 
 	var product models.Product
-	err := r.db.QueryRow(query, productID).Scan(
-		&product.ProductID,
-		&product.SKU,
-		&product.Manufacturer,
-		&product.CategoryID,
-		&product.Weight,
-		&product.SomeOtherID,
-	)
+	err := scanProduct(r.db.QueryRowContext(ctx, query, productID), &product)
 
 	if err == sql.ErrNoRows {
 		return nil, ErrProductNotFound
@@ -45,40 +39,75 @@ func (r *ProductMySQLRepository) GetByID(productID int) (*models.Product, error)
 	return &product, nil
 }
 
+// GetByIDs retrieves the products that exist among productIDs in one query
+func (r *ProductMySQLRepository) GetByIDs(ctx context.Context, productIDs []int) ([]models.Product, error) {
+	query := `
+		SELECT product_id, sku, manufacturer, category_id, weight, price, some_other_id, purchase_limit, prices
+		FROM products
+		WHERE product_id IN (%s)
+	`
+
+	return getProductsSQL(ctx, r.db, query, func(int) string { return "?" }, productIDs)
+}
+
 // Upsert creates or updates a product's details
-func (r *ProductMySQLRepository) Upsert(product *models.Product) error {
+func (r *ProductMySQLRepository) Upsert(ctx context.Context, product *models.Product) error {
+	prices, err := nullPrices(product.Prices)
+	if err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO products (product_id, sku, manufacturer, category_id, weight, some_other_id)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO products (product_id, sku, manufacturer, category_id, weight, price, some_other_id, purchase_limit, prices)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON DUPLICATE KEY UPDATE
 			sku = VALUES(sku),
 			manufacturer = VALUES(manufacturer),
 			category_id = VALUES(category_id),
 			weight = VALUES(weight),
-			some_other_id = VALUES(some_other_id)
+			price = VALUES(price),
+			some_other_id = VALUES(some_other_id),
+			purchase_limit = VALUES(purchase_limit),
+			prices = VALUES(prices)
 	`
 
-	_, err := r.db.Exec(query,
+	_, err = r.db.ExecContext(ctx, query,
 		product.ProductID,
 		product.SKU,
 		product.Manufacturer,
 		product.CategoryID,
 		product.Weight,
+		product.Price,
 		product.SomeOtherID,
+		product.PurchaseLimit,
+		prices,
 	)
 
 	return err
 }
 
 // Exists checks if a product exists
-func (r *ProductMySQLRepository) Exists(productID int) (bool, error) {
+func (r *ProductMySQLRepository) Exists(ctx context.Context, productID int) (bool, error) {
 	query := `SELECT EXISTS(SELECT 1 FROM products WHERE product_id = ?)`
 
 	var exists bool
-	err := r.db.QueryRow(query, productID).Scan(&exists)
+	err := r.db.QueryRowContext(ctx, query, productID).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
 
 	return exists, nil
 }
+
+// List returns products in ID order
+func (r *ProductMySQLRepository) List(ctx context.Context, cursor string, limit int) ([]models.Product, string, error) {
+	query := `
+		SELECT product_id, sku, manufacturer, category_id, weight, price, some_other_id, purchase_limit, prices
+		FROM products
+		WHERE product_id > ?
+		ORDER BY product_id
+		LIMIT ?
+	`
+
+	return listProductsSQL(ctx, r.db, query, cursor, limit)
+}
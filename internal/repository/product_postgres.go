@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+type ProductPostgresRepository struct {
+	db *sql.DB
+}
+
+func NewProductPostgresRepository(db *sql.DB) *ProductPostgresRepository {
+	return &ProductPostgresRepository{
+		db: db,
+	}
+}
+
+// GetByID retrieves a product by its ID
+func (r *ProductPostgresRepository) GetByID(ctx context.Context, productID int) (*models.Product, error) {
+	query := `
+		SELECT product_id, sku, manufacturer, category_id, weight, price, some_other_id, purchase_limit, prices
+		FROM products
+		WHERE product_id = $1
+	`
+
+	var product models.Product
+	err := scanProduct(r.db.QueryRowContext(ctx, query, productID), &product)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+// GetByIDs retrieves the products that exist among productIDs in one query
+func (r *ProductPostgresRepository) GetByIDs(ctx context.Context, productIDs []int) ([]models.Product, error) {
+	query := `
+		SELECT product_id, sku, manufacturer, category_id, weight, price, some_other_id, purchase_limit, prices
+		FROM products
+		WHERE product_id IN (%s)
+	`
+
+	return getProductsSQL(ctx, r.db, query, func(n int) string { return "$" + strconv.Itoa(n) }, productIDs)
+}
+
+// Upsert creates or updates a product's details
+func (r *ProductPostgresRepository) Upsert(ctx context.Context, product *models.Product) error {
+	prices, err := nullPrices(product.Prices)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO products (product_id, sku, manufacturer, category_id, weight, price, some_other_id, purchase_limit, prices)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (product_id) DO UPDATE SET
+			sku = EXCLUDED.sku,
+			manufacturer = EXCLUDED.manufacturer,
+			category_id = EXCLUDED.category_id,
+			weight = EXCLUDED.weight,
+			price = EXCLUDED.price,
+			some_other_id = EXCLUDED.some_other_id,
+			purchase_limit = EXCLUDED.purchase_limit,
+			prices = EXCLUDED.prices,
+			updated_at = now()
+	`
+
+	_, err = r.db.ExecContext(ctx, query,
+		product.ProductID,
+		product.SKU,
+		product.Manufacturer,
+		product.CategoryID,
+		product.Weight,
+		product.Price,
+		product.SomeOtherID,
+		product.PurchaseLimit,
+		prices,
+	)
+
+	return err
+}
+
+// Exists checks if a product exists
+func (r *ProductPostgresRepository) Exists(ctx context.Context, productID int) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM products WHERE product_id = $1)`
+
+	var exists bool
+	err := r.db.QueryRowContext(ctx, query, productID).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// List returns products in ID order
+func (r *ProductPostgresRepository) List(ctx context.Context, cursor string, limit int) ([]models.Product, string, error) {
+	query := `
+		SELECT product_id, sku, manufacturer, category_id, weight, price, some_other_id, purchase_limit, prices
+		FROM products
+		WHERE product_id > $1
+		ORDER BY product_id
+		LIMIT $2
+	`
+
+	return listProductsSQL(ctx, r.db, query, cursor, limit)
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	_ "modernc.org/sqlite"
+)
+
+type ProductSQLiteRepository struct {
+	db *sql.DB
+}
+
+func NewProductSQLiteRepository(db *sql.DB) *ProductSQLiteRepository {
+	return &ProductSQLiteRepository{
+		db: db,
+	}
+}
+
+// GetByID retrieves a product by its ID
+func (r *ProductSQLiteRepository) GetByID(ctx context.Context, productID int) (*models.Product, error) {
+	query := `
+		SELECT product_id, sku, manufacturer, category_id, weight, price, some_other_id, purchase_limit, prices
+		FROM products
+		WHERE product_id = ?
+	`
+
+	var product models.Product
+	err := scanProduct(r.db.QueryRowContext(ctx, query, productID), &product)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+// GetByIDs retrieves the products that exist among productIDs in one query
+func (r *ProductSQLiteRepository) GetByIDs(ctx context.Context, productIDs []int) ([]models.Product, error) {
+	query := `
+		SELECT product_id, sku, manufacturer, category_id, weight, price, some_other_id, purchase_limit, prices
+		FROM products
+		WHERE product_id IN (%s)
+	`
+
+	return getProductsSQL(ctx, r.db, query, func(int) string { return "?" }, productIDs)
+}
+
+// Upsert creates or updates a product's details
+func (r *ProductSQLiteRepository) Upsert(ctx context.Context, product *models.Product) error {
+	prices, err := nullPrices(product.Prices)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO products (product_id, sku, manufacturer, category_id, weight, price, some_other_id, purchase_limit, prices)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (product_id) DO UPDATE SET
+			sku = excluded.sku,
+			manufacturer = excluded.manufacturer,
+			category_id = excluded.category_id,
+			weight = excluded.weight,
+			price = excluded.price,
+			some_other_id = excluded.some_other_id,
+			purchase_limit = excluded.purchase_limit,
+			prices = excluded.prices,
+			updated_at = CURRENT_TIMESTAMP
+	`
+
+	_, err = r.db.ExecContext(ctx, query,
+		product.ProductID,
+		product.SKU,
+		product.Manufacturer,
+		product.CategoryID,
+		product.Weight,
+		product.Price,
+		product.SomeOtherID,
+		product.PurchaseLimit,
+		prices,
+	)
+
+	return err
+}
+
+// Exists checks if a product exists
+func (r *ProductSQLiteRepository) Exists(ctx context.Context, productID int) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM products WHERE product_id = ?)`
+
+	var exists bool
+	err := r.db.QueryRowContext(ctx, query, productID).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// List returns products in ID order
+func (r *ProductSQLiteRepository) List(ctx context.Context, cursor string, limit int) ([]models.Product, string, error) {
+	query := `
+		SELECT product_id, sku, manufacturer, category_id, weight, price, some_other_id, purchase_limit, prices
+		FROM products
+		WHERE product_id > ?
+		ORDER BY product_id
+		LIMIT ?
+	`
+
+	return listProductsSQL(ctx, r.db, query, cursor, limit)
+}
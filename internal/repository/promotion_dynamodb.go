@@ -0,0 +1,335 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// promotionRedemptionRecord counts a customer's uses of a code. It is kept in the
+// promotions table under the code and customer ID joined by a #, which no code
+// contains, so that it can be updated in one transaction with the promotion.
+type promotionRedemptionRecord struct {
+	Key  string `dynamodbav:"code"`
+	Uses int    `dynamodbav:"uses"`
+}
+
+// promotionRedemptionKey returns the key of the customer's uses of the code
+func promotionRedemptionKey(code string, customerID int) string {
+	return code + "#" + strconv.Itoa(customerID)
+}
+
+type PromotionDynamoDBRepository struct {
+	client    DynamoDBAPI
+	tableName string
+}
+
+func NewPromotionDynamoDBRepository(client DynamoDBAPI, tableName string) *PromotionDynamoDBRepository {
+	return &PromotionDynamoDBRepository{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+// GetByCode retrieves a promotion by its coupon code
+func (r *PromotionDynamoDBRepository) GetByCode(ctx context.Context, code string) (*models.Promotion, error) {
+	// Redemption counts share the table under keys containing a #
+	if strings.Contains(code, "#") {
+		return nil, ErrPromotionNotFound
+	}
+
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            promotionKey(code),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Item == nil {
+		return nil, ErrPromotionNotFound
+	}
+
+	var promotion models.Promotion
+	if err := attributevalue.UnmarshalMap(result.Item, &promotion); err != nil {
+		return nil, err
+	}
+	// Epoch seconds are decoded in the local time zone
+	promotion.StartsAt = promotion.StartsAt.UTC()
+	promotion.EndsAt = promotion.EndsAt.UTC()
+
+	return &promotion, nil
+}
+
+// Upsert creates or updates a promotion, keeping its uses
+func (r *PromotionDynamoDBRepository) Upsert(ctx context.Context, promotion *models.Promotion) error {
+	uses := expression.Name("uses")
+	update := expression.Set(expression.Name("type"), expression.Value(string(promotion.Type))).
+		Set(expression.Name("percent_off"), expression.Value(promotion.PercentOff)).
+		Set(expression.Name("amount_off"), expression.Value(promotion.AmountOff)).
+		Set(expression.Name("buy_quantity"), expression.Value(promotion.BuyQuantity)).
+		Set(expression.Name("get_quantity"), expression.Value(promotion.GetQuantity)).
+		Set(expression.Name("category_id"), expression.Value(promotion.CategoryID)).
+		Set(expression.Name("product_id"), expression.Value(promotion.ProductID)).
+		Set(expression.Name("max_uses"), expression.Value(promotion.MaxUses)).
+		Set(expression.Name("max_uses_per_customer"), expression.Value(promotion.MaxUsesPerCustomer)).
+		Set(uses, expression.IfNotExists(uses, expression.Value(0)))
+	update = setUnixTime(update, "starts_at", promotion.StartsAt)
+	update = setUnixTime(update, "ends_at", promotion.EndsAt)
+
+	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(r.tableName),
+		Key:                       promotionKey(promotion.Code),
+		UpdateExpression:          expr.Update(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	return err
+}
+
+// CustomerUses returns how many times the customer has redeemed the code
+func (r *PromotionDynamoDBRepository) CustomerUses(ctx context.Context, code string, customerID int) (int, error) {
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            promotionKey(promotionRedemptionKey(code, customerID)),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var record promotionRedemptionRecord
+	if err := attributevalue.UnmarshalMap(result.Item, &record); err != nil {
+		return 0, err
+	}
+	return record.Uses, nil
+}
+
+// Redeem records one use of the code by the customer unless its limits are reached.
+// The promotion and the customer's count are updated in one transaction, each on
+// condition that it is below its limit.
+func (r *PromotionDynamoDBRepository) Redeem(ctx context.Context, code string, customerID int) error {
+	promotion, err := r.GetByCode(ctx, code)
+	if err != nil {
+		return err
+	}
+
+	uses, maxUses := expression.Name("uses"), expression.Name("max_uses")
+	promotionUpdate := expression.Set(uses, expression.Plus(uses, expression.Value(1)))
+	promotionCondition := expression.AttributeExists(expression.Name("code")).
+		And(expression.Or(maxUses.Equal(expression.Value(0)), uses.LessThan(maxUses)))
+	promotionExpr, err := expression.NewBuilder().WithUpdate(promotionUpdate).WithCondition(promotionCondition).Build()
+	if err != nil {
+		return err
+	}
+
+	items := []types.TransactWriteItem{
+		{Update: &types.Update{
+			TableName:                 aws.String(r.tableName),
+			Key:                       promotionKey(code),
+			UpdateExpression:          promotionExpr.Update(),
+			ConditionExpression:       promotionExpr.Condition(),
+			ExpressionAttributeNames:  promotionExpr.Names(),
+			ExpressionAttributeValues: promotionExpr.Values(),
+		}},
+	}
+
+	// Guests' uses only count towards the code's total
+	if customerID != 0 {
+		builder := expression.NewBuilder().
+			WithUpdate(expression.Set(uses, expression.Plus(expression.IfNotExists(uses, expression.Value(0)), expression.Value(1))))
+		if promotion.MaxUsesPerCustomer > 0 {
+			builder = builder.WithCondition(expression.Or(
+				expression.AttributeNotExists(uses),
+				uses.LessThan(expression.Value(promotion.MaxUsesPerCustomer)),
+			))
+		}
+		customerExpr, err := builder.Build()
+		if err != nil {
+			return err
+		}
+
+		items = append(items, types.TransactWriteItem{Update: &types.Update{
+			TableName:                 aws.String(r.tableName),
+			Key:                       promotionKey(promotionRedemptionKey(code, customerID)),
+			UpdateExpression:          customerExpr.Update(),
+			ConditionExpression:       customerExpr.Condition(),
+			ExpressionAttributeNames:  customerExpr.Names(),
+			ExpressionAttributeValues: customerExpr.Values(),
+		}})
+	}
+
+	_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	var canceled *types.TransactionCanceledException
+	if !errors.As(err, &canceled) {
+		return err
+	}
+
+	reasons := canceled.CancellationReasons
+	if len(reasons) > 0 && aws.ToString(reasons[0].Code) == "ConditionalCheckFailed" {
+		// The promotion may have been deleted since it was read
+		if _, err := r.GetByCode(ctx, code); err != nil {
+			return err
+		}
+		return ErrPromotionUsedUp
+	}
+	if len(reasons) > 1 && aws.ToString(reasons[1].Code) == "ConditionalCheckFailed" {
+		return ErrPromotionCustomerUsedUp
+	}
+	return err
+}
+
+// Release undoes one redemption of the code by the customer. The two counts are
+// decremented separately, each no lower than zero.
+func (r *PromotionDynamoDBRepository) Release(ctx context.Context, code string, customerID int) error {
+	keys := []string{code}
+	if customerID != 0 {
+		keys = append(keys, promotionRedemptionKey(code, customerID))
+	}
+
+	uses := expression.Name("uses")
+	expr, err := expression.NewBuilder().
+		WithUpdate(expression.Set(uses, expression.Minus(uses, expression.Value(1)))).
+		WithCondition(uses.GreaterThan(expression.Value(0))).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(r.tableName),
+			Key:                       promotionKey(key),
+			UpdateExpression:          expr.Update(),
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+		})
+		if err != nil && !isConditionalCheckFailed(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns promotions in table scan order. Redemption counts, which have no type,
+// are filtered out, so a page may hold fewer than limit promotions even when more follow.
+func (r *PromotionDynamoDBRepository) List(ctx context.Context, cursor string, limit int) ([]models.Promotion, string, error) {
+	items, next, err := r.scan(ctx, expression.AttributeExists(expression.Name("type")), cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	promotions := []models.Promotion{}
+	if err := attributevalue.UnmarshalListOfMaps(items, &promotions); err != nil {
+		return nil, "", err
+	}
+	for i := range promotions {
+		promotions[i].StartsAt = promotions[i].StartsAt.UTC()
+		promotions[i].EndsAt = promotions[i].EndsAt.UTC()
+	}
+	return promotions, next, nil
+}
+
+// Put stores a promotion as given, uses included
+func (r *PromotionDynamoDBRepository) Put(ctx context.Context, promotion *models.Promotion) error {
+	item, err := attributevalue.MarshalMap(promotion)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	})
+	return err
+}
+
+// ListRedemptions returns redemptions in table scan order, so a page may hold fewer
+// than limit redemptions even when more follow
+func (r *PromotionDynamoDBRepository) ListRedemptions(ctx context.Context, cursor string, limit int) ([]models.Redemption, string, error) {
+	items, next, err := r.scan(ctx, expression.AttributeNotExists(expression.Name("type")), cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var records []promotionRedemptionRecord
+	if err := attributevalue.UnmarshalListOfMaps(items, &records); err != nil {
+		return nil, "", err
+	}
+
+	redemptions := make([]models.Redemption, 0, len(records))
+	for _, record := range records {
+		code, customerID, err := parseRedemptionCursor(record.Key)
+		if err != nil {
+			return nil, "", fmt.Errorf("redemption key %q: %w", record.Key, err)
+		}
+		redemptions = append(redemptions, models.Redemption{Code: code, CustomerID: customerID, Uses: record.Uses})
+	}
+	return redemptions, next, nil
+}
+
+// PutRedemption sets a customer's uses of a code
+func (r *PromotionDynamoDBRepository) PutRedemption(ctx context.Context, redemption *models.Redemption) error {
+	if _, err := r.GetByCode(ctx, redemption.Code); err != nil {
+		return err
+	}
+
+	item, err := attributevalue.MarshalMap(promotionRedemptionRecord{
+		Key:  promotionRedemptionKey(redemption.Code, redemption.CustomerID),
+		Uses: redemption.Uses,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	})
+	return err
+}
+
+// scan reads up to limit items of the table following cursor and returns those
+// matching filter with the cursor that follows them
+func (r *PromotionDynamoDBRepository) scan(ctx context.Context, filter expression.ConditionBuilder, cursor string, limit int) ([]map[string]types.AttributeValue, string, error) {
+	expr, err := expression.NewBuilder().WithFilter(filter).Build()
+	if err != nil {
+		return nil, "", err
+	}
+
+	result, err := r.client.Scan(ctx, &dynamodb.ScanInput{
+		TableName:                 aws.String(r.tableName),
+		Limit:                     aws.Int32(int32(limit)),
+		ExclusiveStartKey:         dynamoDBStringStartKey("code", cursor),
+		ConsistentRead:            aws.Bool(true),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return result.Items, dynamoDBStringCursor("code", result.LastEvaluatedKey), nil
+}
+
+func promotionKey(code string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"code": &types.AttributeValueMemberS{Value: code},
+	}
+}
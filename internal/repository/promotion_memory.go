@@ -0,0 +1,334 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+)
+
+var (
+	ErrPromotionNotFound = errors.New("promotion not found")
+	// ErrPromotionUsedUp means the code has been redeemed as often as it may be
+	ErrPromotionUsedUp = errors.New("promotion used up")
+	// ErrPromotionCustomerUsedUp means the customer has redeemed the code as often as they may
+	ErrPromotionCustomerUsedUp = errors.New("promotion used up by customer")
+)
+
+// redemptionKey identifies a customer's uses of a code
+type redemptionKey struct {
+	Code       string `json:"code"`
+	CustomerID int    `json:"customer_id"`
+}
+
+// redemption is the on-disk form of a customer's uses of a code
+type redemption struct {
+	redemptionKey
+	Uses int `json:"uses"`
+}
+
+type PromotionMemoryRepository struct {
+	promotions  map[string]*models.Promotion
+	redemptions map[redemptionKey]int
+	mu          sync.RWMutex
+	journal     *memoryJournal // nil unless persistent
+}
+
+// promotionSnapshot is the on-disk form of the whole repository
+type promotionSnapshot struct {
+	Promotions  []models.Promotion `json:"promotions"`
+	Redemptions []redemption       `json:"redemptions"`
+}
+
+// promotionEntry is one journal line: a promotion's state after a change and,
+// after a redemption or release, the customer's uses of its code
+type promotionEntry struct {
+	Promotion  models.Promotion `json:"promotion"`
+	Redemption *redemption      `json:"redemption,omitempty"`
+}
+
+func NewPromotionMemoryRepository() *PromotionMemoryRepository {
+	return &PromotionMemoryRepository{
+		promotions:  make(map[string]*models.Promotion),
+		redemptions: make(map[redemptionKey]int),
+	}
+}
+
+// NewPersistentPromotionMemoryRepository creates a memory repository that restores
+// its state from dir and journals every change there. With sync, each change is
+// flushed to disk before the call returns.
+func NewPersistentPromotionMemoryRepository(dir string, sync bool) (*PromotionMemoryRepository, error) {
+	r := NewPromotionMemoryRepository()
+
+	restore := func(data []byte) error {
+		var snapshot promotionSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return err
+		}
+		for i := range snapshot.Promotions {
+			r.promotions[snapshot.Promotions[i].Code] = &snapshot.Promotions[i]
+		}
+		for _, redemption := range snapshot.Redemptions {
+			r.redemptions[redemption.redemptionKey] = redemption.Uses
+		}
+		return nil
+	}
+	replay := func(data []byte) error {
+		var entry promotionEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return err
+		}
+		r.store(entry)
+		return nil
+	}
+
+	journal, err := openMemoryJournal(dir, "promotions", sync, restore, replay)
+	if err != nil {
+		return nil, err
+	}
+	r.journal = journal
+
+	return r, nil
+}
+
+// apply journals a change and then makes it in memory
+func (r *PromotionMemoryRepository) apply(entry promotionEntry) error {
+	if r.journal != nil {
+		if err := r.journal.append(entry); err != nil {
+			return err
+		}
+	}
+	r.store(entry)
+	return nil
+}
+
+// store makes a journalled change in memory
+func (r *PromotionMemoryRepository) store(entry promotionEntry) {
+	r.promotions[entry.Promotion.Code] = &entry.Promotion
+	if entry.Redemption != nil {
+		r.redemptions[entry.Redemption.redemptionKey] = entry.Redemption.Uses
+	}
+}
+
+// GetByCode retrieves a promotion by its coupon code
+func (r *PromotionMemoryRepository) GetByCode(ctx context.Context, code string) (*models.Promotion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	promotion, exists := r.promotions[code]
+	if !exists {
+		return nil, ErrPromotionNotFound
+	}
+
+	// Return a copy to prevent external modifications
+	promotionCopy := *promotion
+	return &promotionCopy, nil
+}
+
+// Upsert creates or updates a promotion, keeping its uses
+func (r *PromotionMemoryRepository) Upsert(ctx context.Context, promotion *models.Promotion) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Store a copy to prevent external modifications
+	promotionCopy := *promotion
+	promotionCopy.Uses = 0
+	if existing, exists := r.promotions[promotion.Code]; exists {
+		promotionCopy.Uses = existing.Uses
+	}
+
+	return r.apply(promotionEntry{Promotion: promotionCopy})
+}
+
+// CustomerUses returns how many times the customer has redeemed the code
+func (r *PromotionMemoryRepository) CustomerUses(ctx context.Context, code string, customerID int) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.redemptions[redemptionKey{Code: code, CustomerID: customerID}], nil
+}
+
+// Redeem records one use of the code by the customer unless its limits are reached
+func (r *PromotionMemoryRepository) Redeem(ctx context.Context, code string, customerID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	promotion, exists := r.promotions[code]
+	if !exists {
+		return ErrPromotionNotFound
+	}
+	if promotion.MaxUses > 0 && promotion.Uses >= promotion.MaxUses {
+		return ErrPromotionUsedUp
+	}
+
+	updated := *promotion
+	updated.Uses++
+	entry := promotionEntry{Promotion: updated}
+
+	// Guests' uses only count towards the code's total
+	if customerID != 0 {
+		key := redemptionKey{Code: code, CustomerID: customerID}
+		uses := r.redemptions[key]
+		if promotion.MaxUsesPerCustomer > 0 && uses >= promotion.MaxUsesPerCustomer {
+			return ErrPromotionCustomerUsedUp
+		}
+		entry.Redemption = &redemption{redemptionKey: key, Uses: uses + 1}
+	}
+
+	return r.apply(entry)
+}
+
+// Release undoes one redemption of the code by the customer
+func (r *PromotionMemoryRepository) Release(ctx context.Context, code string, customerID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	promotion, exists := r.promotions[code]
+	if !exists {
+		return nil
+	}
+
+	updated := *promotion
+	updated.Uses = max(updated.Uses-1, 0)
+	entry := promotionEntry{Promotion: updated}
+	if customerID != 0 {
+		key := redemptionKey{Code: code, CustomerID: customerID}
+		entry.Redemption = &redemption{redemptionKey: key, Uses: max(r.redemptions[key]-1, 0)}
+	}
+
+	return r.apply(entry)
+}
+
+// List returns promotions in code order
+func (r *PromotionMemoryRepository) List(ctx context.Context, cursor string, limit int) ([]models.Promotion, string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	codes := make([]string, 0, len(r.promotions))
+	for code := range r.promotions {
+		if code > cursor {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	codes = codes[:min(limit, len(codes))]
+
+	promotions := make([]models.Promotion, len(codes))
+	for i, code := range codes {
+		promotions[i] = *r.promotions[code]
+	}
+	if len(promotions) == 0 {
+		return promotions, "", nil
+	}
+	return promotions, nextCodeCursor(codes[len(codes)-1], len(codes), limit), nil
+}
+
+// Put stores a promotion as given, uses included
+func (r *PromotionMemoryRepository) Put(ctx context.Context, promotion *models.Promotion) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.apply(promotionEntry{Promotion: *promotion})
+}
+
+// ListRedemptions returns redemptions in code and then customer order
+func (r *PromotionMemoryRepository) ListRedemptions(ctx context.Context, cursor string, limit int) ([]models.Redemption, string, error) {
+	code, customerID, err := parseRedemptionCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	after := redemptionKey{Code: code, CustomerID: customerID}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]redemptionKey, 0, len(r.redemptions))
+	for key := range r.redemptions {
+		if redemptionBefore(after, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return redemptionBefore(keys[i], keys[j]) })
+	keys = keys[:min(limit, len(keys))]
+
+	redemptions := make([]models.Redemption, len(keys))
+	for i, key := range keys {
+		redemptions[i] = models.Redemption{Code: key.Code, CustomerID: key.CustomerID, Uses: r.redemptions[key]}
+	}
+	if len(redemptions) == 0 {
+		return redemptions, "", nil
+	}
+	return redemptions, nextRedemptionCursor(redemptions[len(redemptions)-1], len(redemptions), limit), nil
+}
+
+// PutRedemption sets a customer's uses of a code
+func (r *PromotionMemoryRepository) PutRedemption(ctx context.Context, uses *models.Redemption) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	promotion, exists := r.promotions[uses.Code]
+	if !exists {
+		return ErrPromotionNotFound
+	}
+
+	key := redemptionKey{Code: uses.Code, CustomerID: uses.CustomerID}
+	return r.apply(promotionEntry{
+		Promotion:  *promotion,
+		Redemption: &redemption{redemptionKey: key, Uses: uses.Uses},
+	})
+}
+
+// redemptionBefore reports whether a sorts before b in code and then customer order
+func redemptionBefore(a, b redemptionKey) bool {
+	return a.Code < b.Code || a.Code == b.Code && a.CustomerID < b.CustomerID
+}
+
+// Compact writes a snapshot of every promotion and redemption and empties the journal.
+// It does nothing for a repository that is not persistent.
+func (r *PromotionMemoryRepository) Compact() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.journal == nil {
+		return nil
+	}
+
+	snapshot := promotionSnapshot{
+		Promotions:  make([]models.Promotion, 0, len(r.promotions)),
+		Redemptions: make([]redemption, 0, len(r.redemptions)),
+	}
+	for _, promotion := range r.promotions {
+		snapshot.Promotions = append(snapshot.Promotions, *promotion)
+	}
+	sort.Slice(snapshot.Promotions, func(i, j int) bool {
+		return snapshot.Promotions[i].Code < snapshot.Promotions[j].Code
+	})
+	for key, uses := range r.redemptions {
+		snapshot.Redemptions = append(snapshot.Redemptions, redemption{redemptionKey: key, Uses: uses})
+	}
+	sort.Slice(snapshot.Redemptions, func(i, j int) bool {
+		return redemptionBefore(snapshot.Redemptions[i].redemptionKey, snapshot.Redemptions[j].redemptionKey)
+	})
+
+	return r.journal.compact(snapshot)
+}
+
+// Close compacts and closes the journal of a persistent repository
+func (r *PromotionMemoryRepository) Close() error {
+	if err := r.Compact(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.journal == nil {
+		return nil
+	}
+	err := r.journal.close()
+	r.journal = nil
+	return err
+}
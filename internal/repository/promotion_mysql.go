@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+)
+
+type PromotionMySQLRepository struct {
+	db *sql.DB
+}
+
+func NewPromotionMySQLRepository(db *sql.DB) *PromotionMySQLRepository {
+	return &PromotionMySQLRepository{
+		db: db,
+	}
+}
+
+func (r *PromotionMySQLRepository) statements() promotionStatements {
+	return promotionStatements{
+		get: `
+			SELECT code, type, percent_off, amount_off, buy_quantity, get_quantity, category_id, product_id, starts_at, ends_at, max_uses, max_uses_per_customer, uses
+			FROM promotions
+			WHERE code = ?
+		`,
+		upsert: `
+			INSERT INTO promotions (code, type, percent_off, amount_off, buy_quantity, get_quantity, category_id, product_id, starts_at, ends_at, max_uses, max_uses_per_customer)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE
+				type = VALUES(type),
+				percent_off = VALUES(percent_off),
+				amount_off = VALUES(amount_off),
+				buy_quantity = VALUES(buy_quantity),
+				get_quantity = VALUES(get_quantity),
+				category_id = VALUES(category_id),
+				product_id = VALUES(product_id),
+				starts_at = VALUES(starts_at),
+				ends_at = VALUES(ends_at),
+				max_uses = VALUES(max_uses),
+				max_uses_per_customer = VALUES(max_uses_per_customer)
+		`,
+		customerUses: `SELECT uses FROM promotion_redemptions WHERE code = ? AND customer_id = ?`,
+		redeem: `
+			UPDATE promotions SET uses = uses + 1
+			WHERE code = ? AND (max_uses = 0 OR uses < max_uses)
+		`,
+		redeemCustomer: `
+			INSERT INTO promotion_redemptions (code, customer_id, uses)
+			VALUES (?, ?, 1)
+			ON DUPLICATE KEY UPDATE uses = uses + 1
+		`,
+		release: `UPDATE promotions SET uses = uses - 1 WHERE code = ? AND uses > 0`,
+		releaseCustomer: `
+			UPDATE promotion_redemptions SET uses = uses - 1
+			WHERE code = ? AND customer_id = ? AND uses > 0
+		`,
+		list: `
+			SELECT code, type, percent_off, amount_off, buy_quantity, get_quantity, category_id, product_id, starts_at, ends_at, max_uses, max_uses_per_customer, uses
+			FROM promotions
+			WHERE code > ?
+			ORDER BY code
+			LIMIT ?
+		`,
+		put: `
+			INSERT INTO promotions (code, type, percent_off, amount_off, buy_quantity, get_quantity, category_id, product_id, starts_at, ends_at, max_uses, max_uses_per_customer, uses)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE
+				type = VALUES(type),
+				percent_off = VALUES(percent_off),
+				amount_off = VALUES(amount_off),
+				buy_quantity = VALUES(buy_quantity),
+				get_quantity = VALUES(get_quantity),
+				category_id = VALUES(category_id),
+				product_id = VALUES(product_id),
+				starts_at = VALUES(starts_at),
+				ends_at = VALUES(ends_at),
+				max_uses = VALUES(max_uses),
+				max_uses_per_customer = VALUES(max_uses_per_customer),
+				uses = VALUES(uses)
+		`,
+		listRedemptions: `
+			SELECT code, customer_id, uses
+			FROM promotion_redemptions
+			WHERE code > ? OR (code = ? AND customer_id > ?)
+			ORDER BY code, customer_id
+			LIMIT ?
+		`,
+		putRedemption: `
+			INSERT INTO promotion_redemptions (code, customer_id, uses)
+			VALUES (?, ?, ?)
+			ON DUPLICATE KEY UPDATE uses = VALUES(uses)
+		`,
+	}
+}
+
+// GetByCode retrieves a promotion by its coupon code
+func (r *PromotionMySQLRepository) GetByCode(ctx context.Context, code string) (*models.Promotion, error) {
+	return getPromotionSQL(ctx, r.db, r.statements(), code)
+}
+
+// Upsert creates or updates a promotion, keeping its uses
+func (r *PromotionMySQLRepository) Upsert(ctx context.Context, promotion *models.Promotion) error {
+	return upsertPromotionSQL(ctx, r.db, r.statements(), promotion)
+}
+
+// CustomerUses returns how many times the customer has redeemed the code
+func (r *PromotionMySQLRepository) CustomerUses(ctx context.Context, code string, customerID int) (int, error) {
+	return customerUsesSQL(ctx, r.db, r.statements(), code, customerID)
+}
+
+// Redeem records one use of the code by the customer unless its limits are reached
+func (r *PromotionMySQLRepository) Redeem(ctx context.Context, code string, customerID int) error {
+	return redeemPromotionSQL(ctx, r.db, r.statements(), code, customerID)
+}
+
+// Release undoes one redemption of the code by the customer
+func (r *PromotionMySQLRepository) Release(ctx context.Context, code string, customerID int) error {
+	return releasePromotionSQL(ctx, r.db, r.statements(), code, customerID)
+}
+
+// List returns promotions in code order
+func (r *PromotionMySQLRepository) List(ctx context.Context, cursor string, limit int) ([]models.Promotion, string, error) {
+	return listPromotionsSQL(ctx, r.db, r.statements(), cursor, limit)
+}
+
+// Put stores a promotion as given, uses included
+func (r *PromotionMySQLRepository) Put(ctx context.Context, promotion *models.Promotion) error {
+	return putPromotionSQL(ctx, r.db, r.statements(), promotion)
+}
+
+// ListRedemptions returns redemptions in code and then customer order
+func (r *PromotionMySQLRepository) ListRedemptions(ctx context.Context, cursor string, limit int) ([]models.Redemption, string, error) {
+	return listRedemptionsSQL(ctx, r.db, r.statements(), cursor, limit)
+}
+
+// PutRedemption sets a customer's uses of a code
+func (r *PromotionMySQLRepository) PutRedemption(ctx context.Context, redemption *models.Redemption) error {
+	return putRedemptionSQL(ctx, r.db, r.statements(), redemption)
+}
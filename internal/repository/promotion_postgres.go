@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+)
+
+type PromotionPostgresRepository struct {
+	db *sql.DB
+}
+
+func NewPromotionPostgresRepository(db *sql.DB) *PromotionPostgresRepository {
+	return &PromotionPostgresRepository{
+		db: db,
+	}
+}
+
+func (r *PromotionPostgresRepository) statements() promotionStatements {
+	return promotionStatements{
+		get: `
+			SELECT code, type, percent_off, amount_off, buy_quantity, get_quantity, category_id, product_id, starts_at, ends_at, max_uses, max_uses_per_customer, uses
+			FROM promotions
+			WHERE code = $1
+		`,
+		upsert: `
+			INSERT INTO promotions (code, type, percent_off, amount_off, buy_quantity, get_quantity, category_id, product_id, starts_at, ends_at, max_uses, max_uses_per_customer)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			ON CONFLICT (code) DO UPDATE SET
+				type = EXCLUDED.type,
+				percent_off = EXCLUDED.percent_off,
+				amount_off = EXCLUDED.amount_off,
+				buy_quantity = EXCLUDED.buy_quantity,
+				get_quantity = EXCLUDED.get_quantity,
+				category_id = EXCLUDED.category_id,
+				product_id = EXCLUDED.product_id,
+				starts_at = EXCLUDED.starts_at,
+				ends_at = EXCLUDED.ends_at,
+				max_uses = EXCLUDED.max_uses,
+				max_uses_per_customer = EXCLUDED.max_uses_per_customer,
+				updated_at = now()
+		`,
+		customerUses: `SELECT uses FROM promotion_redemptions WHERE code = $1 AND customer_id = $2`,
+		redeem: `
+			UPDATE promotions SET uses = uses + 1
+			WHERE code = $1 AND (max_uses = 0 OR uses < max_uses)
+		`,
+		redeemCustomer: `
+			INSERT INTO promotion_redemptions (code, customer_id, uses)
+			VALUES ($1, $2, 1)
+			ON CONFLICT (code, customer_id) DO UPDATE SET uses = promotion_redemptions.uses + 1
+		`,
+		release: `UPDATE promotions SET uses = uses - 1 WHERE code = $1 AND uses > 0`,
+		releaseCustomer: `
+			UPDATE promotion_redemptions SET uses = uses - 1
+			WHERE code = $1 AND customer_id = $2 AND uses > 0
+		`,
+		list: `
+			SELECT code, type, percent_off, amount_off, buy_quantity, get_quantity, category_id, product_id, starts_at, ends_at, max_uses, max_uses_per_customer, uses
+			FROM promotions
+			WHERE code > $1
+			ORDER BY code
+			LIMIT $2
+		`,
+		put: `
+			INSERT INTO promotions (code, type, percent_off, amount_off, buy_quantity, get_quantity, category_id, product_id, starts_at, ends_at, max_uses, max_uses_per_customer, uses)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			ON CONFLICT (code) DO UPDATE SET
+				type = EXCLUDED.type,
+				percent_off = EXCLUDED.percent_off,
+				amount_off = EXCLUDED.amount_off,
+				buy_quantity = EXCLUDED.buy_quantity,
+				get_quantity = EXCLUDED.get_quantity,
+				category_id = EXCLUDED.category_id,
+				product_id = EXCLUDED.product_id,
+				starts_at = EXCLUDED.starts_at,
+				ends_at = EXCLUDED.ends_at,
+				max_uses = EXCLUDED.max_uses,
+				max_uses_per_customer = EXCLUDED.max_uses_per_customer,
+				uses = EXCLUDED.uses,
+				updated_at = now()
+		`,
+		listRedemptions: `
+			SELECT code, customer_id, uses
+			FROM promotion_redemptions
+			WHERE code > $1 OR (code = $2 AND customer_id > $3)
+			ORDER BY code, customer_id
+			LIMIT $4
+		`,
+		putRedemption: `
+			INSERT INTO promotion_redemptions (code, customer_id, uses)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (code, customer_id) DO UPDATE SET uses = EXCLUDED.uses
+		`,
+	}
+}
+
+// GetByCode retrieves a promotion by its coupon code
+func (r *PromotionPostgresRepository) GetByCode(ctx context.Context, code string) (*models.Promotion, error) {
+	return getPromotionSQL(ctx, r.db, r.statements(), code)
+}
+
+// Upsert creates or updates a promotion, keeping its uses
+func (r *PromotionPostgresRepository) Upsert(ctx context.Context, promotion *models.Promotion) error {
+	return upsertPromotionSQL(ctx, r.db, r.statements(), promotion)
+}
+
+// CustomerUses returns how many times the customer has redeemed the code
+func (r *PromotionPostgresRepository) CustomerUses(ctx context.Context, code string, customerID int) (int, error) {
+	return customerUsesSQL(ctx, r.db, r.statements(), code, customerID)
+}
+
+// Redeem records one use of the code by the customer unless its limits are reached
+func (r *PromotionPostgresRepository) Redeem(ctx context.Context, code string, customerID int) error {
+	return redeemPromotionSQL(ctx, r.db, r.statements(), code, customerID)
+}
+
+// Release undoes one redemption of the code by the customer
+func (r *PromotionPostgresRepository) Release(ctx context.Context, code string, customerID int) error {
+	return releasePromotionSQL(ctx, r.db, r.statements(), code, customerID)
+}
+
+// List returns promotions in code order
+func (r *PromotionPostgresRepository) List(ctx context.Context, cursor string, limit int) ([]models.Promotion, string, error) {
+	return listPromotionsSQL(ctx, r.db, r.statements(), cursor, limit)
+}
+
+// Put stores a promotion as given, uses included
+func (r *PromotionPostgresRepository) Put(ctx context.Context, promotion *models.Promotion) error {
+	return putPromotionSQL(ctx, r.db, r.statements(), promotion)
+}
+
+// ListRedemptions returns redemptions in code and then customer order
+func (r *PromotionPostgresRepository) ListRedemptions(ctx context.Context, cursor string, limit int) ([]models.Redemption, string, error) {
+	return listRedemptionsSQL(ctx, r.db, r.statements(), cursor, limit)
+}
+
+// PutRedemption sets a customer's uses of a code
+func (r *PromotionPostgresRepository) PutRedemption(ctx context.Context, redemption *models.Redemption) error {
+	return putRedemptionSQL(ctx, r.db, r.statements(), redemption)
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+)
+
+type PromotionSQLiteRepository struct {
+	db *sql.DB
+}
+
+func NewPromotionSQLiteRepository(db *sql.DB) *PromotionSQLiteRepository {
+	return &PromotionSQLiteRepository{
+		db: db,
+	}
+}
+
+func (r *PromotionSQLiteRepository) statements() promotionStatements {
+	return promotionStatements{
+		get: `
+			SELECT code, type, percent_off, amount_off, buy_quantity, get_quantity, category_id, product_id, starts_at, ends_at, max_uses, max_uses_per_customer, uses
+			FROM promotions
+			WHERE code = ?
+		`,
+		upsert: `
+			INSERT INTO promotions (code, type, percent_off, amount_off, buy_quantity, get_quantity, category_id, product_id, starts_at, ends_at, max_uses, max_uses_per_customer)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (code) DO UPDATE SET
+				type = excluded.type,
+				percent_off = excluded.percent_off,
+				amount_off = excluded.amount_off,
+				buy_quantity = excluded.buy_quantity,
+				get_quantity = excluded.get_quantity,
+				category_id = excluded.category_id,
+				product_id = excluded.product_id,
+				starts_at = excluded.starts_at,
+				ends_at = excluded.ends_at,
+				max_uses = excluded.max_uses,
+				max_uses_per_customer = excluded.max_uses_per_customer,
+				updated_at = CURRENT_TIMESTAMP
+		`,
+		customerUses: `SELECT uses FROM promotion_redemptions WHERE code = ? AND customer_id = ?`,
+		redeem: `
+			UPDATE promotions SET uses = uses + 1
+			WHERE code = ? AND (max_uses = 0 OR uses < max_uses)
+		`,
+		redeemCustomer: `
+			INSERT INTO promotion_redemptions (code, customer_id, uses)
+			VALUES (?, ?, 1)
+			ON CONFLICT (code, customer_id) DO UPDATE SET uses = promotion_redemptions.uses + 1
+		`,
+		release: `UPDATE promotions SET uses = uses - 1 WHERE code = ? AND uses > 0`,
+		releaseCustomer: `
+			UPDATE promotion_redemptions SET uses = uses - 1
+			WHERE code = ? AND customer_id = ? AND uses > 0
+		`,
+		list: `
+			SELECT code, type, percent_off, amount_off, buy_quantity, get_quantity, category_id, product_id, starts_at, ends_at, max_uses, max_uses_per_customer, uses
+			FROM promotions
+			WHERE code > ?
+			ORDER BY code
+			LIMIT ?
+		`,
+		put: `
+			INSERT INTO promotions (code, type, percent_off, amount_off, buy_quantity, get_quantity, category_id, product_id, starts_at, ends_at, max_uses, max_uses_per_customer, uses)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (code) DO UPDATE SET
+				type = excluded.type,
+				percent_off = excluded.percent_off,
+				amount_off = excluded.amount_off,
+				buy_quantity = excluded.buy_quantity,
+				get_quantity = excluded.get_quantity,
+				category_id = excluded.category_id,
+				product_id = excluded.product_id,
+				starts_at = excluded.starts_at,
+				ends_at = excluded.ends_at,
+				max_uses = excluded.max_uses,
+				max_uses_per_customer = excluded.max_uses_per_customer,
+				uses = excluded.uses,
+				updated_at = CURRENT_TIMESTAMP
+		`,
+		listRedemptions: `
+			SELECT code, customer_id, uses
+			FROM promotion_redemptions
+			WHERE code > ? OR (code = ? AND customer_id > ?)
+			ORDER BY code, customer_id
+			LIMIT ?
+		`,
+		putRedemption: `
+			INSERT INTO promotion_redemptions (code, customer_id, uses)
+			VALUES (?, ?, ?)
+			ON CONFLICT (code, customer_id) DO UPDATE SET uses = excluded.uses
+		`,
+	}
+}
+
+// GetByCode retrieves a promotion by its coupon code
+func (r *PromotionSQLiteRepository) GetByCode(ctx context.Context, code string) (*models.Promotion, error) {
+	return getPromotionSQL(ctx, r.db, r.statements(), code)
+}
+
+// Upsert creates or updates a promotion, keeping its uses
+func (r *PromotionSQLiteRepository) Upsert(ctx context.Context, promotion *models.Promotion) error {
+	return upsertPromotionSQL(ctx, r.db, r.statements(), promotion)
+}
+
+// CustomerUses returns how many times the customer has redeemed the code
+func (r *PromotionSQLiteRepository) CustomerUses(ctx context.Context, code string, customerID int) (int, error) {
+	return customerUsesSQL(ctx, r.db, r.statements(), code, customerID)
+}
+
+// Redeem records one use of the code by the customer unless its limits are reached
+func (r *PromotionSQLiteRepository) Redeem(ctx context.Context, code string, customerID int) error {
+	return redeemPromotionSQL(ctx, r.db, r.statements(), code, customerID)
+}
+
+// Release undoes one redemption of the code by the customer
+func (r *PromotionSQLiteRepository) Release(ctx context.Context, code string, customerID int) error {
+	return releasePromotionSQL(ctx, r.db, r.statements(), code, customerID)
+}
+
+// List returns promotions in code order
+func (r *PromotionSQLiteRepository) List(ctx context.Context, cursor string, limit int) ([]models.Promotion, string, error) {
+	return listPromotionsSQL(ctx, r.db, r.statements(), cursor, limit)
+}
+
+// Put stores a promotion as given, uses included
+func (r *PromotionSQLiteRepository) Put(ctx context.Context, promotion *models.Promotion) error {
+	return putPromotionSQL(ctx, r.db, r.statements(), promotion)
+}
+
+// ListRedemptions returns redemptions in code and then customer order
+func (r *PromotionSQLiteRepository) ListRedemptions(ctx context.Context, cursor string, limit int) ([]models.Redemption, string, error) {
+	return listRedemptionsSQL(ctx, r.db, r.statements(), cursor, limit)
+}
+
+// PutRedemption sets a customer's uses of a code
+func (r *PromotionSQLiteRepository) PutRedemption(ctx context.Context, redemption *models.Redemption) error {
+	return putRedemptionSQL(ctx, r.db, r.statements(), redemption)
+}
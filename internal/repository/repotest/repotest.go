@@ -0,0 +1,1662 @@
+// Package repotest is a conformance suite for repository implementations.
+//
+// Every backend runs the same tests so they agree on the contract:
+//   - Missing records are reported with repository.ErrProductNotFound and
+//     repository.ErrCartNotFound, including AddItem and AddItems on a missing cart.
+//   - Cart repositories do not check that products exist; that is the service's job.
+//   - Adding a product already in a cart adds to its quantity.
+//   - Values passed in and returned are copies; mutating them does not change stored data.
+//   - Concurrent calls do not lose updates.
+//   - List pages through every record exactly once, in an order of the backend's choosing.
+//   - Put keeps the cart's ID, and carts created afterwards never reuse it.
+//   - Cart times are kept to the second; a zero expiry means the cart never expires.
+//   - A guest cart's token hash is stored and returned like any other field.
+//   - A customer has at most one active cart, even when it is created concurrently.
+//   - Of several callers marking an abandoned cart as reminded, only the first succeeds.
+//   - A cart's coupon codes keep the order they were added in, each once.
+//   - A cart's shipping address is stored whole and cleared with nil.
+//   - A cart's shipping option ID is stored as given and cleared with "".
+//   - A cart keeps the currency it was created with; CreateActive returns an existing
+//     active cart whatever its currency.
+//   - Promotion redemptions never exceed their limits, even when made concurrently.
+package repotest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+)
+
+// concurrency is the number of goroutines used by the concurrency tests
+const concurrency = 16
+
+// expiresIn returns an expiry d from now, kept to the second as the backends store it
+func expiresIn(d time.Duration) time.Time {
+	return time.Now().Add(d).UTC().Truncate(time.Second)
+}
+
+// ProductFactory returns an empty repository for one test
+type ProductFactory func(t *testing.T) repository.ProductRepository
+
+// CartFactory returns an empty repository for one test
+type CartFactory func(t *testing.T) repository.CartRepository
+
+// PromotionFactory returns an empty repository for one test
+type PromotionFactory func(t *testing.T) repository.PromotionRepository
+
+// TestProductRepository runs the product conformance tests against repositories from newRepo
+func TestProductRepository(t *testing.T, newRepo ProductFactory) {
+	t.Run("GetByIDMissing", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.GetByID(t.Context(), 404)
+		if !errors.Is(err, repository.ErrProductNotFound) {
+			t.Fatalf("GetByID(missing) error = %v, want ErrProductNotFound", err)
+		}
+	})
+
+	t.Run("UpsertThenGet", func(t *testing.T) {
+		repo := newRepo(t)
+		want := product(1)
+
+		mustUpsert(t, repo, &want)
+		got := mustGetProduct(t, repo, 1)
+		if !reflect.DeepEqual(*got, want) {
+			t.Fatalf("GetByID = %+v, want %+v", *got, want)
+		}
+	})
+
+	t.Run("UpsertReplaces", func(t *testing.T) {
+		repo := newRepo(t)
+		first := product(1)
+		mustUpsert(t, repo, &first)
+
+		second := product(1)
+		second.SKU = "SKU-1-v2"
+		second.Manufacturer = "Other Corp"
+		second.CategoryID = 9
+		second.Weight = 900
+		second.Price = 4999
+		second.SomeOtherID = 99
+		second.PurchaseLimit = 0 // clearing the limit must not keep the old one
+		second.Prices = nil      // nor clearing the price list the old prices
+		mustUpsert(t, repo, &second)
+
+		got := mustGetProduct(t, repo, 1)
+		if !reflect.DeepEqual(*got, second) {
+			t.Fatalf("GetByID after second Upsert = %+v, want %+v", *got, second)
+		}
+	})
+
+	t.Run("Exists", func(t *testing.T) {
+		repo := newRepo(t)
+		p := product(1)
+		mustUpsert(t, repo, &p)
+
+		for id, want := range map[int]bool{1: true, 2: false} {
+			got, err := repo.Exists(t.Context(), id)
+			if err != nil {
+				t.Fatalf("Exists(%d): %v", id, err)
+			}
+			if got != want {
+				t.Errorf("Exists(%d) = %v, want %v", id, got, want)
+			}
+		}
+	})
+
+	t.Run("GetByIDs", func(t *testing.T) {
+		repo := newRepo(t)
+		// More products than DynamoDB returns from one batch
+		const stored = 120
+		for i := range stored {
+			p := product(i + 1)
+			mustUpsert(t, repo, &p)
+		}
+
+		// Missing and repeated IDs are asked for along with the stored ones
+		ids := []int{1, 1}
+		for id := 1; id <= stored+10; id++ {
+			ids = append(ids, id)
+		}
+		got, err := repo.GetByIDs(t.Context(), ids)
+		if err != nil {
+			t.Fatalf("GetByIDs: %v", err)
+		}
+		if len(got) != stored {
+			t.Fatalf("GetByIDs returned %d products, want %d", len(got), stored)
+		}
+		seen := make(map[int]bool)
+		for _, p := range got {
+			if want := product(p.ProductID); !reflect.DeepEqual(p, want) || seen[p.ProductID] {
+				t.Fatalf("GetByIDs returned %+v, want each stored product once", p)
+			}
+			seen[p.ProductID] = true
+		}
+
+		if none, err := repo.GetByIDs(t.Context(), []int{stored + 1}); err != nil || len(none) != 0 {
+			t.Fatalf("GetByIDs of a missing product = %v, %v; want no products", none, err)
+		}
+	})
+
+	t.Run("CopyIsolation", func(t *testing.T) {
+		repo := newRepo(t)
+		want := product(1)
+		in := want
+		mustUpsert(t, repo, &in)
+
+		// Changing the value passed to Upsert must not change what was stored
+		in.SKU = "mutated-input"
+		in.Prices["EUR"] = 1
+		want.Prices = product(1).Prices
+		got := mustGetProduct(t, repo, 1)
+		if !reflect.DeepEqual(*got, want) {
+			t.Fatalf("stored product changed with Upsert argument: %+v", *got)
+		}
+
+		// Changing a returned value must not change what is stored
+		got.SKU = "mutated-output"
+		got.Prices["EUR"] = 2
+		again := mustGetProduct(t, repo, 1)
+		if !reflect.DeepEqual(*again, want) {
+			t.Fatalf("stored product changed with GetByID result: %+v", *again)
+		}
+	})
+
+	t.Run("ConcurrentUpserts", func(t *testing.T) {
+		repo := newRepo(t)
+
+		run(t, concurrency, func(i int) error {
+			p := product(i + 1)
+			return repo.Upsert(t.Context(), &p)
+		})
+
+		for i := range concurrency {
+			want := product(i + 1)
+			if got := mustGetProduct(t, repo, i+1); !reflect.DeepEqual(*got, want) {
+				t.Errorf("GetByID(%d) = %+v, want %+v", i+1, *got, want)
+			}
+		}
+	})
+
+	t.Run("ListPages", func(t *testing.T) {
+		repo := newRepo(t)
+		for id := 1; id <= 7; id++ {
+			p := product(id * 10)
+			mustUpsert(t, repo, &p)
+		}
+
+		got := make(map[int]models.Product)
+		cursor, pages := "", 0
+		for {
+			page, next, err := repo.List(t.Context(), cursor, 3)
+			if err != nil {
+				t.Fatalf("List(%q): %v", cursor, err)
+			}
+			if len(page) > 3 {
+				t.Fatalf("List returned %d products, limit 3", len(page))
+			}
+			for _, p := range page {
+				if _, dup := got[p.ProductID]; dup {
+					t.Fatalf("List returned product %d twice", p.ProductID)
+				}
+				got[p.ProductID] = p
+			}
+			if pages++; pages > 10 {
+				t.Fatal("List did not finish after 10 pages")
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+
+		if len(got) != 7 {
+			t.Fatalf("List returned %d products, want 7", len(got))
+		}
+		for id := 1; id <= 7; id++ {
+			if want := product(id * 10); !reflect.DeepEqual(got[id*10], want) {
+				t.Errorf("listed product %d = %+v, want %+v", id*10, got[id*10], want)
+			}
+		}
+	})
+
+	t.Run("ListEmpty", func(t *testing.T) {
+		repo := newRepo(t)
+
+		page, next, err := repo.List(t.Context(), "", 10)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(page) != 0 || next != "" {
+			t.Fatalf("List on an empty repository = %v, %q; want nothing", page, next)
+		}
+	})
+}
+
+// TestCartRepository runs the cart conformance tests against repositories from newRepo
+func TestCartRepository(t *testing.T, newRepo CartFactory) {
+	t.Run("CreateThenGet", func(t *testing.T) {
+		repo := newRepo(t)
+
+		created := mustCreate(t, repo, 42)
+		if created.CustomerID != 42 {
+			t.Errorf("Create CustomerID = %d, want 42", created.CustomerID)
+		}
+		if created.Items == nil || len(created.Items) != 0 {
+			t.Errorf("Create Items = %#v, want empty non-nil slice", created.Items)
+		}
+
+		got := mustGetCart(t, repo, created.CartID)
+		if got.CartID != created.CartID || got.CustomerID != 42 {
+			t.Errorf("GetByID = %+v, want cart %d for customer 42", got, created.CartID)
+		}
+		if got.Items == nil || len(got.Items) != 0 {
+			t.Errorf("GetByID Items = %#v, want empty non-nil slice", got.Items)
+		}
+	})
+
+	t.Run("CreateAssignsDistinctIDs", func(t *testing.T) {
+		repo := newRepo(t)
+
+		first := mustCreate(t, repo, 1)
+		second := mustCreate(t, repo, 1)
+		if first.CartID == second.CartID {
+			t.Fatalf("two carts share ID %d", first.CartID)
+		}
+	})
+
+	t.Run("GetByIDMissing", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.GetByID(t.Context(), 404)
+		if !errors.Is(err, repository.ErrCartNotFound) {
+			t.Fatalf("GetByID(missing) error = %v, want ErrCartNotFound", err)
+		}
+	})
+
+	t.Run("AddItemMissingCart", func(t *testing.T) {
+		repo := newRepo(t)
+
+		err := repo.AddItem(t.Context(), 404, models.CartItem{ProductID: 1, Quantity: 1}, expiresIn(time.Hour))
+		if !errors.Is(err, repository.ErrCartNotFound) {
+			t.Fatalf("AddItem(missing cart) error = %v, want ErrCartNotFound", err)
+		}
+	})
+
+	t.Run("AddItemUnknownProduct", func(t *testing.T) {
+		repo := newRepo(t)
+		cart := mustCreate(t, repo, 1)
+
+		// Product existence is checked by the service, not the repository
+		if err := repo.AddItem(t.Context(), cart.CartID, models.CartItem{ProductID: 987654, Quantity: 1}, expiresIn(time.Hour)); err != nil {
+			t.Fatalf("AddItem(unknown product): %v", err)
+		}
+		assertItems(t, mustGetCart(t, repo, cart.CartID), map[int]int{987654: 1})
+	})
+
+	t.Run("AddItemMergesQuantity", func(t *testing.T) {
+		repo := newRepo(t)
+		cart := mustCreate(t, repo, 1)
+
+		mustAddItem(t, repo, cart.CartID, 1, 2)
+		mustAddItem(t, repo, cart.CartID, 2, 1)
+		mustAddItem(t, repo, cart.CartID, 1, 3)
+
+		assertItems(t, mustGetCart(t, repo, cart.CartID), map[int]int{1: 5, 2: 1})
+	})
+
+	t.Run("AddItems", func(t *testing.T) {
+		repo := newRepo(t)
+		cart := mustCreate(t, repo, 1)
+		mustAddItem(t, repo, cart.CartID, 1, 2)
+
+		items := []models.CartItem{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 4}, {ProductID: 2, Quantity: 1}}
+		if err := repo.AddItems(t.Context(), cart.CartID, items, expiresIn(time.Hour)); err != nil {
+			t.Fatalf("AddItems: %v", err)
+		}
+
+		assertItems(t, mustGetCart(t, repo, cart.CartID), map[int]int{1: 3, 2: 5})
+	})
+
+	t.Run("AddItemsMissingCart", func(t *testing.T) {
+		repo := newRepo(t)
+
+		items := []models.CartItem{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 1}}
+		err := repo.AddItems(t.Context(), 404, items, expiresIn(time.Hour))
+		if !errors.Is(err, repository.ErrCartNotFound) {
+			t.Fatalf("AddItems(missing cart) error = %v, want ErrCartNotFound", err)
+		}
+	})
+
+	t.Run("Coupons", func(t *testing.T) {
+		repo := newRepo(t)
+		cart := mustCreate(t, repo, 1)
+
+		for _, code := range []string{"SPRING", "BULK", "SPRING"} {
+			if err := repo.AddCoupon(t.Context(), cart.CartID, code); err != nil {
+				t.Fatalf("AddCoupon(%s): %v", code, err)
+			}
+		}
+		assertCoupons(t, mustGetCart(t, repo, cart.CartID), "SPRING", "BULK")
+
+		for _, code := range []string{"SPRING", "UNKNOWN"} {
+			if err := repo.RemoveCoupon(t.Context(), cart.CartID, code); err != nil {
+				t.Fatalf("RemoveCoupon(%s): %v", code, err)
+			}
+		}
+		assertCoupons(t, mustGetCart(t, repo, cart.CartID), "BULK")
+
+		if err := repo.RemoveCoupon(t.Context(), cart.CartID, "BULK"); err != nil {
+			t.Fatalf("RemoveCoupon(BULK): %v", err)
+		}
+		assertCoupons(t, mustGetCart(t, repo, cart.CartID))
+	})
+
+	t.Run("CouponsMissingCart", func(t *testing.T) {
+		repo := newRepo(t)
+
+		if err := repo.AddCoupon(t.Context(), 404, "SPRING"); !errors.Is(err, repository.ErrCartNotFound) {
+			t.Fatalf("AddCoupon(missing cart) error = %v, want ErrCartNotFound", err)
+		}
+		if err := repo.RemoveCoupon(t.Context(), 404, "SPRING"); !errors.Is(err, repository.ErrCartNotFound) {
+			t.Fatalf("RemoveCoupon(missing cart) error = %v, want ErrCartNotFound", err)
+		}
+	})
+
+	t.Run("Currency", func(t *testing.T) {
+		repo := newRepo(t)
+		now := time.Now()
+
+		created, err := repo.Create(t.Context(), 1, "", "JPY", expiresIn(time.Hour))
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if created.Currency != "JPY" {
+			t.Errorf("Create Currency = %q, want JPY", created.Currency)
+		}
+		if got := mustGetCart(t, repo, created.CartID); got.Currency != "JPY" {
+			t.Errorf("GetByID Currency = %q, want JPY", got.Currency)
+		}
+
+		active, isNew, err := repo.CreateActive(t.Context(), 2, "EUR", now, expiresIn(time.Hour))
+		if err != nil || !isNew || active.Currency != "EUR" {
+			t.Fatalf("CreateActive = %+v, %t, %v; want a new EUR cart", active, isNew, err)
+		}
+		again, isNew, err := repo.CreateActive(t.Context(), 2, "USD", now, expiresIn(time.Hour))
+		if err != nil || isNew || again.CartID != active.CartID || again.Currency != "EUR" {
+			t.Fatalf("second CreateActive = %+v, %t, %v; want the existing EUR cart", again, isNew, err)
+		}
+		if got, err := repo.GetActive(t.Context(), 2, now); err != nil || got.Currency != "EUR" {
+			t.Fatalf("GetActive = %+v, %v; want the EUR cart", got, err)
+		}
+	})
+
+	t.Run("ShippingAddress", func(t *testing.T) {
+		repo := newRepo(t)
+		cart := mustCreate(t, repo, 1)
+
+		address := models.Address{Line1: "400 Broad St", City: "Seattle", State: "WA", PostalCode: "98109", Country: "US"}
+		for range 2 {
+			if err := repo.SetShippingAddress(t.Context(), cart.CartID, &address); err != nil {
+				t.Fatalf("SetShippingAddress: %v", err)
+			}
+		}
+		// The repository keeps its own copy
+		address.City = "Tacoma"
+
+		got := mustGetCart(t, repo, cart.CartID)
+		if got.ShippingAddress == nil || got.ShippingAddress.City != "Seattle" || got.ShippingAddress.State != "WA" {
+			t.Fatalf("GetByID ShippingAddress = %+v, want the Seattle address", got.ShippingAddress)
+		}
+
+		if err := repo.SetShippingAddress(t.Context(), cart.CartID, nil); err != nil {
+			t.Fatalf("SetShippingAddress(nil): %v", err)
+		}
+		if got := mustGetCart(t, repo, cart.CartID); got.ShippingAddress != nil {
+			t.Fatalf("GetByID ShippingAddress = %+v after clearing it, want nil", got.ShippingAddress)
+		}
+
+		err := repo.SetShippingAddress(t.Context(), 404, &address)
+		if !errors.Is(err, repository.ErrCartNotFound) {
+			t.Fatalf("SetShippingAddress(missing cart) error = %v, want ErrCartNotFound", err)
+		}
+	})
+
+	t.Run("ShippingOption", func(t *testing.T) {
+		repo := newRepo(t)
+		cart := mustCreate(t, repo, 1)
+
+		for range 2 {
+			if err := repo.SetShippingOption(t.Context(), cart.CartID, "ups-ground"); err != nil {
+				t.Fatalf("SetShippingOption: %v", err)
+			}
+		}
+		if got := mustGetCart(t, repo, cart.CartID); got.ShippingOptionID != "ups-ground" {
+			t.Fatalf("GetByID ShippingOptionID = %q, want ups-ground", got.ShippingOptionID)
+		}
+
+		if err := repo.SetShippingOption(t.Context(), cart.CartID, ""); err != nil {
+			t.Fatalf("SetShippingOption(\"\"): %v", err)
+		}
+		if got := mustGetCart(t, repo, cart.CartID); got.ShippingOptionID != "" {
+			t.Fatalf("GetByID ShippingOptionID = %q after clearing it, want empty", got.ShippingOptionID)
+		}
+
+		err := repo.SetShippingOption(t.Context(), 404, "ups-ground")
+		if !errors.Is(err, repository.ErrCartNotFound) {
+			t.Fatalf("SetShippingOption(missing cart) error = %v, want ErrCartNotFound", err)
+		}
+	})
+
+	t.Run("ItemsBelongToOneCart", func(t *testing.T) {
+		repo := newRepo(t)
+		first := mustCreate(t, repo, 1)
+		second := mustCreate(t, repo, 2)
+
+		mustAddItem(t, repo, first.CartID, 1, 2)
+
+		assertItems(t, mustGetCart(t, repo, first.CartID), map[int]int{1: 2})
+		assertItems(t, mustGetCart(t, repo, second.CartID), map[int]int{})
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		repo := newRepo(t)
+		cart := mustCreate(t, repo, 1)
+		mustAddItem(t, repo, cart.CartID, 1, 2)
+
+		if err := repo.Delete(t.Context(), cart.CartID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := repo.GetByID(t.Context(), cart.CartID); !errors.Is(err, repository.ErrCartNotFound) {
+			t.Fatalf("GetByID after Delete error = %v, want ErrCartNotFound", err)
+		}
+		if err := repo.AddItem(t.Context(), cart.CartID, models.CartItem{ProductID: 1, Quantity: 1}, expiresIn(time.Hour)); !errors.Is(err, repository.ErrCartNotFound) {
+			t.Fatalf("AddItem after Delete error = %v, want ErrCartNotFound", err)
+		}
+		if err := repo.Delete(t.Context(), cart.CartID); !errors.Is(err, repository.ErrCartNotFound) {
+			t.Fatalf("second Delete error = %v, want ErrCartNotFound", err)
+		}
+	})
+
+	t.Run("DeleteMissing", func(t *testing.T) {
+		repo := newRepo(t)
+
+		if err := repo.Delete(t.Context(), 404); !errors.Is(err, repository.ErrCartNotFound) {
+			t.Fatalf("Delete(missing) error = %v, want ErrCartNotFound", err)
+		}
+	})
+
+	t.Run("CopyIsolation", func(t *testing.T) {
+		repo := newRepo(t)
+
+		// Changing the cart returned by Create must not change what was stored
+		created := mustCreate(t, repo, 1)
+		created.CustomerID = 999
+		created.Items = append(created.Items, models.CartItem{ProductID: 7, Quantity: 7})
+
+		got := mustGetCart(t, repo, created.CartID)
+		if got.CustomerID != 1 {
+			t.Fatalf("stored cart changed with Create result: customer %d", got.CustomerID)
+		}
+		assertItems(t, got, map[int]int{})
+
+		// Changing a cart returned by GetByID must not change what is stored
+		mustAddItem(t, repo, created.CartID, 1, 2)
+		got = mustGetCart(t, repo, created.CartID)
+		got.Items[0].Quantity = 100
+		got.Items = append(got.Items, models.CartItem{ProductID: 8, Quantity: 8})
+
+		assertItems(t, mustGetCart(t, repo, created.CartID), map[int]int{1: 2})
+	})
+
+	t.Run("Timestamps", func(t *testing.T) {
+		repo := newRepo(t)
+		before := time.Now().UTC().Truncate(time.Second)
+		expiresAt := expiresIn(time.Hour)
+
+		created, err := repo.Create(t.Context(), 1, "", "USD", expiresAt)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if created.CreatedAt.Before(before) || !created.UpdatedAt.Equal(created.CreatedAt) {
+			t.Errorf("Create times = created %v, updated %v; want both the creation time", created.CreatedAt, created.UpdatedAt)
+		}
+		assertTimes(t, mustGetCart(t, repo, created.CartID), created.CreatedAt, created.UpdatedAt, expiresAt)
+
+		// Adding an item moves the expiry
+		later := expiresIn(2 * time.Hour)
+		if err := repo.AddItem(t.Context(), created.CartID, models.CartItem{ProductID: 1, Quantity: 1}, later); err != nil {
+			t.Fatalf("AddItem: %v", err)
+		}
+		got := mustGetCart(t, repo, created.CartID)
+		if got.UpdatedAt.Before(created.UpdatedAt) {
+			t.Errorf("UpdatedAt after AddItem = %v, want at least %v", got.UpdatedAt, created.UpdatedAt)
+		}
+		assertTimes(t, got, created.CreatedAt, got.UpdatedAt, later)
+	})
+
+	t.Run("GuestCart", func(t *testing.T) {
+		repo := newRepo(t)
+		hash := strings.Repeat("ab", 32)
+
+		created, err := repo.Create(t.Context(), 0, hash, "USD", expiresIn(time.Hour))
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if created.CustomerID != 0 || created.GuestTokenHash != hash {
+			t.Errorf("Create = customer %d, hash %q; want a guest cart with the hash", created.CustomerID, created.GuestTokenHash)
+		}
+		mustAddItem(t, repo, created.CartID, 1, 2)
+		if got := mustGetCart(t, repo, created.CartID); got.GuestTokenHash != hash {
+			t.Errorf("GetByID GuestTokenHash = %q, want %q", got.GuestTokenHash, hash)
+		}
+
+		page, _, err := repo.List(t.Context(), "", 10)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(page) != 1 || page[0].GuestTokenHash != hash {
+			t.Errorf("List = %+v, want the guest cart with its hash", page)
+		}
+
+		// Importing the cart for a customer drops the hash
+		owned := mustGetCart(t, repo, created.CartID)
+		owned.CustomerID, owned.GuestTokenHash = 7, ""
+		if err := repo.Put(t.Context(), owned); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if got := mustGetCart(t, repo, created.CartID); got.CustomerID != 7 || got.GuestTokenHash != "" {
+			t.Errorf("after Put, customer %d and hash %q; want customer 7 and no hash", got.CustomerID, got.GuestTokenHash)
+		}
+	})
+
+	t.Run("ActiveCart", func(t *testing.T) {
+		repo := newRepo(t)
+		now := time.Now()
+
+		if _, err := repo.GetActive(t.Context(), 7, now); !errors.Is(err, repository.ErrCartNotFound) {
+			t.Fatalf("GetActive with no active cart error = %v, want ErrCartNotFound", err)
+		}
+		// Carts made by Create are never active
+		plain := mustCreate(t, repo, 7)
+		if _, err := repo.GetActive(t.Context(), 7, now); !errors.Is(err, repository.ErrCartNotFound) {
+			t.Fatalf("GetActive after Create error = %v, want ErrCartNotFound", err)
+		}
+
+		active := mustCreateActive(t, repo, 7, now, true)
+		if active.CartID == plain.CartID || active.CustomerID != 7 {
+			t.Fatalf("CreateActive = cart %d for customer %d, want a new cart for customer 7", active.CartID, active.CustomerID)
+		}
+		mustAddItem(t, repo, active.CartID, 1, 2)
+
+		again := mustCreateActive(t, repo, 7, now, false)
+		if again.CartID != active.CartID {
+			t.Fatalf("second CreateActive = cart %d, want the active cart %d", again.CartID, active.CartID)
+		}
+		assertItems(t, again, map[int]int{1: 2})
+		got, err := repo.GetActive(t.Context(), 7, now)
+		if err != nil {
+			t.Fatalf("GetActive: %v", err)
+		}
+		if got.CartID != active.CartID {
+			t.Fatalf("GetActive = cart %d, want %d", got.CartID, active.CartID)
+		}
+		assertItems(t, got, map[int]int{1: 2})
+
+		if other := mustCreateActive(t, repo, 8, now, true); other.CartID == active.CartID {
+			t.Fatal("two customers share an active cart")
+		}
+
+		// Once the active cart has expired it is replaced, though it is not deleted
+		later := now.Add(2 * time.Hour)
+		if _, err := repo.GetActive(t.Context(), 7, later); !errors.Is(err, repository.ErrCartNotFound) {
+			t.Fatalf("GetActive of an expired cart error = %v, want ErrCartNotFound", err)
+		}
+		replaced := mustCreateActive(t, repo, 7, later, true)
+		if replaced.CartID == active.CartID {
+			t.Fatal("CreateActive returned an expired cart")
+		}
+		if len(replaced.Items) != 0 {
+			t.Fatalf("replacement cart items = %+v, want none", replaced.Items)
+		}
+		assertItems(t, mustGetCart(t, repo, active.CartID), map[int]int{1: 2})
+		// The expired cart is released, so it is not found again even at a time it had not expired
+		if got, err := repo.GetActive(t.Context(), 7, now); err != nil || got.CartID != replaced.CartID {
+			t.Fatalf("GetActive after replacement = %v, %v; want cart %d", got, err, replaced.CartID)
+		}
+		mustCreateActive(t, repo, 7, later, false)
+
+		// Deleting the active cart, as checkout does, leaves the customer without one
+		if err := repo.Delete(t.Context(), replaced.CartID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := repo.GetActive(t.Context(), 7, now); !errors.Is(err, repository.ErrCartNotFound) {
+			t.Fatalf("GetActive after Delete error = %v, want ErrCartNotFound", err)
+		}
+		mustCreateActive(t, repo, 7, now, true)
+
+		page, _, err := repo.List(t.Context(), "", 10)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(page) != 4 {
+			t.Errorf("List returned %d carts, want the 4 not deleted", len(page))
+		}
+	})
+
+	t.Run("NoExpiry", func(t *testing.T) {
+		repo := newRepo(t)
+
+		created, err := repo.Create(t.Context(), 1, "", "USD", time.Time{})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if got := mustGetCart(t, repo, created.CartID); !got.ExpiresAt.IsZero() {
+			t.Errorf("ExpiresAt = %v, want zero for a cart that never expires", got.ExpiresAt)
+		}
+	})
+
+	t.Run("DeleteExpired", func(t *testing.T) {
+		repo := newRepo(t)
+		deleter, ok := repo.(repository.ExpiredCartDeleter)
+		if !ok {
+			t.Skip("the backend expires carts itself")
+		}
+
+		var expired []int
+		for i := range 3 {
+			cart, err := repo.Create(t.Context(), 1, "", "USD", expiresIn(-time.Duration(i+1)*time.Hour))
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			expired = append(expired, cart.CartID)
+		}
+		// Items go with their cart
+		if err := repo.AddItem(t.Context(), expired[0], models.CartItem{ProductID: 1, Quantity: 1}, expiresIn(-time.Hour)); err != nil {
+			t.Fatalf("AddItem: %v", err)
+		}
+		live := mustCreate(t, repo, 2)
+		forever, err := repo.Create(t.Context(), 3, "", "USD", time.Time{})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		// Expired carts are deleted in batches of at most limit
+		now := time.Now()
+		for _, want := range []int{2, 1, 0} {
+			deleted, err := deleter.DeleteExpired(t.Context(), now, 2)
+			if err != nil {
+				t.Fatalf("DeleteExpired: %v", err)
+			}
+			if deleted != want {
+				t.Fatalf("DeleteExpired deleted %d carts, want %d", deleted, want)
+			}
+		}
+
+		for _, cartID := range expired {
+			if _, err := repo.GetByID(t.Context(), cartID); !errors.Is(err, repository.ErrCartNotFound) {
+				t.Errorf("GetByID(expired cart %d) error = %v, want ErrCartNotFound", cartID, err)
+			}
+		}
+		mustGetCart(t, repo, live.CartID)
+		mustGetCart(t, repo, forever.CartID)
+	})
+
+	t.Run("AbandonedCarts", func(t *testing.T) {
+		repo := newRepo(t)
+		store, ok := repo.(repository.AbandonedCartStore)
+		if !ok {
+			t.Skip("the backend does not track abandoned carts")
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		idleSince := now.Add(-time.Hour)
+		put := func(cartID int, updatedAt, expiresAt, remindedAt time.Time, items ...models.CartItem) {
+			t.Helper()
+			cart := models.Cart{
+				CartID:     cartID,
+				CustomerID: cartID,
+				Items:      items,
+				CreatedAt:  updatedAt,
+				UpdatedAt:  updatedAt,
+				ExpiresAt:  expiresAt,
+				RemindedAt: remindedAt,
+			}
+			if err := repo.Put(t.Context(), &cart); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+		}
+		item := models.CartItem{ProductID: 1, Quantity: 2}
+		put(101, now.Add(-3*time.Hour), now.Add(time.Hour), time.Time{}, item)
+		put(102, now.Add(-2*time.Hour), time.Time{}, time.Time{}, item)
+		// Recently changed, empty, expired, already reminded and guest carts are not abandoned
+		put(103, now.Add(-time.Minute), now.Add(time.Hour), time.Time{}, item)
+		put(104, now.Add(-3*time.Hour), now.Add(time.Hour), time.Time{})
+		put(105, now.Add(-3*time.Hour), now.Add(-time.Minute), time.Time{}, item)
+		put(106, now.Add(-3*time.Hour), now.Add(time.Hour), now.Add(-time.Hour), item)
+		guest := models.Cart{CartID: 107, Items: []models.CartItem{item}, UpdatedAt: now.Add(-3 * time.Hour), GuestTokenHash: strings.Repeat("ab", 32)}
+		if err := repo.Put(t.Context(), &guest); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+
+		listed := func(limit int) map[int]bool {
+			t.Helper()
+			carts, err := store.ListAbandoned(t.Context(), idleSince, now, limit)
+			if err != nil {
+				t.Fatalf("ListAbandoned: %v", err)
+			}
+			ids := make(map[int]bool)
+			for _, cart := range carts {
+				assertItems(t, &cart, map[int]int{1: 2})
+				ids[cart.CartID] = true
+			}
+			return ids
+		}
+		if got := listed(10); len(got) != 2 || !got[101] || !got[102] {
+			t.Fatalf("ListAbandoned = %v, want carts 101 and 102", got)
+		}
+		if got := listed(1); len(got) != 1 {
+			t.Fatalf("ListAbandoned(limit 1) returned %d carts", len(got))
+		}
+
+		// Only the first of several marks succeeds
+		mark := func(cartID int) bool {
+			t.Helper()
+			marked, err := store.MarkReminded(t.Context(), cartID, idleSince, now)
+			if err != nil {
+				t.Fatalf("MarkReminded: %v", err)
+			}
+			return marked
+		}
+		if !mark(101) {
+			t.Fatal("MarkReminded(101) = false, want true")
+		}
+		if mark(101) {
+			t.Fatal("second MarkReminded(101) = true, want false")
+		}
+		if got := mustGetCart(t, repo, 101); !got.RemindedAt.Equal(now) {
+			t.Errorf("RemindedAt = %v, want %v", got.RemindedAt, now)
+		}
+		if got := listed(10); len(got) != 1 || !got[102] {
+			t.Fatalf("ListAbandoned after MarkReminded = %v, want cart 102", got)
+		}
+
+		// A cart that changed since it was listed is no longer abandoned
+		mustAddItem(t, repo, 102, 2, 1)
+		if mark(102) {
+			t.Error("MarkReminded of a changed cart = true, want false")
+		}
+		if mark(999) {
+			t.Error("MarkReminded of a missing cart = true, want false")
+		}
+
+		// A reminder that could not be sent is retried
+		if err := store.UnmarkReminded(t.Context(), 101); err != nil {
+			t.Fatalf("UnmarkReminded: %v", err)
+		}
+		if got := listed(10); len(got) != 1 || !got[101] {
+			t.Fatalf("ListAbandoned after UnmarkReminded = %v, want cart 101", got)
+		}
+		if err := store.UnmarkReminded(t.Context(), 999); err != nil {
+			t.Fatalf("UnmarkReminded of a missing cart: %v", err)
+		}
+		if _, err := repo.GetByID(t.Context(), 999); !errors.Is(err, repository.ErrCartNotFound) {
+			t.Errorf("GetByID after UnmarkReminded of a missing cart error = %v, want ErrCartNotFound", err)
+		}
+	})
+
+	t.Run("ConcurrentCreates", func(t *testing.T) {
+		repo := newRepo(t)
+
+		var mu sync.Mutex
+		ids := make(map[int]bool)
+		run(t, concurrency, func(i int) error {
+			cart, err := repo.Create(t.Context(), i+1, "", "USD", expiresIn(time.Hour))
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if ids[cart.CartID] {
+				return fmt.Errorf("cart ID %d assigned twice", cart.CartID)
+			}
+			ids[cart.CartID] = true
+			return nil
+		})
+	})
+
+	t.Run("ConcurrentCreateActive", func(t *testing.T) {
+		repo := newRepo(t)
+		now := time.Now()
+
+		var mu sync.Mutex
+		ids := make(map[int]bool)
+		created := 0
+		run(t, concurrency, func(i int) error {
+			cart, isNew, err := repo.CreateActive(t.Context(), 1, "USD", now, expiresIn(time.Hour))
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			ids[cart.CartID] = true
+			if isNew {
+				created++
+			}
+			return nil
+		})
+
+		if len(ids) != 1 || created != 1 {
+			t.Fatalf("concurrent CreateActive returned %d carts, %d of them created; want one cart created once", len(ids), created)
+		}
+	})
+
+	t.Run("ConcurrentReplaceExpiredActive", func(t *testing.T) {
+		repo := newRepo(t)
+		now := time.Now()
+		expired := mustCreateActive(t, repo, 1, now, true)
+		later := now.Add(2 * time.Hour)
+
+		var mu sync.Mutex
+		ids := make(map[int]bool)
+		created := 0
+		run(t, concurrency, func(i int) error {
+			cart, isNew, err := repo.CreateActive(t.Context(), 1, "USD", later, later.Add(time.Hour))
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			ids[cart.CartID] = true
+			if isNew {
+				created++
+			}
+			return nil
+		})
+
+		if len(ids) != 1 || created != 1 || ids[expired.CartID] {
+			t.Fatalf("concurrent CreateActive returned carts %v, %d of them created; want one new cart created once", ids, created)
+		}
+	})
+
+	t.Run("ConcurrentAddItems", func(t *testing.T) {
+		repo := newRepo(t)
+		cart := mustCreate(t, repo, 1)
+
+		// Half the goroutines add to the same product, the rest add distinct products
+		run(t, concurrency, func(i int) error {
+			productID := 1
+			if i%2 == 1 {
+				productID = 100 + i
+			}
+			return repo.AddItem(t.Context(), cart.CartID, models.CartItem{ProductID: productID, Quantity: 1}, expiresIn(time.Hour))
+		})
+
+		want := map[int]int{1: concurrency / 2}
+		for i := 1; i < concurrency; i += 2 {
+			want[100+i] = 1
+		}
+		assertItems(t, mustGetCart(t, repo, cart.CartID), want)
+	})
+
+	t.Run("ConcurrentAddCoupons", func(t *testing.T) {
+		repo := newRepo(t)
+		cart := mustCreate(t, repo, 1)
+
+		run(t, concurrency, func(i int) error {
+			return repo.AddCoupon(t.Context(), cart.CartID, fmt.Sprintf("CODE%d", i))
+		})
+
+		got := mustGetCart(t, repo, cart.CartID).Coupons
+		sort.Strings(got)
+		want := make([]string, concurrency)
+		for i := range want {
+			want[i] = fmt.Sprintf("CODE%d", i)
+		}
+		sort.Strings(want)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("coupons = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("ListPages", func(t *testing.T) {
+		repo := newRepo(t)
+		want := make(map[int]map[int]int)
+		for i := range 7 {
+			cart := mustCreate(t, repo, i+1)
+			want[cart.CartID] = map[int]int{}
+			for j := range i % 3 {
+				mustAddItem(t, repo, cart.CartID, j+1, i+1)
+				want[cart.CartID][j+1] = i + 1
+			}
+		}
+
+		got := make(map[int]models.Cart)
+		cursor, pages := "", 0
+		for {
+			page, next, err := repo.List(t.Context(), cursor, 3)
+			if err != nil {
+				t.Fatalf("List(%q): %v", cursor, err)
+			}
+			if len(page) > 3 {
+				t.Fatalf("List returned %d carts, limit 3", len(page))
+			}
+			for _, cart := range page {
+				if _, dup := got[cart.CartID]; dup {
+					t.Fatalf("List returned cart %d twice", cart.CartID)
+				}
+				got[cart.CartID] = cart
+			}
+			if pages++; pages > 10 {
+				t.Fatal("List did not finish after 10 pages")
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("List returned %d carts, want %d", len(got), len(want))
+		}
+		for cartID, items := range want {
+			cart, ok := got[cartID]
+			if !ok {
+				t.Fatalf("List did not return cart %d", cartID)
+			}
+			if cart.Items == nil {
+				t.Errorf("listed cart %d has nil items, want a non-nil slice", cartID)
+			}
+			assertItems(t, &cart, items)
+		}
+	})
+
+	t.Run("ListEmpty", func(t *testing.T) {
+		repo := newRepo(t)
+
+		page, next, err := repo.List(t.Context(), "", 10)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(page) != 0 || next != "" {
+			t.Fatalf("List on an empty repository = %v, %q; want nothing", page, next)
+		}
+	})
+
+	t.Run("PutKeepsID", func(t *testing.T) {
+		repo := newRepo(t)
+		created := mustCreate(t, repo, 1)
+
+		// Take the ID the next Create would most likely use
+		imported := models.Cart{
+			CartID:     created.CartID + 1,
+			CustomerID: 7,
+			Items:      []models.CartItem{{ProductID: 1, Quantity: 2}, {ProductID: 3, Quantity: 4}},
+		}
+		if err := repo.Put(t.Context(), &imported); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+
+		got := mustGetCart(t, repo, imported.CartID)
+		if got.CustomerID != 7 {
+			t.Errorf("GetByID CustomerID = %d, want 7", got.CustomerID)
+		}
+		assertItems(t, got, map[int]int{1: 2, 3: 4})
+
+		next := mustCreate(t, repo, 2)
+		if next.CartID == imported.CartID || next.CartID == created.CartID {
+			t.Fatalf("Create after Put reused cart ID %d", next.CartID)
+		}
+		assertItems(t, mustGetCart(t, repo, imported.CartID), map[int]int{1: 2, 3: 4})
+
+		// The imported cart behaves like any other
+		mustAddItem(t, repo, imported.CartID, 1, 1)
+		assertItems(t, mustGetCart(t, repo, imported.CartID), map[int]int{1: 3, 3: 4})
+	})
+
+	t.Run("PutActive", func(t *testing.T) {
+		repo := newRepo(t)
+		now := time.Now()
+		active := mustCreateActive(t, repo, 7, now, true)
+		plain := mustCreate(t, repo, 7)
+
+		// List marks the active cart, so copying it keeps it active
+		page, _, err := repo.List(t.Context(), "", 10)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		for _, cart := range page {
+			if want := cart.CartID == active.CartID; cart.Active != want {
+				t.Errorf("listed cart %d Active = %t, want %t", cart.CartID, cart.Active, want)
+			}
+		}
+
+		// An imported active cart is the one CreateActive returns
+		imported := models.Cart{CartID: plain.CartID + 100, CustomerID: 9, Items: []models.CartItem{{ProductID: 1, Quantity: 2}}, Active: true}
+		if err := repo.Put(t.Context(), &imported); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if got := mustCreateActive(t, repo, 9, now, false); got.CartID != imported.CartID {
+			t.Fatalf("CreateActive after Put = cart %d, want the imported cart %d", got.CartID, imported.CartID)
+		}
+
+		// It takes over from the customer's active cart
+		imported = models.Cart{CartID: plain.CartID + 101, CustomerID: 7, Active: true}
+		if err := repo.Put(t.Context(), &imported); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if got := mustCreateActive(t, repo, 7, now, false); got.CartID != imported.CartID {
+			t.Fatalf("CreateActive after Put = cart %d, want the imported cart %d", got.CartID, imported.CartID)
+		}
+
+		// Putting it again without the mark keeps it active
+		imported.Active = false
+		if err := repo.Put(t.Context(), &imported); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if got := mustCreateActive(t, repo, 7, now, false); got.CartID != imported.CartID {
+			t.Fatalf("CreateActive after a second Put = cart %d, want %d", got.CartID, imported.CartID)
+		}
+		page, _, err = repo.List(t.Context(), "", 10)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		for _, cart := range page {
+			if want := cart.CartID == imported.CartID || cart.CustomerID == 9; cart.Active != want {
+				t.Errorf("listed cart %d Active = %t, want %t", cart.CartID, cart.Active, want)
+			}
+		}
+	})
+
+	t.Run("PutKeepsTimes", func(t *testing.T) {
+		repo := newRepo(t)
+
+		createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		imported := models.Cart{
+			CartID:     100,
+			CustomerID: 7,
+			Items:      []models.CartItem{{ProductID: 1, Quantity: 2}},
+			CreatedAt:  createdAt,
+			UpdatedAt:  createdAt.Add(time.Minute),
+			ExpiresAt:  createdAt.Add(7 * 24 * time.Hour),
+			RemindedAt: createdAt.Add(24 * time.Hour),
+		}
+		if err := repo.Put(t.Context(), &imported); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		got := mustGetCart(t, repo, imported.CartID)
+		assertTimes(t, got, imported.CreatedAt, imported.UpdatedAt, imported.ExpiresAt)
+		if !got.RemindedAt.Equal(imported.RemindedAt) {
+			t.Errorf("RemindedAt = %v, want %v", got.RemindedAt, imported.RemindedAt)
+		}
+	})
+
+	t.Run("PutReplaces", func(t *testing.T) {
+		repo := newRepo(t)
+		cart := mustCreate(t, repo, 1)
+		mustAddItem(t, repo, cart.CartID, 1, 2)
+		mustAddItem(t, repo, cart.CartID, 2, 2)
+
+		if err := repo.AddCoupon(t.Context(), cart.CartID, "SPRING"); err != nil {
+			t.Fatalf("AddCoupon: %v", err)
+		}
+
+		replacement := models.Cart{
+			CartID:           cart.CartID,
+			CustomerID:       9,
+			Items:            []models.CartItem{{ProductID: 2, Quantity: 5}},
+			Coupons:          []string{"BULK"},
+			ShippingAddress:  &models.Address{Line1: "1 Rue de Rivoli", City: "Paris", Country: "FR"},
+			ShippingOptionID: "dhl-express",
+			Currency:         "EUR",
+		}
+		if err := repo.Put(t.Context(), &replacement); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		// Putting the same cart again changes nothing
+		if err := repo.Put(t.Context(), &replacement); err != nil {
+			t.Fatalf("second Put: %v", err)
+		}
+
+		got := mustGetCart(t, repo, cart.CartID)
+		if got.CustomerID != 9 {
+			t.Errorf("GetByID CustomerID = %d, want 9", got.CustomerID)
+		}
+		assertItems(t, got, map[int]int{2: 5})
+		assertCoupons(t, got, "BULK")
+		if got.ShippingAddress == nil || got.ShippingAddress.Country != "FR" {
+			t.Errorf("GetByID ShippingAddress = %+v, want the Paris address", got.ShippingAddress)
+		}
+		if got.ShippingOptionID != "dhl-express" {
+			t.Errorf("GetByID ShippingOptionID = %q, want dhl-express", got.ShippingOptionID)
+		}
+		if got.Currency != "EUR" {
+			t.Errorf("GetByID Currency = %q, want EUR", got.Currency)
+		}
+	})
+}
+
+// TestPromotionRepository runs the promotion conformance tests against repositories from newRepo
+func TestPromotionRepository(t *testing.T, newRepo PromotionFactory) {
+	t.Run("GetByCodeMissing", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.GetByCode(t.Context(), "MISSING")
+		if !errors.Is(err, repository.ErrPromotionNotFound) {
+			t.Fatalf("GetByCode(missing) error = %v, want ErrPromotionNotFound", err)
+		}
+	})
+
+	t.Run("UpsertThenGet", func(t *testing.T) {
+		repo := newRepo(t)
+		want := promotion("SPRING")
+		want.StartsAt = expiresIn(-time.Hour)
+		want.EndsAt = expiresIn(time.Hour)
+
+		mustUpsertPromotion(t, repo, &want)
+
+		got := mustGetPromotion(t, repo, "SPRING")
+		if !got.StartsAt.Equal(want.StartsAt) || !got.EndsAt.Equal(want.EndsAt) {
+			t.Fatalf("GetByCode window = %v to %v, want %v to %v", got.StartsAt, got.EndsAt, want.StartsAt, want.EndsAt)
+		}
+		got.StartsAt, got.EndsAt = want.StartsAt, want.EndsAt
+		if *got != want {
+			t.Fatalf("GetByCode = %+v, want %+v", *got, want)
+		}
+	})
+
+	t.Run("UpsertKeepsUses", func(t *testing.T) {
+		repo := newRepo(t)
+		p := promotion("SPRING")
+		mustUpsertPromotion(t, repo, &p)
+		mustRedeem(t, repo, "SPRING", 1)
+
+		p.PercentOff = 20
+		p.Uses = 0
+		mustUpsertPromotion(t, repo, &p)
+
+		got := mustGetPromotion(t, repo, "SPRING")
+		if got.PercentOff != 20 || got.Uses != 1 {
+			t.Fatalf("GetByCode percent off = %d, uses = %d; want 20 and 1", got.PercentOff, got.Uses)
+		}
+	})
+
+	t.Run("RedeemMissing", func(t *testing.T) {
+		repo := newRepo(t)
+
+		err := repo.Redeem(t.Context(), "MISSING", 1)
+		if !errors.Is(err, repository.ErrPromotionNotFound) {
+			t.Fatalf("Redeem(missing) error = %v, want ErrPromotionNotFound", err)
+		}
+	})
+
+	t.Run("RedeemUpToMaxUses", func(t *testing.T) {
+		repo := newRepo(t)
+		p := promotion("SPRING")
+		p.MaxUses = 2
+		mustUpsertPromotion(t, repo, &p)
+
+		mustRedeem(t, repo, "SPRING", 1)
+		mustRedeem(t, repo, "SPRING", 0)
+		if err := repo.Redeem(t.Context(), "SPRING", 2); !errors.Is(err, repository.ErrPromotionUsedUp) {
+			t.Fatalf("third Redeem error = %v, want ErrPromotionUsedUp", err)
+		}
+
+		if got := mustGetPromotion(t, repo, "SPRING").Uses; got != 2 {
+			t.Fatalf("uses = %d, want 2", got)
+		}
+		assertCustomerUses(t, repo, "SPRING", 2, 0)
+	})
+
+	t.Run("RedeemUpToMaxUsesPerCustomer", func(t *testing.T) {
+		repo := newRepo(t)
+		p := promotion("SPRING")
+		p.MaxUsesPerCustomer = 1
+		mustUpsertPromotion(t, repo, &p)
+
+		mustRedeem(t, repo, "SPRING", 1)
+		if err := repo.Redeem(t.Context(), "SPRING", 1); !errors.Is(err, repository.ErrPromotionCustomerUsedUp) {
+			t.Fatalf("second Redeem by customer 1 error = %v, want ErrPromotionCustomerUsedUp", err)
+		}
+		mustRedeem(t, repo, "SPRING", 2)
+		// Guests are only held to the code's total
+		mustRedeem(t, repo, "SPRING", 0)
+		mustRedeem(t, repo, "SPRING", 0)
+
+		if got := mustGetPromotion(t, repo, "SPRING").Uses; got != 4 {
+			t.Fatalf("uses = %d, want 4", got)
+		}
+		assertCustomerUses(t, repo, "SPRING", 1, 1)
+		assertCustomerUses(t, repo, "SPRING", 2, 1)
+	})
+
+	t.Run("Release", func(t *testing.T) {
+		repo := newRepo(t)
+		p := promotion("SPRING")
+		p.MaxUses = 1
+		p.MaxUsesPerCustomer = 1
+		mustUpsertPromotion(t, repo, &p)
+		mustRedeem(t, repo, "SPRING", 1)
+
+		// Releasing twice goes no lower than zero
+		for range 2 {
+			if err := repo.Release(t.Context(), "SPRING", 1); err != nil {
+				t.Fatalf("Release: %v", err)
+			}
+		}
+		if got := mustGetPromotion(t, repo, "SPRING").Uses; got != 0 {
+			t.Fatalf("uses = %d, want 0", got)
+		}
+		assertCustomerUses(t, repo, "SPRING", 1, 0)
+
+		// The released use can be redeemed again
+		mustRedeem(t, repo, "SPRING", 1)
+	})
+
+	t.Run("ConcurrentRedeem", func(t *testing.T) {
+		repo := newRepo(t)
+		p := promotion("SPRING")
+		p.MaxUses = concurrency / 2
+		mustUpsertPromotion(t, repo, &p)
+
+		var mu sync.Mutex
+		redeemed := 0
+		run(t, concurrency, func(i int) error {
+			err := repo.Redeem(t.Context(), "SPRING", i+1)
+			if errors.Is(err, repository.ErrPromotionUsedUp) {
+				return nil
+			}
+			if err == nil {
+				mu.Lock()
+				redeemed++
+				mu.Unlock()
+			}
+			return err
+		})
+
+		if redeemed != concurrency/2 {
+			t.Fatalf("%d concurrent redemptions succeeded, want %d", redeemed, concurrency/2)
+		}
+		if got := mustGetPromotion(t, repo, "SPRING").Uses; got != concurrency/2 {
+			t.Fatalf("uses = %d, want %d", got, concurrency/2)
+		}
+	})
+
+	t.Run("ConcurrentRedeemByOneCustomer", func(t *testing.T) {
+		repo := newRepo(t)
+		p := promotion("SPRING")
+		p.MaxUsesPerCustomer = 1
+		mustUpsertPromotion(t, repo, &p)
+
+		var mu sync.Mutex
+		redeemed := 0
+		run(t, concurrency, func(i int) error {
+			err := repo.Redeem(t.Context(), "SPRING", 1)
+			if errors.Is(err, repository.ErrPromotionCustomerUsedUp) {
+				return nil
+			}
+			if err == nil {
+				mu.Lock()
+				redeemed++
+				mu.Unlock()
+			}
+			return err
+		})
+
+		if redeemed != 1 {
+			t.Fatalf("%d concurrent redemptions by one customer succeeded, want 1", redeemed)
+		}
+		assertCustomerUses(t, repo, "SPRING", 1, 1)
+	})
+
+	t.Run("ListPages", func(t *testing.T) {
+		repo := newRepo(t)
+		for i := range 7 {
+			p := promotion(fmt.Sprintf("CODE%d", i))
+			mustUpsertPromotion(t, repo, &p)
+			mustRedeem(t, repo, p.Code, i+1)
+		}
+
+		got := make(map[string]models.Promotion)
+		cursor, pages := "", 0
+		for {
+			page, next, err := repo.List(t.Context(), cursor, 3)
+			if err != nil {
+				t.Fatalf("List(%q): %v", cursor, err)
+			}
+			if len(page) > 3 {
+				t.Fatalf("List returned %d promotions, limit 3", len(page))
+			}
+			for _, p := range page {
+				if _, dup := got[p.Code]; dup {
+					t.Fatalf("List returned promotion %s twice", p.Code)
+				}
+				got[p.Code] = p
+			}
+			if pages++; pages > 20 {
+				t.Fatal("List did not finish after 20 pages")
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+
+		if len(got) != 7 {
+			t.Fatalf("List returned %d promotions, want 7", len(got))
+		}
+		for i := range 7 {
+			want := promotion(fmt.Sprintf("CODE%d", i))
+			want.Uses = 1
+			if got[want.Code] != want {
+				t.Errorf("listed promotion %s = %+v, want %+v", want.Code, got[want.Code], want)
+			}
+		}
+	})
+
+	t.Run("PutKeepsUses", func(t *testing.T) {
+		repo := newRepo(t)
+		want := promotion("SPRING")
+		want.StartsAt = expiresIn(-time.Hour)
+		want.Uses = 42
+
+		if err := repo.Put(t.Context(), &want); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		got := mustGetPromotion(t, repo, "SPRING")
+		if !got.StartsAt.Equal(want.StartsAt) {
+			t.Fatalf("GetByCode StartsAt = %v, want %v", got.StartsAt, want.StartsAt)
+		}
+		got.StartsAt = want.StartsAt
+		if *got != want {
+			t.Fatalf("GetByCode = %+v, want %+v", *got, want)
+		}
+
+		want.Uses = 7
+		if err := repo.Put(t.Context(), &want); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if got := mustGetPromotion(t, repo, "SPRING"); got.Uses != 7 {
+			t.Fatalf("GetByCode uses after a second Put = %d, want 7", got.Uses)
+		}
+	})
+
+	t.Run("ListRedemptionsPages", func(t *testing.T) {
+		repo := newRepo(t)
+		want := make(map[models.Redemption]bool)
+		for i := range 3 {
+			p := promotion(fmt.Sprintf("CODE%d", i))
+			mustUpsertPromotion(t, repo, &p)
+			for customerID := 1; customerID <= i+2; customerID++ {
+				for range customerID {
+					mustRedeem(t, repo, p.Code, customerID)
+				}
+				want[models.Redemption{Code: p.Code, CustomerID: customerID, Uses: customerID}] = true
+			}
+		}
+		// Guests' uses are not recorded per customer
+		mustRedeem(t, repo, "CODE0", 0)
+
+		got := make(map[models.Redemption]bool)
+		cursor, pages := "", 0
+		for {
+			page, next, err := repo.ListRedemptions(t.Context(), cursor, 4)
+			if err != nil {
+				t.Fatalf("ListRedemptions(%q): %v", cursor, err)
+			}
+			if len(page) > 4 {
+				t.Fatalf("ListRedemptions returned %d redemptions, limit 4", len(page))
+			}
+			for _, redemption := range page {
+				if got[redemption] {
+					t.Fatalf("ListRedemptions returned %+v twice", redemption)
+				}
+				got[redemption] = true
+			}
+			if pages++; pages > 20 {
+				t.Fatal("ListRedemptions did not finish after 20 pages")
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("ListRedemptions = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("PutRedemption", func(t *testing.T) {
+		repo := newRepo(t)
+		p := promotion("SPRING")
+		p.MaxUsesPerCustomer = 3
+		mustUpsertPromotion(t, repo, &p)
+
+		if err := repo.PutRedemption(t.Context(), &models.Redemption{Code: "SPRING", CustomerID: 1, Uses: 3}); err != nil {
+			t.Fatalf("PutRedemption: %v", err)
+		}
+		assertCustomerUses(t, repo, "SPRING", 1, 3)
+		if err := repo.Redeem(t.Context(), "SPRING", 1); !errors.Is(err, repository.ErrPromotionCustomerUsedUp) {
+			t.Fatalf("Redeem after PutRedemption error = %v, want ErrPromotionCustomerUsedUp", err)
+		}
+
+		if err := repo.PutRedemption(t.Context(), &models.Redemption{Code: "SPRING", CustomerID: 1, Uses: 1}); err != nil {
+			t.Fatalf("PutRedemption: %v", err)
+		}
+		assertCustomerUses(t, repo, "SPRING", 1, 1)
+	})
+
+	t.Run("PutRedemptionMissing", func(t *testing.T) {
+		repo := newRepo(t)
+
+		err := repo.PutRedemption(t.Context(), &models.Redemption{Code: "MISSING", CustomerID: 1, Uses: 1})
+		if !errors.Is(err, repository.ErrPromotionNotFound) {
+			t.Fatalf("PutRedemption(missing) error = %v, want ErrPromotionNotFound", err)
+		}
+	})
+}
+
+func product(id int) models.Product {
+	return models.Product{
+		ProductID:     id,
+		SKU:           fmt.Sprintf("SKU-%d", id),
+		Manufacturer:  "Acme Corporation",
+		CategoryID:    456,
+		Weight:        1250,
+		Price:         1999,
+		SomeOtherID:   789,
+		PurchaseLimit: 10,
+		Prices:        map[string]int64{"EUR": 1849, "JPY": 2980},
+	}
+}
+
+func promotion(code string) models.Promotion {
+	return models.Promotion{
+		Code:       code,
+		Type:       models.PromotionPercentOff,
+		PercentOff: 10,
+		CategoryID: 456,
+	}
+}
+
+// run calls fn from n goroutines at once and fails the test on any error
+func run(t *testing.T, n int, fn func(i int) error) {
+	t.Helper()
+
+	start := make(chan struct{})
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			errs <- fn(i)
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if t.Failed() {
+		t.FailNow()
+	}
+}
+
+func mustUpsert(t *testing.T, repo repository.ProductRepository, p *models.Product) {
+	t.Helper()
+	if err := repo.Upsert(t.Context(), p); err != nil {
+		t.Fatalf("Upsert(%d): %v", p.ProductID, err)
+	}
+}
+
+func mustGetProduct(t *testing.T, repo repository.ProductRepository, productID int) *models.Product {
+	t.Helper()
+	p, err := repo.GetByID(t.Context(), productID)
+	if err != nil {
+		t.Fatalf("GetByID(%d): %v", productID, err)
+	}
+	return p
+}
+
+func mustCreate(t *testing.T, repo repository.CartRepository, customerID int) *models.Cart {
+	t.Helper()
+	cart, err := repo.Create(t.Context(), customerID, "", "USD", expiresIn(time.Hour))
+	if err != nil {
+		t.Fatalf("Create(%d): %v", customerID, err)
+	}
+	return cart
+}
+
+func mustCreateActive(t *testing.T, repo repository.CartRepository, customerID int, now time.Time, wantCreated bool) *models.Cart {
+	t.Helper()
+	cart, created, err := repo.CreateActive(t.Context(), customerID, "USD", now, now.Add(time.Hour).UTC().Truncate(time.Second))
+	if err != nil {
+		t.Fatalf("CreateActive(%d): %v", customerID, err)
+	}
+	if created != wantCreated {
+		t.Fatalf("CreateActive(%d) created = %t, want %t", customerID, created, wantCreated)
+	}
+	return cart
+}
+
+func mustGetCart(t *testing.T, repo repository.CartRepository, cartID int) *models.Cart {
+	t.Helper()
+	cart, err := repo.GetByID(t.Context(), cartID)
+	if err != nil {
+		t.Fatalf("GetByID(%d): %v", cartID, err)
+	}
+	return cart
+}
+
+func mustAddItem(t *testing.T, repo repository.CartRepository, cartID, productID, quantity int) {
+	t.Helper()
+	if err := repo.AddItem(t.Context(), cartID, models.CartItem{ProductID: productID, Quantity: quantity}, expiresIn(time.Hour)); err != nil {
+		t.Fatalf("AddItem(%d, product %d): %v", cartID, productID, err)
+	}
+}
+
+func mustUpsertPromotion(t *testing.T, repo repository.PromotionRepository, p *models.Promotion) {
+	t.Helper()
+	if err := repo.Upsert(t.Context(), p); err != nil {
+		t.Fatalf("Upsert(%s): %v", p.Code, err)
+	}
+}
+
+func mustGetPromotion(t *testing.T, repo repository.PromotionRepository, code string) *models.Promotion {
+	t.Helper()
+	p, err := repo.GetByCode(t.Context(), code)
+	if err != nil {
+		t.Fatalf("GetByCode(%s): %v", code, err)
+	}
+	return p
+}
+
+func mustRedeem(t *testing.T, repo repository.PromotionRepository, code string, customerID int) {
+	t.Helper()
+	if err := repo.Redeem(t.Context(), code, customerID); err != nil {
+		t.Fatalf("Redeem(%s, customer %d): %v", code, customerID, err)
+	}
+}
+
+// assertCustomerUses compares how often the customer has redeemed the code to want
+func assertCustomerUses(t *testing.T, repo repository.PromotionRepository, code string, customerID, want int) {
+	t.Helper()
+	got, err := repo.CustomerUses(t.Context(), code, customerID)
+	if err != nil {
+		t.Fatalf("CustomerUses(%s, customer %d): %v", code, customerID, err)
+	}
+	if got != want {
+		t.Fatalf("CustomerUses(%s, customer %d) = %d, want %d", code, customerID, got, want)
+	}
+}
+
+// assertCoupons compares a cart's coupon codes, in order, to want
+func assertCoupons(t *testing.T, cart *models.Cart, want ...string) {
+	t.Helper()
+	if strings.Join(cart.Coupons, ",") != strings.Join(want, ",") {
+		t.Fatalf("cart %d coupons = %v, want %v", cart.CartID, cart.Coupons, want)
+	}
+}
+
+// assertTimes compares a cart's times to the wanted ones
+func assertTimes(t *testing.T, cart *models.Cart, createdAt, updatedAt, expiresAt time.Time) {
+	t.Helper()
+	if !cart.CreatedAt.Equal(createdAt) || !cart.UpdatedAt.Equal(updatedAt) || !cart.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("cart %d times = created %v, updated %v, expires %v; want %v, %v, %v", cart.CartID,
+			cart.CreatedAt, cart.UpdatedAt, cart.ExpiresAt, createdAt, updatedAt, expiresAt)
+	}
+}
+
+// assertItems compares a cart's items to product ID → quantity, ignoring order
+func assertItems(t *testing.T, cart *models.Cart, want map[int]int) {
+	t.Helper()
+
+	got := make(map[int]int, len(cart.Items))
+	for _, item := range cart.Items {
+		if _, dup := got[item.ProductID]; dup {
+			t.Errorf("cart %d lists product %d more than once", cart.CartID, item.ProductID)
+		}
+		got[item.ProductID] = item.Quantity
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("cart %d items = %s, want %s", cart.CartID, formatItems(got), formatItems(want))
+	}
+	for productID, quantity := range want {
+		if got[productID] != quantity {
+			t.Fatalf("cart %d items = %s, want %s", cart.CartID, formatItems(got), formatItems(want))
+		}
+	}
+}
+
+func formatItems(items map[int]int) string {
+	ids := make([]int, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	s := "{"
+	for i, id := range ids {
+		if i > 0 {
+			s += ", "
+		}
+		s += fmt.Sprintf("%d:%d", id, items[id])
+	}
+	return s + "}"
+}
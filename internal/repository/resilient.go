@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/resilience"
+)
+
+// ErrCircuitOpen is returned, wrapped, when a backend's circuit breaker is rejecting calls
+var ErrCircuitOpen = resilience.ErrCircuitOpen
+
+// Resilience configures the retry and circuit breaker decorators.
+// Repositories sharing a backend should share a Breaker so they trip together.
+type Resilience struct {
+	Retry resilience.RetryPolicy
+	// Breaker may be nil to only retry
+	Breaker *resilience.Breaker
+}
+
+// call runs fn through the breaker, retrying it as the policy allows. Calls that
+// are not idempotent are only retried after errors that guarantee nothing changed.
+func call[T any](ctx context.Context, r Resilience, idempotent bool, fn func() (T, error)) (T, error) {
+	var result T
+	attempt := func() error {
+		var err error
+		result, err = fn()
+		return err
+	}
+
+	err := resilience.Retry(ctx, r.Retry, idempotent, ClassifyError, func() error {
+		if r.Breaker == nil {
+			return attempt()
+		}
+		return r.Breaker.Do(attempt, isBackendFailure)
+	})
+	return result, err
+}
+
+// ResilientProductRepository retries transient failures of another ProductRepository
+// and fails fast with ErrCircuitOpen while its backend is down
+type ResilientProductRepository struct {
+	repo       ProductRepository
+	resilience Resilience
+}
+
+func NewResilientProductRepository(repo ProductRepository, r Resilience) *ResilientProductRepository {
+	return &ResilientProductRepository{repo: repo, resilience: r}
+}
+
+// GetByID retrieves a product by its ID
+func (r *ResilientProductRepository) GetByID(ctx context.Context, productID int) (*models.Product, error) {
+	return call(ctx, r.resilience, true, func() (*models.Product, error) {
+		return r.repo.GetByID(ctx, productID)
+	})
+}
+
+// GetByIDs retrieves the products that exist among productIDs
+func (r *ResilientProductRepository) GetByIDs(ctx context.Context, productIDs []int) ([]models.Product, error) {
+	return call(ctx, r.resilience, true, func() ([]models.Product, error) {
+		return r.repo.GetByIDs(ctx, productIDs)
+	})
+}
+
+// Upsert creates or updates a product's details; repeating it has no further effect
+func (r *ResilientProductRepository) Upsert(ctx context.Context, product *models.Product) error {
+	_, err := call(ctx, r.resilience, true, func() (struct{}, error) {
+		return struct{}{}, r.repo.Upsert(ctx, product)
+	})
+	return err
+}
+
+// Exists checks if a product exists
+func (r *ResilientProductRepository) Exists(ctx context.Context, productID int) (bool, error) {
+	return call(ctx, r.resilience, true, func() (bool, error) {
+		return r.repo.Exists(ctx, productID)
+	})
+}
+
+// List returns a page of products
+func (r *ResilientProductRepository) List(ctx context.Context, cursor string, limit int) ([]models.Product, string, error) {
+	type page struct {
+		products []models.Product
+		next     string
+	}
+	result, err := call(ctx, r.resilience, true, func() (page, error) {
+		products, next, err := r.repo.List(ctx, cursor, limit)
+		return page{products, next}, err
+	})
+	return result.products, result.next, err
+}
+
+// ResilientCartRepository retries transient failures of another CartRepository
+// and fails fast with ErrCircuitOpen while its backend is down
+type ResilientCartRepository struct {
+	repo       CartRepository
+	resilience Resilience
+}
+
+func NewResilientCartRepository(repo CartRepository, r Resilience) *ResilientCartRepository {
+	return &ResilientCartRepository{repo: repo, resilience: r}
+}
+
+// Create creates a new cart; it is not idempotent, as a repeat would create a second cart
+func (r *ResilientCartRepository) Create(ctx context.Context, customerID int, guestTokenHash, currency string, expiresAt time.Time) (*models.Cart, error) {
+	return call(ctx, r.resilience, false, func() (*models.Cart, error) {
+		return r.repo.Create(ctx, customerID, guestTokenHash, currency, expiresAt)
+	})
+}
+
+// CreateActive returns or creates the customer's active cart; a repeat returns the
+// same cart, though it may then report it as not created
+func (r *ResilientCartRepository) CreateActive(ctx context.Context, customerID int, currency string, now, expiresAt time.Time) (*models.Cart, bool, error) {
+	type active struct {
+		cart    *models.Cart
+		created bool
+	}
+	result, err := call(ctx, r.resilience, true, func() (active, error) {
+		cart, created, err := r.repo.CreateActive(ctx, customerID, currency, now, expiresAt)
+		return active{cart, created}, err
+	})
+	return result.cart, result.created, err
+}
+
+// GetByID retrieves a cart by its ID
+func (r *ResilientCartRepository) GetByID(ctx context.Context, cartID int) (*models.Cart, error) {
+	return call(ctx, r.resilience, true, func() (*models.Cart, error) {
+		return r.repo.GetByID(ctx, cartID)
+	})
+}
+
+// GetActive retrieves the customer's active cart
+func (r *ResilientCartRepository) GetActive(ctx context.Context, customerID int, now time.Time) (*models.Cart, error) {
+	return call(ctx, r.resilience, true, func() (*models.Cart, error) {
+		return r.repo.GetActive(ctx, customerID, now)
+	})
+}
+
+// AddItem adds an item to a cart; it is not idempotent, as a repeat would add the quantity twice
+func (r *ResilientCartRepository) AddItem(ctx context.Context, cartID int, item models.CartItem, expiresAt time.Time) error {
+	_, err := call(ctx, r.resilience, false, func() (struct{}, error) {
+		return struct{}{}, r.repo.AddItem(ctx, cartID, item, expiresAt)
+	})
+	return err
+}
+
+// AddItems adds items to a cart; it is not idempotent, as a repeat would add the quantities twice
+func (r *ResilientCartRepository) AddItems(ctx context.Context, cartID int, items []models.CartItem, expiresAt time.Time) error {
+	_, err := call(ctx, r.resilience, false, func() (struct{}, error) {
+		return struct{}{}, r.repo.AddItems(ctx, cartID, items, expiresAt)
+	})
+	return err
+}
+
+// AddCoupon adds a coupon code to a cart; repeating it has no further effect
+func (r *ResilientCartRepository) AddCoupon(ctx context.Context, cartID int, code string) error {
+	_, err := call(ctx, r.resilience, true, func() (struct{}, error) {
+		return struct{}{}, r.repo.AddCoupon(ctx, cartID, code)
+	})
+	return err
+}
+
+// RemoveCoupon removes a coupon code from a cart; repeating it has no further effect
+func (r *ResilientCartRepository) RemoveCoupon(ctx context.Context, cartID int, code string) error {
+	_, err := call(ctx, r.resilience, true, func() (struct{}, error) {
+		return struct{}{}, r.repo.RemoveCoupon(ctx, cartID, code)
+	})
+	return err
+}
+
+// SetShippingAddress replaces a cart's shipping address; repeating it has no further effect
+func (r *ResilientCartRepository) SetShippingAddress(ctx context.Context, cartID int, address *models.Address) error {
+	_, err := call(ctx, r.resilience, true, func() (struct{}, error) {
+		return struct{}{}, r.repo.SetShippingAddress(ctx, cartID, address)
+	})
+	return err
+}
+
+// SetShippingOption replaces the ID of a cart's shipping option; repeating it has no further effect
+func (r *ResilientCartRepository) SetShippingOption(ctx context.Context, cartID int, optionID string) error {
+	_, err := call(ctx, r.resilience, true, func() (struct{}, error) {
+		return struct{}{}, r.repo.SetShippingOption(ctx, cartID, optionID)
+	})
+	return err
+}
+
+// Delete removes a cart; it is not idempotent, as a repeat would report ErrCartNotFound
+func (r *ResilientCartRepository) Delete(ctx context.Context, cartID int) error {
+	_, err := call(ctx, r.resilience, false, func() (struct{}, error) {
+		return struct{}{}, r.repo.Delete(ctx, cartID)
+	})
+	return err
+}
+
+// List returns a page of carts
+func (r *ResilientCartRepository) List(ctx context.Context, cursor string, limit int) ([]models.Cart, string, error) {
+	type page struct {
+		carts []models.Cart
+		next  string
+	}
+	result, err := call(ctx, r.resilience, true, func() (page, error) {
+		carts, next, err := r.repo.List(ctx, cursor, limit)
+		return page{carts, next}, err
+	})
+	return result.carts, result.next, err
+}
+
+// Put stores a cart; repeating it has no further effect
+func (r *ResilientCartRepository) Put(ctx context.Context, cart *models.Cart) error {
+	_, err := call(ctx, r.resilience, true, func() (struct{}, error) {
+		return struct{}{}, r.repo.Put(ctx, cart)
+	})
+	return err
+}
+
+// ResilientPromotionRepository retries transient failures of another PromotionRepository
+// and fails fast with ErrCircuitOpen while its backend is down
+type ResilientPromotionRepository struct {
+	repo       PromotionRepository
+	resilience Resilience
+}
+
+func NewResilientPromotionRepository(repo PromotionRepository, r Resilience) *ResilientPromotionRepository {
+	return &ResilientPromotionRepository{repo: repo, resilience: r}
+}
+
+// GetByCode retrieves a promotion by its coupon code
+func (r *ResilientPromotionRepository) GetByCode(ctx context.Context, code string) (*models.Promotion, error) {
+	return call(ctx, r.resilience, true, func() (*models.Promotion, error) {
+		return r.repo.GetByCode(ctx, code)
+	})
+}
+
+// Upsert creates or updates a promotion; repeating it has no further effect
+func (r *ResilientPromotionRepository) Upsert(ctx context.Context, promotion *models.Promotion) error {
+	_, err := call(ctx, r.resilience, true, func() (struct{}, error) {
+		return struct{}{}, r.repo.Upsert(ctx, promotion)
+	})
+	return err
+}
+
+// CustomerUses returns how many times the customer has redeemed the code
+func (r *ResilientPromotionRepository) CustomerUses(ctx context.Context, code string, customerID int) (int, error) {
+	return call(ctx, r.resilience, true, func() (int, error) {
+		return r.repo.CustomerUses(ctx, code, customerID)
+	})
+}
+
+// Redeem records a use of the code; it is not idempotent, as a repeat would count twice
+func (r *ResilientPromotionRepository) Redeem(ctx context.Context, code string, customerID int) error {
+	_, err := call(ctx, r.resilience, false, func() (struct{}, error) {
+		return struct{}{}, r.repo.Redeem(ctx, code, customerID)
+	})
+	return err
+}
+
+// Release undoes a use of the code; it is not idempotent, as a repeat would undo two
+func (r *ResilientPromotionRepository) Release(ctx context.Context, code string, customerID int) error {
+	_, err := call(ctx, r.resilience, false, func() (struct{}, error) {
+		return struct{}{}, r.repo.Release(ctx, code, customerID)
+	})
+	return err
+}
+
+// List returns a page of promotions
+func (r *ResilientPromotionRepository) List(ctx context.Context, cursor string, limit int) ([]models.Promotion, string, error) {
+	type page struct {
+		promotions []models.Promotion
+		next       string
+	}
+	result, err := call(ctx, r.resilience, true, func() (page, error) {
+		promotions, next, err := r.repo.List(ctx, cursor, limit)
+		return page{promotions, next}, err
+	})
+	return result.promotions, result.next, err
+}
+
+// Put stores a promotion; repeating it has no further effect
+func (r *ResilientPromotionRepository) Put(ctx context.Context, promotion *models.Promotion) error {
+	_, err := call(ctx, r.resilience, true, func() (struct{}, error) {
+		return struct{}{}, r.repo.Put(ctx, promotion)
+	})
+	return err
+}
+
+// ListRedemptions returns a page of redemptions
+func (r *ResilientPromotionRepository) ListRedemptions(ctx context.Context, cursor string, limit int) ([]models.Redemption, string, error) {
+	type page struct {
+		redemptions []models.Redemption
+		next        string
+	}
+	result, err := call(ctx, r.resilience, true, func() (page, error) {
+		redemptions, next, err := r.repo.ListRedemptions(ctx, cursor, limit)
+		return page{redemptions, next}, err
+	})
+	return result.redemptions, result.next, err
+}
+
+// PutRedemption sets a customer's uses of a code; repeating it has no further effect
+func (r *ResilientPromotionRepository) PutRedemption(ctx context.Context, redemption *models.Redemption) error {
+	_, err := call(ctx, r.resilience, true, func() (struct{}, error) {
+		return struct{}{}, r.repo.PutRedemption(ctx, redemption)
+	})
+	return err
+}
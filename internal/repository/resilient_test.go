@@ -0,0 +1,173 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository/repotest"
+	"github.com/LuoZihYuan/Go-Cart/internal/resilience"
+)
+
+var throttled = &types.ProvisionedThroughputExceededException{Message: new(string)}
+
+// flakyCartRepository fails each call with the queued errors before passing it on
+type flakyCartRepository struct {
+	repository.CartRepository
+	errs  []error
+	calls int
+}
+
+func (r *flakyCartRepository) fail() error {
+	r.calls++
+	if len(r.errs) == 0 {
+		return nil
+	}
+	err := r.errs[0]
+	r.errs = r.errs[1:]
+	return err
+}
+
+func (r *flakyCartRepository) GetByID(ctx context.Context, cartID int) (*models.Cart, error) {
+	if err := r.fail(); err != nil {
+		return nil, err
+	}
+	return r.CartRepository.GetByID(ctx, cartID)
+}
+
+func (r *flakyCartRepository) AddItem(ctx context.Context, cartID int, item models.CartItem, expiresAt time.Time) error {
+	if err := r.fail(); err != nil {
+		return err
+	}
+	return r.CartRepository.AddItem(ctx, cartID, item, expiresAt)
+}
+
+func testResilience(threshold int) repository.Resilience {
+	return repository.Resilience{
+		Retry:   resilience.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		Breaker: resilience.NewBreaker("test", threshold, time.Hour),
+	}
+}
+
+func TestResilientProductRepository(t *testing.T) {
+	repotest.TestProductRepository(t, func(t *testing.T) repository.ProductRepository {
+		return repository.NewResilientProductRepository(repository.NewProductMemoryRepository(), testResilience(5))
+	})
+}
+
+func TestResilientCartRepository(t *testing.T) {
+	repotest.TestCartRepository(t, func(t *testing.T) repository.CartRepository {
+		return repository.NewResilientCartRepository(repository.NewCartMemoryRepository(), testResilience(5))
+	})
+}
+
+func TestResilientPromotionRepository(t *testing.T) {
+	repotest.TestPromotionRepository(t, func(t *testing.T) repository.PromotionRepository {
+		return repository.NewResilientPromotionRepository(repository.NewPromotionMemoryRepository(), testResilience(5))
+	})
+}
+
+func TestResilientCartRepositoryRetries(t *testing.T) {
+	inner := &flakyCartRepository{CartRepository: repository.NewCartMemoryRepository()}
+	repo := repository.NewResilientCartRepository(inner, testResilience(5))
+	cart, err := repo.Create(t.Context(), 1, "", "USD", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Throttled requests were never applied, so even AddItem is retried
+	inner.errs, inner.calls = []error{throttled, throttled}, 0
+	if err := repo.AddItem(t.Context(), cart.CartID, models.CartItem{ProductID: 1, Quantity: 1}, time.Time{}); err != nil {
+		t.Fatalf("AddItem after two throttles: %v", err)
+	}
+	if inner.calls != 3 {
+		t.Errorf("AddItem called %d times, want 3", inner.calls)
+	}
+
+	// A reset connection may have applied the write, so AddItem is not repeated...
+	inner.errs, inner.calls = []error{syscall.ECONNRESET}, 0
+	if err := repo.AddItem(t.Context(), cart.CartID, models.CartItem{ProductID: 1, Quantity: 1}, time.Time{}); !errors.Is(err, syscall.ECONNRESET) {
+		t.Fatalf("AddItem after a reset = %v, want ECONNRESET", err)
+	}
+	if inner.calls != 1 {
+		t.Errorf("AddItem called %d times, want 1", inner.calls)
+	}
+
+	// ...but a read is
+	inner.errs, inner.calls = []error{syscall.ECONNRESET}, 0
+	if _, err := repo.GetByID(t.Context(), cart.CartID); err != nil {
+		t.Fatalf("GetByID after a reset: %v", err)
+	}
+	if inner.calls != 2 {
+		t.Errorf("GetByID called %d times, want 2", inner.calls)
+	}
+
+	// Answers such as "not found" are never retried
+	inner.calls = 0
+	if _, err := repo.GetByID(t.Context(), 404); !errors.Is(err, repository.ErrCartNotFound) {
+		t.Fatalf("GetByID(missing) = %v, want ErrCartNotFound", err)
+	}
+	if inner.calls != 1 {
+		t.Errorf("GetByID(missing) called %d times, want 1", inner.calls)
+	}
+}
+
+func TestResilientCartRepositoryCircuitBreaker(t *testing.T) {
+	inner := &flakyCartRepository{CartRepository: repository.NewCartMemoryRepository()}
+	// Three attempts per call and a threshold of three: one failed call opens the breaker
+	repo := repository.NewResilientCartRepository(inner, testResilience(3))
+
+	inner.errs = []error{throttled, throttled, throttled}
+	if _, err := repo.GetByID(t.Context(), 1); !errors.As(err, new(*types.ProvisionedThroughputExceededException)) {
+		t.Fatalf("GetByID = %v, want the throttling error once retries run out", err)
+	}
+
+	inner.calls = 0
+	_, err := repo.GetByID(t.Context(), 1)
+	if !errors.Is(err, repository.ErrCircuitOpen) {
+		t.Fatalf("GetByID with the breaker open = %v, want ErrCircuitOpen", err)
+	}
+	if inner.calls != 0 {
+		t.Errorf("the backend was called %d times while the breaker was open", inner.calls)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want resilience.Class
+	}{
+		{repository.ErrCartNotFound, resilience.NotRetryable},
+		{context.DeadlineExceeded, resilience.NotRetryable},
+		{fmt.Errorf("get: %w", throttled), resilience.Retryable},
+		{&types.InternalServerError{}, resilience.RetryableIfIdempotent},
+		{&types.ConditionalCheckFailedException{}, resilience.NotRetryable},
+		{&mysql.MySQLError{Number: 1213, Message: "deadlock"}, resilience.Retryable},
+		{&mysql.MySQLError{Number: 1062, Message: "duplicate entry"}, resilience.NotRetryable},
+		{mysql.ErrInvalidConn, resilience.RetryableIfIdempotent},
+		{&pgconn.PgError{Code: "40001"}, resilience.Retryable},
+		{&pgconn.PgError{Code: "08006"}, resilience.RetryableIfIdempotent},
+		{&pgconn.PgError{Code: "23505"}, resilience.NotRetryable},
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, resilience.Retryable},
+		{&net.OpError{Op: "read", Err: syscall.ECONNRESET}, resilience.RetryableIfIdempotent},
+		{io.ErrUnexpectedEOF, resilience.RetryableIfIdempotent},
+		{errors.New("syntax error"), resilience.NotRetryable},
+	}
+
+	for _, tt := range tests {
+		if got := repository.ClassifyError(tt.err); got != tt.want {
+			t.Errorf("ClassifyError(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+)
+
+// The SQL backends share the shape of their bulk export and import; only the
+// statements differ between dialects.
+
+// listProductsSQL runs query, which selects every product column for
+// product_id > after in ID order, limited to limit rows
+func listProductsSQL(ctx context.Context, db *sql.DB, query, cursor string, limit int) ([]models.Product, string, error) {
+	after, err := parseIDCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rows, err := db.QueryContext(ctx, query, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	products := []models.Product{}
+	for rows.Next() {
+		var product models.Product
+		if err := scanProduct(rows, &product); err != nil {
+			return nil, "", err
+		}
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(products) == 0 {
+		return products, "", nil
+	}
+	return products, nextIDCursor(products[len(products)-1].ProductID, len(products), limit), nil
+}
+
+// listCartsSQL runs cartsQuery, which selects the columns read by scanCart and then
+// whether the cart is active for cart_id > after in ID order limited to limit rows, then itemsQuery, which selects cart_id, product_id
+// and quantity for cart IDs between its two arguments
+func listCartsSQL(ctx context.Context, db *sql.DB, cartsQuery, itemsQuery, cursor string, limit int) ([]models.Cart, string, error) {
+	after, err := parseIDCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rows, err := db.QueryContext(ctx, cartsQuery, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	carts := []models.Cart{}
+	index := make(map[int]int) // cart ID → position in carts
+	for rows.Next() {
+		cart := models.Cart{Items: []models.CartItem{}}
+		withActive := scanFunc(func(dest ...any) error {
+			return rows.Scan(append(dest, &cart.Active)...)
+		})
+		if err := scanCart(withActive, &cart); err != nil {
+			return nil, "", err
+		}
+		index[cart.CartID] = len(carts)
+		carts = append(carts, cart)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	rows.Close()
+
+	if len(carts) == 0 {
+		return carts, "", nil
+	}
+	first, last := carts[0].CartID, carts[len(carts)-1].CartID
+
+	items, err := db.QueryContext(ctx, itemsQuery, first, last)
+	if err != nil {
+		return nil, "", err
+	}
+	defer items.Close()
+
+	for items.Next() {
+		var cartID int
+		var item models.CartItem
+		if err := items.Scan(&cartID, &item.ProductID, &item.Quantity); err != nil {
+			return nil, "", err
+		}
+		// Carts created since the first query are not part of this page
+		if i, ok := index[cartID]; ok {
+			carts[i].Items = append(carts[i].Items, item)
+		}
+	}
+	if err := items.Err(); err != nil {
+		return nil, "", err
+	}
+
+	return carts, nextIDCursor(last, len(carts), limit), nil
+}
+
+// cartPutStatements are the dialect's statements for importing a cart
+type cartPutStatements struct {
+	// releaseActive clears active_customer_id of the customer_id on any cart but the cart_id
+	releaseActive string
+	// upsertCart inserts or updates the cart row from cart_id, customer_id, created_at,
+	// updated_at, expires_at, reminded_at, guest_token_hash, coupons, shipping_address,
+	// shipping_option_id, currency and active_customer_id; a NULL created_at is to be
+	// replaced with the current time, and a NULL active_customer_id keeps that of the
+	// replaced cart if its customer_id is unchanged
+	upsertCart string
+	// deleteItems removes the items of the cart_id
+	deleteItems string
+	// insertItem adds one item from cart_id, product_id and quantity
+	insertItem string
+	// afterPut, if set, runs last with the cart_id, e.g. to move an ID sequence past it
+	afterPut string
+}
+
+// putCartSQL replaces a cart and its items in one transaction
+func putCartSQL(ctx context.Context, db *sql.DB, statements cartPutStatements, cart *models.Cart) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The customer's claim is taken from any other cart first, as only one cart may hold it
+	activeCustomerID := sql.NullInt64{Int64: int64(cart.CustomerID), Valid: cart.Active}
+	if cart.Active {
+		if _, err := tx.ExecContext(ctx, statements.releaseActive, cart.CustomerID, cart.CartID); err != nil {
+			return err
+		}
+	}
+
+	shippingAddress, err := nullAddress(cart.ShippingAddress)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, statements.upsertCart, cart.CartID, cart.CustomerID,
+		nullTime(cart.CreatedAt), nullTime(cart.UpdatedAt), nullTime(cart.ExpiresAt), nullTime(cart.RemindedAt),
+		nullString(cart.GuestTokenHash), joinCoupons(cart.Coupons), shippingAddress, cart.ShippingOptionID, cart.Currency,
+		activeCustomerID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, statements.deleteItems, cart.CartID); err != nil {
+		return err
+	}
+	for _, item := range cart.Items {
+		if _, err := tx.ExecContext(ctx, statements.insertItem, cart.CartID, item.ProductID, item.Quantity); err != nil {
+			return err
+		}
+	}
+	if statements.afterPut != "" {
+		if _, err := tx.ExecContext(ctx, statements.afterPut, cart.CartID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// scanFunc adapts a function to the row interface read by scanCart
+type scanFunc func(dest ...any) error
+
+func (f scanFunc) Scan(dest ...any) error {
+	return f(dest...)
+}
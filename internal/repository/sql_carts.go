@@ -0,0 +1,402 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+)
+
+// The SQL cart repositories share how they read, update and expire carts; only
+// the statements differ between dialects.
+
+// nullTime converts a cart time for a nullable column, storing zero as NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+// scannedTime converts a nullable column back to a cart time, reading NULL as zero.
+// Times written by the database itself, such as a default created_at, may be finer
+// than a second and are truncated like the rest.
+func scannedTime(t sql.NullTime) time.Time {
+	if !t.Valid {
+		return time.Time{}
+	}
+	return t.Time.UTC().Truncate(time.Second)
+}
+
+// nullString stores an empty string as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// scanCart reads cart_id, customer_id, created_at, updated_at, expires_at, reminded_at,
+// guest_token_hash, coupons, shipping_address, shipping_option_id and currency into cart
+func scanCart(row interface{ Scan(...any) error }, cart *models.Cart) error {
+	var createdAt, updatedAt, expiresAt, remindedAt sql.NullTime
+	var guestTokenHash, shippingAddress sql.NullString
+	var coupons string
+	if err := row.Scan(&cart.CartID, &cart.CustomerID, &createdAt, &updatedAt, &expiresAt, &remindedAt, &guestTokenHash, &coupons, &shippingAddress, &cart.ShippingOptionID, &cart.Currency); err != nil {
+		return err
+	}
+	cart.GuestTokenHash = guestTokenHash.String
+	cart.Coupons = splitCoupons(coupons)
+	address, err := scannedAddress(shippingAddress)
+	if err != nil {
+		return err
+	}
+	cart.ShippingAddress = address
+	cart.CreatedAt = scannedTime(createdAt)
+	cart.UpdatedAt = scannedTime(updatedAt)
+	cart.ExpiresAt = scannedTime(expiresAt)
+	cart.RemindedAt = scannedTime(remindedAt)
+	return nil
+}
+
+// addCartItemsSQL runs touchCart, which sets updated_at and expires_at from its first
+// two arguments on the cart_id in its third, then upsertItem, which adds to the cart
+// from cart_id, product_id and quantity, for each item, in one transaction.
+// isMissingCart reports whether an error from upsertItem is the cart foreign key failing.
+func addCartItemsSQL(ctx context.Context, db *sql.DB, touchCart, upsertItem string, cartID int, items []models.CartItem, expiresAt time.Time, isMissingCart func(error) bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Updating the cart first locks it against a concurrent delete before the item is added
+	if _, err := tx.ExecContext(ctx, touchCart, cartTimestamp(), nullTime(expiresAt), cartID); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if _, err := tx.ExecContext(ctx, upsertItem, cartID, item.ProductID, item.Quantity); err != nil {
+			// The only foreign key on cart_items is the cart, so a violation means it does not exist
+			if isMissingCart(err) {
+				return ErrCartNotFound
+			}
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// joinCoupons stores a cart's coupon codes in one column; codes never contain commas
+func joinCoupons(coupons []string) string {
+	return strings.Join(coupons, ",")
+}
+
+// splitCoupons reads a cart's coupon codes back from their column, reading "" as none
+func splitCoupons(coupons string) []string {
+	if coupons == "" {
+		return nil
+	}
+	return strings.Split(coupons, ",")
+}
+
+// updateCouponsSQL replaces a cart's coupons with what change returns for them. It runs
+// selectCoupons, which selects coupons of the cart_id, then updateCoupons, which sets
+// coupons to its first argument on the cart_id in its second if they still equal its
+// third, starting over if another request changed them in between.
+func updateCouponsSQL(ctx context.Context, db *sql.DB, selectCoupons, updateCoupons string, cartID int, change func(coupons []string) []string) error {
+	for attempt := 0; attempt < cartUpdateMaxAttempts; attempt++ {
+		var current string
+		err := db.QueryRowContext(ctx, selectCoupons, cartID).Scan(&current)
+		if err == sql.ErrNoRows {
+			return ErrCartNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		updated := joinCoupons(change(splitCoupons(current)))
+		if updated == current {
+			return nil
+		}
+
+		result, err := db.ExecContext(ctx, updateCoupons, updated, cartID, current)
+		if err != nil {
+			return err
+		}
+		swapped, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if swapped == 1 {
+			return nil
+		}
+	}
+	return ErrCartConflict
+}
+
+// nullAddress stores an address as JSON, and nil as NULL
+func nullAddress(address *models.Address) (sql.NullString, error) {
+	if address == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(address)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// scannedAddress reads an address back from its column, reading NULL as nil
+func scannedAddress(column sql.NullString) (*models.Address, error) {
+	if !column.Valid {
+		return nil, nil
+	}
+	var address models.Address
+	if err := json.Unmarshal([]byte(column.String), &address); err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// setShippingAddressSQL runs setAddress, which sets shipping_address to its first
+// argument on the cart_id in its second, as setCartColumnSQL does
+func setShippingAddressSQL(ctx context.Context, db *sql.DB, setAddress, cartExists string, cartID int, address *models.Address) error {
+	column, err := nullAddress(address)
+	if err != nil {
+		return err
+	}
+	return setCartColumnSQL(ctx, db, setAddress, cartExists, cartID, column)
+}
+
+// setCartColumnSQL runs set, which sets a column to its first argument on the cart_id
+// in its second. If that changes no row, cartExists, which selects 1 for the cart_id,
+// tells a missing cart from an unchanged value, which MySQL does not count as affected.
+func setCartColumnSQL(ctx context.Context, db *sql.DB, set, cartExists string, cartID int, value any) error {
+	result, err := db.ExecContext(ctx, set, value, cartID)
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 1 {
+		return nil
+	}
+
+	var exists int
+	err = db.QueryRowContext(ctx, cartExists, cartID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrCartNotFound
+	}
+	return err
+}
+
+// deleteExpiredSQL runs query, which deletes up to its second argument carts whose
+// expires_at is at or before its first, returning how many it deleted
+func deleteExpiredSQL(ctx context.Context, db *sql.DB, query string, now time.Time, limit int) (int, error) {
+	result, err := db.ExecContext(ctx, query, now.UTC(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(deleted), nil
+}
+
+// listAbandonedSQL runs cartsQuery, which selects the columns read by scanCart for up
+// to its third argument carts abandoned since its first and unexpired at its second,
+// then itemsQuery, which selects product_id and quantity of the cart_id, for each cart
+func listAbandonedSQL(ctx context.Context, db *sql.DB, cartsQuery, itemsQuery string, idleSince, now time.Time, limit int) ([]models.Cart, error) {
+	rows, err := db.QueryContext(ctx, cartsQuery, idleSince.UTC(), now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	carts := []models.Cart{}
+	for rows.Next() {
+		var cart models.Cart
+		if err := scanCart(rows, &cart); err != nil {
+			return nil, err
+		}
+		carts = append(carts, cart)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// Batches are small, so each cart's items are read on their own
+	for i := range carts {
+		items, err := cartItemsSQL(ctx, db, itemsQuery, carts[i].CartID)
+		if err != nil {
+			return nil, err
+		}
+		carts[i].Items = items
+	}
+
+	return carts, nil
+}
+
+// cartItemsSQL runs query, which selects product_id and quantity of the cart_id
+func cartItemsSQL(ctx context.Context, db *sql.DB, query string, cartID int) ([]models.CartItem, error) {
+	rows, err := db.QueryContext(ctx, query, cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.CartItem{}
+	for rows.Next() {
+		var item models.CartItem
+		if err := rows.Scan(&item.ProductID, &item.Quantity); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// markRemindedSQL runs query, which sets reminded_at to its first argument on the
+// cart_id in its second if the cart has no reminded_at and an updated_at at or before
+// its third, and reports whether it did
+func markRemindedSQL(ctx context.Context, db *sql.DB, query string, cartID int, idleSince, remindedAt time.Time) (bool, error) {
+	result, err := db.ExecContext(ctx, query, remindedAt.UTC().Truncate(time.Second), cartID, idleSince.UTC())
+	if err != nil {
+		return false, err
+	}
+
+	marked, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return marked == 1, nil
+}
+
+// activeCartStatements are the dialect's statements for a customer's active cart, which
+// holds the customer's ID in active_customer_id
+type activeCartStatements struct {
+	// selectActive selects the columns read by scanCart of the cart whose
+	// active_customer_id is its argument
+	selectActive string
+	// release clears active_customer_id of the cart_id
+	release string
+	// insertActive creates a cart from customer_id, created_at, updated_at, expires_at,
+	// active_customer_id and currency, returning its cart_id unless lastInsertID is set
+	insertActive string
+	// lastInsertID reads the new cart_id from the result of insertActive instead
+	lastInsertID bool
+	// items selects product_id and quantity of the cart_id
+	items string
+}
+
+// getActiveCartSQL retrieves the customer's active cart unless it has expired at now
+func getActiveCartSQL(ctx context.Context, db *sql.DB, statements activeCartStatements, customerID int, now time.Time) (*models.Cart, error) {
+	var cart models.Cart
+	err := scanCart(db.QueryRowContext(ctx, statements.selectActive, customerID), &cart)
+	if err == sql.ErrNoRows {
+		return nil, ErrCartNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cart.Expired(now) {
+		return nil, ErrCartNotFound
+	}
+
+	items, err := cartItemsSQL(ctx, db, statements.items, cart.CartID)
+	if err != nil {
+		return nil, err
+	}
+	cart.Items = items
+	return &cart, nil
+}
+
+// createActiveCartSQL returns the customer's active cart if it has not expired at now,
+// and otherwise creates one expiring at expiresAt. The unique index on
+// active_customer_id lets only one of several concurrent creations commit; the others
+// see isDuplicate fail their insert and start over, finding the winner's cart.
+func createActiveCartSQL(ctx context.Context, db *sql.DB, statements activeCartStatements, customerID int, currency string, now, expiresAt time.Time, isDuplicate func(error) bool) (*models.Cart, bool, error) {
+	for attempt := 0; attempt < cartUpdateMaxAttempts; attempt++ {
+		cart, err := getActiveCartSQL(ctx, db, statements, customerID, now)
+		if err == nil {
+			return cart, false, nil
+		}
+		if err != ErrCartNotFound {
+			return nil, false, err
+		}
+
+		cart, err = insertActiveCartSQL(ctx, db, statements, customerID, currency, now, expiresAt)
+		if err == errActiveCartTaken || isDuplicate(err) {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return cart, true, nil
+	}
+	return nil, false, ErrCartConflict
+}
+
+// errActiveCartTaken means another request made a cart active since it was looked for
+var errActiveCartTaken = errors.New("active cart taken")
+
+// insertActiveCartSQL creates a cart and makes it the customer's active cart in one
+// transaction, taking the place of an active cart that has expired at now
+func insertActiveCartSQL(ctx context.Context, db *sql.DB, statements activeCartStatements, customerID int, currency string, now, expiresAt time.Time) (*models.Cart, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var current models.Cart
+	err = scanCart(tx.QueryRowContext(ctx, statements.selectActive, customerID), &current)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil {
+		if !current.Expired(now) {
+			return nil, errActiveCartTaken
+		}
+		// The expired cart stays behind, answering 410 Gone until it is deleted
+		if _, err := tx.ExecContext(ctx, statements.release, current.CartID); err != nil {
+			return nil, err
+		}
+	}
+
+	created := cartTimestamp()
+	args := []any{customerID, created, created, nullTime(expiresAt), customerID, currency}
+	var cartID int
+	if statements.lastInsertID {
+		result, err := tx.ExecContext(ctx, statements.insertActive, args...)
+		if err != nil {
+			return nil, err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		cartID = int(id)
+	} else if err := tx.QueryRowContext(ctx, statements.insertActive, args...).Scan(&cartID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &models.Cart{
+		CartID:     cartID,
+		CustomerID: customerID,
+		Items:      []models.CartItem{},
+		CreatedAt:  created,
+		UpdatedAt:  created,
+		ExpiresAt:  expiresAt,
+		Currency:   currency,
+	}, nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+)
+
+// scanProduct reads every product column, in table order, into product
+func scanProduct(row interface{ Scan(...any) error }, product *models.Product) error {
+	var prices sql.NullString
+	err := row.Scan(
+		&product.ProductID,
+		&product.SKU,
+		&product.Manufacturer,
+		&product.CategoryID,
+		&product.Weight,
+		&product.Price,
+		&product.SomeOtherID,
+		&product.PurchaseLimit,
+		&prices,
+	)
+	if err != nil {
+		return err
+	}
+	product.Prices = nil
+	if prices.Valid {
+		return json.Unmarshal([]byte(prices.String), &product.Prices)
+	}
+	return nil
+}
+
+// nullPrices stores a price list as JSON, and an empty one as NULL
+func nullPrices(prices map[string]int64) (sql.NullString, error) {
+	if len(prices) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(prices)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// getProductsSQL runs query, which selects every product column for the product IDs
+// in the list its %s is replaced with; placeholder returns the dialect's placeholder
+// for the nth argument, counting from 1
+func getProductsSQL(ctx context.Context, db *sql.DB, query string, placeholder func(n int) string, productIDs []int) ([]models.Product, error) {
+	productIDs = uniqueIDs(productIDs)
+	if len(productIDs) == 0 {
+		return []models.Product{}, nil
+	}
+
+	placeholders := make([]string, len(productIDs))
+	args := make([]any, len(productIDs))
+	for i, productID := range productIDs {
+		placeholders[i] = placeholder(i + 1)
+		args[i] = productID
+	}
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(query, strings.Join(placeholders, ", ")), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []models.Product{}
+	for rows.Next() {
+		var product models.Product
+		if err := scanProduct(rows, &product); err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	return products, rows.Err()
+}
@@ -0,0 +1,266 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+)
+
+// The SQL promotion repositories share how they read and redeem promotions; only the
+// statements differ between dialects.
+
+// promotionStatements are the dialect's statements for promotions and their redemptions
+type promotionStatements struct {
+	// get selects every promotion column of the code
+	get string
+	// upsert inserts or updates a promotion from every column but uses, in table order
+	upsert string
+	// customerUses selects uses of the code and customer_id from promotion_redemptions
+	customerUses string
+	// redeem adds one to uses of the code unless it has reached max_uses
+	redeem string
+	// redeemCustomer adds one to uses of the code and customer_id, inserting the row at 1
+	redeemCustomer string
+	// release takes one from uses of the code unless it is 0
+	release string
+	// releaseCustomer takes one from uses of the code and customer_id unless it is 0
+	releaseCustomer string
+	// list selects every promotion column for code > its argument in code order,
+	// limited to limit rows
+	list string
+	// put inserts or replaces a promotion from every column, in table order
+	put string
+	// listRedemptions selects code, customer_id and uses from promotion_redemptions
+	// following a code and customer_id, given as code, code, customer_id, in code and
+	// then customer order, limited to limit rows
+	listRedemptions string
+	// putRedemption inserts or replaces the uses of a code and customer_id
+	putRedemption string
+}
+
+// scanPromotion reads every promotion column, in table order, into promotion
+func scanPromotion(row interface{ Scan(...any) error }, promotion *models.Promotion) error {
+	var promotionType string
+	var startsAt, endsAt sql.NullTime
+	err := row.Scan(
+		&promotion.Code,
+		&promotionType,
+		&promotion.PercentOff,
+		&promotion.AmountOff,
+		&promotion.BuyQuantity,
+		&promotion.GetQuantity,
+		&promotion.CategoryID,
+		&promotion.ProductID,
+		&startsAt,
+		&endsAt,
+		&promotion.MaxUses,
+		&promotion.MaxUsesPerCustomer,
+		&promotion.Uses,
+	)
+	if err != nil {
+		return err
+	}
+	promotion.Type = models.PromotionType(promotionType)
+	promotion.StartsAt = scannedTime(startsAt)
+	promotion.EndsAt = scannedTime(endsAt)
+	return nil
+}
+
+// getPromotionSQL retrieves a promotion by its coupon code
+func getPromotionSQL(ctx context.Context, db *sql.DB, statements promotionStatements, code string) (*models.Promotion, error) {
+	var promotion models.Promotion
+	err := scanPromotion(db.QueryRowContext(ctx, statements.get, code), &promotion)
+	if err == sql.ErrNoRows {
+		return nil, ErrPromotionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &promotion, nil
+}
+
+// upsertPromotionSQL creates or updates a promotion, leaving its uses alone
+func upsertPromotionSQL(ctx context.Context, db *sql.DB, statements promotionStatements, promotion *models.Promotion) error {
+	_, err := db.ExecContext(ctx, statements.upsert,
+		promotion.Code,
+		string(promotion.Type),
+		promotion.PercentOff,
+		promotion.AmountOff,
+		promotion.BuyQuantity,
+		promotion.GetQuantity,
+		promotion.CategoryID,
+		promotion.ProductID,
+		nullTime(promotion.StartsAt),
+		nullTime(promotion.EndsAt),
+		promotion.MaxUses,
+		promotion.MaxUsesPerCustomer,
+	)
+	return err
+}
+
+// customerUsesSQL returns how many times the customer has redeemed the code
+func customerUsesSQL(ctx context.Context, db *sql.DB, statements promotionStatements, code string, customerID int) (int, error) {
+	var uses int
+	err := db.QueryRowContext(ctx, statements.customerUses, code, customerID).Scan(&uses)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return uses, err
+}
+
+// redeemPromotionSQL records a use of the code, and of the customer's uses of it unless
+// customerID is 0, in one transaction. Updating the promotion first locks it, so
+// concurrent redemptions of the code check the customer's uses one at a time.
+func redeemPromotionSQL(ctx context.Context, db *sql.DB, statements promotionStatements, code string, customerID int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, statements.redeem, code)
+	if err != nil {
+		return err
+	}
+	redeemed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	var promotion models.Promotion
+	err = scanPromotion(tx.QueryRowContext(ctx, statements.get, code), &promotion)
+	if err == sql.ErrNoRows {
+		return ErrPromotionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if redeemed == 0 {
+		return ErrPromotionUsedUp
+	}
+
+	if customerID != 0 {
+		if _, err := tx.ExecContext(ctx, statements.redeemCustomer, code, customerID); err != nil {
+			return err
+		}
+		var uses int
+		if err := tx.QueryRowContext(ctx, statements.customerUses, code, customerID).Scan(&uses); err != nil {
+			return err
+		}
+		if promotion.MaxUsesPerCustomer > 0 && uses > promotion.MaxUsesPerCustomer {
+			return ErrPromotionCustomerUsedUp
+		}
+	}
+
+	return tx.Commit()
+}
+
+// releasePromotionSQL undoes a use of the code, and of the customer's uses of it unless
+// customerID is 0, in one transaction
+func releasePromotionSQL(ctx context.Context, db *sql.DB, statements promotionStatements, code string, customerID int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, statements.release, code); err != nil {
+		return err
+	}
+	if customerID != 0 {
+		if _, err := tx.ExecContext(ctx, statements.releaseCustomer, code, customerID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// listPromotionsSQL returns up to limit promotions following cursor in code order
+func listPromotionsSQL(ctx context.Context, db *sql.DB, statements promotionStatements, cursor string, limit int) ([]models.Promotion, string, error) {
+	rows, err := db.QueryContext(ctx, statements.list, cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	promotions := []models.Promotion{}
+	for rows.Next() {
+		var promotion models.Promotion
+		if err := scanPromotion(rows, &promotion); err != nil {
+			return nil, "", err
+		}
+		promotions = append(promotions, promotion)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(promotions) == 0 {
+		return promotions, "", nil
+	}
+	return promotions, nextCodeCursor(promotions[len(promotions)-1].Code, len(promotions), limit), nil
+}
+
+// putPromotionSQL stores a promotion as given, uses included
+func putPromotionSQL(ctx context.Context, db *sql.DB, statements promotionStatements, promotion *models.Promotion) error {
+	_, err := db.ExecContext(ctx, statements.put,
+		promotion.Code,
+		string(promotion.Type),
+		promotion.PercentOff,
+		promotion.AmountOff,
+		promotion.BuyQuantity,
+		promotion.GetQuantity,
+		promotion.CategoryID,
+		promotion.ProductID,
+		nullTime(promotion.StartsAt),
+		nullTime(promotion.EndsAt),
+		promotion.MaxUses,
+		promotion.MaxUsesPerCustomer,
+		promotion.Uses,
+	)
+	return err
+}
+
+// listRedemptionsSQL returns up to limit redemptions following cursor in code and
+// then customer order
+func listRedemptionsSQL(ctx context.Context, db *sql.DB, statements promotionStatements, cursor string, limit int) ([]models.Redemption, string, error) {
+	code, customerID, err := parseRedemptionCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rows, err := db.QueryContext(ctx, statements.listRedemptions, code, code, customerID, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	redemptions := []models.Redemption{}
+	for rows.Next() {
+		var redemption models.Redemption
+		if err := rows.Scan(&redemption.Code, &redemption.CustomerID, &redemption.Uses); err != nil {
+			return nil, "", err
+		}
+		redemptions = append(redemptions, redemption)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(redemptions) == 0 {
+		return redemptions, "", nil
+	}
+	return redemptions, nextRedemptionCursor(redemptions[len(redemptions)-1], len(redemptions), limit), nil
+}
+
+// putRedemptionSQL sets a customer's uses of a code, returning ErrPromotionNotFound
+// if there is no promotion with the code
+func putRedemptionSQL(ctx context.Context, db *sql.DB, statements promotionStatements, redemption *models.Redemption) error {
+	if _, err := getPromotionSQL(ctx, db, statements, redemption.Code); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, statements.putRedemption, redemption.Code, redemption.CustomerID, redemption.Uses)
+	return err
+}
@@ -0,0 +1,52 @@
+package repository_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/migrations"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository/repotest"
+)
+
+// openSQLite creates a migrated database in a temporary directory
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "gocart.db")
+	db, err := sql.Open("sqlite", "file:"+path+
+		"?_pragma=journal_mode(WAL)&_pragma=foreign_keys(ON)&_pragma=busy_timeout(5000)&_txlock=immediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrations.NewSQLite(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestProductSQLiteRepository(t *testing.T) {
+	repotest.TestProductRepository(t, func(t *testing.T) repository.ProductRepository {
+		return repository.NewProductSQLiteRepository(openSQLite(t))
+	})
+}
+
+func TestCartSQLiteRepository(t *testing.T) {
+	repotest.TestCartRepository(t, func(t *testing.T) repository.CartRepository {
+		return repository.NewCartSQLiteRepository(openSQLite(t))
+	})
+}
+
+func TestPromotionSQLiteRepository(t *testing.T) {
+	repotest.TestPromotionRepository(t, func(t *testing.T) repository.PromotionRepository {
+		return repository.NewPromotionSQLiteRepository(openSQLite(t))
+	})
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/aws/smithy-go"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/resilience"
+)
+
+// MySQL server errors after which the statement was rolled back
+const (
+	mysqlErrTooManyConnections = 1040
+	mysqlErrLockWaitTimeout    = 1205
+	mysqlErrDeadlock           = 1213
+	mysqlErrReadOnly           = 1290 // e.g. during a failover
+)
+
+// DynamoDB error codes for requests that were rejected without being applied
+var dynamoDBRejectedCodes = map[string]bool{
+	"ProvisionedThroughputExceededException": true,
+	"ThrottlingException":                    true,
+	"RequestLimitExceeded":                   true,
+	"TransactionConflictException":           true,
+}
+
+// DynamoDB error codes for server failures where the request may have been applied
+var dynamoDBServerCodes = map[string]bool{
+	"InternalServerError": true,
+	"ServiceUnavailable":  true,
+}
+
+// ClassifyError decides whether a repository call that failed with err may be retried.
+// It recognises throttling, lock conflicts and connection failures from every backend;
+// domain errors such as ErrCartNotFound are never retried.
+func ClassifyError(err error) resilience.Class {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return resilience.NotRetryable
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case dynamoDBRejectedCodes[apiErr.ErrorCode()]:
+			return resilience.Retryable
+		case dynamoDBServerCodes[apiErr.ErrorCode()]:
+			return resilience.RetryableIfIdempotent
+		}
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case mysqlErrTooManyConnections, mysqlErrLockWaitTimeout, mysqlErrDeadlock, mysqlErrReadOnly:
+			return resilience.Retryable
+		}
+		return resilience.NotRetryable
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == "40001", // serialization_failure
+			pgErr.Code == "40P01", // deadlock_detected
+			pgErr.Code == "55P03", // lock_not_available
+			pgErr.Code == "53300", // too_many_connections
+			pgErr.Code == "57P03", // cannot_connect_now
+			pgErr.Code == "08001", // sqlclient_unable_to_establish_sqlconnection
+			pgErr.Code == "08004": // sqlserver_rejected_establishment_of_sqlconnection
+			return resilience.Retryable
+		case strings.HasPrefix(pgErr.Code, "08"), // connection_exception
+			pgErr.Code == "57P01": // admin_shutdown
+			return resilience.RetryableIfIdempotent
+		}
+		return resilience.NotRetryable
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() & 0xff {
+		case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+			return resilience.Retryable
+		}
+		return resilience.NotRetryable
+	}
+
+	// database/sql only returns ErrBadConn when the query was never sent
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, syscall.ECONNREFUSED) {
+		return resilience.Retryable
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return resilience.Retryable
+	}
+
+	var netErr net.Error
+	if errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.As(err, &netErr) {
+		return resilience.RetryableIfIdempotent
+	}
+
+	return resilience.NotRetryable
+}
+
+// isBackendFailure reports whether err suggests the backend is unhealthy, for the circuit breaker
+func isBackendFailure(err error) bool {
+	return ClassifyError(err) != resilience.NotRetryable || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,207 @@
+package resilience
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned, wrapped in an *OpenError, while a breaker rejects calls
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// OpenError reports a call rejected by an open breaker
+type OpenError struct {
+	Name string
+	// Until is when the breaker will let a trial call through
+	Until time.Time
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Name, ErrCircuitOpen)
+}
+
+func (e *OpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// RetryAfter returns how long a client should wait before trying again
+func (e *OpenError) RetryAfter() time.Duration {
+	return max(time.Until(e.Until), 0)
+}
+
+// State is the position of a circuit breaker
+type State int
+
+const (
+	// Closed lets every call through
+	Closed State = iota
+	// Open rejects every call until the open timeout has passed
+	Open
+	// HalfOpen lets a single trial call through to decide whether to close again
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// Breaker is a circuit breaker. After a number of consecutive failures it opens
+// and fails calls immediately; once the open timeout has passed it lets one trial
+// call through, closing again if it succeeds and reopening if it fails.
+type Breaker struct {
+	name        string
+	threshold   int
+	openTimeout time.Duration
+	now         func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int // consecutive failures while closed
+	openedAt time.Time
+	trial    bool // a half-open trial call is in flight
+	// generation counts state changes; a call's result only counts in the
+	// generation it was admitted in, so a slow call cannot decide a later state
+	generation uint64
+}
+
+// BreakerStats is a snapshot of a breaker
+type BreakerStats struct {
+	State    string `json:"state"`
+	Failures int    `json:"consecutive_failures"`
+}
+
+// NewBreaker creates a closed breaker that opens after threshold consecutive failures.
+// A threshold below 1 disables the breaker.
+func NewBreaker(name string, threshold int, openTimeout time.Duration) *Breaker {
+	return &Breaker{
+		name:        name,
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		now:         time.Now,
+	}
+}
+
+// Do calls fn unless the breaker is open. isFailure decides which errors count
+// against the backend; others, such as "not found", count as successes.
+func (b *Breaker) Do(fn func() error, isFailure func(error) bool) error {
+	generation, err := b.allow()
+	if err != nil {
+		return err
+	}
+
+	succeeded := false
+	defer func() {
+		// A panicking call counts as a failure so a half-open trial is never left in flight
+		b.record(generation, succeeded)
+	}()
+
+	err = fn()
+	succeeded = err == nil || !isFailure(err)
+	return err
+}
+
+// State returns the breaker's current state
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	return b.state
+}
+
+// Stats returns a snapshot for metrics
+func (b *Breaker) Stats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	return BreakerStats{State: b.state.String(), Failures: b.failures}
+}
+
+// allow admits a call, returning the generation its result is recorded against
+func (b *Breaker) allow() (uint64, error) {
+	if b.threshold < 1 {
+		return 0, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+
+	switch b.state {
+	case Open:
+		return 0, &OpenError{Name: b.name, Until: b.openedAt.Add(b.openTimeout)}
+	case HalfOpen:
+		if b.trial {
+			return 0, &OpenError{Name: b.name, Until: b.now()}
+		}
+		b.trial = true
+	}
+	return b.generation, nil
+}
+
+// record counts the result of a call admitted in generation
+func (b *Breaker) record(generation uint64, succeeded bool) {
+	if b.threshold < 1 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Calls admitted before the breaker last changed state, such as one still running
+	// when it opened, finish too late to count; only the trial call decides a half-open breaker
+	if generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case HalfOpen:
+		b.trial = false
+		if succeeded {
+			b.setState(Closed)
+			b.failures = 0
+			slog.Info("circuit breaker closed", "breaker", b.name)
+		} else {
+			b.open()
+		}
+	case Closed:
+		if succeeded {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.threshold {
+			b.open()
+		}
+	}
+}
+
+func (b *Breaker) open() {
+	b.setState(Open)
+	b.openedAt = b.now()
+	slog.Warn("circuit breaker opened", "breaker", b.name, "consecutive_failures", b.failures, "open_timeout", b.openTimeout)
+}
+
+// advance moves an open breaker to half-open once its timeout has passed
+func (b *Breaker) advance() {
+	if b.state == Open && !b.now().Before(b.openedAt.Add(b.openTimeout)) {
+		b.setState(HalfOpen)
+		b.trial = false
+	}
+}
+
+// setState moves the breaker to state, starting a new generation
+func (b *Breaker) setState(state State) {
+	b.state = state
+	b.generation++
+}
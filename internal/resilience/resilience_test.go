@@ -0,0 +1,241 @@
+package resilience_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/resilience"
+)
+
+var (
+	errThrottled = errors.New("throttled")
+	errReset     = errors.New("connection reset")
+	errNotFound  = errors.New("not found")
+)
+
+func classify(err error) resilience.Class {
+	switch {
+	case errors.Is(err, errThrottled):
+		return resilience.Retryable
+	case errors.Is(err, errReset):
+		return resilience.RetryableIfIdempotent
+	default:
+		return resilience.NotRetryable
+	}
+}
+
+var policy = resilience.RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+// failing returns a function that fails with the given errors in turn, then succeeds
+func failing(calls *int, errs ...error) func() error {
+	return func() error {
+		*calls++
+		if *calls <= len(errs) {
+			return errs[*calls-1]
+		}
+		return nil
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		idempotent bool
+		errs       []error
+		wantErr    error
+		wantCalls  int
+	}{
+		{name: "Success", errs: nil, wantCalls: 1},
+		{name: "RetryableThenSuccess", errs: []error{errThrottled, errThrottled}, wantCalls: 3},
+		{name: "AttemptsExhausted", errs: []error{errThrottled, errThrottled, errThrottled, errThrottled}, wantErr: errThrottled, wantCalls: 4},
+		{name: "NotRetryable", errs: []error{errNotFound}, wantErr: errNotFound, wantCalls: 1},
+		{name: "AmbiguousIdempotent", idempotent: true, errs: []error{errReset}, wantCalls: 2},
+		{name: "AmbiguousNotIdempotent", errs: []error{errReset}, wantErr: errReset, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := resilience.Retry(t.Context(), policy, tt.idempotent, classify, failing(&calls, tt.errs...))
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("Retry() = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryStopsAtDeadline(t *testing.T) {
+	slow := resilience.RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: time.Second}
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	start := time.Now()
+	err := resilience.Retry(ctx, slow, true, classify, func() error {
+		calls++
+		return errThrottled
+	})
+	if !errors.Is(err, errThrottled) {
+		t.Errorf("Retry() = %v, want the last error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Retry took %v, want it to give up within the 50ms deadline", elapsed)
+	}
+	if calls >= 10 {
+		t.Errorf("called %d times, want retries cut short by the deadline", calls)
+	}
+}
+
+func isFailure(err error) bool {
+	return classify(err) != resilience.NotRetryable
+}
+
+func TestBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	breaker := resilience.NewBreaker("test", 3, time.Hour)
+
+	for range 2 {
+		breaker.Do(func() error { return errThrottled }, isFailure)
+	}
+	// A success, or an error that is an answer rather than a failure, resets the count
+	breaker.Do(func() error { return errNotFound }, isFailure)
+	for range 2 {
+		breaker.Do(func() error { return errThrottled }, isFailure)
+	}
+	if state := breaker.State(); state != resilience.Closed {
+		t.Fatalf("state after 2 consecutive failures = %s, want closed", state)
+	}
+
+	breaker.Do(func() error { return errThrottled }, isFailure)
+	if state := breaker.State(); state != resilience.Open {
+		t.Fatalf("state after 3 consecutive failures = %s, want open", state)
+	}
+
+	called := false
+	err := breaker.Do(func() error { called = true; return nil }, isFailure)
+	if called || !errors.Is(err, resilience.ErrCircuitOpen) {
+		t.Errorf("open breaker: called %v, err %v; want rejected with ErrCircuitOpen", called, err)
+	}
+
+	var openErr *resilience.OpenError
+	if !errors.As(err, &openErr) {
+		t.Fatalf("error %v is not an *OpenError", err)
+	}
+	if retryAfter := openErr.RetryAfter(); retryAfter < 59*time.Minute {
+		t.Errorf("RetryAfter = %v, want about the 1h open timeout", retryAfter)
+	}
+}
+
+func TestBreakerHalfOpenTrial(t *testing.T) {
+	const openTimeout = 20 * time.Millisecond
+	breaker := resilience.NewBreaker("test", 1, openTimeout)
+
+	breaker.Do(func() error { return errThrottled }, isFailure)
+	time.Sleep(2 * openTimeout)
+	if state := breaker.State(); state != resilience.HalfOpen {
+		t.Fatalf("state after the open timeout = %s, want half-open", state)
+	}
+
+	// Only one trial call is let through at a time
+	started := make(chan struct{})
+	release := make(chan struct{})
+	trialDone := make(chan error)
+	go func() {
+		trialDone <- breaker.Do(func() error {
+			close(started)
+			<-release
+			return errThrottled
+		}, isFailure)
+	}()
+	<-started
+	if err := breaker.Do(func() error { return nil }, isFailure); !errors.Is(err, resilience.ErrCircuitOpen) {
+		t.Errorf("call during the trial = %v, want ErrCircuitOpen", err)
+	}
+	close(release)
+	<-trialDone
+
+	if state := breaker.State(); state != resilience.Open {
+		t.Fatalf("state after a failed trial = %s, want open", state)
+	}
+
+	time.Sleep(2 * openTimeout)
+	if err := breaker.Do(func() error { return nil }, isFailure); err != nil {
+		t.Fatalf("trial call: %v", err)
+	}
+	if state := breaker.State(); state != resilience.Closed {
+		t.Fatalf("state after a successful trial = %s, want closed", state)
+	}
+}
+
+// startCall begins a call through breaker that returns result once released.
+// release lets it finish and waits for it.
+func startCall(t *testing.T, breaker *resilience.Breaker, result error) (release func()) {
+	t.Helper()
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- breaker.Do(func() error {
+			close(started)
+			<-unblock
+			return result
+		}, isFailure)
+	}()
+	<-started
+	return func() {
+		close(unblock)
+		if err := <-done; err != result {
+			t.Errorf("call returned %v, want %v", err, result)
+		}
+	}
+}
+
+func TestBreakerIgnoresStaleResults(t *testing.T) {
+	const openTimeout = 20 * time.Millisecond
+	breaker := resilience.NewBreaker("test", 1, openTimeout)
+
+	// Two slow calls are admitted while closed, then another call opens the breaker
+	releaseSuccess := startCall(t, breaker, nil)
+	releaseFailure := startCall(t, breaker, errThrottled)
+	breaker.Do(func() error { return errThrottled }, isFailure)
+	time.Sleep(2 * openTimeout)
+	if state := breaker.State(); state != resilience.HalfOpen {
+		t.Fatalf("state after the open timeout = %s, want half-open", state)
+	}
+
+	// A stale success neither closes the breaker nor takes the trial call's place
+	releaseSuccess()
+	if state := breaker.State(); state != resilience.HalfOpen {
+		t.Fatalf("state after a stale success = %s, want half-open", state)
+	}
+	releaseTrial := startCall(t, breaker, nil)
+
+	// A stale failure neither reopens the breaker nor ends the trial
+	releaseFailure()
+	if state := breaker.State(); state != resilience.HalfOpen {
+		t.Fatalf("state after a stale failure = %s, want half-open", state)
+	}
+	if err := breaker.Do(func() error { return nil }, isFailure); !errors.Is(err, resilience.ErrCircuitOpen) {
+		t.Errorf("call during the trial = %v, want ErrCircuitOpen", err)
+	}
+
+	releaseTrial()
+	if state := breaker.State(); state != resilience.Closed {
+		t.Fatalf("state after a successful trial = %s, want closed", state)
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	breaker := resilience.NewBreaker("test", 0, time.Hour)
+	for range 10 {
+		breaker.Do(func() error { return errThrottled }, isFailure)
+	}
+	if err := breaker.Do(func() error { return nil }, isFailure); err != nil {
+		t.Errorf("disabled breaker rejected a call: %v", err)
+	}
+}
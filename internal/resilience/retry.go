@@ -0,0 +1,81 @@
+// Package resilience retries transient failures and stops calling backends
+// that keep failing.
+package resilience
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Class describes whether a failed call may be tried again
+type Class int
+
+const (
+	// NotRetryable errors would fail again, or are answers rather than failures (not found, invalid input)
+	NotRetryable Class = iota
+	// Retryable errors guarantee the call had no effect, e.g. throttling or a refused connection
+	Retryable
+	// RetryableIfIdempotent errors leave it unknown whether the call took effect,
+	// e.g. a connection reset after the request was sent
+	RetryableIfIdempotent
+)
+
+// RetryPolicy bounds how a call is retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of calls, including the first; 1 disables retries
+	MaxAttempts int
+	// BaseDelay is the backoff before the second attempt; it doubles with each attempt
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff
+	MaxDelay time.Duration
+}
+
+// Retry calls fn until it succeeds or returns an error that should not be retried.
+// Idempotent calls are retried after Retryable and RetryableIfIdempotent errors,
+// other calls only after Retryable ones. Backoff is exponential with full jitter.
+// Retrying stops when attempts run out, when ctx is done, or when the next delay
+// would end after ctx's deadline; the last error is returned.
+func Retry(ctx context.Context, policy RetryPolicy, idempotent bool, classify func(error) Class, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= policy.MaxAttempts {
+			return err
+		}
+
+		switch classify(err) {
+		case Retryable:
+		case RetryableIfIdempotent:
+			if !idempotent {
+				return err
+			}
+		default:
+			return err
+		}
+
+		delay := policy.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns a random delay of up to BaseDelay·2^(attempt-1), capped at MaxDelay
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.MaxDelay
+	if shift := attempt - 1; shift < 32 && p.BaseDelay<<shift < p.MaxDelay && p.BaseDelay<<shift > 0 {
+		ceiling = p.BaseDelay << shift
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling + 1)
+}
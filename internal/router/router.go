@@ -7,8 +7,9 @@ import (
 )
 
 type AllHandlers struct {
-	ProductHandler *handlers.ProductHandler
-	CartHandler    *handlers.CartHandler
+	ProductHandler   *handlers.ProductHandler
+	CartHandler      *handlers.CartHandler
+	PromotionHandler *handlers.PromotionHandler
 }
 
 func SetupRoutes(r *gin.Engine, h *AllHandlers) {
@@ -28,6 +29,25 @@ func SetupRoutes(r *gin.Engine, h *AllHandlers) {
 			carts.GET("/:shoppingCartId", h.CartHandler.GetCart)
 			carts.POST("/:shoppingCartId/items", h.CartHandler.AddItemsToCart)
 			carts.POST("/:shoppingCartId/checkout", h.CartHandler.CheckoutCart)
+			carts.POST("/:shoppingCartId/merge", h.CartHandler.MergeCart)
+			carts.POST("/:shoppingCartId/coupons", h.CartHandler.ApplyCoupon)
+			carts.DELETE("/:shoppingCartId/coupons/:code", h.CartHandler.RemoveCoupon)
+			carts.PUT("/:shoppingCartId/shipping-address", h.CartHandler.SetShippingAddress)
+			carts.GET("/:shoppingCartId/shipping-options", h.CartHandler.GetShippingOptions)
+			carts.PUT("/:shoppingCartId/shipping-option", h.CartHandler.SelectShippingOption)
+		}
+
+		// Promotion routes
+		promotions := v1.Group("/promotions")
+		{
+			promotions.GET("/:code", h.PromotionHandler.GetPromotion)
+			promotions.PUT("/:code", h.PromotionHandler.UpsertPromotion)
+		}
+
+		// Customer routes
+		customers := v1.Group("/customers")
+		{
+			customers.GET("/:customerId/active-cart", h.CartHandler.GetActiveCart)
 		}
 	}
 }
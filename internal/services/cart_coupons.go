@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+)
+
+// maxCartCoupons is the most coupon codes one cart may hold
+const maxCartCoupons = 5
+
+var (
+	ErrCouponNotFound  = NotFound("Coupon not found", "No promotion exists with the coupon code")
+	ErrCouponNotActive = &Error{
+		Kind:    KindInvalidState,
+		Code:    "COUPON_NOT_ACTIVE",
+		Message: "Coupon not active",
+		Details: "The coupon's promotion has not started or has ended",
+	}
+	ErrCouponUsedUp = &Error{
+		Kind:    KindInvalidState,
+		Code:    "COUPON_USED_UP",
+		Message: "Coupon used up",
+		Details: "The coupon has been redeemed as often as it may be",
+	}
+	ErrCouponNeedsCustomer = InvalidState("Coupon requires a customer", "The coupon is limited per customer and cannot be applied to a guest cart")
+	ErrTooManyCoupons      = InvalidState("Too many coupons", fmt.Sprintf("A cart may hold at most %d coupons", maxCartCoupons))
+)
+
+// ApplyCoupon adds a coupon code, in any case, to a cart and returns the cart priced
+// with it; guestToken is required for a guest cart. The code's limits are checked
+// now and again at checkout, where it is redeemed. Applying a code the cart already
+// holds changes nothing.
+func (s *CartService) ApplyCoupon(ctx context.Context, cartID int, guestToken string, code string) (*models.PricedCart, error) {
+	if cartID < 1 {
+		return nil, ErrInvalidCart
+	}
+	code = normalizeCouponCode(code)
+	if !couponCodePattern.MatchString(code) {
+		return nil, ErrCouponNotFound
+	}
+
+	// Verify cart exists and has not expired
+	cart, err := s.getCart(ctx, cartID, guestToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(cart.Coupons, code) {
+		if len(cart.Coupons) >= maxCartCoupons {
+			return nil, ErrTooManyCoupons
+		}
+		if err := s.checkCoupon(ctx, cart, code); err != nil {
+			return nil, err
+		}
+
+		err = s.cartRepo.AddCoupon(ctx, cartID, code)
+		if errors.Is(err, repository.ErrCartNotFound) {
+			return nil, ErrCartNotFound
+		}
+		if err != nil {
+			return nil, repositoryError(err)
+		}
+
+		if cart, err = s.getCart(ctx, cartID, guestToken); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.price(ctx, cart)
+}
+
+// RemoveCoupon removes a coupon code, in any case, from a cart; guestToken is required
+// for a guest cart. Removing a code the cart does not hold changes nothing.
+func (s *CartService) RemoveCoupon(ctx context.Context, cartID int, guestToken string, code string) error {
+	if cartID < 1 {
+		return ErrInvalidCart
+	}
+
+	// Verify cart exists and has not expired
+	if _, err := s.getCart(ctx, cartID, guestToken); err != nil {
+		return err
+	}
+
+	err := s.cartRepo.RemoveCoupon(ctx, cartID, normalizeCouponCode(code))
+	if errors.Is(err, repository.ErrCartNotFound) {
+		return ErrCartNotFound
+	}
+	if err != nil {
+		return repositoryError(err)
+	}
+
+	return nil
+}
+
+// checkCoupon reports why code cannot be applied to cart, if it cannot
+func (s *CartService) checkCoupon(ctx context.Context, cart *models.Cart, code string) error {
+	promotion, err := s.promotionRepo.GetByCode(ctx, code)
+	if errors.Is(err, repository.ErrPromotionNotFound) {
+		return ErrCouponNotFound
+	}
+	if err != nil {
+		return repositoryError(err)
+	}
+
+	if !promotion.Active(time.Now()) {
+		return ErrCouponNotActive
+	}
+	if promotion.MaxUses > 0 && promotion.Uses >= promotion.MaxUses {
+		return ErrCouponUsedUp
+	}
+
+	if promotion.MaxUsesPerCustomer > 0 {
+		// A guest's uses cannot be told apart from another guest's
+		if cart.Guest() {
+			return ErrCouponNeedsCustomer
+		}
+		uses, err := s.promotionRepo.CustomerUses(ctx, code, cart.CustomerID)
+		if err != nil {
+			return repositoryError(err)
+		}
+		if uses >= promotion.MaxUsesPerCustomer {
+			return ErrCouponUsedUp
+		}
+	}
+
+	return nil
+}
+
+// activePromotions looks up the promotions of codes, in order, leaving out those that
+// no longer exist or are not active now
+func (s *CartService) activePromotions(ctx context.Context, codes []string) ([]models.Promotion, error) {
+	now := time.Now()
+
+	var promotions []models.Promotion
+	for _, code := range codes {
+		promotion, err := s.promotionRepo.GetByCode(ctx, code)
+		if errors.Is(err, repository.ErrPromotionNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, repositoryError(err)
+		}
+		if promotion.Active(now) {
+			promotions = append(promotions, *promotion)
+		}
+	}
+	return promotions, nil
+}
+
+// redeemCoupons redeems the codes for the customer, all of them or none
+func (s *CartService) redeemCoupons(ctx context.Context, codes []string, customerID int) error {
+	for i, code := range codes {
+		err := s.promotionRepo.Redeem(ctx, code, customerID)
+		if err == nil {
+			continue
+		}
+
+		if releaseErr := s.releaseCoupons(context.WithoutCancel(ctx), codes[:i], customerID); releaseErr != nil {
+			return repositoryError(errors.Join(err, releaseErr))
+		}
+		switch {
+		case errors.Is(err, repository.ErrPromotionUsedUp), errors.Is(err, repository.ErrPromotionCustomerUsedUp):
+			return ErrCouponUsedUp
+		case errors.Is(err, repository.ErrPromotionNotFound):
+			return ErrCouponNotFound
+		default:
+			return repositoryError(err)
+		}
+	}
+	return nil
+}
+
+// releaseCoupons undoes the redemption of the codes by the customer
+func (s *CartService) releaseCoupons(ctx context.Context, codes []string, customerID int) error {
+	var errs []error
+	for _, code := range codes {
+		if err := s.promotionRepo.Release(ctx, code, customerID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
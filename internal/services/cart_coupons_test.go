@@ -0,0 +1,242 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+	"github.com/LuoZihYuan/Go-Cart/internal/services"
+)
+
+// failingDeletes is a cart repository whose carts cannot be deleted
+type failingDeletes struct {
+	repository.CartRepository
+}
+
+func (failingDeletes) Delete(context.Context, int) error {
+	return errors.New("delete failed")
+}
+
+// addPromotion stores a 10% off promotion with code and the given limits
+func (f *cartFixture) addPromotion(t *testing.T, code string, maxUses, maxUsesPerCustomer int) {
+	t.Helper()
+
+	promotion := models.Promotion{
+		Code:               code,
+		Type:               models.PromotionPercentOff,
+		PercentOff:         10,
+		MaxUses:            maxUses,
+		MaxUsesPerCustomer: maxUsesPerCustomer,
+	}
+	if err := f.promotions.Upsert(t.Context(), &promotion); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkUses fails the test unless code has been redeemed uses times in all and
+// customerUses times by customerID
+func (f *cartFixture) checkUses(t *testing.T, code string, customerID, uses, customerUses int) {
+	t.Helper()
+
+	promotion, err := f.promotions.GetByCode(t.Context(), code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.promotions.CustomerUses(t.Context(), code, customerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if promotion.Uses != uses || got != customerUses {
+		t.Fatalf("%s uses = %d, by customer %d = %d; want %d and %d", code, promotion.Uses, customerID, got, uses, customerUses)
+	}
+}
+
+func TestApplyCoupon(t *testing.T) {
+	f := newCartFixture(t, 0, services.QuantityLimits{})
+	f.addProducts(t, 1)
+	f.addPromotion(t, "SAVE10", 0, 0)
+	cartID := f.newCart(t, 1, models.CartItem{ProductID: 1, Quantity: 2})
+
+	priced, err := f.service.ApplyCoupon(t.Context(), cartID, "", " save10 ")
+	if err != nil {
+		t.Fatalf("ApplyCoupon: %v", err)
+	}
+	if len(priced.Coupons) != 1 || priced.Coupons[0] != "SAVE10" || priced.Total != 1800 {
+		t.Fatalf("priced cart = coupons %v, total %d; want SAVE10 and 1800", priced.Coupons, priced.Total)
+	}
+
+	// Applying the code again changes nothing
+	if priced, err = f.service.ApplyCoupon(t.Context(), cartID, "", "SAVE10"); err != nil || len(priced.Coupons) != 1 {
+		t.Fatalf("ApplyCoupon again = %v, %v; want one coupon", priced, err)
+	}
+
+	// Coupons are only redeemed at checkout
+	f.checkUses(t, "SAVE10", 1, 0, 0)
+
+	if err := f.service.RemoveCoupon(t.Context(), cartID, "", "save10"); err != nil {
+		t.Fatalf("RemoveCoupon: %v", err)
+	}
+	cart, err := f.service.GetCart(t.Context(), cartID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cart.Coupons) != 0 || cart.Total != 2000 {
+		t.Fatalf("cart after RemoveCoupon = coupons %v, total %d; want none and 2000", cart.Coupons, cart.Total)
+	}
+}
+
+func TestApplyCouponRejected(t *testing.T) {
+	f := newCartFixture(t, 0, services.QuantityLimits{})
+	f.addProducts(t, 1)
+	f.addPromotion(t, "USEDUP", 1, 0)
+	f.addPromotion(t, "ONCE", 0, 1)
+	if err := f.promotions.Redeem(t.Context(), "USEDUP", 9); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.promotions.Redeem(t.Context(), "ONCE", 1); err != nil {
+		t.Fatal(err)
+	}
+	guest, token, err := f.service.CreateGuestCart(t.Context(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		customerID  int
+		guestCartID int
+		code        string
+		want        *services.Error
+	}{
+		{name: "malformed code", customerID: 1, code: "NOT A CODE", want: services.ErrCouponNotFound},
+		{name: "missing code", customerID: 1, code: "MISSING", want: services.ErrCouponNotFound},
+		{name: "used up", customerID: 1, code: "USEDUP", want: services.ErrCouponUsedUp},
+		{name: "used up by the customer", customerID: 1, code: "ONCE", want: services.ErrCouponUsedUp},
+		{name: "another customer", customerID: 2, code: "ONCE"},
+		{name: "limited per customer on a guest cart", guestCartID: guest.CartID, code: "ONCE", want: services.ErrCouponNeedsCustomer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cartID, guestToken := tt.guestCartID, ""
+			if cartID == 0 {
+				cartID = f.newCart(t, tt.customerID)
+			} else {
+				guestToken = token
+			}
+
+			_, err := f.service.ApplyCoupon(t.Context(), cartID, guestToken, tt.code)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("ApplyCoupon: %v", err)
+				}
+				return
+			}
+			if err != tt.want {
+				t.Fatalf("ApplyCoupon error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyCouponLimitsCouponsPerCart(t *testing.T) {
+	f := newCartFixture(t, 0, services.QuantityLimits{})
+	cartID := f.newCart(t, 1)
+
+	for i := range 6 {
+		code := fmt.Sprintf("CODE%d", i)
+		f.addPromotion(t, code, 0, 0)
+		_, err := f.service.ApplyCoupon(t.Context(), cartID, "", code)
+		if i < 5 && err != nil {
+			t.Fatalf("ApplyCoupon %s: %v", code, err)
+		}
+		if i == 5 && err != services.ErrTooManyCoupons {
+			t.Fatalf("ApplyCoupon %s error = %v, want ErrTooManyCoupons", code, err)
+		}
+	}
+	if !strings.Contains(services.ErrTooManyCoupons.Details, "at most 5 coupons") {
+		t.Fatalf("ErrTooManyCoupons details = %q, want the limit of 5", services.ErrTooManyCoupons.Details)
+	}
+}
+
+func TestCheckoutRedeemsCoupons(t *testing.T) {
+	f := newCartFixture(t, 0, services.QuantityLimits{})
+	f.addProducts(t, 1)
+	f.addPromotion(t, "SAVE10", 0, 1)
+	f.addPromotion(t, "REMOVED", 0, 0)
+	cartID := f.newCart(t, 1, models.CartItem{ProductID: 1, Quantity: 1})
+	for _, code := range []string{"SAVE10", "REMOVED"} {
+		if _, err := f.service.ApplyCoupon(t.Context(), cartID, "", code); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.service.RemoveCoupon(t.Context(), cartID, "", "REMOVED"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, priced, err := f.service.CheckoutCart(t.Context(), cartID, "")
+	if err != nil {
+		t.Fatalf("CheckoutCart: %v", err)
+	}
+	if priced.Total != 900 {
+		t.Fatalf("checkout total = %d, want 900", priced.Total)
+	}
+	f.checkUses(t, "SAVE10", 1, 1, 1)
+	// A removed coupon is not redeemed
+	f.checkUses(t, "REMOVED", 1, 0, 0)
+
+	// The customer has used up the code, so it cannot be applied again
+	next := f.newCart(t, 1, models.CartItem{ProductID: 1, Quantity: 1})
+	if _, err := f.service.ApplyCoupon(t.Context(), next, "", "SAVE10"); err != services.ErrCouponUsedUp {
+		t.Fatalf("ApplyCoupon after checkout error = %v, want ErrCouponUsedUp", err)
+	}
+}
+
+func TestFailedCheckoutReleasesCoupons(t *testing.T) {
+	t.Run("cart not deleted", func(t *testing.T) {
+		f := newCartFixture(t, 0, services.QuantityLimits{})
+		f.addProducts(t, 1)
+		f.addPromotion(t, "SAVE10", 10, 1)
+		cartID := f.newCart(t, 1, models.CartItem{ProductID: 1, Quantity: 1})
+		if _, err := f.service.ApplyCoupon(t.Context(), cartID, "", "SAVE10"); err != nil {
+			t.Fatal(err)
+		}
+
+		service := services.NewCartService(failingDeletes{f.carts}, f.products, f.promotions, services.Pricing{Currency: "USD"}, 0, false, services.QuantityLimits{})
+		if _, _, err := service.CheckoutCart(t.Context(), cartID, ""); err == nil {
+			t.Fatal("CheckoutCart succeeded, want the failed delete to fail it")
+		}
+		f.checkUses(t, "SAVE10", 1, 0, 0)
+	})
+
+	t.Run("coupon used up at checkout", func(t *testing.T) {
+		f := newCartFixture(t, 0, services.QuantityLimits{})
+		f.addProducts(t, 1)
+		f.addPromotion(t, "FIRST", 0, 0)
+		f.addPromotion(t, "LAST", 1, 0)
+		cartID := f.newCart(t, 1, models.CartItem{ProductID: 1, Quantity: 1})
+		for _, code := range []string{"FIRST", "LAST"} {
+			if _, err := f.service.ApplyCoupon(t.Context(), cartID, "", code); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Another checkout takes the last use of LAST after it was applied
+		if err := f.promotions.Redeem(t.Context(), "LAST", 2); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := f.service.CheckoutCart(t.Context(), cartID, ""); err != services.ErrCouponUsedUp {
+			t.Fatalf("CheckoutCart error = %v, want ErrCouponUsedUp", err)
+		}
+		f.checkUses(t, "FIRST", 1, 0, 0)
+		f.checkUses(t, "LAST", 1, 1, 0)
+		if _, err := f.service.GetCart(t.Context(), cartID, ""); err != nil {
+			t.Fatalf("cart after a failed checkout: %v", err)
+		}
+	})
+}
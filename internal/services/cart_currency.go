@@ -0,0 +1,109 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/pricing"
+)
+
+var (
+	ErrUnsupportedCurrency = &Error{
+		Kind:    KindInvalidInput,
+		Code:    "UNSUPPORTED_CURRENCY",
+		Message: "Unsupported currency",
+		Details: "Carts can only be priced in the base currency and those with an exchange rate in effect",
+	}
+	ErrActiveCartCurrency = &Error{
+		Kind:    KindInvalidState,
+		Code:    "CURRENCY_MISMATCH",
+		Message: "Currency mismatch",
+		Details: "The customer's active cart is priced in another currency",
+	}
+	ErrMergeCurrency = &Error{
+		Kind:    KindInvalidState,
+		Code:    "CURRENCY_MISMATCH",
+		Message: "Currency mismatch",
+		Details: "The guest cart is priced in another currency",
+	}
+)
+
+// newCartCurrency returns the currency a new cart is priced in: currency in upper case,
+// or the base currency if it is empty. A cart can be priced in a currency only if
+// amounts in the base currency can be converted to it now.
+func (s *CartService) newCartCurrency(currency string) (string, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" || currency == s.pricing.Currency {
+		return s.pricing.Currency, nil
+	}
+
+	if _, ok := pricing.MinorUnits(currency); !ok || s.pricing.Exchange == nil {
+		return "", ErrUnsupportedCurrency
+	}
+	if _, err := s.pricing.Exchange.Convert(0, s.pricing.Currency, currency, time.Now()); err != nil {
+		return "", ErrUnsupportedCurrency
+	}
+	return currency, nil
+}
+
+// withCurrency fills in the base currency on a cart stored before carts had currencies
+func (s *CartService) withCurrency(cart *models.Cart) *models.Cart {
+	if cart.Currency == "" {
+		cart.Currency = s.pricing.Currency
+	}
+	return cart
+}
+
+// fromBase converts an amount in the base currency to currency
+func (s *CartService) fromBase(amount int64, currency string) (int64, error) {
+	return s.convert(amount, s.pricing.Currency, currency)
+}
+
+// toBase converts an amount in currency to the base currency
+func (s *CartService) toBase(amount int64, currency string) (int64, error) {
+	return s.convert(amount, currency, s.pricing.Currency)
+}
+
+// convert converts amount between currencies at the exchange rates in effect now
+func (s *CartService) convert(amount int64, from, to string) (int64, error) {
+	if from == to {
+		return amount, nil
+	}
+	if s.pricing.Exchange == nil {
+		return 0, Internal(fmt.Errorf("%w from %s to %s: no exchange rates are configured", pricing.ErrNoExchangeRate, from, to))
+	}
+	converted, err := s.pricing.Exchange.Convert(amount, from, to, time.Now())
+	if err != nil {
+		return 0, Internal(err)
+	}
+	return converted, nil
+}
+
+// checkPrices reports the items, named by field, whose products have no price in
+// currency; products holds every item's product
+func (s *CartService) checkPrices(currency string, items []models.CartItem, products map[int]*models.Product, field func(i int, name string) string) error {
+	var fields []models.FieldError
+	for i, item := range items {
+		if _, ok := products[item.ProductID].PriceIn(currency, s.pricing.Currency); !ok {
+			fields = append(fields, unpricedProductField(field(i, "product_id"), item.ProductID, currency))
+		}
+	}
+	if len(fields) > 0 {
+		return priceNotAvailable(fields)
+	}
+	return nil
+}
+
+// priceNotAvailable rejects items whose products are not sold in the cart's currency,
+// each field's Param giving the currency
+func priceNotAvailable(fields []models.FieldError) *Error {
+	return &Error{
+		Kind:    KindInvalidState,
+		Code:    "PRICE_NOT_AVAILABLE",
+		Message: "Price not available",
+		Details: "One or more products have no price in the cart's currency",
+		Fields:  fields,
+	}
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/pricing"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+)
+
+var (
+	ErrNoShippingAddress = &Error{
+		Kind:    KindInvalidState,
+		Code:    "SHIPPING_ADDRESS_REQUIRED",
+		Message: "Shipping address required",
+		Details: "The cart needs a shipping address to work out its shipping and taxes",
+	}
+	ErrNoShippingOption = &Error{
+		Kind:    KindInvalidState,
+		Code:    "SHIPPING_OPTION_REQUIRED",
+		Message: "Shipping option required",
+		Details: "Choose one of the cart's shipping options before checking out",
+	}
+	ErrShippingOptionUnavailable = &Error{
+		Kind:    KindInvalidState,
+		Code:    "SHIPPING_OPTION_UNAVAILABLE",
+		Message: "Shipping option unavailable",
+		Details: "The shipping option does not ship the cart to its address",
+	}
+)
+
+// Pricing holds how carts are charged beyond their items and discounts; a nil Taxes
+// or Shipping charges nothing for it
+type Pricing struct {
+	// Currency is the ISO 4217 code of the base currency: that of products' prices,
+	// fixed promotion amounts and shipping rates, and of carts that name none
+	Currency string
+	// Exchange converts amounts in the base currency to that of a cart; without it
+	// carts can only be priced in the base currency
+	Exchange pricing.Converter
+	// Taxes works out the taxes on a cart from its shipping address
+	Taxes pricing.TaxCalculator
+	// Shipping quotes the ways a cart can be shipped to its address
+	Shipping pricing.ShippingCalculator
+}
+
+// requiresAddress reports whether carts need a shipping address to be checked out
+func (p Pricing) requiresAddress() bool {
+	return p.Taxes != nil || p.Shipping != nil
+}
+
+// SetShippingAddress sets where a cart is shipped, and so how it is taxed, and returns
+// the cart priced with it; guestToken is required for a guest cart
+func (s *CartService) SetShippingAddress(ctx context.Context, cartID int, guestToken string, address *models.Address) (*models.PricedCart, error) {
+	if cartID < 1 {
+		return nil, ErrInvalidCart
+	}
+	normalizeAddress(address)
+
+	// Verify cart exists and has not expired
+	if _, err := s.getCart(ctx, cartID, guestToken); err != nil {
+		return nil, err
+	}
+
+	err := s.cartRepo.SetShippingAddress(ctx, cartID, address)
+	if errors.Is(err, repository.ErrCartNotFound) {
+		return nil, ErrCartNotFound
+	}
+	if err != nil {
+		return nil, repositoryError(err)
+	}
+
+	return s.GetCart(ctx, cartID, guestToken)
+}
+
+// ShippingOptions lists the ways a cart can be shipped to its address, cheapest first;
+// guestToken is required for a guest cart. The list is empty if no shipping rates are
+// configured.
+func (s *CartService) ShippingOptions(ctx context.Context, cartID int, guestToken string) (*models.ShippingOptionsResponse, error) {
+	if cartID < 1 {
+		return nil, ErrInvalidCart
+	}
+
+	cart, err := s.getCart(ctx, cartID, guestToken)
+	if err != nil {
+		return nil, err
+	}
+	if cart.ShippingAddress == nil {
+		return nil, ErrNoShippingAddress
+	}
+
+	priced, lines, err := s.priceGoods(ctx, cart)
+	if err != nil {
+		return nil, err
+	}
+	options, err := s.shippingOptions(*cart.ShippingAddress, cart.Currency, lines, priced.Total)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ShippingOptionsResponse{
+		Weight:   pricing.Weight(lines),
+		Currency: cart.Currency,
+		Options:  options,
+	}, nil
+}
+
+// SelectShippingOption chooses how a cart is shipped and returns the cart priced with
+// it; guestToken is required for a guest cart. The option must be one of the cart's
+// shipping options. Its price is quoted again whenever the cart is priced, and it
+// stops counting if the cart changes so that it is no longer offered.
+func (s *CartService) SelectShippingOption(ctx context.Context, cartID int, guestToken string, optionID string) (*models.PricedCart, error) {
+	if cartID < 1 {
+		return nil, ErrInvalidCart
+	}
+	optionID = strings.TrimSpace(optionID)
+
+	cart, err := s.getCart(ctx, cartID, guestToken)
+	if err != nil {
+		return nil, err
+	}
+	if cart.ShippingAddress == nil {
+		return nil, ErrNoShippingAddress
+	}
+
+	priced, lines, err := s.priceGoods(ctx, cart)
+	if err != nil {
+		return nil, err
+	}
+	options, err := s.shippingOptions(*cart.ShippingAddress, cart.Currency, lines, priced.Total)
+	if err != nil {
+		return nil, err
+	}
+	if findShippingOption(options, optionID) == nil {
+		return nil, ErrShippingOptionUnavailable
+	}
+
+	err = s.cartRepo.SetShippingOption(ctx, cartID, optionID)
+	if errors.Is(err, repository.ErrCartNotFound) {
+		return nil, ErrCartNotFound
+	}
+	if err != nil {
+		return nil, repositoryError(err)
+	}
+
+	return s.GetCart(ctx, cartID, guestToken)
+}
+
+// normalizeAddress trims an address's fields and upper-cases its codes
+func normalizeAddress(address *models.Address) {
+	address.Name = strings.TrimSpace(address.Name)
+	address.Line1 = strings.TrimSpace(address.Line1)
+	address.Line2 = strings.TrimSpace(address.Line2)
+	address.City = strings.TrimSpace(address.City)
+	address.State = strings.ToUpper(strings.TrimSpace(address.State))
+	address.PostalCode = strings.ToUpper(strings.TrimSpace(address.PostalCode))
+	address.Country = strings.ToUpper(strings.TrimSpace(address.Country))
+}
+
+// price prices cart in its currency at its products' current prices with those of its
+// coupons whose promotions exist and are active now. If it has a shipping address, its chosen
+// shipping option is added while it is still offered, and it is taxed. Taxes are
+// worked out on what the items cost after discounts; shipping is not taxed.
+func (s *CartService) price(ctx context.Context, cart *models.Cart) (*models.PricedCart, error) {
+	priced, lines, err := s.priceGoods(ctx, cart)
+	if err != nil {
+		return nil, err
+	}
+	if cart.ShippingAddress == nil {
+		return priced, nil
+	}
+	address, goods := *cart.ShippingAddress, priced.Total
+
+	if cart.ShippingOptionID != "" {
+		options, err := s.shippingOptions(address, cart.Currency, lines, goods)
+		if err != nil {
+			return nil, err
+		}
+		priced.Shipping = findShippingOption(options, cart.ShippingOptionID)
+		if priced.Shipping != nil {
+			priced.Total += priced.Shipping.Price
+		}
+	}
+
+	if s.pricing.Taxes == nil {
+		return priced, nil
+	}
+	amounts := pricing.Allocate(lines, priced.Subtotal, goods)
+	priced.Taxes, err = s.pricing.Taxes.Taxes(address, lines, amounts)
+	if err != nil {
+		return nil, Internal(err)
+	}
+	for _, tax := range priced.Taxes {
+		if !tax.Inclusive {
+			priced.Total += tax.Amount
+		}
+	}
+	return priced, nil
+}
+
+// priceGoods prices cart's items and discounts only, returning them with the lines
+// they were worked out on. Fixed promotion amounts are converted to cart's currency.
+func (s *CartService) priceGoods(ctx context.Context, cart *models.Cart) (*models.PricedCart, []pricing.Line, error) {
+	products, err := s.itemProducts(ctx, cart.Items)
+	if err != nil {
+		return nil, nil, err
+	}
+	promotions, err := s.activePromotions(ctx, cart.Coupons)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range promotions {
+		if promotions[i].Type != models.PromotionFixedOff {
+			continue
+		}
+		if promotions[i].AmountOff, err = s.fromBase(promotions[i].AmountOff, cart.Currency); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	lines := pricing.Lines(cart.Items, products, cart.Currency, s.pricing.Currency)
+	priced := &models.PricedCart{Cart: *cart}
+	priced.Subtotal, priced.Discounts, priced.Total = pricing.Price(lines, promotions)
+	return priced, lines, nil
+}
+
+// shippingOptions quotes the ways lines, whose goods cost goods in currency, can be
+// shipped to address, priced in currency; it never returns nil options. Shipping
+// rates are in the base currency, so goods are converted to it to be quoted.
+func (s *CartService) shippingOptions(address models.Address, currency string, lines []pricing.Line, goods int64) ([]models.ShippingOption, error) {
+	if s.pricing.Shipping == nil {
+		return []models.ShippingOption{}, nil
+	}
+	baseGoods, err := s.toBase(goods, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	options := s.pricing.Shipping.Options(address, pricing.Weight(lines), baseGoods)
+	if options == nil {
+		return []models.ShippingOption{}, nil
+	}
+	for i := range options {
+		if options[i].Price, err = s.fromBase(options[i].Price, currency); err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+// findShippingOption returns the option of options with id, or nil if there is none
+func findShippingOption(options []models.ShippingOption, id string) *models.ShippingOption {
+	for i := range options {
+		if options[i].ID == id {
+			return &options[i]
+		}
+	}
+	return nil
+}
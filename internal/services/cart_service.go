@@ -1,61 +1,175 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"github.com/LuoZihYuan/Go-Cart/internal/models"
 	"github.com/LuoZihYuan/Go-Cart/internal/repository"
 )
 
 var (
-	ErrCartNotFound = errors.New("cart not found")
-	ErrInvalidCart  = errors.New("invalid cart data")
-	ErrEmptyCart    = errors.New("cart is empty")
+	ErrCartNotFound = NotFound("Cart not found", "No cart exists with the specified ID")
+	ErrInvalidCart  = InvalidInput("Invalid input data", "invalid cart data")
+	ErrEmptyCart    = InvalidState("Cart is empty", "Cannot checkout an empty cart")
+	ErrCartExpired  = Gone("CART_EXPIRED", "Cart expired", "The cart expired after a period of inactivity")
+
+	ErrInvalidCustomer     = InvalidInput("Invalid input data", "invalid customer ID")
+	ErrNoActiveCart        = NotFound("Active cart not found", "The customer has no open cart")
+	ErrActiveCartsDisabled = NotFound("Active cart not found", "Customers are not limited to one active cart")
+
+	ErrInvalidGuestToken  = InvalidInput("Invalid guest token", "The guest token is malformed")
+	ErrGuestCartNotFound  = NotFound("Guest cart not found", "No guest cart matches the token")
+	ErrMergeIntoGuestCart = InvalidState("Cannot merge into a guest cart", "A guest cart can only be merged into a customer's cart")
 )
 
 type CartService struct {
-	cartRepo    repository.CartRepository
-	productRepo repository.ProductRepository
+	cartRepo      repository.CartRepository
+	productRepo   repository.ProductRepository
+	promotionRepo repository.PromotionRepository
+	pricing       Pricing
+	ttl           time.Duration
+	singleActive  bool
+	limits        QuantityLimits
 }
 
-func NewCartService(cartRepo repository.CartRepository, productRepo repository.ProductRepository) *CartService {
+// NewCartService creates a cart service whose carts expire ttl after they last
+// changed; a ttl of 0 keeps carts forever. With singleActive, each customer has one
+// open cart, which creating a cart returns if there is one. Adding items beyond
+// limits is rejected with a QUANTITY_LIMIT_EXCEEDED error, and adding products with
+// no price in the cart's currency with a PRICE_NOT_AVAILABLE error. Carts are priced
+// with the promotions of their coupons and then shipped and taxed as pricing says,
+// by their shipping address, which checkout then requires.
+func NewCartService(cartRepo repository.CartRepository, productRepo repository.ProductRepository, promotionRepo repository.PromotionRepository, pricing Pricing, ttl time.Duration, singleActive bool, limits QuantityLimits) *CartService {
 	return &CartService{
-		cartRepo:    cartRepo,
-		productRepo: productRepo,
+		cartRepo:      cartRepo,
+		productRepo:   productRepo,
+		promotionRepo: promotionRepo,
+		pricing:       pricing,
+		ttl:           ttl,
+		singleActive:  singleActive,
+		limits:        limits,
+	}
+}
+
+// expiry returns when a cart changed now expires, or zero if carts never expire
+func (s *CartService) expiry() time.Time {
+	if s.ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(s.ttl).UTC().Truncate(time.Second)
+}
+
+// getCart retrieves a cart, reporting one that has expired but not yet been deleted as gone.
+// A guest cart is only found with its guestToken; customer carts ignore it.
+func (s *CartService) getCart(ctx context.Context, cartID int, guestToken string) (*models.Cart, error) {
+	cart, err := s.cartRepo.GetByID(ctx, cartID)
+	if errors.Is(err, repository.ErrCartNotFound) {
+		return nil, ErrCartNotFound
 	}
+	if err != nil {
+		return nil, repositoryError(err)
+	}
+
+	// Without the token a guest cart is indistinguishable from a missing one
+	if cart.Guest() && !guestTokenMatches(guestToken, cart.CartID, cart.GuestTokenHash) {
+		return nil, ErrCartNotFound
+	}
+
+	if cart.Expired(time.Now()) {
+		return nil, ErrCartExpired
+	}
+
+	return s.withCurrency(cart), nil
 }
 
-// CreateCart creates a new cart
-func (s *CartService) CreateCart(customerID int) (*models.Cart, error) {
+// CreateCart creates a new cart for the customer, priced in currency, or the base
+// currency if it is empty. If each customer has one active cart and theirs is open,
+// it is returned instead; created reports which. Asking for a currency other than
+// that of the open cart is an error.
+func (s *CartService) CreateCart(ctx context.Context, customerID int, currency string) (cart *models.Cart, created bool, err error) {
 	if customerID < 1 {
-		return nil, ErrInvalidCart
+		return nil, false, ErrInvalidCart
+	}
+	cartCurrency, err := s.newCartCurrency(currency)
+	if err != nil {
+		return nil, false, err
 	}
 
-	return s.cartRepo.Create(customerID)
+	if s.singleActive {
+		cart, created, err = s.cartRepo.CreateActive(ctx, customerID, cartCurrency, time.Now(), s.expiry())
+	} else {
+		cart, err = s.cartRepo.Create(ctx, customerID, "", cartCurrency, s.expiry())
+		created = true
+	}
+	if err != nil {
+		return nil, false, repositoryError(err)
+	}
+
+	s.withCurrency(cart)
+	if !created && currency != "" && cart.Currency != cartCurrency {
+		return nil, false, ErrActiveCartCurrency
+	}
+	return cart, created, nil
 }
 
-// AddItemToCart adds an item to a cart
-func (s *CartService) AddItemToCart(cartID int, productID int, quantity int) error {
+// GetActiveCart retrieves the customer's open cart, priced, if each customer has one active cart
+func (s *CartService) GetActiveCart(ctx context.Context, customerID int) (*models.PricedCart, error) {
+	if customerID < 1 {
+		return nil, ErrInvalidCustomer
+	}
+	if !s.singleActive {
+		return nil, ErrActiveCartsDisabled
+	}
+
+	cart, err := s.cartRepo.GetActive(ctx, customerID, time.Now())
+	if errors.Is(err, repository.ErrCartNotFound) {
+		return nil, ErrNoActiveCart
+	}
+	if err != nil {
+		return nil, repositoryError(err)
+	}
+
+	return s.price(ctx, s.withCurrency(cart))
+}
+
+// CreateGuestCart creates a cart for a visitor who has not signed in, priced in
+// currency as CreateCart does, returning it with the token that grants access to it
+func (s *CartService) CreateGuestCart(ctx context.Context, currency string) (*models.Cart, string, error) {
+	cartCurrency, err := s.newCartCurrency(currency)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, hash := newGuestSecret()
+
+	cart, err := s.cartRepo.Create(ctx, 0, hash, cartCurrency, s.expiry())
+	if err != nil {
+		return nil, "", repositoryError(err)
+	}
+
+	return cart, formatGuestToken(cart.CartID, secret), nil
+}
+
+// AddItemToCart adds an item to a cart; guestToken is required for a guest cart
+func (s *CartService) AddItemToCart(ctx context.Context, cartID int, guestToken string, productID int, quantity int) error {
 	if cartID < 1 || productID < 1 || quantity < 1 {
 		return ErrInvalidCart
 	}
 
-	// Verify cart exists
-	_, err := s.cartRepo.GetByID(cartID)
-	if err == repository.ErrCartNotFound {
-		return ErrCartNotFound
-	}
+	// Verify cart exists and has not expired
+	cart, err := s.getCart(ctx, cartID, guestToken)
 	if err != nil {
 		return err
 	}
 
 	// Verify product exists
-	_, err = s.productRepo.GetByID(productID)
-	if err == repository.ErrProductNotFound {
+	product, err := s.productRepo.GetByID(ctx, productID)
+	if errors.Is(err, repository.ErrProductNotFound) {
 		return ErrProductNotFound
 	}
 	if err != nil {
-		return err
+		return repositoryError(err)
 	}
 
 	// Add item to cart
@@ -64,27 +178,134 @@ func (s *CartService) AddItemToCart(cartID int, productID int, quantity int) err
 		Quantity:  quantity,
 	}
 
-	return s.cartRepo.AddItem(cartID, item)
+	products := map[int]*models.Product{productID: product}
+	if err := s.checkPrices(cart.Currency, []models.CartItem{item}, products, requestField); err != nil {
+		return err
+	}
+
+	// Limits are checked against the cart as read, so concurrent adds may each pass
+	if err := s.limits.check(cart, []models.CartItem{item}, products, requestField); err != nil {
+		return err
+	}
+
+	// Adding an item restarts the cart's lifetime
+	err = s.cartRepo.AddItem(ctx, cartID, item, s.expiry())
+	if errors.Is(err, repository.ErrCartNotFound) {
+		return ErrCartNotFound
+	}
+	if err != nil {
+		return repositoryError(err)
+	}
+
+	return nil
+}
+
+// AddItemsToCart adds several items to a cart, all of them or none; guestToken is
+// required for a guest cart. Every product is looked up in one batch, and each item
+// that names a missing product is listed in the error's Fields.
+func (s *CartService) AddItemsToCart(ctx context.Context, cartID int, guestToken string, items []models.CartItem) error {
+	if cartID < 1 || len(items) == 0 {
+		return ErrInvalidCart
+	}
+
+	var fields []models.FieldError
+	for i, item := range items {
+		if item.ProductID < 1 {
+			fields = append(fields, minField(itemField(i, "product_id"), 1))
+		}
+		if item.Quantity < 1 {
+			fields = append(fields, minField(itemField(i, "quantity"), 1))
+		}
+	}
+	if len(fields) > 0 {
+		return ValidationFailed(fields...)
+	}
+
+	// Verify cart exists and has not expired
+	cart, err := s.getCart(ctx, cartID, guestToken)
+	if err != nil {
+		return err
+	}
+
+	// Verify every product exists
+	products, err := s.itemProducts(ctx, items)
+	if err != nil {
+		return err
+	}
+	for i, item := range items {
+		if products[item.ProductID] == nil {
+			fields = append(fields, missingProductField(itemField(i, "product_id"), item.ProductID))
+		}
+	}
+	if len(fields) > 0 {
+		return &Error{
+			Kind:    KindNotFound,
+			Message: "Product not found",
+			Details: "One or more products do not exist",
+			Fields:  fields,
+		}
+	}
+
+	if err := s.checkPrices(cart.Currency, items, products, itemField); err != nil {
+		return err
+	}
+
+	// Limits are checked against the cart as read, so concurrent adds may each pass
+	if err := s.limits.check(cart, items, products, itemField); err != nil {
+		return err
+	}
+
+	// Adding items restarts the cart's lifetime
+	err = s.cartRepo.AddItems(ctx, cartID, items, s.expiry())
+	if errors.Is(err, repository.ErrCartNotFound) {
+		return ErrCartNotFound
+	}
+	if err != nil {
+		return repositoryError(err)
+	}
+
+	return nil
 }
 
-// CheckoutCart processes checkout for a cart
-func (s *CartService) CheckoutCart(cartID int) (int, error) {
+// CheckoutCart processes checkout for a cart, returning the order ID and the cart as
+// priced for the order; guestToken is required for a guest cart. The cart's active
+// coupons are redeemed, all of them or none, and released again if the cart cannot
+// be deleted.
+func (s *CartService) CheckoutCart(ctx context.Context, cartID int, guestToken string) (int, *models.PricedCart, error) {
 	if cartID < 1 {
-		return 0, ErrInvalidCart
+		return 0, nil, ErrInvalidCart
 	}
 
 	// Get cart
-	cart, err := s.cartRepo.GetByID(cartID)
-	if err == repository.ErrCartNotFound {
-		return 0, ErrCartNotFound
-	}
+	cart, err := s.getCart(ctx, cartID, guestToken)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
-	// Validate cart has items
+	// Validate cart has items and, if it is to be shipped or taxed, somewhere to ship them
 	if len(cart.Items) == 0 {
-		return 0, ErrEmptyCart
+		return 0, nil, ErrEmptyCart
+	}
+	if s.pricing.requiresAddress() && cart.ShippingAddress == nil {
+		return 0, nil, ErrNoShippingAddress
+	}
+	if s.pricing.Shipping != nil && cart.ShippingOptionID == "" {
+		return 0, nil, ErrNoShippingOption
+	}
+
+	priced, err := s.price(ctx, cart)
+	if err != nil {
+		return 0, nil, err
+	}
+	if s.pricing.Shipping != nil && priced.Shipping == nil {
+		return 0, nil, ErrShippingOptionUnavailable
+	}
+	codes := make([]string, len(priced.Discounts))
+	for i, discount := range priced.Discounts {
+		codes[i] = discount.Code
+	}
+	if err := s.redeemCoupons(ctx, codes, cart.CustomerID); err != nil {
+		return 0, nil, err
 	}
 
 	// In a real system, this would:
@@ -96,23 +317,124 @@ func (s *CartService) CheckoutCart(cartID int) (int, error) {
 	// For now, just generate an order ID and delete the cart
 	orderID := cartID * 1000 // Simple order ID generation
 
-	err = s.cartRepo.Delete(cartID)
+	err = s.cartRepo.Delete(ctx, cartID)
 	if err != nil {
-		return 0, err
+		if releaseErr := s.releaseCoupons(context.WithoutCancel(ctx), codes, cart.CustomerID); releaseErr != nil {
+			err = errors.Join(err, releaseErr)
+		}
+		if errors.Is(err, repository.ErrCartNotFound) {
+			return 0, nil, ErrCartNotFound
+		}
+		return 0, nil, repositoryError(err)
 	}
 
-	return orderID, nil
+	return orderID, priced, nil
 }
 
-// GetCart retrieves a cart
-func (s *CartService) GetCart(cartID int) (*models.Cart, error) {
+// GetCart retrieves a cart, priced; guestToken is required for a guest cart
+func (s *CartService) GetCart(ctx context.Context, cartID int, guestToken string) (*models.PricedCart, error) {
 	if cartID < 1 {
 		return nil, ErrInvalidCart
 	}
 
-	cart, err := s.cartRepo.GetByID(cartID)
-	if err == repository.ErrCartNotFound {
-		return nil, ErrCartNotFound
+	cart, err := s.getCart(ctx, cartID, guestToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.price(ctx, cart)
+}
+
+// MergeGuestCart folds the guest cart named by guestToken into the customer's cart,
+// adding its items as AddItemToCart would, and deletes the guest cart. Both carts
+// must be priced in the same currency. The guest cart's coupons are not carried over.
+// The guest cart is deleted first so that of concurrent merges only one applies; if
+// adding the items fails, the guest cart is put back.
+func (s *CartService) MergeGuestCart(ctx context.Context, cartID int, guestToken string) (*models.PricedCart, error) {
+	if cartID < 1 {
+		return nil, ErrInvalidCart
+	}
+	guestCartID, _, ok := parseGuestToken(guestToken)
+	if !ok {
+		return nil, ErrInvalidGuestToken
+	}
+
+	cart, err := s.getCart(ctx, cartID, "")
+	if err != nil {
+		return nil, err
+	}
+	if cart.Guest() {
+		return nil, ErrMergeIntoGuestCart
+	}
+
+	guest, err := s.getCart(ctx, guestCartID, guestToken)
+	if errors.Is(err, ErrCartNotFound) {
+		return nil, ErrGuestCartNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// getCart ignores the token of a customer's cart, so it would name any of them
+	if !guest.Guest() {
+		return nil, ErrGuestCartNotFound
+	}
+	if guest.Currency != cart.Currency {
+		return nil, ErrMergeCurrency
+	}
+	if err := s.checkMergeLimits(ctx, cart, guest); err != nil {
+		return nil, err
+	}
+
+	err = s.cartRepo.Delete(ctx, guest.CartID)
+	if errors.Is(err, repository.ErrCartNotFound) {
+		// Merged or checked out concurrently
+		return nil, ErrGuestCartNotFound
+	}
+	if err != nil {
+		return nil, repositoryError(err)
+	}
+
+	if len(guest.Items) > 0 {
+		err := s.cartRepo.AddItems(ctx, cart.CartID, guest.Items, s.expiry())
+		if err != nil {
+			if restoreErr := s.cartRepo.Put(context.WithoutCancel(ctx), guest); restoreErr != nil {
+				err = errors.Join(err, restoreErr)
+			}
+			if errors.Is(err, repository.ErrCartNotFound) {
+				return nil, ErrCartNotFound
+			}
+			return nil, repositoryError(err)
+		}
+	}
+
+	return s.GetCart(ctx, cart.CartID, "")
+}
+
+// checkMergeLimits checks that the guest cart's items fit in cart, naming them by
+// their position in the guest cart
+func (s *CartService) checkMergeLimits(ctx context.Context, cart, guest *models.Cart) error {
+	products, err := s.itemProducts(ctx, guest.Items)
+	if err != nil {
+		return err
+	}
+	return s.limits.check(cart, guest.Items, products, itemField)
+}
+
+// itemProducts looks up the products of items in one batch, keyed by ID;
+// missing products are left out
+func (s *CartService) itemProducts(ctx context.Context, items []models.CartItem) (map[int]*models.Product, error) {
+	productIDs := make([]int, len(items))
+	for i, item := range items {
+		productIDs[i] = item.ProductID
+	}
+	products, err := s.productRepo.GetByIDs(ctx, productIDs)
+	if err != nil {
+		return nil, repositoryError(err)
+	}
+
+	byID := make(map[int]*models.Product, len(products))
+	for i := range products {
+		byID[products[i].ProductID] = &products[i]
 	}
-	return cart, err
+	return byID, nil
 }
@@ -0,0 +1,75 @@
+package services_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+	"github.com/LuoZihYuan/Go-Cart/internal/services"
+)
+
+// cartFixture is a cart service over memory repositories, pricing carts in USD
+type cartFixture struct {
+	service    *services.CartService
+	carts      *repository.CartMemoryRepository
+	products   *repository.ProductMemoryRepository
+	promotions *repository.PromotionMemoryRepository
+}
+
+func newCartFixture(t *testing.T, ttl time.Duration, limits services.QuantityLimits) *cartFixture {
+	t.Helper()
+
+	f := &cartFixture{
+		carts:      repository.NewCartMemoryRepository(),
+		products:   repository.NewProductMemoryRepository(),
+		promotions: repository.NewPromotionMemoryRepository(),
+	}
+	f.service = services.NewCartService(f.carts, f.products, f.promotions, services.Pricing{Currency: "USD"}, ttl, false, limits)
+	return f
+}
+
+// addProducts stores a product with each of ids, priced at 1000 and with no purchase limit
+func (f *cartFixture) addProducts(t *testing.T, ids ...int) {
+	t.Helper()
+
+	for _, id := range ids {
+		f.addProduct(t, models.Product{ProductID: id, SKU: "SKU", Manufacturer: "Acme", CategoryID: 1, Price: 1000, SomeOtherID: 1})
+	}
+}
+
+func (f *cartFixture) addProduct(t *testing.T, product models.Product) {
+	t.Helper()
+
+	if err := f.products.Upsert(t.Context(), &product); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newCart creates a cart for customerID holding items
+func (f *cartFixture) newCart(t *testing.T, customerID int, items ...models.CartItem) int {
+	t.Helper()
+
+	cart, _, err := f.service.CreateCart(t.Context(), customerID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) > 0 {
+		if err := f.service.AddItemsToCart(t.Context(), cart.CartID, "", items); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cart.CartID
+}
+
+// serviceError returns err as a service error, failing the test if it is not one
+func serviceError(t *testing.T, err error) *services.Error {
+	t.Helper()
+
+	var serviceErr *services.Error
+	if !errors.As(err, &serviceErr) {
+		t.Fatalf("error = %v, want a service error", err)
+	}
+	return serviceErr
+}
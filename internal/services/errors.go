@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+)
+
+// Kind classifies a domain error so the transport layer can map it to a response
+type Kind int
+
+const (
+	// KindInternal is an unexpected failure whose details must not reach clients
+	KindInternal Kind = iota
+	// KindInvalidInput means the request data was rejected
+	KindInvalidInput
+	// KindNotFound means a referenced resource does not exist
+	KindNotFound
+	// KindInvalidState means the resource cannot perform the operation in its current state
+	KindInvalidState
+	// KindUnavailable means a backend is temporarily refusing requests and the client may retry later
+	KindUnavailable
+	// KindGone means the resource existed but is no longer available and will not return
+	KindGone
+)
+
+// Error is a typed domain error returned by the services.
+// Message, Details and Fields are safe to show to clients; Err is the underlying cause and is only logged.
+type Error struct {
+	Kind Kind
+	// Code, if set, replaces the Kind's error code so clients can tell this error apart
+	Code    string
+	Message string
+	Details string
+	Fields  []models.FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if e.Details != "" {
+		msg += ": " + e.Details
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// InvalidInput creates a KindInvalidInput error
+func InvalidInput(message, details string) *Error {
+	return &Error{Kind: KindInvalidInput, Message: message, Details: details}
+}
+
+// NotFound creates a KindNotFound error
+func NotFound(message, details string) *Error {
+	return &Error{Kind: KindNotFound, Message: message, Details: details}
+}
+
+// InvalidState creates a KindInvalidState error
+func InvalidState(message, details string) *Error {
+	return &Error{Kind: KindInvalidState, Message: message, Details: details}
+}
+
+// Gone creates a KindGone error with its own error code
+func Gone(code, message, details string) *Error {
+	return &Error{Kind: KindGone, Code: code, Message: message, Details: details}
+}
+
+// Internal wraps an unexpected error so it is logged but never shown to clients
+func Internal(err error) *Error {
+	return &Error{Kind: KindInternal, Message: "Internal server error", Err: err}
+}
+
+// Unavailable wraps an error from a backend that is temporarily out of service
+func Unavailable(err error) *Error {
+	return &Error{
+		Kind:    KindUnavailable,
+		Message: "Service unavailable",
+		Details: "A backend service is temporarily unavailable, please retry later",
+		Err:     err,
+	}
+}
+
+// repositoryError wraps an unexpected repository error, reporting an open circuit
+// breaker or an exceeded request deadline as Unavailable and anything else as Internal
+func repositoryError(err error) *Error {
+	if errors.Is(err, repository.ErrCircuitOpen) || errors.Is(err, context.DeadlineExceeded) {
+		return Unavailable(err)
+	}
+	return Internal(err)
+}
+
+// KindOf reports the Kind of err, treating untyped errors as internal
+func KindOf(err error) Kind {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr.Kind
+	}
+	return KindInternal
+}
+
+// ValidationFailed creates a KindInvalidInput error listing the rejected fields
+func ValidationFailed(fields ...models.FieldError) *Error {
+	return &Error{
+		Kind:    KindInvalidInput,
+		Message: "Invalid input data",
+		Details: "One or more fields failed validation",
+		Fields:  fields,
+	}
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// A guest token is "<cart ID>.<secret>". Only the SHA-256 of the secret is stored, so
+// the tokens cannot be recovered from the database; the cart ID lets a token alone
+// name its cart.
+
+// guestSecretBytes is the entropy of a guest token's secret
+const guestSecretBytes = 24
+
+// newGuestSecret returns a random secret and the hash stored for it
+func newGuestSecret() (secret, hash string) {
+	b := make([]byte, guestSecretBytes)
+	// crypto/rand.Read never fails on supported platforms
+	rand.Read(b)
+	secret = base64.RawURLEncoding.EncodeToString(b)
+	return secret, hashGuestSecret(secret)
+}
+
+func hashGuestSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// formatGuestToken returns the token given to the guest for a cart
+func formatGuestToken(cartID int, secret string) string {
+	return strconv.Itoa(cartID) + "." + secret
+}
+
+// parseGuestToken splits a token into the cart it names and its secret
+func parseGuestToken(token string) (cartID int, secret string, ok bool) {
+	id, secret, found := strings.Cut(token, ".")
+	if !found || secret == "" {
+		return 0, "", false
+	}
+	cartID, err := strconv.Atoi(id)
+	if err != nil || cartID < 1 {
+		return 0, "", false
+	}
+	return cartID, secret, true
+}
+
+// guestTokenMatches reports whether token was issued for the guest cart with the given
+// ID and stored hash
+func guestTokenMatches(token string, cartID int, hash string) bool {
+	tokenCartID, secret, ok := parseGuestToken(token)
+	if !ok || tokenCartID != cartID {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashGuestSecret(secret)), []byte(hash)) == 1
+}
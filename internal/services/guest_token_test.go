@@ -0,0 +1,191 @@
+package services_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/services"
+)
+
+func TestGuestToken(t *testing.T) {
+	f := newCartFixture(t, time.Hour, services.QuantityLimits{})
+	guest, token, err := f.service.CreateGuestCart(t.Context(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, otherToken, err := f.service.CreateGuestCart(t.Context(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cartID := strconv.Itoa(guest.CartID)
+	_, secret, _ := strings.Cut(token, ".")
+
+	if !strings.HasPrefix(token, cartID+".") || secret == "" {
+		t.Fatalf("token = %q, want %s.<secret>", token, cartID)
+	}
+	if _, err := f.service.GetCart(t.Context(), guest.CartID, token); err != nil {
+		t.Fatalf("GetCart with its token: %v", err)
+	}
+
+	// Without the right token a guest cart is reported missing, as if it did not exist
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "no token", token: ""},
+		{name: "no separator", token: cartID + secret},
+		{name: "no secret", token: cartID + "."},
+		{name: "no cart ID", token: "." + secret},
+		{name: "cart ID not a number", token: "x." + secret},
+		{name: "cart ID not positive", token: "0." + secret},
+		{name: "wrong secret", token: cartID + "." + strings.Repeat("A", len(secret))},
+		{name: "secret prefix", token: cartID + "." + secret[:len(secret)-1]},
+		{name: "secret with extra characters", token: token + "A"},
+		{name: "another cart's secret", token: cartID + "." + strings.SplitN(otherToken, ".", 2)[1]},
+		{name: "another cart's token", token: otherToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := f.service.GetCart(t.Context(), guest.CartID, tt.token); err != services.ErrCartNotFound {
+				t.Fatalf("GetCart error = %v, want ErrCartNotFound", err)
+			}
+			if err := f.service.AddItemToCart(t.Context(), guest.CartID, tt.token, 1, 1); err != services.ErrCartNotFound {
+				t.Fatalf("AddItemToCart error = %v, want ErrCartNotFound", err)
+			}
+		})
+	}
+
+	// The other cart still opens with its own token
+	if _, err := f.service.GetCart(t.Context(), other.CartID, otherToken); err != nil {
+		t.Fatalf("GetCart of the other cart: %v", err)
+	}
+}
+
+func TestGuestTokenExpiredCart(t *testing.T) {
+	f := newCartFixture(t, time.Hour, services.QuantityLimits{})
+	guest, token, err := f.service.CreateGuestCart(t.Context(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expire(t, f, guest.CartID)
+
+	if _, err := f.service.GetCart(t.Context(), guest.CartID, token); err != services.ErrCartExpired {
+		t.Fatalf("GetCart error = %v, want ErrCartExpired", err)
+	}
+	// The token is checked first, so an expired cart is not revealed without it
+	if _, err := f.service.GetCart(t.Context(), guest.CartID, ""); err != services.ErrCartNotFound {
+		t.Fatalf("GetCart without the token error = %v, want ErrCartNotFound", err)
+	}
+}
+
+// expire makes a cart's expiry an hour ago
+func expire(t *testing.T, f *cartFixture, cartID int) {
+	t.Helper()
+
+	cart, err := f.carts.GetByID(t.Context(), cartID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cart.ExpiresAt = time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	if err := f.carts.Put(t.Context(), cart); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMergeGuestCart(t *testing.T) {
+	f := newCartFixture(t, time.Hour, services.QuantityLimits{})
+	f.addProducts(t, 1, 2)
+	f.addPromotion(t, "SAVE10", 0, 0)
+	cartID := f.newCart(t, 1, models.CartItem{ProductID: 1, Quantity: 1})
+
+	guest, token, err := f.service.CreateGuestCart(t.Context(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	guestItems := []models.CartItem{{ProductID: 1, Quantity: 2}, {ProductID: 2, Quantity: 3}}
+	if err := f.service.AddItemsToCart(t.Context(), guest.CartID, token, guestItems); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.service.ApplyCoupon(t.Context(), guest.CartID, token, "SAVE10"); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := f.service.MergeGuestCart(t.Context(), cartID, token)
+	if err != nil {
+		t.Fatalf("MergeGuestCart: %v", err)
+	}
+	quantities := map[int]int{}
+	for _, item := range merged.Items {
+		quantities[item.ProductID] = item.Quantity
+	}
+	if len(quantities) != 2 || quantities[1] != 3 || quantities[2] != 3 {
+		t.Fatalf("merged items = %+v, want product 1 × 3 and product 2 × 3", merged.Items)
+	}
+	// The guest cart's coupons are not carried over
+	if len(merged.Coupons) != 0 {
+		t.Fatalf("merged coupons = %v, want none", merged.Coupons)
+	}
+
+	// The guest cart is gone, so merging it again finds nothing
+	if _, err := f.service.GetCart(t.Context(), guest.CartID, token); err != services.ErrCartNotFound {
+		t.Fatalf("GetCart of the merged guest cart error = %v, want ErrCartNotFound", err)
+	}
+	if _, err := f.service.MergeGuestCart(t.Context(), cartID, token); err != services.ErrGuestCartNotFound {
+		t.Fatalf("second MergeGuestCart error = %v, want ErrGuestCartNotFound", err)
+	}
+}
+
+func TestMergeGuestCartRejected(t *testing.T) {
+	f := newCartFixture(t, time.Hour, services.QuantityLimits{})
+	f.addProducts(t, 1)
+	cartID := f.newCart(t, 1)
+	otherCartID := f.newCart(t, 2, models.CartItem{ProductID: 1, Quantity: 1})
+	guest, token, err := f.service.CreateGuestCart(t.Context(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredGuest, expiredToken, err := f.service.CreateGuestCart(t.Context(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expire(t, f, expiredGuest.CartID)
+	_, secret, _ := strings.Cut(token, ".")
+
+	tests := []struct {
+		name   string
+		cartID int
+		token  string
+		want   error
+	}{
+		{name: "malformed token", cartID: cartID, token: "not-a-token", want: services.ErrInvalidGuestToken},
+		{name: "empty token", cartID: cartID, token: "", want: services.ErrInvalidGuestToken},
+		{name: "wrong secret", cartID: cartID, token: strconv.Itoa(guest.CartID) + "." + strings.Repeat("A", len(secret)), want: services.ErrGuestCartNotFound},
+		{name: "missing guest cart", cartID: cartID, token: "999." + secret, want: services.ErrGuestCartNotFound},
+		{name: "expired guest cart", cartID: cartID, token: expiredToken, want: services.ErrCartExpired},
+		// Without its own token a guest cart cannot be found to merge into
+		{name: "into a guest cart", cartID: guest.CartID, token: token, want: services.ErrCartNotFound},
+		{name: "customer's own cart", cartID: cartID, token: strconv.Itoa(cartID) + "." + secret, want: services.ErrGuestCartNotFound},
+		{name: "another customer's cart", cartID: cartID, token: strconv.Itoa(otherCartID) + "." + secret, want: services.ErrGuestCartNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := f.service.MergeGuestCart(t.Context(), tt.cartID, tt.token); err != tt.want {
+				t.Fatalf("MergeGuestCart error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// A rejected merge leaves the carts in place
+	if _, err := f.service.GetCart(t.Context(), guest.CartID, token); err != nil {
+		t.Fatalf("guest cart after rejected merges: %v", err)
+	}
+	other, err := f.service.GetCart(t.Context(), otherCartID, "")
+	if err != nil || len(other.Items) != 1 {
+		t.Fatalf("other customer's cart after rejected merges = %+v, %v; want it unchanged", other, err)
+	}
+}
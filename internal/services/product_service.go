@@ -1,15 +1,24 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"maps"
+	"slices"
 
 	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/pricing"
 	"github.com/LuoZihYuan/Go-Cart/internal/repository"
 )
 
 var (
-	ErrProductNotFound = errors.New("product not found")
-	ErrInvalidProduct  = errors.New("invalid product data")
+	ErrProductNotFound   = NotFound("Product not found", "No product exists with the specified ID")
+	ErrInvalidProduct    = InvalidInput("Invalid input data", "invalid product data")
+	ErrProductIDMismatch = ValidationFailed(models.FieldError{
+		Field:   "product_id",
+		Rule:    "path_match",
+		Message: "product_id must match the product ID in the path",
+	})
 )
 
 type ProductService struct {
@@ -21,31 +30,31 @@ func NewProductService(repo repository.ProductRepository) *ProductService {
 }
 
 // GetProduct retrieves a product by ID
-func (s *ProductService) GetProduct(productID int) (*models.Product, error) {
+func (s *ProductService) GetProduct(ctx context.Context, productID int) (*models.Product, error) {
 	if productID < 1 {
 		return nil, ErrInvalidProduct
 	}
 
-	product, err := s.repo.GetByID(productID)
-	if err == repository.ErrProductNotFound {
+	product, err := s.repo.GetByID(ctx, productID)
+	if errors.Is(err, repository.ErrProductNotFound) {
 		return nil, ErrProductNotFound
 	}
 	if err != nil {
-		return nil, err
+		return nil, repositoryError(err)
 	}
 
 	return product, nil
 }
 
 // AddProductDetails adds or updates product details
-func (s *ProductService) AddProductDetails(productID int, product *models.Product) error {
+func (s *ProductService) AddProductDetails(ctx context.Context, productID int, product *models.Product) error {
 	if productID < 1 {
 		return ErrInvalidProduct
 	}
 
 	// Ensure the productID in the path matches the one in the body
 	if product.ProductID != productID {
-		return errors.New("product ID mismatch")
+		return ErrProductIDMismatch
 	}
 
 	// Validate product data
@@ -53,29 +62,54 @@ func (s *ProductService) AddProductDetails(productID int, product *models.Produc
 		return err
 	}
 
-	return s.repo.Upsert(product)
+	if err := s.repo.Upsert(ctx, product); err != nil {
+		return repositoryError(err)
+	}
+
+	return nil
 }
 
-// validateProduct performs business validation on product data
+// validateProduct performs business validation on product data,
+// reporting every invalid field rather than stopping at the first
 func (s *ProductService) validateProduct(product *models.Product) error {
+	var fields []models.FieldError
+
 	if product.ProductID < 1 {
-		return errors.New("product_id must be positive")
+		fields = append(fields, minField("product_id", 1))
 	}
 	if product.SKU == "" {
-		return errors.New("sku is required")
+		fields = append(fields, requiredField("sku"))
 	}
 	if product.Manufacturer == "" {
-		return errors.New("manufacturer is required")
+		fields = append(fields, requiredField("manufacturer"))
 	}
 	if product.CategoryID < 1 {
-		return errors.New("category_id must be positive")
+		fields = append(fields, minField("category_id", 1))
 	}
 	if product.Weight < 0 {
-		return errors.New("weight cannot be negative")
+		fields = append(fields, minField("weight", 0))
+	}
+	if product.Price < 0 {
+		fields = append(fields, minField("price", 0))
 	}
 	if product.SomeOtherID < 1 {
-		return errors.New("some_other_id must be positive")
+		fields = append(fields, minField("some_other_id", 1))
+	}
+	if product.PurchaseLimit < 0 {
+		fields = append(fields, minField("purchase_limit", 0))
+	}
+	for _, currency := range slices.Sorted(maps.Keys(product.Prices)) {
+		field := "prices[" + currency + "]"
+		if _, ok := pricing.MinorUnits(currency); !ok {
+			fields = append(fields, currencyField(field, currency))
+		}
+		if product.Prices[currency] < 0 {
+			fields = append(fields, minField(field, 0))
+		}
 	}
 
+	if len(fields) > 0 {
+		return ValidationFailed(fields...)
+	}
 	return nil
 }
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/repository"
+)
+
+var (
+	ErrPromotionNotFound     = NotFound("Promotion not found", "No promotion exists with the specified code")
+	ErrPromotionCodeMismatch = ValidationFailed(models.FieldError{
+		Field:   "code",
+		Rule:    "path_match",
+		Message: "code must match the coupon code in the path",
+	})
+)
+
+// couponCodePattern is what a coupon code looks like once normalized
+var couponCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{1,32}$`)
+
+// normalizeCouponCode makes coupon codes case-insensitive
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+type PromotionService struct {
+	repo repository.PromotionRepository
+}
+
+func NewPromotionService(repo repository.PromotionRepository) *PromotionService {
+	return &PromotionService{repo: repo}
+}
+
+// GetPromotion retrieves a promotion by its coupon code, in any case
+func (s *PromotionService) GetPromotion(ctx context.Context, code string) (*models.Promotion, error) {
+	code = normalizeCouponCode(code)
+	if !couponCodePattern.MatchString(code) {
+		return nil, ErrPromotionNotFound
+	}
+
+	promotion, err := s.repo.GetByCode(ctx, code)
+	if errors.Is(err, repository.ErrPromotionNotFound) {
+		return nil, ErrPromotionNotFound
+	}
+	if err != nil {
+		return nil, repositoryError(err)
+	}
+
+	return promotion, nil
+}
+
+// UpsertPromotion creates or updates the promotion with the coupon code, keeping how
+// often it has been redeemed. The body's code may be omitted; if given, it must match.
+func (s *PromotionService) UpsertPromotion(ctx context.Context, code string, promotion *models.Promotion) error {
+	code = normalizeCouponCode(code)
+	if promotion.Code == "" {
+		promotion.Code = code
+	}
+	promotion.Code = normalizeCouponCode(promotion.Code)
+	if promotion.Code != code {
+		return ErrPromotionCodeMismatch
+	}
+
+	// Validate promotion data
+	if err := s.validatePromotion(promotion); err != nil {
+		return err
+	}
+
+	// Times are kept to the second, as carts' are
+	promotion.StartsAt = promotion.StartsAt.UTC().Truncate(time.Second)
+	promotion.EndsAt = promotion.EndsAt.UTC().Truncate(time.Second)
+
+	if err := s.repo.Upsert(ctx, promotion); err != nil {
+		return repositoryError(err)
+	}
+
+	return nil
+}
+
+// validatePromotion performs business validation on promotion data,
+// reporting every invalid field rather than stopping at the first
+func (s *PromotionService) validatePromotion(promotion *models.Promotion) error {
+	var fields []models.FieldError
+
+	if !couponCodePattern.MatchString(promotion.Code) {
+		fields = append(fields, models.FieldError{
+			Field:   "code",
+			Rule:    "pattern",
+			Param:   couponCodePattern.String(),
+			Message: "code must be 1 to 32 letters, digits, hyphens or underscores",
+		})
+	}
+
+	switch promotion.Type {
+	case models.PromotionPercentOff:
+		if promotion.PercentOff < 1 {
+			fields = append(fields, minField("percent_off", 1))
+		}
+		if promotion.PercentOff > 100 {
+			fields = append(fields, maxField("percent_off", 100))
+		}
+	case models.PromotionFixedOff:
+		if promotion.AmountOff < 1 {
+			fields = append(fields, minField("amount_off", 1))
+		}
+	case models.PromotionBuyXGetY:
+		if promotion.BuyQuantity < 1 {
+			fields = append(fields, minField("buy_quantity", 1))
+		}
+		if promotion.GetQuantity < 1 {
+			fields = append(fields, minField("get_quantity", 1))
+		}
+	default:
+		fields = append(fields, models.FieldError{
+			Field:   "type",
+			Rule:    "oneof",
+			Param:   "percent_off fixed_off buy_x_get_y",
+			Message: "type must be one of percent_off, fixed_off or buy_x_get_y",
+		})
+	}
+
+	if promotion.CategoryID < 0 {
+		fields = append(fields, minField("category_id", 0))
+	}
+	if promotion.ProductID < 0 {
+		fields = append(fields, minField("product_id", 0))
+	}
+	if promotion.MaxUses < 0 {
+		fields = append(fields, minField("max_uses", 0))
+	}
+	if promotion.MaxUsesPerCustomer < 0 {
+		fields = append(fields, minField("max_uses_per_customer", 0))
+	}
+	if !promotion.StartsAt.IsZero() && !promotion.EndsAt.IsZero() && !promotion.EndsAt.After(promotion.StartsAt) {
+		fields = append(fields, models.FieldError{
+			Field:   "ends_at",
+			Rule:    "gtfield",
+			Param:   "starts_at",
+			Message: "ends_at must be after starts_at",
+		})
+	}
+
+	if len(fields) > 0 {
+		return ValidationFailed(fields...)
+	}
+	return nil
+}
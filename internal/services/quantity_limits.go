@@ -0,0 +1,98 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+)
+
+// maxLineQuantity is the most of one product any cart may hold, whatever the limits,
+// so that quantities fit the databases' 32-bit columns
+const maxLineQuantity = math.MaxInt32
+
+// QuantityLimits bounds what a cart may hold; a zero field sets no limit of its own.
+// A product's PurchaseLimit further bounds how much of it one cart may hold.
+type QuantityLimits struct {
+	// MaxLineQuantity is the most of any one product a cart may hold
+	MaxLineQuantity int
+	// MaxLines is the most different products a cart may hold
+	MaxLines int
+}
+
+// lineMax returns the most of product a cart may hold; product is nil if it is unknown
+func (l QuantityLimits) lineMax(product *models.Product) int {
+	limit := maxLineQuantity
+	if l.MaxLineQuantity > 0 {
+		limit = min(limit, l.MaxLineQuantity)
+	}
+	if product != nil && product.PurchaseLimit > 0 {
+		limit = min(limit, product.PurchaseLimit)
+	}
+	return limit
+}
+
+// check reports every one of items that would take cart past the limits once added,
+// naming each with field; products holds the items' products by ID
+func (l QuantityLimits) check(cart *models.Cart, items []models.CartItem, products map[int]*models.Product, field func(i int, name string) string) error {
+	quantities := make(map[int]int, len(cart.Items)+len(items))
+	for _, item := range cart.Items {
+		quantities[item.ProductID] = item.Quantity
+	}
+
+	var fields []models.FieldError
+	linesExceeded := false
+	for i, item := range items {
+		held, inCart := quantities[item.ProductID]
+		if !inCart && l.MaxLines > 0 && len(quantities) >= l.MaxLines {
+			// Report the first product that does not fit; later ones would only repeat it
+			if !linesExceeded {
+				fields = append(fields, models.FieldError{
+					Field:   field(i, "product_id"),
+					Rule:    "max",
+					Param:   strconv.Itoa(l.MaxLines),
+					Message: fmt.Sprintf("a cart may hold at most %d different products", l.MaxLines),
+				})
+				linesExceeded = true
+			}
+			continue
+		}
+
+		// Compared as a difference so that a huge quantity cannot overflow the sum
+		limit := l.lineMax(products[item.ProductID])
+		if item.Quantity > limit-held {
+			name := field(i, "quantity")
+			fields = append(fields, models.FieldError{
+				Field:   name,
+				Rule:    "max",
+				Param:   strconv.Itoa(limit),
+				Message: fmt.Sprintf("%s would bring product %d to more than %d in the cart", name, item.ProductID, limit),
+			})
+			continue
+		}
+		quantities[item.ProductID] = held + item.Quantity
+	}
+
+	if len(fields) > 0 {
+		return quantityLimitExceeded(fields)
+	}
+	return nil
+}
+
+// quantityLimitExceeded rejects items that would take a cart past its limits,
+// each field's Param giving the allowed maximum
+func quantityLimitExceeded(fields []models.FieldError) *Error {
+	return &Error{
+		Kind:    KindInvalidState,
+		Code:    "QUANTITY_LIMIT_EXCEEDED",
+		Message: "Quantity limit exceeded",
+		Details: "Adding the items would take the cart past its limits",
+		Fields:  fields,
+	}
+}
+
+// requestField names a field of a request holding a single item
+func requestField(_ int, name string) string {
+	return name
+}
@@ -0,0 +1,153 @@
+package services_test
+
+import (
+	"testing"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+	"github.com/LuoZihYuan/Go-Cart/internal/services"
+)
+
+func TestQuantityLimits(t *testing.T) {
+	limits := services.QuantityLimits{MaxLineQuantity: 10, MaxLines: 2}
+
+	tests := []struct {
+		name string
+		held []models.CartItem
+		// add is added with AddItemToCart if it holds one item, else with AddItemsToCart
+		add []models.CartItem
+		// field and param name the rejected item and its limit; empty if the add succeeds
+		field, param string
+	}{
+		{name: "line at limit", add: []models.CartItem{{ProductID: 1, Quantity: 10}}},
+		{name: "line past limit", add: []models.CartItem{{ProductID: 1, Quantity: 11}}, field: "quantity", param: "10"},
+		{
+			name: "line at limit with held quantity",
+			held: []models.CartItem{{ProductID: 1, Quantity: 4}},
+			add:  []models.CartItem{{ProductID: 1, Quantity: 6}},
+		},
+		{
+			name:  "line past limit with held quantity",
+			held:  []models.CartItem{{ProductID: 1, Quantity: 4}},
+			add:   []models.CartItem{{ProductID: 1, Quantity: 7}},
+			field: "quantity", param: "10",
+		},
+		{name: "product at purchase limit", add: []models.CartItem{{ProductID: 3, Quantity: 3}}},
+		{name: "product past purchase limit", add: []models.CartItem{{ProductID: 3, Quantity: 4}}, field: "quantity", param: "3"},
+		{
+			name: "cart at line limit",
+			held: []models.CartItem{{ProductID: 1, Quantity: 1}},
+			add:  []models.CartItem{{ProductID: 2, Quantity: 1}},
+		},
+		{
+			name:  "cart past line limit",
+			held:  []models.CartItem{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 1}},
+			add:   []models.CartItem{{ProductID: 3, Quantity: 1}},
+			field: "product_id", param: "2",
+		},
+		{
+			name: "full cart adds to a held line",
+			held: []models.CartItem{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 1}},
+			add:  []models.CartItem{{ProductID: 2, Quantity: 9}},
+		},
+		{
+			name: "batch merged at line limit",
+			add:  []models.CartItem{{ProductID: 1, Quantity: 5}, {ProductID: 1, Quantity: 5}},
+		},
+		{
+			name:  "batch merged past line limit",
+			add:   []models.CartItem{{ProductID: 1, Quantity: 5}, {ProductID: 1, Quantity: 6}},
+			field: "items[1].quantity", param: "10",
+		},
+		{
+			name: "batch merged with held at line limit",
+			held: []models.CartItem{{ProductID: 1, Quantity: 4}},
+			add:  []models.CartItem{{ProductID: 1, Quantity: 3}, {ProductID: 1, Quantity: 3}},
+		},
+		{
+			name:  "batch merged with held past line limit",
+			held:  []models.CartItem{{ProductID: 1, Quantity: 4}},
+			add:   []models.CartItem{{ProductID: 1, Quantity: 3}, {ProductID: 1, Quantity: 4}},
+			field: "items[1].quantity", param: "10",
+		},
+		{
+			name: "batch merged at purchase limit",
+			add:  []models.CartItem{{ProductID: 3, Quantity: 2}, {ProductID: 3, Quantity: 1}},
+		},
+		{
+			name:  "batch merged past purchase limit",
+			add:   []models.CartItem{{ProductID: 3, Quantity: 2}, {ProductID: 3, Quantity: 2}},
+			field: "items[1].quantity", param: "3",
+		},
+		{
+			name: "batch at line limit",
+			add:  []models.CartItem{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 1}, {ProductID: 1, Quantity: 1}},
+		},
+		{
+			name:  "batch past line limit",
+			add:   []models.CartItem{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 1}, {ProductID: 3, Quantity: 1}},
+			field: "items[2].product_id", param: "2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newCartFixture(t, 0, limits)
+			f.addProducts(t, 1, 2)
+			f.addProduct(t, models.Product{ProductID: 3, SKU: "SKU", Manufacturer: "Acme", CategoryID: 1, Price: 1000, SomeOtherID: 1, PurchaseLimit: 3})
+			cartID := f.newCart(t, 1, tt.held...)
+
+			var err error
+			if len(tt.add) == 1 {
+				err = f.service.AddItemToCart(t.Context(), cartID, "", tt.add[0].ProductID, tt.add[0].Quantity)
+			} else {
+				err = f.service.AddItemsToCart(t.Context(), cartID, "", tt.add)
+			}
+
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("add: %v", err)
+				}
+				return
+			}
+			serviceErr := serviceError(t, err)
+			if serviceErr.Code != "QUANTITY_LIMIT_EXCEEDED" || len(serviceErr.Fields) != 1 {
+				t.Fatalf("error = %+v, want QUANTITY_LIMIT_EXCEEDED for one field", serviceErr)
+			}
+			if field := serviceErr.Fields[0]; field.Field != tt.field || field.Param != tt.param {
+				t.Fatalf("field = %+v, want %s limited to %s", field, tt.field, tt.param)
+			}
+
+			// A rejected add leaves the cart as it was
+			cart, err := f.service.GetCart(t.Context(), cartID, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cart.Items) != len(tt.held) {
+				t.Fatalf("cart items = %+v, want %+v", cart.Items, tt.held)
+			}
+		})
+	}
+}
+
+func TestQuantityLimitsOnMerge(t *testing.T) {
+	f := newCartFixture(t, 0, services.QuantityLimits{MaxLineQuantity: 10, MaxLines: 2})
+	f.addProducts(t, 1, 2)
+	cartID := f.newCart(t, 1, models.CartItem{ProductID: 1, Quantity: 6})
+
+	guest, token, err := f.service.CreateGuestCart(t.Context(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.service.AddItemsToCart(t.Context(), guest.CartID, token, []models.CartItem{{ProductID: 1, Quantity: 5}}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = f.service.MergeGuestCart(t.Context(), cartID, token)
+	serviceErr := serviceError(t, err)
+	if serviceErr.Code != "QUANTITY_LIMIT_EXCEEDED" || len(serviceErr.Fields) != 1 || serviceErr.Fields[0].Field != "items[0].quantity" {
+		t.Fatalf("merge error = %+v, want items[0].quantity past its limit", serviceErr)
+	}
+	if _, err := f.service.GetCart(t.Context(), guest.CartID, token); err != nil {
+		t.Fatalf("guest cart after a rejected merge: %v", err)
+	}
+}
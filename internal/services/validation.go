@@ -0,0 +1,72 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/LuoZihYuan/Go-Cart/internal/models"
+)
+
+// requiredField reports a missing field using the same rule names as the request validator
+func requiredField(field string) models.FieldError {
+	return models.FieldError{
+		Field:   field,
+		Rule:    "required",
+		Message: field + " is required",
+	}
+}
+
+// minField reports a numeric field below its minimum
+func minField(field string, min int) models.FieldError {
+	return models.FieldError{
+		Field:   field,
+		Rule:    "min",
+		Param:   strconv.Itoa(min),
+		Message: fmt.Sprintf("%s must be at least %d", field, min),
+	}
+}
+
+// maxField reports a numeric field above its maximum
+func maxField(field string, max int) models.FieldError {
+	return models.FieldError{
+		Field:   field,
+		Rule:    "max",
+		Param:   strconv.Itoa(max),
+		Message: fmt.Sprintf("%s must be at most %d", field, max),
+	}
+}
+
+// missingProductField reports a field naming a product that does not exist
+func missingProductField(field string, productID int) models.FieldError {
+	return models.FieldError{
+		Field:   field,
+		Rule:    "exists",
+		Param:   strconv.Itoa(productID),
+		Message: fmt.Sprintf("%s: product %d does not exist", field, productID),
+	}
+}
+
+// unpricedProductField reports a field naming a product with no price in currency
+func unpricedProductField(field string, productID int, currency string) models.FieldError {
+	return models.FieldError{
+		Field:   field,
+		Rule:    "priced_in",
+		Param:   currency,
+		Message: fmt.Sprintf("%s: product %d has no price in %s", field, productID, currency),
+	}
+}
+
+// currencyField reports a field that is not a supported ISO 4217 currency code
+func currencyField(field, currency string) models.FieldError {
+	return models.FieldError{
+		Field:   field,
+		Rule:    "currency",
+		Param:   currency,
+		Message: fmt.Sprintf("%s: %s is not a supported currency", field, currency),
+	}
+}
+
+// itemField names a field of the i-th item in a batch, as the request validator does
+func itemField(i int, field string) string {
+	return fmt.Sprintf("items[%d].%s", i, field)
+}
@@ -29,9 +29,46 @@ resource "aws_dynamodb_table" "carts" {
     type = "N"
   }
 
+  attribute {
+    name = "customer_id"
+    type = "N"
+  }
+
+  # Look up carts by customer (must match repository.CartsTable)
+  global_secondary_index {
+    name            = "customer_id-index"
+    hash_key        = "customer_id"
+    projection_type = "ALL"
+  }
+
+  # Delete expired carts (must match repository.CartsTable)
+  ttl {
+    attribute_name = "expires_at"
+    enabled        = true
+  }
+
   tags = {
     Name        = "Carts"
     Environment = var.environment
     Project     = var.project_name
   }
 }
+
+# Promotions table, also holding each customer's redemptions of a code
+# (must match repository.PromotionsTable)
+resource "aws_dynamodb_table" "promotions" {
+  name         = "Promotions"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "code"
+
+  attribute {
+    name = "code"
+    type = "S"
+  }
+
+  tags = {
+    Name        = "Promotions"
+    Environment = var.environment
+    Project     = var.project_name
+  }
+}
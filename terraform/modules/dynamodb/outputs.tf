@@ -17,3 +17,13 @@ output "carts_table_arn" {
   description = "ARN of the Carts DynamoDB table"
   value       = aws_dynamodb_table.carts.arn
 }
+
+output "promotions_table_name" {
+  description = "Name of the Promotions DynamoDB table"
+  value       = "Promotions"
+}
+
+output "promotions_table_arn" {
+  description = "ARN of the Promotions DynamoDB table"
+  value       = aws_dynamodb_table.promotions.arn
+}
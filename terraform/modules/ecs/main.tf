@@ -34,6 +34,10 @@ locals {
     {
       name  = "DB_TYPE"
       value = var.db_type
+    },
+    {
+      name  = "LOG_LEVEL"
+      value = "info"
     }
   ]
 
@@ -66,24 +66,3 @@ resource "aws_db_instance" "main" {
     Project     = var.project_name
   }
 }
-
-# Execute schema initialization SQL
-resource "null_resource" "schema_init" {
-  # Trigger re-run if init.sql changes
-  triggers = {
-    init_sql_hash = filemd5("${path.root}/../../scripts/mysql/init.sql")
-  }
-
-  provisioner "local-exec" {
-    command = <<-EOT
-      mysql -h ${aws_db_instance.main.address} \
-            -P ${aws_db_instance.main.port} \
-            -u ${var.db_username} \
-            -p${var.db_password} \
-            ${aws_db_instance.main.db_name} \
-            < ${path.root}/../../scripts/mysql/init.sql
-    EOT
-  }
-
-  depends_on = [aws_db_instance.main]
-}
@@ -26,7 +26,8 @@ output "rds_address" {
 output "dynamodb_tables" {
   description = "DynamoDB table names (only populated when db_type=dynamo)"
   value = var.db_type == "dynamo" ? {
-    products = module.dynamodb[0].products_table_name
-    carts    = module.dynamodb[0].carts_table_name
+    products   = module.dynamodb[0].products_table_name
+    carts      = module.dynamodb[0].carts_table_name
+    promotions = module.dynamodb[0].promotions_table_name
   } : null
 }